@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"gopkg.in/ini.v1"
 )
@@ -25,8 +27,55 @@ type ConfigList struct {
 	DirectMessagesTableName   string
 	DMLinesTableName          string
 	//jwt-token
-	TokenHourLifeSpan string
-	SecretKey         string
+	TokenHourLifeSpan        int
+	RefreshTokenHourLifeSpan int
+	SecretKey                string
+	SecretKeyRetiredAt       time.Time
+	SigningKeyId             string
+	KeyGracePeriodHour       int
+	JwtKeys                  []JwtKeyConfig
+
+	// mail関連
+	MailDriver   string
+	SmtpHost     string
+	SmtpPort     string
+	SmtpUsername string
+	SmtpPassword string
+	MailFrom     string
+
+	// OIDC関連
+	OidcProviders []OidcProviderConfig
+
+	// rate limit関連
+	// RateLimitsはbucket名と"<回数>/<期間>"の組
+	RateLimitDriver string
+	RateLimits      map[string]string
+
+	// workspace関連
+	// 削除されたworkspaceは猶予期間の間は復元でき、その後定期的に完全に削除する
+	WorkspaceDeletionGraceHour   int
+	WorkspacePurgeIntervalMinute int
+}
+
+// [oidc.<name>] sectionごとに1つのidentity providerを設定する
+type OidcProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+	WorkspaceId  int
+	Scopes       []string
+}
+
+// [jwt-key.<kid>] sectionごとに1つの署名鍵を設定する
+// retiredAtを過ぎた鍵は猶予期間の間だけ検証に使う
+type JwtKeyConfig struct {
+	Id             string
+	Algorithm      string
+	Secret         string
+	PrivateKeyFile string
+	RetiredAt      time.Time
 }
 
 var Config ConfigList
@@ -65,7 +114,74 @@ func init() {
 		DirectMessagesTableName:   cfg.Section("db").Key("directMessagesTableName").String(),
 		DMLinesTableName:          cfg.Section("db").Key("dmLinesTableName").String(),
 
-		TokenHourLifeSpan: cfg.Section("jwt-token").Key("tokenHourLifespan").String(),
-		SecretKey:         cfg.Section("jwt-token").Key("secretKey").String(),
+		TokenHourLifeSpan:        cfg.Section("jwt-token").Key("tokenHourLifespan").MustInt(2),
+		RefreshTokenHourLifeSpan: cfg.Section("jwt-token").Key("refreshTokenHourLifespan").MustInt(24 * 30),
+		SecretKey:                cfg.Section("jwt-token").Key("secretKey").String(),
+		SecretKeyRetiredAt:       cfg.Section("jwt-token").Key("secretKeyRetiredAt").MustTime(time.Time{}),
+		SigningKeyId:             cfg.Section("jwt-token").Key("signingKeyId").String(),
+		KeyGracePeriodHour:       cfg.Section("jwt-token").Key("keyGracePeriodHours").MustInt(cfg.Section("jwt-token").Key("tokenHourLifespan").MustInt(2)),
+		JwtKeys:                  loadJwtKeys(cfg),
+
+		MailDriver:   cfg.Section("mail").Key("driver").MustString("log"),
+		SmtpHost:     cfg.Section("mail").Key("smtpHost").String(),
+		SmtpPort:     cfg.Section("mail").Key("smtpPort").MustString("587"),
+		SmtpUsername: cfg.Section("mail").Key("smtpUsername").String(),
+		SmtpPassword: cfg.Section("mail").Key("smtpPassword").String(),
+		MailFrom:     cfg.Section("mail").Key("from").String(),
+
+		OidcProviders: loadOidcProviders(cfg),
+
+		RateLimitDriver: cfg.Section("ratelimit").Key("driver").MustString("memory"),
+		RateLimits:      loadRateLimits(cfg),
+
+		WorkspaceDeletionGraceHour:   cfg.Section("workspace").Key("deletionGracePeriodHours").MustInt(24 * 7),
+		WorkspacePurgeIntervalMinute: cfg.Section("workspace").Key("purgeIntervalMinutes").MustInt(60),
+	}
+}
+
+func loadOidcProviders(cfg *ini.File) []OidcProviderConfig {
+	providers := make([]OidcProviderConfig, 0)
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name(), "oidc.") {
+			continue
+		}
+		providers = append(providers, OidcProviderConfig{
+			Name:         strings.TrimPrefix(section.Name(), "oidc."),
+			Issuer:       section.Key("issuer").String(),
+			ClientId:     section.Key("clientId").String(),
+			ClientSecret: section.Key("clientSecret").String(),
+			RedirectUrl:  section.Key("redirectUrl").String(),
+			WorkspaceId:  section.Key("workspaceId").MustInt(0),
+			Scopes:       strings.Fields(section.Key("scopes").MustString("openid email profile")),
+		})
+	}
+	return providers
+}
+
+func loadJwtKeys(cfg *ini.File) []JwtKeyConfig {
+	keys := make([]JwtKeyConfig, 0)
+	for _, section := range cfg.Sections() {
+		if !strings.HasPrefix(section.Name(), "jwt-key.") {
+			continue
+		}
+		keys = append(keys, JwtKeyConfig{
+			Id:             strings.TrimPrefix(section.Name(), "jwt-key."),
+			Algorithm:      section.Key("algorithm").MustString("HS256"),
+			Secret:         section.Key("secret").String(),
+			PrivateKeyFile: section.Key("privateKeyFile").String(),
+			RetiredAt:      section.Key("retiredAt").MustTime(time.Time{}),
+		})
+	}
+	return keys
+}
+
+func loadRateLimits(cfg *ini.File) map[string]string {
+	limits := make(map[string]string)
+	for _, key := range cfg.Section("ratelimit").Keys() {
+		if key.Name() == "driver" {
+			continue
+		}
+		limits[key.Name()] = key.String()
 	}
+	return limits
 }
@@ -0,0 +1,125 @@
+package controllerUtils
+
+import (
+	"fmt"
+
+	"backend/models"
+)
+
+func policyCapability(ws models.WorkspaceSetting, capability string) (string, bool) {
+	// workspaceの設定で許可するroleを変更できるcapability
+	switch capability {
+	case models.CapChannelsCreatePublic:
+		return ws.PublicChannelCreatePolicy, true
+	case models.CapChannelsCreatePrivate:
+		return ws.PrivateChannelCreatePolicy, true
+	case models.CapMembersInvite:
+		return ws.InvitePolicy, true
+	}
+	return "", false
+}
+
+func Authorize(workspaceId int, userId uint32, capability string) (bool, error) {
+	// userがworkspaceでcapabilityを持っているかを判定する
+	// userがworkspaceに参加していない場合はerrorを返す
+	wau, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(workspaceId, userId)
+	if err != nil {
+		return false, err
+	}
+	return AuthorizeRole(workspaceId, wau.RoleId, capability)
+}
+
+func AuthorizeRole(workspaceId, roleId int, capability string) (bool, error) {
+	r, err := models.GetRoleById(roleId)
+	if err != nil {
+		return false, err
+	}
+	// 他のworkspaceのcustom roleでは何もできない
+	if !r.IsAvailableInWorkspace(workspaceId) {
+		return false, nil
+	}
+
+	// 設定で変更できるcapabilityは、built-in roleでは設定のみで判定し
+	// custom roleでは設定で許可されているか個別に与えられている場合に許可する
+	ws, err := models.GetWorkspaceSettingByWorkspaceId(workspaceId)
+	if err != nil {
+		return false, err
+	}
+	if policy, ok := policyCapability(ws, capability); ok {
+		if models.IsRoleAllowedByPolicy(policy, r.Rank()) {
+			return true, nil
+		}
+		if r.IsBuiltIn() {
+			return false, nil
+		}
+	}
+
+	capabilities, err := models.GetCapabilitiesByRole(r)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range capabilities {
+		if c == capability {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func CanGrantRole(workspaceId, requestRoleId, newRoleId int) (bool, error) {
+	// 自分より強い権限は付与できない(adminはownerを追加, 招待できない)
+	// custom roleは自分が持っていないcapabilityを含む場合は付与できない
+	// primary ownerは譲渡でのみ変更する
+	// 存在しないroleや他のworkspaceのcustom roleの場合はerrorを返す
+	newRole, err := getAssignableRole(workspaceId, newRoleId)
+	if err != nil {
+		return false, err
+	}
+	requestRole, err := models.GetRoleById(requestRoleId)
+	if err != nil {
+		return false, err
+	}
+	if newRole.Rank() < requestRole.Rank() {
+		return false, nil
+	}
+	if newRole.IsBuiltIn() {
+		return true, nil
+	}
+	capabilities, err := models.GetCapabilitiesByRole(newRole)
+	if err != nil {
+		return false, err
+	}
+	return HasAllCapabilitiesRole(workspaceId, requestRoleId, capabilities)
+}
+
+func CanManageMember(requestRoleId, targetRoleId int) (bool, error) {
+	// 自分より弱い権限のuserのみ変更, 無効化, 削除できる(adminはownerを, ownerは他のownerを操作できない)
+	requestRole, err := models.GetRoleById(requestRoleId)
+	if err != nil {
+		return false, err
+	}
+	targetRole, err := models.GetRoleById(targetRoleId)
+	if err != nil {
+		return false, err
+	}
+	return targetRole.Rank() > requestRole.Rank(), nil
+}
+
+func CanChangeRole(workspaceId, requestRoleId, targetRoleId, newRoleId int) (bool, error) {
+	// 自分より弱い権限のuserのroleのみ変更できる
+	if b, err := CanManageMember(requestRoleId, targetRoleId); !b || err != nil {
+		return false, err
+	}
+	return CanGrantRole(workspaceId, requestRoleId, newRoleId)
+}
+
+func getAssignableRole(workspaceId, roleId int) (models.Role, error) {
+	r, err := models.GetRoleById(roleId)
+	if err != nil {
+		return r, fmt.Errorf("invalid role_id")
+	}
+	if r.ID == models.RolePrimaryOwner || !r.IsAvailableInWorkspace(workspaceId) {
+		return r, fmt.Errorf("invalid role_id")
+	}
+	return r, nil
+}
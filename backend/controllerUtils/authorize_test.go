@@ -0,0 +1,192 @@
+package controllerUtils
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/models"
+)
+
+func TestAuthorize(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	w := models.NewWorkspace(0, randomstring.EnglishFrequencyString(30), rand.Uint32())
+	assert.Empty(t, w.Create())
+
+	// custom roleにはworkspaceの名前の変更とpublic channelの削除のみ与える
+	custom := models.NewCustomRole(w.ID, randomstring.EnglishFrequencyString(30))
+	assert.Empty(t, custom.Create())
+	assert.Empty(t, models.SetRoleCapabilities(custom.ID, []string{models.CapWorkspaceRename, models.CapChannelsRemoveMember}))
+
+	// roleごとにuserをworkspaceに追加する
+	roleIds := []int{models.RolePrimaryOwner, models.RoleOwner, models.RoleAdmin, models.RoleFullMember, models.RoleMultiChannelGuest, models.RoleSingleChannelGuest, custom.ID}
+	userIds := make(map[int]uint32)
+	for _, roleId := range roleIds {
+		userIds[roleId] = rand.Uint32()
+		assert.Empty(t, models.NewWorkspaceAndUsers(w.ID, userIds[roleId], roleId).Create())
+	}
+
+	// 初期設定でのrole × capabilityの判定
+	testCases := []struct {
+		capability string
+		allowed    map[int]bool
+	}{
+		{models.CapWorkspaceRename, map[int]bool{1: true, 2: true, 3: true, 4: false, 5: false, 6: false, custom.ID: true}},
+		{models.CapWorkspaceSettings, map[int]bool{1: true, 2: true, 3: true, 4: false, 5: false, 6: false, custom.ID: false}},
+		{models.CapMembersAdd, map[int]bool{1: true, 2: true, 3: true, 4: false, 5: false, 6: false, custom.ID: false}},
+		{models.CapMembersInvite, map[int]bool{1: true, 2: true, 3: true, 4: false, 5: false, 6: false, custom.ID: false}},
+		{models.CapMembersRemove, map[int]bool{1: true, 2: true, 3: true, 4: false, 5: false, 6: false, custom.ID: false}},
+		{models.CapMembersDeactivate, map[int]bool{1: true, 2: true, 3: true, 4: false, 5: false, 6: false, custom.ID: false}},
+		{models.CapMembersChangeRole, map[int]bool{1: true, 2: true, 3: true, 4: false, 5: false, 6: false, custom.ID: false}},
+		{models.CapRolesManage, map[int]bool{1: true, 2: true, 3: false, 4: false, 5: false, 6: false, custom.ID: false}},
+		{models.CapBotTokensManage, map[int]bool{1: true, 2: true, 3: true, 4: false, 5: false, 6: false, custom.ID: false}},
+		{models.CapChannelsCreatePublic, map[int]bool{1: true, 2: true, 3: true, 4: true, 5: false, 6: false, custom.ID: true}},
+		{models.CapChannelsCreatePrivate, map[int]bool{1: true, 2: true, 3: true, 4: true, 5: false, 6: false, custom.ID: true}},
+		{models.CapChannelsRemoveMember, map[int]bool{1: true, 2: true, 3: true, 4: false, 5: false, 6: false, custom.ID: true}},
+		{models.CapUserGroupsManage, map[int]bool{1: true, 2: true, 3: true, 4: false, 5: false, 6: false, custom.ID: false}},
+		{models.CapChannelsDelete, map[int]bool{1: true, 2: true, 3: true, 4: false, 5: false, 6: false, custom.ID: false}},
+	}
+	for _, tc := range testCases {
+		for _, roleId := range roleIds {
+			b, err := Authorize(w.ID, userIds[roleId], tc.capability)
+			assert.Empty(t, err)
+			assert.Equal(t, tc.allowed[roleId], b, "role %d, capability %s", roleId, tc.capability)
+		}
+	}
+
+	// workspaceの設定で変更できるcapabilityの判定
+	ws := models.NewWorkspaceSetting(w.ID)
+	ws.PublicChannelCreatePolicy = models.PolicyAdmins
+	ws.PrivateChannelCreatePolicy = models.PolicyOwners
+	ws.InvitePolicy = models.PolicyEveryone
+	assert.Empty(t, ws.Save().Error)
+	assert.Empty(t, models.SetRoleCapabilities(custom.ID, []string{models.CapChannelsCreatePrivate}))
+
+	policyTestCases := []struct {
+		capability string
+		allowed    map[int]bool
+	}{
+		{models.CapChannelsCreatePublic, map[int]bool{1: true, 2: true, 3: true, 4: false, 5: false, 6: false, custom.ID: false}},
+		{models.CapChannelsCreatePrivate, map[int]bool{1: true, 2: true, 3: false, 4: false, 5: false, 6: false, custom.ID: true}},
+		{models.CapMembersInvite, map[int]bool{1: true, 2: true, 3: true, 4: true, 5: false, 6: false, custom.ID: true}},
+	}
+	for _, tc := range policyTestCases {
+		for _, roleId := range roleIds {
+			b, err := Authorize(w.ID, userIds[roleId], tc.capability)
+			assert.Empty(t, err)
+			assert.Equal(t, tc.allowed[roleId], b, "role %d, capability %s", roleId, tc.capability)
+		}
+	}
+
+	// workspaceに参加していないuserはerror
+	b, err := Authorize(w.ID, rand.Uint32(), models.CapWorkspaceRename)
+	assert.Equal(t, false, b)
+	assert.NotEmpty(t, err)
+
+	// 他のworkspaceのcustom roleでは何もできない
+	other := models.NewWorkspace(0, randomstring.EnglishFrequencyString(30), rand.Uint32())
+	assert.Empty(t, other.Create())
+	b, err = AuthorizeRole(other.ID, custom.ID, models.CapChannelsCreatePrivate)
+	assert.Equal(t, false, b)
+	assert.Empty(t, err)
+}
+
+func TestCanGrantRole(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	w := models.NewWorkspace(0, randomstring.EnglishFrequencyString(30), rand.Uint32())
+	assert.Empty(t, w.Create())
+	custom := models.NewCustomRole(w.ID, randomstring.EnglishFrequencyString(30))
+	assert.Empty(t, custom.Create())
+
+	testCases := []struct {
+		requestRoleId int
+		newRoleId     int
+		want          bool
+	}{
+		{models.RolePrimaryOwner, models.RoleOwner, true},
+		{models.RoleOwner, models.RoleOwner, true},
+		{models.RoleOwner, models.RoleAdmin, true},
+		{models.RoleAdmin, models.RoleOwner, false},
+		{models.RoleAdmin, custom.ID, true},
+		{models.RoleFullMember, models.RoleAdmin, false},
+		{models.RoleFullMember, custom.ID, true},
+		{models.RoleFullMember, models.RoleSingleChannelGuest, true},
+		{models.RoleMultiChannelGuest, models.RoleFullMember, false},
+		{models.RoleMultiChannelGuest, custom.ID, false},
+	}
+	for _, tc := range testCases {
+		b, err := CanGrantRole(w.ID, tc.requestRoleId, tc.newRoleId)
+		assert.Empty(t, err)
+		assert.Equal(t, tc.want, b, "request role %d, new role %d", tc.requestRoleId, tc.newRoleId)
+	}
+
+	// primary ownerや存在しないrole, 他のworkspaceのroleは付与できない
+	other := models.NewWorkspace(0, randomstring.EnglishFrequencyString(30), rand.Uint32())
+	assert.Empty(t, other.Create())
+	for _, roleId := range []int{models.RolePrimaryOwner, 0, -1, rand.Int()} {
+		_, err := CanGrantRole(w.ID, models.RolePrimaryOwner, roleId)
+		assert.NotEmpty(t, err)
+	}
+	_, err := CanGrantRole(other.ID, models.RolePrimaryOwner, custom.ID)
+	assert.NotEmpty(t, err)
+
+	// 自分が持っていないcapabilityを含むcustom roleは付与できない
+	manager := models.NewCustomRole(w.ID, randomstring.EnglishFrequencyString(30))
+	assert.Empty(t, manager.Create())
+	assert.Empty(t, models.SetRoleCapabilities(manager.ID, []string{models.CapRolesManage}))
+	for _, tc := range []struct {
+		requestRoleId int
+		want          bool
+	}{
+		{models.RoleOwner, true},
+		{models.RoleAdmin, false},
+		{custom.ID, false},
+	} {
+		b, err := CanGrantRole(w.ID, tc.requestRoleId, manager.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, tc.want, b, "request role %d", tc.requestRoleId)
+	}
+
+	// ownerは他のownerのroleを変更できない
+	b, err := CanChangeRole(w.ID, models.RoleOwner, models.RoleOwner, models.RoleAdmin)
+	assert.Equal(t, false, b)
+	assert.Empty(t, err)
+	b, err = CanChangeRole(w.ID, models.RoleOwner, models.RoleAdmin, custom.ID)
+	assert.Equal(t, true, b)
+	assert.Empty(t, err)
+}
+
+func TestCanManageMember(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	testCases := []struct {
+		requestRoleId int
+		targetRoleId  int
+		want          bool
+	}{
+		{models.RolePrimaryOwner, models.RoleOwner, true},
+		{models.RoleOwner, models.RoleOwner, false},
+		{models.RoleOwner, models.RoleAdmin, true},
+		{models.RoleAdmin, models.RoleOwner, false},
+		{models.RoleAdmin, models.RoleAdmin, false},
+		{models.RoleAdmin, models.RoleFullMember, true},
+		{models.RoleAdmin, models.RoleSingleChannelGuest, true},
+		{models.RoleFullMember, models.RoleMultiChannelGuest, true},
+		{models.RoleMultiChannelGuest, models.RoleFullMember, false},
+	}
+	for _, tc := range testCases {
+		b, err := CanManageMember(tc.requestRoleId, tc.targetRoleId)
+		assert.Empty(t, err)
+		assert.Equal(t, tc.want, b, "request role %d, target role %d", tc.requestRoleId, tc.targetRoleId)
+	}
+}
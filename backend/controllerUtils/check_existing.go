@@ -6,6 +6,7 @@ import (
 	"gorm.io/gorm"
 
 	"backend/models"
+	"backend/utils"
 )
 
 func IsExistChannelAndUserInSameWorkspace(channelId int, userId uint32) (bool, error) {
@@ -28,21 +29,44 @@ func IsExistCAUByChannelIdAndUserId(channelId int, userId uint32) (bool, error)
 	return cau.ChannelId == channelId && cau.UserId == userId, nil
 }
 
-func IsExistUserSameUsernameAndPassword(userName, password string) bool {
-	u, err := models.GetUserByNameAndPassword(userName, password)
+func IsExistUserSameUsername(userName string) bool {
+	b, err := models.IsExistUserByName(userName)
 	if err != nil {
 		fmt.Println(err)
 		return false
 	}
-	return u.Name == userName && u.PassWord == password
+	return b
+}
+
+func IsExistUserSameEmail(email string) bool {
+	b, err := models.IsExistUserByEmail(email)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	return b
+}
+
+func IsCorrectPassword(userId uint32, password string) (bool, error) {
+	u, err := models.GetUserById(userId)
+	if err != nil {
+		return false, err
+	}
+	// passwordを設定していないSSOのuserは先にpasswordを設定する必要がある
+	b, err := u.HasPassword()
+	if err != nil || !b {
+		return false, err
+	}
+	return utils.CheckPassword(u.PassWord, password), nil
 }
 
 func IsExistWorkspaceById(id int) bool {
+	// 削除されたworkspaceは存在しないものとして扱う
 	w, err := models.GetWorkspaceById(id)
 	if err != nil {
 		fmt.Println(err)
 	}
-	return w.ID == id
+	return w.ID == id && !w.IsDeleted()
 }
 
 func IsExistWAUByWorkspaceIdAndUserId(workspaceId int, userId uint32) bool {
@@ -54,6 +78,19 @@ func IsExistWAUByWorkspaceIdAndUserId(workspaceId int, userId uint32) bool {
 	return wau.WorkspaceId == workspaceId && wau.UserId == userId
 }
 
+func IsPrimaryOwnerOfAnyWorkspace(userId uint32) (bool, error) {
+	waus, err := models.GetWAUsByUserId(userId)
+	if err != nil {
+		return false, err
+	}
+	for _, wau := range waus {
+		if wau.RoleId == models.RolePrimaryOwner {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func IsExistDMById(dmId uint) (bool, error) {
 	_, err := models.GetDMById(dmId)
 	if err != nil {
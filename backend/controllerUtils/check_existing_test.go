@@ -2,6 +2,7 @@ package controllerUtils
 
 import (
 	"math/rand"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,7 +28,7 @@ func TestIsExistCAUByChannelIdAndUserId(t *testing.T) {
 	assert.NotEmpty(t, err)
 }
 
-func TestIsExistUserSameUsernameAndPassword(t *testing.T) {
+func TestIsExistUserSameUsername(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode.")
 	}
@@ -37,17 +38,31 @@ func TestIsExistUserSameUsernameAndPassword(t *testing.T) {
 	}
 
 	for _, name := range names {
-		u := models.NewUser(rand.Uint32(), name, "pass")
+		u := models.NewUser(0, name, "pass")
 		assert.Empty(t, u.Create())
 	}
 
 	for _, name := range names {
-		assert.Equal(t, true, IsExistUserSameUsernameAndPassword(name, "pass"))
-		assert.Equal(t, false, IsExistUserSameUsernameAndPassword(name, "wrong pass"))
-		assert.Equal(t, false, IsExistUserSameUsernameAndPassword(name+" wrong name", "pass"))
+		assert.Equal(t, true, IsExistUserSameUsername(name))
+		assert.Equal(t, true, IsExistUserSameUsername(strings.ToUpper(name)))
+		assert.Equal(t, false, IsExistUserSameUsername(name+" wrong name"))
 	}
 }
 
+func TestIsExistUserSameEmail(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	email := randomstring.EnglishFrequencyString(30) + "@example.com"
+	u := models.NewUser(0, randomstring.EnglishFrequencyString(30), "pass")
+	u.Email = email
+	assert.Empty(t, u.Create())
+
+	assert.Equal(t, true, IsExistUserSameEmail(email))
+	assert.Equal(t, true, IsExistUserSameEmail(strings.ToUpper(email)))
+	assert.Equal(t, false, IsExistUserSameEmail("wrong"+email))
+}
+
 func TestIsExistWorkspaceAndUser(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode.")
@@ -0,0 +1,13 @@
+package controllerUtils
+
+import "backend/models"
+
+func DeleteUserAccount(u *models.User) error {
+	// userに関するデータを削除し、users tableの個人情報を消す
+	// messageはworkspaceの設定に応じて匿名化するか残す
+	workspaceIds, err := models.GetWorkspaceIdsByDeletedUserMessagePolicy(models.DeletedUserMessagesAnonymize)
+	if err != nil {
+		return err
+	}
+	return u.DeleteAccount(workspaceIds)
+}
@@ -0,0 +1,50 @@
+package controllerUtils
+
+import (
+	"fmt"
+	"time"
+
+	"backend/config"
+	"backend/models"
+)
+
+func WorkspacePurgeAt(w models.Workspace) time.Time {
+	// 削除されたworkspaceはこの時刻以降に完全に削除される
+	if w.DeletedAt == nil {
+		return time.Time{}
+	}
+	return w.DeletedAt.Add(time.Hour * time.Duration(config.Config.WorkspaceDeletionGraceHour))
+}
+
+func PurgeDeletedWorkspaces(now time.Time) (int, error) {
+	// 猶予期間を過ぎたworkspaceを完全に削除し、削除した数を返す
+	before := now.Add(-time.Hour * time.Duration(config.Config.WorkspaceDeletionGraceHour))
+	ids, err := models.GetWorkspaceIdsDeletedBefore(before)
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if err := models.PurgeWorkspace(id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+func RunPurger(interval time.Duration) {
+	// 定期的に猶予期間を過ぎたworkspaceと保存期間を過ぎたmessageを削除し、有効期限を過ぎたguestを無効化する
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := PurgeDeletedWorkspaces(time.Now()); err != nil {
+			fmt.Println(err)
+		}
+		if _, err := PurgeExpiredMessages(time.Now()); err != nil {
+			fmt.Println(err)
+		}
+		if _, err := DeactivateExpiredGuests(time.Now()); err != nil {
+			fmt.Println(err)
+		}
+		<-ticker.C
+	}
+}
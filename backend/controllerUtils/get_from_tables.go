@@ -1,11 +1,40 @@
 package controllerUtils
 
-import "backend/models"
+import (
+	"time"
+
+	"backend/models"
+)
 
 type UserInfoInWorkspace struct {
-	ID     uint32 `json:"id"`
-	Name   string `json:"name"`
-	RoleId int    `json:"role_id"`
+	ID          uint32 `json:"id"`
+	Name        string `json:"name"`
+	RoleId      int    `json:"role_id"`
+	DisplayName string `json:"display_name"`
+	RealName    string `json:"real_name"`
+	Title       string `json:"title"`
+	Phone       string `json:"phone"`
+	Timezone    string `json:"timezone"`
+	AvatarUrl   string `json:"avatar_url"`
+	Pronouns    string `json:"pronouns"`
+	PresenceInfo
+}
+
+type PresenceInfo struct {
+	Presence        string     `json:"presence"`
+	StatusEmoji     string     `json:"status_emoji"`
+	StatusText      string     `json:"status_text"`
+	StatusExpiresAt *time.Time `json:"status_expires_at"`
+}
+
+type UserPresence struct {
+	UserId uint32 `json:"user_id"`
+	PresenceInfo
+}
+
+type SessionInfo struct {
+	models.Session
+	IsCurrent bool `json:"is_current"`
 }
 
 func GetWorkspacesByUserId(userId uint32) ([]models.Workspace, error) {
@@ -79,14 +108,46 @@ func GetUserInWorkspace(workspaceId int) ([]UserInfoInWorkspace, error) {
 		return res, err
 	}
 
+	// profileとworkspaceごとの表示名を取得する
+	userIds := make([]uint32, 0, len(waus))
+	for _, wau := range waus {
+		userIds = append(userIds, wau.UserId)
+	}
+	profiles, err := models.GetProfilesByUserIds(userIds)
+	if err != nil {
+		return res, err
+	}
+	wps, err := models.GetWorkspaceProfilesByWorkspaceId(workspaceId)
+	if err != nil {
+		return res, err
+	}
+	presences, err := models.GetPresencesByUserIds(userIds)
+	if err != nil {
+		return res, err
+	}
+
 	// 2つのデータからuser_idが等しいものの組み合わせを見つけて、res配列に追加する
 	for _, wau := range waus {
 		for _, user := range users {
 			if user.ID == wau.UserId {
+				p := profiles[user.ID]
+				// workspaceで表示名が設定されている場合はそちらを優先する
+				displayName := p.DisplayName
+				if wp, ok := wps[user.ID]; ok {
+					displayName = wp.DisplayName
+				}
 				res = append(res, UserInfoInWorkspace{
-					ID:     user.ID,
-					Name:   user.Name,
-					RoleId: wau.RoleId,
+					ID:           user.ID,
+					Name:         user.Name,
+					RoleId:       wau.RoleId,
+					DisplayName:  displayName,
+					RealName:     p.RealName,
+					Title:        p.Title,
+					Phone:        p.Phone,
+					Timezone:     p.Timezone,
+					AvatarUrl:    p.AvatarUrl,
+					Pronouns:     p.Pronouns,
+					PresenceInfo: newPresenceInfo(presences[user.ID]),
 				})
 				break
 			}
@@ -94,3 +155,76 @@ func GetUserInWorkspace(workspaceId int) ([]UserInfoInWorkspace, error) {
 	}
 	return res, nil
 }
+
+func GetSessionsByUserId(userId uint32, currentSessionId string) ([]SessionInfo, error) {
+	// userの有効なsessionを配列にして返す
+	// requestに使われているsessionにはis_currentをつける
+
+	res := make([]SessionInfo, 0)
+	sessions, err := models.GetActiveSessionsByUserId(userId)
+	if err != nil {
+		return res, err
+	}
+	for _, s := range sessions {
+		res = append(res, SessionInfo{
+			Session:   s,
+			IsCurrent: s.ID == currentSessionId,
+		})
+	}
+	return res, nil
+}
+
+func newPresenceInfo(p models.Presence) PresenceInfo {
+	return PresenceInfo{
+		Presence:        p.State(),
+		StatusEmoji:     p.StatusEmoji,
+		StatusText:      p.StatusText,
+		StatusExpiresAt: p.StatusExpiresAt,
+	}
+}
+
+func GetVisibleUserIds(userId uint32) (map[uint32]bool, error) {
+	// userと同じworkspaceに所属しているuserのidを返す(自分自身も含む)
+	// guestとして所属しているworkspaceでは同じchannelのuserのみ
+	res := map[uint32]bool{userId: true}
+	waus, err := models.GetWAUsByUserId(userId)
+	if err != nil {
+		return res, err
+	}
+	for _, wau := range waus {
+		if models.IsGuestRole(wau.RoleId) {
+			ids, err := GetChannelMemberIds(wau.WorkspaceId, userId)
+			if err != nil {
+				return res, err
+			}
+			for id := range ids {
+				res[id] = true
+			}
+			continue
+		}
+		members, err := models.GetWAUsByWorkspaceId(wau.WorkspaceId)
+		if err != nil {
+			return res, err
+		}
+		for _, m := range members {
+			res[m.UserId] = true
+		}
+	}
+	return res, nil
+}
+
+func GetPresencesByUserIds(userIds []uint32) ([]UserPresence, error) {
+	// 指定された順番でpresenceを配列にして返す
+	res := make([]UserPresence, 0, len(userIds))
+	presences, err := models.GetPresencesByUserIds(userIds)
+	if err != nil {
+		return res, err
+	}
+	for _, id := range userIds {
+		res = append(res, UserPresence{
+			UserId:       id,
+			PresenceInfo: newPresenceInfo(presences[id]),
+		})
+	}
+	return res, nil
+}
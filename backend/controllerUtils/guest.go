@@ -0,0 +1,75 @@
+package controllerUtils
+
+import (
+	"time"
+
+	"backend/models"
+)
+
+func IsGuestInWorkspace(workspaceId int, userId uint32) (bool, error) {
+	roleId, err := models.GetRoleIdByWorkspaceIdAndUserId(workspaceId, userId)
+	if err != nil {
+		return false, err
+	}
+	return models.IsGuestRole(roleId), nil
+}
+
+func GetChannelMemberIds(workspaceId int, userId uint32) (map[uint32]bool, error) {
+	// workspaceでuserと同じchannelに参加しているuserのidを返す(自分自身も含む)
+	res := map[uint32]bool{userId: true}
+	chs, err := GetChannelsByUserIdAndWorkspaceId(userId, workspaceId)
+	if err != nil {
+		return res, err
+	}
+	for _, ch := range chs {
+		caus, err := models.GetCAUsByChannelId(ch.ID)
+		if err != nil {
+			return res, err
+		}
+		for _, cau := range caus {
+			res[cau.UserId] = true
+		}
+	}
+	return res, nil
+}
+
+func CanSendDMAsGuest(workspaceId int, userId, receiveUserId uint32) (bool, error) {
+	// guestは同じchannelに参加しているuserにのみDMを送れる
+	b, err := IsGuestInWorkspace(workspaceId, userId)
+	if err != nil || !b {
+		return !b, err
+	}
+	ids, err := GetChannelMemberIds(workspaceId, userId)
+	if err != nil {
+		return false, err
+	}
+	return ids[receiveUserId], nil
+}
+
+func CanJoinChannelAsGuest(wau models.WorkspaceAndUsers) (bool, error) {
+	// single-channel guestは1つのchannelにのみ参加できる
+	if wau.RoleId != models.RoleSingleChannelGuest {
+		return true, nil
+	}
+	chs, err := GetChannelsByUserIdAndWorkspaceId(wau.UserId, wau.WorkspaceId)
+	if err != nil {
+		return false, err
+	}
+	return len(chs) == 0, nil
+}
+
+func ExceedsGuestChannelLimit(wau models.WorkspaceAndUsers, newRoleId int) (bool, error) {
+	// single-channel guestに変更するuserが既に複数のchannelに参加していないか確認する
+	if newRoleId != models.RoleSingleChannelGuest {
+		return false, nil
+	}
+	chs, err := GetChannelsByUserIdAndWorkspaceId(wau.UserId, wau.WorkspaceId)
+	if err != nil {
+		return false, err
+	}
+	return len(chs) > 1, nil
+}
+
+func DeactivateExpiredGuests(now time.Time) (int64, error) {
+	return models.DeactivateExpiredMembers(now)
+}
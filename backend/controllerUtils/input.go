@@ -2,24 +2,119 @@ package controllerUtils
 
 import (
 	"fmt"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"backend/models"
+)
+
+const maxUsernameLength = 80
+
+// profileの各項目の最大文字数
+const (
+	maxDisplayNameLength = 80
+	maxRealNameLength    = 80
+	maxTitleLength       = 100
+	maxPhoneLength       = 30
+	maxPronounsLength    = 30
+	maxAvatarUrlLength   = 2048
+)
+
+// custom statusの最大文字数
+const (
+	maxStatusEmojiLength = 100
+	maxStatusTextLength  = 100
+)
+
+// API tokenの名前の最大文字数
+const maxApiTokenNameLength = 80
+
+// presenceを一度に取得できるuserの最大数
+const maxPresenceQueryUsers = 100
+
+// 招待の有効期限(時間)の初期値と最大値
+const (
+	defaultInviteExpiresInHours = 7 * 24
+	maxInviteExpiresInHours     = 30 * 24
+)
+
+// custom roleの名前の最大文字数
+const maxRoleNameLength = 80
+
+// user groupの各項目の最大値
+const (
+	maxUserGroupHandleLength      = 21
+	maxUserGroupNameLength        = 80
+	maxUserGroupDescriptionLength = 250
+)
+
+// workspaceの設定の各項目の最大値
+const (
+	maxWorkspaceDescriptionLength = 250
+	maxWorkspaceIconUrlLength     = 2048
+	maxMessageRetentionDays       = 10 * 365
+	maxAllowedEmailDomains        = 20
 )
 
 type SignUpAndLoginInput struct {
-	Name     string `json:"name"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	DeviceName string `json:"device_name"`
+}
+
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type PasswordInput struct {
 	Password string `json:"password"`
 }
 
+type EnableTwoFactorInput struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+type LoginTwoFactorInput struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type SetPasswordInput struct {
+	NewPassword string `json:"new_password"`
+}
+
+type ForgotPasswordInput struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordInput struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
 type CreateWorkspaceInput struct {
 	Name          string `json:"name"`
 	RequestUserId uint32 `json:"user_id"`
 }
 
 type AddUserInWorkspaceInput struct {
-	WorkspaceId int    `json:"workspace_id"`
-	UserId      uint32 `json:"user_id"`
-	RoleId      int    `json:"role_id"`
+	WorkspaceId int        `json:"workspace_id"`
+	UserId      uint32     `json:"user_id"`
+	RoleId      int        `json:"role_id"`
+	ExpiresAt   *time.Time `json:"expires_at"`
 }
 
 type RenameWorkspaceNameInput struct {
@@ -59,14 +154,344 @@ type EditDMInput struct {
 	Text string `json:"text"`
 }
 
-func InputSignUpAndLogin(c *gin.Context) (SignUpAndLoginInput, error) {
+// 指定されなかった項目は変更しない
+type UpdateProfileInput struct {
+	DisplayName *string `json:"display_name"`
+	RealName    *string `json:"real_name"`
+	Title       *string `json:"title"`
+	Phone       *string `json:"phone"`
+	Timezone    *string `json:"timezone"`
+	AvatarUrl   *string `json:"avatar_url"`
+	Pronouns    *string `json:"pronouns"`
+}
+
+type UpdateWorkspaceProfileInput struct {
+	DisplayName string `json:"display_name"`
+}
+
+type WorkspaceMemberInput struct {
+	WorkspaceId int    `json:"workspace_id"`
+	UserId      uint32 `json:"user_id"`
+}
+
+type SetGuestExpirationInput struct {
+	WorkspaceId int        `json:"workspace_id"`
+	UserId      uint32     `json:"user_id"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+type ChangeRoleInWorkspaceInput struct {
+	WorkspaceId int    `json:"workspace_id"`
+	UserId      uint32 `json:"user_id"`
+	RoleId      int    `json:"role_id"`
+}
+
+type TransferPrimaryOwnershipInput struct {
+	WorkspaceId int    `json:"workspace_id"`
+	UserId      uint32 `json:"user_id"`
+	Password    string `json:"password"`
+}
+
+type UpdateWorkspaceSettingInput struct {
+	DeletedUserMessagePolicy   *string   `json:"deleted_user_message_policy"`
+	RequireVerifiedEmail       *bool     `json:"require_verified_email"`
+	PublicChannelCreatePolicy  *string   `json:"public_channel_create_policy"`
+	PrivateChannelCreatePolicy *string   `json:"private_channel_create_policy"`
+	InvitePolicy               *string   `json:"invite_policy"`
+	AllowChannelMention        *bool     `json:"allow_channel_mention"`
+	MessageRetentionDays       *int      `json:"message_retention_days"`
+	AllowedEmailDomains        *[]string `json:"allowed_email_domains"`
+	Description                *string   `json:"description"`
+	IconUrl                    *string   `json:"icon_url"`
+}
+
+type CreateApiTokenInput struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type SetPresenceInput struct {
+	Presence string `json:"presence"`
+}
+
+type SetStatusInput struct {
+	Emoji     string     `json:"emoji"`
+	Text      string     `json:"text"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type SSOCallbackInput struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+type VerifyEmailInput struct {
+	Token string `json:"token"`
+}
+
+type CreateWorkspaceInviteInput struct {
+	Email          string `json:"email"`
+	RoleId         int    `json:"role_id"`
+	MaxUses        int    `json:"max_uses"`
+	ExpiresInHours int    `json:"expires_in_hours"`
+}
+
+type AcceptWorkspaceInviteInput struct {
+	Code string `json:"code"`
+}
+
+type CreateRoleInput struct {
+	Name         string   `json:"name"`
+	Capabilities []string `json:"capabilities"`
+}
+
+type UpdateRoleInput struct {
+	Name         *string   `json:"name"`
+	Capabilities *[]string `json:"capabilities"`
+}
+
+type CreateUserGroupInput struct {
+	Handle      string   `json:"handle"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	MemberIds   []uint32 `json:"member_ids"`
+	ChannelIds  []int    `json:"channel_ids"`
+}
+
+type UpdateUserGroupInput struct {
+	Handle      *string   `json:"handle"`
+	Name        *string   `json:"name"`
+	Description *string   `json:"description"`
+	MemberIds   *[]uint32 `json:"member_ids"`
+	ChannelIds  *[]int    `json:"channel_ids"`
+}
+
+func validateUsername(name string) error {
+	if len(name) > maxUsernameLength {
+		return fmt.Errorf("name is too long")
+	}
+	// emailでもloginできるので@はusernameに使えない
+	if strings.Contains(name, "@") {
+		return fmt.Errorf("name must not contain @")
+	}
+	return nil
+}
+
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("invalid email")
+	}
+	return nil
+}
+
+func validateLength(field, value string, max int) error {
+	if len([]rune(value)) > max {
+		return fmt.Errorf("%s is too long", field)
+	}
+	return nil
+}
+
+func validatePhone(phone string) error {
+	for _, r := range phone {
+		if !strings.ContainsRune("0123456789+-() ", r) {
+			return fmt.Errorf("invalid phone")
+		}
+	}
+	return nil
+}
+
+func validateTimezone(timezone string) error {
+	// IANAのtimezone名のみ受け付ける
+	if timezone == "Local" {
+		return fmt.Errorf("invalid timezone")
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("invalid timezone")
+	}
+	return nil
+}
+
+func validateAvatarUrl(avatarUrl string) error {
+	return validateUrl("avatar_url", avatarUrl)
+}
+
+func validateUrl(field, rawUrl string) error {
+	u, err := url.Parse(rawUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid %s", field)
+	}
+	return nil
+}
+
+func validatePolicy(field string, policy *string) error {
+	if policy == nil {
+		return nil
+	}
+	for _, p := range models.Policies {
+		if *policy == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be one of %s", field, strings.Join(models.Policies, ", "))
+}
+
+func normalizeEmailDomains(domains []string) ([]string, error) {
+	// 小文字にして重複を取り除く
+	if len(domains) > maxAllowedEmailDomains {
+		return nil, fmt.Errorf("too many allowed_email_domains")
+	}
+	result := make([]string, 0, len(domains))
+	seen := make(map[string]bool)
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(d), "@")))
+		if d == "" || !strings.Contains(d, ".") || strings.ContainsAny(d, "@ \t/") {
+			return nil, fmt.Errorf("invalid email domain")
+		}
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+func trimProfileField(p **string) {
+	if *p != nil {
+		s := strings.TrimSpace(**p)
+		*p = &s
+	}
+}
+
+func InputAndValidateSignUp(c *gin.Context) (SignUpAndLoginInput, error) {
 	var in SignUpAndLoginInput
 	if err := c.ShouldBindJSON(&in); err != nil {
 		return in, err
 	}
+	in.Name = strings.TrimSpace(in.Name)
+	in.Email = strings.TrimSpace(in.Email)
 	if in.Name == "" || in.Password == "" {
 		return in, fmt.Errorf("name or password not found")
 	}
+	if err := validateUsername(in.Name); err != nil {
+		return in, err
+	}
+	if in.Email != "" {
+		if err := validateEmail(in.Email); err != nil {
+			return in, err
+		}
+	}
+	return in, nil
+}
+
+func InputAndValidateLogin(c *gin.Context) (SignUpAndLoginInput, error) {
+	// nameとemailのどちらかとpasswordが必要
+	var in SignUpAndLoginInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	in.Email = strings.TrimSpace(in.Email)
+	if (in.Name == "" && in.Email == "") || in.Password == "" {
+		return in, fmt.Errorf("name or password not found")
+	}
+	return in, nil
+}
+
+func InputAndValidateRefreshToken(c *gin.Context) (RefreshTokenInput, error) {
+	var in RefreshTokenInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.RefreshToken == "" {
+		return in, fmt.Errorf("refresh_token not found")
+	}
+	return in, nil
+}
+
+func InputAndValidatePassword(c *gin.Context) (PasswordInput, error) {
+	var in PasswordInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.Password == "" {
+		return in, fmt.Errorf("password not found")
+	}
+	return in, nil
+}
+
+func InputAndValidateEnableTwoFactor(c *gin.Context) (EnableTwoFactorInput, error) {
+	var in EnableTwoFactorInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.Password == "" {
+		return in, fmt.Errorf("password not found")
+	}
+	if in.Code == "" {
+		return in, fmt.Errorf("code not found")
+	}
+	return in, nil
+}
+
+func InputAndValidateLoginTwoFactor(c *gin.Context) (LoginTwoFactorInput, error) {
+	// codeとrecovery_codeのどちらか一方が必要
+	var in LoginTwoFactorInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.ChallengeToken == "" {
+		return in, fmt.Errorf("challenge_token not found")
+	}
+	if in.Code == "" && in.RecoveryCode == "" {
+		return in, fmt.Errorf("code or recovery_code not found")
+	}
+	return in, nil
+}
+
+func InputAndValidateChangePassword(c *gin.Context) (ChangePasswordInput, error) {
+	var in ChangePasswordInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.CurrentPassword == "" || in.NewPassword == "" {
+		return in, fmt.Errorf("current_password or new_password not found")
+	}
+	return in, nil
+}
+
+func InputAndValidateSetPassword(c *gin.Context) (SetPasswordInput, error) {
+	var in SetPasswordInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.NewPassword == "" {
+		return in, fmt.Errorf("new_password not found")
+	}
+	return in, nil
+}
+
+func InputAndValidateForgotPassword(c *gin.Context) (ForgotPasswordInput, error) {
+	var in ForgotPasswordInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	in.Email = strings.TrimSpace(in.Email)
+	if in.Email == "" {
+		return in, fmt.Errorf("email not found")
+	}
+	return in, nil
+}
+
+func InputAndValidateResetPassword(c *gin.Context) (ResetPasswordInput, error) {
+	var in ResetPasswordInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.Token == "" || in.NewPassword == "" {
+		return in, fmt.Errorf("token or new_password not found")
+	}
 	return in, nil
 }
 
@@ -100,6 +525,15 @@ func InputAndValidateAddUserInWorkspace(c *gin.Context) (AddUserInWorkspaceInput
 	if in.RoleId == 0 {
 		return in, fmt.Errorf("role_id not found")
 	}
+	// 有効期限はguestにのみ設定できる
+	if in.ExpiresAt != nil {
+		if !models.IsGuestRole(in.RoleId) {
+			return in, fmt.Errorf("expires_at is only for guests")
+		}
+		if !in.ExpiresAt.After(time.Now()) {
+			return in, fmt.Errorf("expires_at must be in the future")
+		}
+	}
 	return in, nil
 }
 
@@ -202,4 +636,520 @@ func InputAndValidateEditDM(c *gin.Context) (EditDMInput, error) {
 		return in, fmt.Errorf("text not found")
 	}
 	return in, nil
-}
\ No newline at end of file
+}
+func InputAndValidateUpdateProfile(c *gin.Context) (UpdateProfileInput, error) {
+	var in UpdateProfileInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	for _, p := range []**string{&in.DisplayName, &in.RealName, &in.Title, &in.Phone, &in.Timezone, &in.AvatarUrl, &in.Pronouns} {
+		trimProfileField(p)
+	}
+
+	// 空文字はその項目の削除として扱う
+	lengths := []struct {
+		field string
+		value *string
+		max   int
+	}{
+		{"display_name", in.DisplayName, maxDisplayNameLength},
+		{"real_name", in.RealName, maxRealNameLength},
+		{"title", in.Title, maxTitleLength},
+		{"phone", in.Phone, maxPhoneLength},
+		{"pronouns", in.Pronouns, maxPronounsLength},
+		{"avatar_url", in.AvatarUrl, maxAvatarUrlLength},
+	}
+	for _, l := range lengths {
+		if l.value == nil {
+			continue
+		}
+		if err := validateLength(l.field, *l.value, l.max); err != nil {
+			return in, err
+		}
+	}
+	if in.Phone != nil && *in.Phone != "" {
+		if err := validatePhone(*in.Phone); err != nil {
+			return in, err
+		}
+	}
+	if in.Timezone != nil && *in.Timezone != "" {
+		if err := validateTimezone(*in.Timezone); err != nil {
+			return in, err
+		}
+	}
+	if in.AvatarUrl != nil && *in.AvatarUrl != "" {
+		if err := validateAvatarUrl(*in.AvatarUrl); err != nil {
+			return in, err
+		}
+	}
+	return in, nil
+}
+
+func InputAndValidateUpdateWorkspaceProfile(c *gin.Context) (UpdateWorkspaceProfileInput, error) {
+	// display_nameが空文字の場合は上書きを解除する
+	var in UpdateWorkspaceProfileInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	in.DisplayName = strings.TrimSpace(in.DisplayName)
+	if err := validateLength("display_name", in.DisplayName, maxDisplayNameLength); err != nil {
+		return in, err
+	}
+	return in, nil
+}
+
+func InputAndValidateSetPresence(c *gin.Context) (SetPresenceInput, error) {
+	// awayは手動でaway, autoは操作状況から判定する
+	var in SetPresenceInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.Presence != "away" && in.Presence != "auto" {
+		return in, fmt.Errorf("presence must be away or auto")
+	}
+	return in, nil
+}
+
+func InputAndValidateSetStatus(c *gin.Context) (SetStatusInput, error) {
+	var in SetStatusInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	in.Emoji = strings.TrimSpace(in.Emoji)
+	in.Text = strings.TrimSpace(in.Text)
+	if in.Emoji == "" && in.Text == "" {
+		return in, fmt.Errorf("emoji or text not found")
+	}
+	if err := validateLength("emoji", in.Emoji, maxStatusEmojiLength); err != nil {
+		return in, err
+	}
+	if err := validateLength("text", in.Text, maxStatusTextLength); err != nil {
+		return in, err
+	}
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+		return in, fmt.Errorf("expires_at must be in the future")
+	}
+	return in, nil
+}
+
+func InputAndValidateQueryPresence(c *gin.Context) ([]uint32, error) {
+	// user_ids=1,2,3 の形式
+	userIds := make([]uint32, 0)
+	q := c.Query("user_ids")
+	if q == "" {
+		return userIds, fmt.Errorf("user_ids not found")
+	}
+	for _, s := range strings.Split(q, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+		if err != nil {
+			return userIds, fmt.Errorf("invalid user_ids")
+		}
+		userIds = append(userIds, uint32(id))
+	}
+	if len(userIds) > maxPresenceQueryUsers {
+		return userIds, fmt.Errorf("too many user_ids")
+	}
+	return userIds, nil
+}
+
+func InputAndValidateWorkspaceMember(c *gin.Context) (WorkspaceMemberInput, error) {
+	var in WorkspaceMemberInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.WorkspaceId == 0 {
+		return in, fmt.Errorf("workspace_id not found")
+	}
+	if in.UserId == 0 {
+		return in, fmt.Errorf("user_id not found")
+	}
+	return in, nil
+}
+
+func InputAndValidateSetGuestExpiration(c *gin.Context) (SetGuestExpirationInput, error) {
+	// expires_atがnullの場合は有効期限をなくす
+	var in SetGuestExpirationInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.WorkspaceId == 0 {
+		return in, fmt.Errorf("workspace_id not found")
+	}
+	if in.UserId == 0 {
+		return in, fmt.Errorf("user_id not found")
+	}
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+		return in, fmt.Errorf("expires_at must be in the future")
+	}
+	return in, nil
+}
+
+func InputAndValidateChangeRoleInWorkspace(c *gin.Context) (ChangeRoleInWorkspaceInput, error) {
+	var in ChangeRoleInWorkspaceInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.WorkspaceId == 0 {
+		return in, fmt.Errorf("workspace_id not found")
+	}
+	if in.UserId == 0 {
+		return in, fmt.Errorf("user_id not found")
+	}
+	if in.RoleId == 0 {
+		return in, fmt.Errorf("role_id not found")
+	}
+	// primary ownerは譲渡でのみ変更する
+	if in.RoleId < models.RoleOwner {
+		return in, fmt.Errorf("invalid role_id")
+	}
+	return in, nil
+}
+
+func InputAndValidateTransferPrimaryOwnership(c *gin.Context) (TransferPrimaryOwnershipInput, error) {
+	var in TransferPrimaryOwnershipInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.WorkspaceId == 0 {
+		return in, fmt.Errorf("workspace_id not found")
+	}
+	if in.UserId == 0 {
+		return in, fmt.Errorf("user_id not found")
+	}
+	if in.Password == "" {
+		return in, fmt.Errorf("password not found")
+	}
+	return in, nil
+}
+
+func InputAndValidateUpdateWorkspaceSetting(c *gin.Context) (UpdateWorkspaceSettingInput, error) {
+	// 指定されなかった項目は変更しない
+	var in UpdateWorkspaceSettingInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if p := in.DeletedUserMessagePolicy; p != nil && *p != models.DeletedUserMessagesRetain && *p != models.DeletedUserMessagesAnonymize {
+		return in, fmt.Errorf("deleted_user_message_policy must be retain or anonymize")
+	}
+	if err := validatePolicy("public_channel_create_policy", in.PublicChannelCreatePolicy); err != nil {
+		return in, err
+	}
+	if err := validatePolicy("private_channel_create_policy", in.PrivateChannelCreatePolicy); err != nil {
+		return in, err
+	}
+	if err := validatePolicy("invite_policy", in.InvitePolicy); err != nil {
+		return in, err
+	}
+	// 0の場合はmessageを削除しない
+	if d := in.MessageRetentionDays; d != nil && (*d < 0 || *d > maxMessageRetentionDays) {
+		return in, fmt.Errorf("message_retention_days must be between 0 and %d", maxMessageRetentionDays)
+	}
+	if in.AllowedEmailDomains != nil {
+		domains, err := normalizeEmailDomains(*in.AllowedEmailDomains)
+		if err != nil {
+			return in, err
+		}
+		in.AllowedEmailDomains = &domains
+	}
+	// 空文字はその項目の削除として扱う
+	trimProfileField(&in.Description)
+	trimProfileField(&in.IconUrl)
+	if in.Description != nil {
+		if err := validateLength("description", *in.Description, maxWorkspaceDescriptionLength); err != nil {
+			return in, err
+		}
+	}
+	if in.IconUrl != nil && *in.IconUrl != "" {
+		if err := validateLength("icon_url", *in.IconUrl, maxWorkspaceIconUrlLength); err != nil {
+			return in, err
+		}
+		if err := validateUrl("icon_url", *in.IconUrl); err != nil {
+			return in, err
+		}
+	}
+	return in, nil
+}
+
+func InputAndValidateCreateApiToken(c *gin.Context) (CreateApiTokenInput, error) {
+	var in CreateApiTokenInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return in, fmt.Errorf("name not found")
+	}
+	if err := validateLength("name", in.Name, maxApiTokenNameLength); err != nil {
+		return in, err
+	}
+	if len(in.Scopes) == 0 {
+		return in, fmt.Errorf("scopes not found")
+	}
+
+	// 重複を除いて定義されているscopeのみ受け付ける
+	scopes := make([]string, 0, len(in.Scopes))
+	seen := make(map[string]bool)
+	for _, scope := range in.Scopes {
+		valid := false
+		for _, s := range models.ApiTokenScopes {
+			if scope == s {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return in, fmt.Errorf("invalid scope: %s", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	in.Scopes = scopes
+	return in, nil
+}
+
+func InputAndValidateSSOCallback(c *gin.Context) (SSOCallbackInput, error) {
+	var in SSOCallbackInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.Code == "" || in.State == "" {
+		return in, fmt.Errorf("code or state not found")
+	}
+	return in, nil
+}
+
+func InputAndValidateVerifyEmail(c *gin.Context) (VerifyEmailInput, error) {
+	var in VerifyEmailInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.Token == "" {
+		return in, fmt.Errorf("token not found")
+	}
+	return in, nil
+}
+
+func InputAndValidateCreateWorkspaceInvite(c *gin.Context) (CreateWorkspaceInviteInput, error) {
+	// role_id, expires_in_hoursが指定されなかった場合は初期値にする
+	// emailを指定した招待はそのemailのuserが1回だけ使える
+	var in CreateWorkspaceInviteInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	in.Email = strings.TrimSpace(in.Email)
+	if in.Email != "" {
+		if err := validateEmail(in.Email); err != nil {
+			return in, err
+		}
+		in.MaxUses = 1
+	}
+	if in.RoleId == 0 {
+		in.RoleId = models.RoleFullMember
+	}
+	if in.RoleId < models.RoleOwner {
+		return in, fmt.Errorf("invalid role_id")
+	}
+	if in.MaxUses < 0 {
+		return in, fmt.Errorf("max_uses must not be negative")
+	}
+	if in.ExpiresInHours == 0 {
+		in.ExpiresInHours = defaultInviteExpiresInHours
+	}
+	if in.ExpiresInHours < 0 || in.ExpiresInHours > maxInviteExpiresInHours {
+		return in, fmt.Errorf("expires_in_hours must be between 1 and %d", maxInviteExpiresInHours)
+	}
+	return in, nil
+}
+
+func InputAndValidateAcceptWorkspaceInvite(c *gin.Context) (AcceptWorkspaceInviteInput, error) {
+	var in AcceptWorkspaceInviteInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	in.Code = strings.TrimSpace(in.Code)
+	if in.Code == "" {
+		return in, fmt.Errorf("code not found")
+	}
+	return in, nil
+}
+
+func normalizeCapabilities(capabilities []string) ([]string, error) {
+	// 重複を除いて定義されているcapabilityのみ受け付ける
+	res := make([]string, 0, len(capabilities))
+	seen := make(map[string]bool)
+	for _, capability := range capabilities {
+		if !models.IsValidCapability(capability) {
+			return nil, fmt.Errorf("invalid capability: %s", capability)
+		}
+		if !seen[capability] {
+			seen[capability] = true
+			res = append(res, capability)
+		}
+	}
+	return res, nil
+}
+
+func InputAndValidateCreateRole(c *gin.Context) (CreateRoleInput, error) {
+	var in CreateRoleInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return in, fmt.Errorf("name not found")
+	}
+	if err := validateLength("name", in.Name, maxRoleNameLength); err != nil {
+		return in, err
+	}
+	capabilities, err := normalizeCapabilities(in.Capabilities)
+	if err != nil {
+		return in, err
+	}
+	in.Capabilities = capabilities
+	return in, nil
+}
+
+func InputAndValidateUpdateRole(c *gin.Context) (UpdateRoleInput, error) {
+	// 指定された項目のみ更新する
+	var in UpdateRoleInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.Name == nil && in.Capabilities == nil {
+		return in, fmt.Errorf("nothing to update")
+	}
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" {
+			return in, fmt.Errorf("name not found")
+		}
+		if err := validateLength("name", name, maxRoleNameLength); err != nil {
+			return in, err
+		}
+		in.Name = &name
+	}
+	if in.Capabilities != nil {
+		capabilities, err := normalizeCapabilities(*in.Capabilities)
+		if err != nil {
+			return in, err
+		}
+		in.Capabilities = &capabilities
+	}
+	return in, nil
+}
+
+func validateUserGroupHandle(handle string) error {
+	// @channelなどのmentionと同じhandleは使えない
+	if err := validateLength("handle", handle, maxUserGroupHandleLength); err != nil {
+		return err
+	}
+	if !userGroupHandlePattern.MatchString(handle) {
+		return fmt.Errorf("invalid handle")
+	}
+	if channelMentionPattern.MatchString("@" + handle) {
+		return fmt.Errorf("handle is reserved")
+	}
+	return nil
+}
+
+func uniqueUserIds(userIds []uint32) []uint32 {
+	res := make([]uint32, 0, len(userIds))
+	seen := make(map[uint32]bool)
+	for _, id := range userIds {
+		if !seen[id] {
+			seen[id] = true
+			res = append(res, id)
+		}
+	}
+	return res
+}
+
+func uniqueChannelIds(channelIds []int) []int {
+	res := make([]int, 0, len(channelIds))
+	seen := make(map[int]bool)
+	for _, id := range channelIds {
+		if !seen[id] {
+			seen[id] = true
+			res = append(res, id)
+		}
+	}
+	return res
+}
+
+func InputAndValidateCreateUserGroup(c *gin.Context) (CreateUserGroupInput, error) {
+	// handleは小文字にそろえる
+	var in CreateUserGroupInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	in.Handle = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(in.Handle), "@"))
+	in.Name = strings.TrimSpace(in.Name)
+	in.Description = strings.TrimSpace(in.Description)
+	if in.Handle == "" {
+		return in, fmt.Errorf("handle not found")
+	}
+	if err := validateUserGroupHandle(in.Handle); err != nil {
+		return in, err
+	}
+	if in.Name == "" {
+		return in, fmt.Errorf("name not found")
+	}
+	if err := validateLength("name", in.Name, maxUserGroupNameLength); err != nil {
+		return in, err
+	}
+	if err := validateLength("description", in.Description, maxUserGroupDescriptionLength); err != nil {
+		return in, err
+	}
+	in.MemberIds = uniqueUserIds(in.MemberIds)
+	in.ChannelIds = uniqueChannelIds(in.ChannelIds)
+	return in, nil
+}
+
+func InputAndValidateUpdateUserGroup(c *gin.Context) (UpdateUserGroupInput, error) {
+	// 指定された項目のみ更新する
+	var in UpdateUserGroupInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		return in, err
+	}
+	if in.Handle == nil && in.Name == nil && in.Description == nil && in.MemberIds == nil && in.ChannelIds == nil {
+		return in, fmt.Errorf("nothing to update")
+	}
+	if in.Handle != nil {
+		handle := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(*in.Handle), "@"))
+		if handle == "" {
+			return in, fmt.Errorf("handle not found")
+		}
+		if err := validateUserGroupHandle(handle); err != nil {
+			return in, err
+		}
+		in.Handle = &handle
+	}
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" {
+			return in, fmt.Errorf("name not found")
+		}
+		if err := validateLength("name", name, maxUserGroupNameLength); err != nil {
+			return in, err
+		}
+		in.Name = &name
+	}
+	if in.Description != nil {
+		description := strings.TrimSpace(*in.Description)
+		if err := validateLength("description", description, maxUserGroupDescriptionLength); err != nil {
+			return in, err
+		}
+		in.Description = &description
+	}
+	if in.MemberIds != nil {
+		memberIds := uniqueUserIds(*in.MemberIds)
+		in.MemberIds = &memberIds
+	}
+	if in.ChannelIds != nil {
+		channelIds := uniqueChannelIds(*in.ChannelIds)
+		in.ChannelIds = &channelIds
+	}
+	return in, nil
+}
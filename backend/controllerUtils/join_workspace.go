@@ -0,0 +1,91 @@
+package controllerUtils
+
+import (
+	"backend/models"
+)
+
+// workspaceに参加したuserが必ず参加するchannel
+const GeneralChannelName = "general"
+
+func IsGeneralChannel(ch models.Channel) bool {
+	return ch.Name == GeneralChannelName
+}
+
+func IsAvailableAsDefaultChannel(ch models.Channel) bool {
+	// private channelとarchiveされたchannelには自動で参加させない
+	return !ch.IsPrivate && !ch.IsArchive
+}
+
+func GetDefaultChannels(workspaceId int) ([]models.Channel, error) {
+	// generalとworkspaceで設定されたchannel
+	result := make([]models.Channel, 0)
+	channelIds, err := models.GetDefaultChannelIdsByWorkspaceId(workspaceId)
+	if err != nil {
+		return result, err
+	}
+	isDefault := make(map[int]bool)
+	for _, id := range channelIds {
+		isDefault[id] = true
+	}
+	channels, err := models.GetChannelsByWorkspaceId(workspaceId)
+	if err != nil {
+		return result, err
+	}
+	for _, ch := range channels {
+		if (IsGeneralChannel(ch) || isDefault[ch.ID]) && IsAvailableAsDefaultChannel(ch) {
+			result = append(result, ch)
+		}
+	}
+	return result, nil
+}
+
+func joinChannel(channelId int, userId uint32) (bool, error) {
+	// 既に参加しているchannelはそのままにする
+	if models.IsExistCAUByChannelIdAndUserId(channelId, userId) {
+		return false, nil
+	}
+	if err := models.NewChannelsAndUses(channelId, userId, false).Create(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func JoinDefaultChannels(wau models.WorkspaceAndUsers) error {
+	// guestは明示的に追加されたchannelにのみ参加する
+	if models.IsGuestRole(wau.RoleId) {
+		return nil
+	}
+	channels, err := GetDefaultChannels(wau.WorkspaceId)
+	if err != nil {
+		return err
+	}
+	for _, ch := range channels {
+		if _, err := joinChannel(ch.ID, wau.UserId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func BackfillDefaultChannel(ch models.Channel) (int, error) {
+	// 既存のworkspaceのmemberをすべてchannelに参加させ、新しく参加した人数を返す
+	waus, err := models.GetWAUsByWorkspaceId(ch.WorkspaceId)
+	if err != nil {
+		return 0, err
+	}
+	cnt := 0
+	for _, wau := range waus {
+		// guestは明示的に追加されたchannelにのみ参加する
+		if models.IsGuestRole(wau.RoleId) {
+			continue
+		}
+		joined, err := joinChannel(ch.ID, wau.UserId)
+		if err != nil {
+			return cnt, err
+		}
+		if joined {
+			cnt++
+		}
+	}
+	return cnt, nil
+}
@@ -0,0 +1,55 @@
+package controllerUtils
+
+import (
+	"backend/models"
+)
+
+func LeaveChannel(ch models.Channel, userId uint32) error {
+	cau, err := models.GetCAUByChannelIdAndUserId(ch.ID, userId)
+	if err != nil {
+		return err
+	}
+	if err := cau.Delete(); err != nil {
+		return err
+	}
+
+	// private channelは管理者しかuserを追加できないので、最後の管理者が抜ける場合は引き継ぐ
+	if !ch.IsPrivate || !cau.IsAdmin {
+		return nil
+	}
+	caus, err := models.GetCAUsByChannelId(ch.ID)
+	if err != nil {
+		return err
+	}
+	// 誰も残らない場合は誰も参加できなくなるのでarchiveする
+	if len(caus) == 0 {
+		return ch.Archive()
+	}
+	for _, c := range caus {
+		if c.IsAdmin {
+			return nil
+		}
+	}
+	// 一番古くから参加しているuserを管理者にする
+	return caus[0].UpdateIsAdmin(true)
+}
+
+func LeaveWorkspace(wau models.WorkspaceAndUsers) error {
+	// workspaceのchannelからすべて抜けてからworkspaceから抜ける
+	channels, err := models.GetChannelsByWorkspaceId(wau.WorkspaceId)
+	if err != nil {
+		return err
+	}
+	for _, ch := range channels {
+		if !models.IsExistCAUByChannelIdAndUserId(ch.ID, wau.UserId) {
+			continue
+		}
+		if err := LeaveChannel(ch, wau.UserId); err != nil {
+			return err
+		}
+	}
+	if err := models.DeleteUserGroupMembersInWorkspace(wau.WorkspaceId, wau.UserId); err != nil {
+		return err
+	}
+	return wau.DeleteWorkspaceAndUser()
+}
@@ -0,0 +1,84 @@
+package controllerUtils
+
+import (
+	"regexp"
+	"strings"
+
+	"backend/models"
+)
+
+// @handleをuser groupのmemberに展開した結果を含める
+type MessageWithMentions struct {
+	models.Message
+	MentionedUserIds []uint32 `json:"mentioned_user_ids"`
+}
+
+type DMWithMentions struct {
+	models.DirectMessage
+	MentionedUserIds []uint32 `json:"mentioned_user_ids"`
+}
+
+func GetMessagesWithMentionsByChannelId(channelId int) ([]MessageWithMentions, error) {
+	res := make([]MessageWithMentions, 0)
+	messages, err := models.GetMessagesByChannelId(channelId)
+	if err != nil {
+		return res, err
+	}
+	mentions, err := models.GetMessageMentionsByChannelId(channelId)
+	if err != nil {
+		return res, err
+	}
+	for _, m := range messages {
+		mentionedUserIds := mentions[m.ID]
+		if mentionedUserIds == nil {
+			mentionedUserIds = make([]uint32, 0)
+		}
+		res = append(res, MessageWithMentions{Message: m, MentionedUserIds: mentionedUserIds})
+	}
+	return res, nil
+}
+
+func GetDMsWithMentionsByDLId(dmLineId uint) ([]DMWithMentions, error) {
+	res := make([]DMWithMentions, 0)
+	dms, err := models.GetAllDMsByDLId(dmLineId)
+	if err != nil {
+		return res, err
+	}
+	mentions, err := models.GetDMMentionsByDLId(dmLineId)
+	if err != nil {
+		return res, err
+	}
+	for _, dm := range dms {
+		mentionedUserIds := mentions[dm.ID]
+		if mentionedUserIds == nil {
+			mentionedUserIds = make([]uint32, 0)
+		}
+		res = append(res, DMWithMentions{DirectMessage: dm, MentionedUserIds: mentionedUserIds})
+	}
+	return res, nil
+}
+
+// channelの全員に通知するmention
+var channelMentionPattern = regexp.MustCompile(`(^|[^\w@])@(channel|here|everyone)\b`)
+
+// user groupのhandleは英小文字, 数字, . _ - のみで、先頭と末尾は英小文字か数字
+var userGroupHandlePattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9._-]*[a-z0-9])?$`)
+var userGroupMentionPattern = regexp.MustCompile(`(?i)(^|[^\w@])@([a-z0-9](?:[a-z0-9._-]*[a-z0-9])?)`)
+
+func ContainsChannelMention(text string) bool {
+	return channelMentionPattern.MatchString(text)
+}
+
+func ExtractMentionHandles(text string) []string {
+	// textに含まれる@handleを重複を除いて出現順に返す
+	handles := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, m := range userGroupMentionPattern.FindAllStringSubmatch(text, -1) {
+		handle := strings.ToLower(m[2])
+		if !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	return handles
+}
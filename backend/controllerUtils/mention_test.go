@@ -0,0 +1,33 @@
+package controllerUtils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractMentionHandles(t *testing.T) {
+	testCases := []struct {
+		text string
+		want []string
+	}{
+		{"hello", []string{}},
+		{"@backend please review", []string{"backend"}},
+		{"cc @Backend-Team, @design.", []string{"backend-team", "design"}},
+		{"@backend @backend", []string{"backend"}},
+		{"mail@example.com @@backend", []string{}},
+		{"(@ops_1)", []string{"ops_1"}},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, ExtractMentionHandles(tc.text), tc.text)
+	}
+}
+
+func TestValidateUserGroupHandle(t *testing.T) {
+	for _, handle := range []string{"backend", "backend-team", "ops_1", "a.b"} {
+		assert.Empty(t, validateUserGroupHandle(handle), handle)
+	}
+	for _, handle := range []string{"Backend", "-backend", "backend.", "back end", "here", "channel", "abcdefghijklmnopqrstuv"} {
+		assert.NotEmpty(t, validateUserGroupHandle(handle), handle)
+	}
+}
@@ -8,46 +8,27 @@ import (
 	"backend/models"
 )
 
-func HasPermissionAddUserInWorkspace(userId uint32, workspaceId int) bool {
-	wau, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(workspaceId, userId)
-	if err != nil {
-		return false
-	}
-	return wau.RoleId == 1 || wau.RoleId == 2 || wau.RoleId == 3
-}
-
-func HasPermissionRenamingWorkspaceName(workspaceId int, userId uint32) (bool, error) {
-	wau, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(workspaceId, userId)
-	if err != nil {
-		return false, err
-	}
-	return (wau.RoleId == 1 || wau.RoleId == 2 || wau.RoleId == 3), nil
-}
-
-func HasPermissionDeletingUserFromWorkspace(workspaceId int, userId uint32) (bool, error) { wau, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(workspaceId, userId)
-	if err != nil {
-		return false, err
-	}
-	return (wau.RoleId == 1 || wau.RoleId == 2 || wau.RoleId == 3), nil	
-}
+// roleに関する権限はAuthorizeで判定する
+// ここではchannelやDMなどrole以外の条件を含む権限を判定する
 
 func HasPermissionAddingUserInChannel(channelId int, userId uint32) bool {
 	return models.IsAdminUserInChannel(channelId, userId)
 }
 
 func HasPermissionDeletingUserInChannel(userId uint32, workspaceId int, ch models.Channel) bool {
+	// private channelはchannelのmember, public channelはcapabilityを持つuserが削除できる
 	if ch.IsPrivate {
 		return models.IsExistCAUByChannelIdAndUserId(ch.ID, userId)
 	}
-	roleId, err := models.GetRoleIdByWorkspaceIdAndUserId(workspaceId, userId)
-	if err != nil {
-		return false
-	}
-	return roleId == 1 || roleId == 2 || roleId == 3
+	b, err := Authorize(workspaceId, userId, models.CapChannelsRemoveMember)
+	return err == nil && b
 }
 
-func HasPermissionDeletingChannel(wau models.WorkspaceAndUsers) bool { 
-	return (wau.RoleId == 1 || wau.RoleId == 2 || wau.RoleId == 3)	
+func HasPermissionCreatingChannel(workspaceId int, userId uint32, isPrivate bool) (bool, error) {
+	if isPrivate {
+		return Authorize(workspaceId, userId, models.CapChannelsCreatePrivate)
+	}
+	return Authorize(workspaceId, userId, models.CapChannelsCreatePublic)
 }
 
 func HasPermissionEditDM(dmId uint, userId uint32) bool {
@@ -61,3 +42,44 @@ func HasPermissionEditDM(dmId uint, userId uint32) bool {
 	return dm.SendUserId == userId
 
 }
+
+func CanJoinWorkspace(workspaceId int, userId uint32) (bool, error) {
+	// emailの確認が済んでいないuserはworkspaceに参加できない
+	// workspaceの設定で確認済みのemailを必須にしている場合はemailがないuserも参加できない
+	u, err := models.GetUserById(userId)
+	if err != nil {
+		return false, err
+	}
+	if u.HasUnverifiedEmail() {
+		return false, nil
+	}
+	ws, err := models.GetWorkspaceSettingByWorkspaceId(workspaceId)
+	if err != nil {
+		return false, err
+	}
+	if ws.RequireVerifiedEmail && !u.IsEmailVerified() {
+		return false, nil
+	}
+	return true, nil
+}
+
+func HasAllowedEmailDomain(workspaceId int, userId uint32) (bool, error) {
+	// workspaceの設定でemailのdomainが制限されている場合は一致するuserのみ参加できる
+	u, err := models.GetUserById(userId)
+	if err != nil {
+		return false, err
+	}
+	ws, err := models.GetWorkspaceSettingByWorkspaceId(workspaceId)
+	if err != nil {
+		return false, err
+	}
+	return ws.IsAllowedEmail(u.Email), nil
+}
+
+func CanChannelMention(workspaceId int) (bool, error) {
+	ws, err := models.GetWorkspaceSettingByWorkspaceId(workspaceId)
+	if err != nil {
+		return false, err
+	}
+	return ws.AllowChannelMention, nil
+}
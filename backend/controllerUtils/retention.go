@@ -0,0 +1,30 @@
+package controllerUtils
+
+import (
+	"time"
+
+	"backend/models"
+)
+
+func PurgeExpiredMessages(now time.Time) (int64, error) {
+	// workspaceの設定した保存期間を過ぎたmessageとDMを削除し、削除した数を返す
+	settings, err := models.GetWorkspaceSettingsWithMessageRetention()
+	if err != nil {
+		return 0, err
+	}
+	var cnt int64
+	for _, ws := range settings {
+		before := now.AddDate(0, 0, -ws.MessageRetentionDays)
+		n, err := models.DeleteMessagesBeforeInWorkspace(ws.WorkspaceId, before)
+		if err != nil {
+			return cnt, err
+		}
+		cnt += n
+		n, err = models.DeleteDMsBeforeInWorkspace(ws.WorkspaceId, before)
+		if err != nil {
+			return cnt, err
+		}
+		cnt += n
+	}
+	return cnt, nil
+}
@@ -0,0 +1,59 @@
+package controllerUtils
+
+import (
+	"fmt"
+
+	"backend/models"
+)
+
+type RoleWithCapabilities struct {
+	models.Role
+	Capabilities []string `json:"capabilities"`
+}
+
+func GetRolesWithCapabilities(workspaceId int) ([]RoleWithCapabilities, error) {
+	res := make([]RoleWithCapabilities, 0)
+	roles, err := models.GetRolesByWorkspaceId(workspaceId)
+	if err != nil {
+		return res, err
+	}
+	for _, r := range roles {
+		capabilities, err := models.GetCapabilitiesByRole(r)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, RoleWithCapabilities{Role: r, Capabilities: capabilities})
+	}
+	return res, nil
+}
+
+func HasAllCapabilities(workspaceId int, userId uint32, capabilities []string) (bool, error) {
+	// 自分が持っていないcapabilityはcustom roleに与えられない
+	wau, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(workspaceId, userId)
+	if err != nil {
+		return false, err
+	}
+	return HasAllCapabilitiesRole(workspaceId, wau.RoleId, capabilities)
+}
+
+func HasAllCapabilitiesRole(workspaceId, roleId int, capabilities []string) (bool, error) {
+	for _, capability := range capabilities {
+		b, err := AuthorizeRole(workspaceId, roleId, capability)
+		if err != nil || !b {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func GetCustomRoleInWorkspace(workspaceId, roleId int) (models.Role, error) {
+	// built-in roleや他のworkspaceのroleは見つからないものとして扱う
+	r, err := models.GetRoleById(roleId)
+	if err != nil {
+		return r, err
+	}
+	if r.IsBuiltIn() || r.WorkspaceId != workspaceId {
+		return r, fmt.Errorf("role not found")
+	}
+	return r, nil
+}
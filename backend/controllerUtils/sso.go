@@ -0,0 +1,145 @@
+package controllerUtils
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"backend/models"
+	"backend/oidc"
+	"backend/utils"
+)
+
+func ssoUsername(claims oidc.IdTokenClaims) string {
+	// preferred_username, emailのlocal part, nameの順にusernameの候補にする
+	candidates := []string{claims.PreferredUsername, strings.Split(claims.Email, "@")[0], claims.Name}
+	for _, c := range candidates {
+		// 数字を付けてもusernameの長さ(byte数)を超えないようにする
+		name := truncateBytes(strings.TrimSpace(strings.ReplaceAll(c, "@", "")), maxUsernameLength-5)
+		if name != "" {
+			return name
+		}
+	}
+	return "user"
+}
+
+func truncateBytes(s string, max int) string {
+	// 文字の途中で切らないようにmax byte以下に切り詰める
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+func uniqueUsername(name string) string {
+	// 同じusernameが存在する場合は数字を付けて区別する
+	candidate := name
+	for i := 0; i < 10 && IsExistUserSameUsername(candidate); i++ {
+		candidate = fmt.Sprintf("%s-%04d", name, rand.Intn(10000))
+	}
+	return candidate
+}
+
+func FindOrCreateUserByIdentity(provider string, claims oidc.IdTokenClaims) (models.User, error) {
+	// 1. 既に紐付けられているuser
+	ui, err := models.GetUserIdentity(provider, claims.Subject)
+	if err == nil {
+		return models.GetUserById(ui.UserId)
+	}
+	if err != gorm.ErrRecordNotFound {
+		return models.User{}, err
+	}
+
+	// 2. providerが確認済みのemailと同じemailのuserに紐付ける
+	// 他人のemailで先にsign upしたaccountを乗っ取られないように, emailの確認が済んだuserにのみ紐付ける
+	// 確認が済んでいない場合は別のuserを作成する
+	var u models.User
+	if claims.Email != "" && claims.EmailVerified {
+		existing, err := models.GetUserByEmail(claims.Email)
+		if err != nil && err != sql.ErrNoRows {
+			return models.User{}, err
+		}
+		if err == nil && existing.IsEmailVerified() {
+			u = existing
+		}
+	}
+
+	// 3. 新しいuserを作成する
+	// passwordでloginできないようにランダムなpasswordを設定し, userがpasswordを設定するまで使えないようにする
+	if u.ID == 0 {
+		password, err := utils.GenerateRandomString(32)
+		if err != nil {
+			return models.User{}, err
+		}
+		u = *models.NewUser(0, uniqueUsername(ssoUsername(claims)), password)
+		if claims.Email != "" && claims.EmailVerified && !IsExistUserSameEmail(claims.Email) {
+			u.Email = claims.Email
+		}
+		if err := u.Create(); err != nil {
+			return models.User{}, err
+		}
+		if err := u.DisablePassword(); err != nil {
+			return models.User{}, err
+		}
+		if claims.Name != "" {
+			p := models.NewProfile(u.ID)
+			realName := []rune(claims.Name)
+			if len(realName) > maxRealNameLength {
+				realName = realName[:maxRealNameLength]
+			}
+			p.RealName = string(realName)
+			if err := p.Save().Error; err != nil {
+				return models.User{}, err
+			}
+		}
+	}
+
+	// providerが確認済みのemailは確認済みとして扱う
+	if claims.EmailVerified && u.HasUnverifiedEmail() && strings.EqualFold(u.Email, claims.Email) {
+		if err := u.VerifyEmail(); err != nil {
+			return models.User{}, err
+		}
+	}
+
+	if err := models.NewUserIdentity(provider, claims.Subject, u.ID, claims.Email).Create().Error; err != nil {
+		return models.User{}, err
+	}
+	return u, nil
+}
+
+func JoinSSOWorkspace(workspaceId int, userId uint32) error {
+	// providerに設定されたworkspaceに一般memberとして参加させる
+	// workspaceが削除されている場合, workspaceで無効化されている場合は参加させない
+	if workspaceId == 0 || !IsExistWorkspaceById(workspaceId) {
+		return nil
+	}
+	_, err := models.IsDeactivatedInWorkspace(workspaceId, userId)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	// emailの確認が済んでいないuserも参加させない
+	b, err := CanJoinWorkspace(workspaceId, userId)
+	if err != nil || !b {
+		return err
+	}
+	// 許可されていないdomainのemailのuserも参加させない
+	b, err = HasAllowedEmailDomain(workspaceId, userId)
+	if err != nil || !b {
+		return err
+	}
+	wau := models.NewWorkspaceAndUsers(workspaceId, userId, models.RoleFullMember)
+	if err := wau.Create(); err != nil {
+		return err
+	}
+	return JoinDefaultChannels(*wau)
+}
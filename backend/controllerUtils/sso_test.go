@@ -0,0 +1,33 @@
+package controllerUtils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+
+	"backend/oidc"
+)
+
+func TestSSOUsername(t *testing.T) {
+	testCases := []struct {
+		claims oidc.IdTokenClaims
+		want   string
+	}{
+		{oidc.IdTokenClaims{PreferredUsername: "alice", Email: "bob@example.com"}, "alice"},
+		{oidc.IdTokenClaims{Email: "bob@example.com", Name: "Bob"}, "bob"},
+		{oidc.IdTokenClaims{Name: " Carol "}, "Carol"},
+		{oidc.IdTokenClaims{}, "user"},
+		{oidc.IdTokenClaims{PreferredUsername: strings.Repeat("a", maxUsernameLength)}, strings.Repeat("a", maxUsernameLength-5)},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, ssoUsername(tc.claims))
+	}
+
+	// 複数byteの文字は途中で切らずにbyte数で切り詰める
+	name := ssoUsername(oidc.IdTokenClaims{PreferredUsername: strings.Repeat("あ", maxUsernameLength)})
+	assert.True(t, len(name) <= maxUsernameLength-5)
+	assert.True(t, utf8.ValidString(name))
+	assert.Equal(t, strings.Repeat("あ", (maxUsernameLength-5)/3), name)
+}
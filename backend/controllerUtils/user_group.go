@@ -0,0 +1,110 @@
+package controllerUtils
+
+import (
+	"backend/models"
+)
+
+type UserGroupInfo struct {
+	models.UserGroup
+	MemberIds  []uint32 `json:"member_ids"`
+	ChannelIds []int    `json:"channel_ids"`
+}
+
+func GetUserGroupInfo(ug models.UserGroup) (UserGroupInfo, error) {
+	res := UserGroupInfo{UserGroup: ug}
+	memberIds, err := models.GetUserGroupMemberIds(ug.ID)
+	if err != nil {
+		return res, err
+	}
+	channelIds, err := models.GetUserGroupChannelIds(ug.ID)
+	if err != nil {
+		return res, err
+	}
+	res.MemberIds = memberIds
+	res.ChannelIds = channelIds
+	return res, nil
+}
+
+func GetUserGroupInfosByWorkspaceId(workspaceId int) ([]UserGroupInfo, error) {
+	res := make([]UserGroupInfo, 0)
+	ugs, err := models.GetUserGroupsByWorkspaceId(workspaceId)
+	if err != nil {
+		return res, err
+	}
+	for _, ug := range ugs {
+		info, err := GetUserGroupInfo(ug)
+		if err != nil {
+			return res, err
+		}
+		res = append(res, info)
+	}
+	return res, nil
+}
+
+func JoinUserGroupChannels(userGroupId uint) (int, error) {
+	// groupのmemberをすべてgroupのchannelに参加させ、新しく参加した人数を返す
+	memberIds, err := models.GetUserGroupMemberIds(userGroupId)
+	if err != nil {
+		return 0, err
+	}
+	channelIds, err := models.GetUserGroupChannelIds(userGroupId)
+	if err != nil {
+		return 0, err
+	}
+	cnt := 0
+	for _, channelId := range channelIds {
+		for _, userId := range memberIds {
+			joined, err := joinChannel(channelId, userId)
+			if err != nil {
+				return cnt, err
+			}
+			if joined {
+				cnt++
+			}
+		}
+	}
+	return cnt, nil
+}
+
+func ExpandUserGroupMentions(workspaceId int, text string, sendUserId uint32, recipientIds []uint32) ([]uint32, error) {
+	// textの@handleをgroupのmemberに展開する
+	// messageを受け取るuser(channelのmemberやDMの相手)のうちworkspaceで有効なmemberのみ対象にし、送信者は含めない
+	// guestはuser groupを見られないので展開しない
+	res := make([]uint32, 0)
+	b, err := IsGuestInWorkspace(workspaceId, sendUserId)
+	if err != nil || b {
+		return res, err
+	}
+	ugs, err := models.GetUserGroupsByHandles(workspaceId, ExtractMentionHandles(text))
+	if err != nil || len(ugs) == 0 {
+		return res, err
+	}
+	waus, err := models.GetWAUsByWorkspaceId(workspaceId)
+	if err != nil {
+		return res, err
+	}
+	recipients := make(map[uint32]bool)
+	for _, userId := range recipientIds {
+		recipients[userId] = true
+	}
+	allowed := make(map[uint32]bool)
+	for _, wau := range waus {
+		if recipients[wau.UserId] {
+			allowed[wau.UserId] = true
+		}
+	}
+	seen := map[uint32]bool{sendUserId: true}
+	for _, ug := range ugs {
+		memberIds, err := models.GetUserGroupMemberIds(ug.ID)
+		if err != nil {
+			return res, err
+		}
+		for _, userId := range memberIds {
+			if allowed[userId] && !seen[userId] {
+				seen[userId] = true
+				res = append(res, userId)
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+func DeactivateAccount(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidatePassword(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	u, err := models.GetUserById(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	// passwordを再確認
+	b, err := controllerUtils.IsCorrectPassword(userId, in.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "wrong password"})
+		return
+	}
+
+	// primary ownerのworkspaceがある場合は無効化できない
+	b, err = controllerUtils.IsPrimaryOwnerOfAnyWorkspace(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if b {
+		c.JSON(http.StatusConflict, gin.H{"message": "primary owner cannot deactivate account"})
+		return
+	}
+
+	if err := u.Deactivate(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// すべてのsessionを無効にする
+	if err := models.RevokeSessionsByUserId(userId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "account deactivated"})
+}
+
+func ReactivateAccount(c *gin.Context) {
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateLogin(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// loginと同じく失敗が続いているaccountはロックする
+	lockoutId := loginLockoutId(c, in)
+	if isLockedOut(c, loginLockout, lockoutId) {
+		return
+	}
+
+	// usernameまたはemailとpasswordからuserを特定
+	// userが存在するかどうかがわからないように同じmessageを返す
+	var u models.User
+	if in.Name != "" {
+		u, err = models.GetUserByNameAndPassword(in.Name, in.Password)
+	} else {
+		u, err = models.GetUserByEmailAndPassword(in.Email, in.Password)
+	}
+	if err != nil {
+		recordFailure(loginLockout, lockoutId)
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "wrong username, email or password"})
+		return
+	}
+	resetFailures(loginLockout, lockoutId)
+
+	if !u.IsDeactivated() {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "account is not deactivated"})
+		return
+	}
+	if err := u.Reactivate(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "account reactivated"})
+}
+
+func DeleteAccount(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidatePassword(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	u, err := models.GetUserById(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	// passwordを再確認
+	b, err := controllerUtils.IsCorrectPassword(userId, in.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "wrong password"})
+		return
+	}
+
+	// primary ownerのworkspaceがある場合は削除できない
+	b, err = controllerUtils.IsPrimaryOwnerOfAnyWorkspace(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if b {
+		c.JSON(http.StatusConflict, gin.H{"message": "primary owner cannot delete account"})
+		return
+	}
+
+	if err := controllerUtils.DeleteUserAccount(&u); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "account deleted"})
+}
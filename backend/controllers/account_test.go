@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+func accountTestFunc(method, path, jwtToken string, input interface{}) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest(method, "/api/user"+path, bytes.NewBuffer(jsonInput))
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+// workspaceを作成してgeneral channelのidを返す
+func createWorkspaceWithGeneral(t *testing.T, lr *LoginResponse) (models.Workspace, int) {
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), lr.Token, lr.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+
+	rr = getChannelsByUserTestFunc(w.ID, lr.Token)
+	chs := make([]models.Channel, 0)
+	json.Unmarshal(rr.Body.Bytes(), &chs)
+	assert.Equal(t, 1, len(chs))
+	return *w, chs[0].ID
+}
+
+func TestDeactivateAccount(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 無効化するとloginできず、再有効化するとloginできる 200
+	// 2 passwordが間違っている場合 403
+	// 3 workspaceのprimary ownerの場合 409
+	// 4 無効化されていないaccountを再有効化した場合 400
+	// 5 usernameやpasswordが間違っている場合は同じmessage 401
+
+	t.Run("1", func(t *testing.T) {
+		lr := signUpAndLogin(t)
+		rr := accountTestFunc("POST", "/deactivate", lr.Token, controllerUtils.PasswordInput{Password: "pass"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, currentUserTestFunc(lr.Token).Code)
+		assert.Equal(t, http.StatusForbidden, loginTestFunc(lr.Username, "pass").Code)
+
+		input := controllerUtils.SignUpAndLoginInput{Name: lr.Username, Password: "wrong"}
+		assert.Equal(t, http.StatusUnauthorized, accountTestFunc("POST", "/reactivate", "", input).Code)
+		input.Password = "pass"
+		assert.Equal(t, http.StatusOK, accountTestFunc("POST", "/reactivate", "", input).Code)
+		assert.Equal(t, http.StatusOK, loginTestFunc(lr.Username, "pass").Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		lr := signUpAndLogin(t)
+		rr := accountTestFunc("POST", "/deactivate", lr.Token, controllerUtils.PasswordInput{Password: "wrong"})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		lr := signUpAndLogin(t)
+		createWorkspaceWithGeneral(t, lr)
+		rr := accountTestFunc("POST", "/deactivate", lr.Token, controllerUtils.PasswordInput{Password: "pass"})
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		lr := signUpAndLogin(t)
+		input := controllerUtils.SignUpAndLoginInput{Name: lr.Username, Password: "pass"}
+		assert.Equal(t, http.StatusBadRequest, accountTestFunc("POST", "/reactivate", "", input).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		lr := signUpAndLogin(t)
+		input := controllerUtils.SignUpAndLoginInput{Name: lr.Username, Password: "wrong"}
+		rr := accountTestFunc("POST", "/reactivate", "", input)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, "{\"message\":\"wrong username, email or password\"}", rr.Body.String())
+
+		input.Name = randomstring.EnglishFrequencyString(30)
+		rr = accountTestFunc("POST", "/reactivate", "", input)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, "{\"message\":\"wrong username, email or password\"}", rr.Body.String())
+	})
+}
+
+func TestDeleteAccount(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 messageを残す設定のworkspaceでは送信者が残り、匿名化する設定のworkspaceでは削除済みuserになる 200
+	// 2 passwordが間違っている場合 403
+	// 3 workspaceのprimary ownerの場合 409
+
+	t.Run("1", func(t *testing.T) {
+		owner := signUpAndLogin(t)
+		member := signUpAndLogin(t)
+		retainWorkspace, retainChannelId := createWorkspaceWithGeneral(t, owner)
+		anonymizeWorkspace, anonymizeChannelId := createWorkspaceWithGeneral(t, owner)
+		policy := models.DeletedUserMessagesAnonymize
+		rr := updateWorkspaceSettingTestFunc(anonymizeWorkspace.ID, owner.Token, controllerUtils.UpdateWorkspaceSettingInput{DeletedUserMessagePolicy: &policy})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		for _, ids := range [][2]int{{retainWorkspace.ID, retainChannelId}, {anonymizeWorkspace.ID, anonymizeChannelId}} {
+			assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(ids[0], 4, member.UserId, owner.Token).Code)
+			assert.True(t, models.IsExistCAUByChannelIdAndUserId(ids[1], member.UserId))
+			assert.Equal(t, http.StatusOK, sendMessageTestFunc("hello", ids[1], member.Token).Code)
+		}
+		assert.Equal(t, http.StatusOK, sendDMTestFunc("hi", member.Token, owner.UserId, retainWorkspace.ID).Code)
+
+		rr = accountTestFunc("DELETE", "", member.Token, controllerUtils.PasswordInput{Password: "pass"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		// loginできず、workspace, channel, DMから削除されている
+		assert.Equal(t, http.StatusUnauthorized, currentUserTestFunc(member.Token).Code)
+		assert.NotEqual(t, http.StatusOK, loginTestFunc(member.Username, "pass").Code)
+		assert.False(t, models.IsExistCAUByChannelIdAndUserId(retainChannelId, member.UserId))
+		_, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(retainWorkspace.ID, member.UserId)
+		assert.NotEmpty(t, err)
+		_, err = models.GetDLByUserIdsAndWorkspaceId(owner.UserId, member.UserId, retainWorkspace.ID)
+		assert.NotEmpty(t, err)
+
+		// 同じusernameで新しく登録できる
+		assert.Equal(t, http.StatusOK, signUpTestFunc(member.Username, "pass").Code)
+
+		ms, err := models.GetMessagesByChannelId(retainChannelId)
+		assert.Empty(t, err)
+		assert.Equal(t, member.UserId, ms[0].UserId)
+		ms, err = models.GetMessagesByChannelId(anonymizeChannelId)
+		assert.Empty(t, err)
+		assert.Equal(t, models.DeletedUserId, ms[0].UserId)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		lr := signUpAndLogin(t)
+		rr := accountTestFunc("DELETE", "", lr.Token, controllerUtils.PasswordInput{Password: "wrong"})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		lr := signUpAndLogin(t)
+		createWorkspaceWithGeneral(t, lr)
+		rr := accountTestFunc("DELETE", "", lr.Token, controllerUtils.PasswordInput{Password: "pass"})
+		assert.Equal(t, http.StatusConflict, rr.Code)
+	})
+}
@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/controllerUtils"
+	"backend/models"
+	"backend/token"
+	"backend/utils"
+)
+
+func newApiToken(prefix string, userId, createdBy uint32, workspaceId int, in controllerUtils.CreateApiTokenInput) (*models.ApiToken, string, error) {
+	// tokenを作成してhash値のみ保存する
+	tokenId, err := utils.GenerateRandomString(12)
+	if err != nil {
+		return nil, "", err
+	}
+	apiToken, err := token.GenerateApiToken(prefix, tokenId)
+	if err != nil {
+		return nil, "", err
+	}
+	t := models.NewApiToken(tokenId, userId, createdBy, in.Name, token.HashApiToken(apiToken), in.Scopes)
+	t.WorkspaceId = workspaceId
+	t.IsBot = prefix == token.BotTokenPrefix
+	return t, apiToken, nil
+}
+
+func createApiToken(prefix string, userId, createdBy uint32, workspaceId int, in controllerUtils.CreateApiTokenInput) (models.ApiToken, string, error) {
+	t, apiToken, err := newApiToken(prefix, userId, createdBy, workspaceId, in)
+	if err != nil {
+		return models.ApiToken{}, "", err
+	}
+	if err := t.Create().Error; err != nil {
+		return models.ApiToken{}, "", err
+	}
+	return *t, apiToken, nil
+}
+
+func CreatePersonalToken(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateCreateApiToken(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	t, apiToken, err := createApiToken(token.PersonalTokenPrefix, userId, userId, 0, in)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// tokenはこのresponseでのみ返す
+	c.JSON(http.StatusOK, gin.H{"token": apiToken, "api_token": t})
+}
+
+func GetPersonalTokens(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	res, err := models.GetActivePersonalTokensByUserId(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func RevokePersonalToken(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// 他のuserのtokenは存在しないものとして扱う
+	t, err := models.GetApiTokenById(c.Param("token_id"))
+	if err != nil || t.UserId != userId || t.IsBot {
+		c.JSON(http.StatusNotFound, gin.H{"message": "api token not found"})
+		return
+	}
+	if !t.IsActive() {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "api token is already revoked"})
+		return
+	}
+
+	if err := t.Revoke(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func CreateBotToken(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateCreateApiToken(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserがbot tokenを管理できるかチェック
+	b, err := controllerUtils.Authorize(workspaceId, userId, models.CapBotTokensManage)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	// bot用のuserを作成してworkspaceに追加する
+	// passwordはランダムな値にしてloginできないようにする
+	suffix, err := utils.GenerateRandomString(6)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	password, err := utils.GenerateRandomString(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	bot := models.NewUser(0, fmt.Sprintf("bot-%s", suffix), password)
+	p := models.NewProfile(0)
+	p.DisplayName = in.Name
+	t, apiToken, err := newApiToken(token.BotTokenPrefix, 0, userId, workspaceId, in)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	// 途中で失敗してもbot用のuserが残らないように同じtransactionで作成する
+	if err := models.CreateBot(bot, workspaceId, p, t); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// tokenはこのresponseでのみ返す
+	c.JSON(http.StatusOK, gin.H{"token": apiToken, "api_token": t})
+}
+
+func GetBotTokens(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserがbot tokenを管理できるかチェック
+	b, err := controllerUtils.Authorize(workspaceId, userId, models.CapBotTokensManage)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	res, err := models.GetActiveBotTokensByWorkspaceId(workspaceId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func RevokeBotToken(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserがbot tokenを管理できるかチェック
+	b, err := controllerUtils.Authorize(workspaceId, userId, models.CapBotTokensManage)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	// 他のworkspaceのtokenは存在しないものとして扱う
+	t, err := models.GetApiTokenById(c.Param("token_id"))
+	if err != nil || t.WorkspaceId != workspaceId || !t.IsBot {
+		c.JSON(http.StatusNotFound, gin.H{"message": "api token not found"})
+		return
+	}
+	if !t.IsActive() {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "api token is already revoked"})
+		return
+	}
+
+	if err := t.Revoke(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+type CreateApiTokenResponse struct {
+	Token    string          `json:"token"`
+	ApiToken models.ApiToken `json:"api_token"`
+}
+
+func apiTokenTestFunc(method, path, jwtToken string, input interface{}) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest(method, "/api"+path, bytes.NewBuffer(jsonInput))
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func createPersonalTokenTestFunc(t *testing.T, jwtToken string, scopes ...string) CreateApiTokenResponse {
+	input := controllerUtils.CreateApiTokenInput{Name: "script", Scopes: scopes}
+	rr := apiTokenTestFunc("POST", "/user/tokens", jwtToken, input)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	res := CreateApiTokenResponse{}
+	json.Unmarshal(rr.Body.Bytes(), &res)
+	return res
+}
+
+func TestPersonalToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 scopeを持っているrouteにはアクセスできる 200
+	// 2 scopeを持っていないrouteにアクセスした場合 403
+	// 3 revokeしたtokenの場合 401
+	// 4 不正なscopeの場合 400
+	// 5 API tokenでtokenを作成しようとした場合 403
+
+	lr := signUpAndLogin(t)
+	res := createPersonalTokenTestFunc(t, lr.Token, models.ScopeUsersRead, models.ScopeUsersRead)
+	assert.Equal(t, models.ScopeUsersRead, res.ApiToken.Scopes)
+
+	t.Run("1", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, currentUserTestFunc(res.Token).Code)
+		assert.Equal(t, http.StatusOK, currentUserTestFunc("Bearer "+res.Token).Code)
+
+		rr := apiTokenTestFunc("GET", "/user/tokens", lr.Token, nil)
+		tokens := make([]models.ApiToken, 0)
+		json.Unmarshal(rr.Body.Bytes(), &tokens)
+		assert.Equal(t, 1, len(tokens))
+		assert.NotNil(t, tokens[0].LastUsedAt)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		rr := apiTokenTestFunc("PATCH", "/user/profile", res.Token, map[string]string{"title": "bot"})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		rr := apiTokenTestFunc("DELETE", "/user/tokens/"+res.ApiToken.ID, lr.Token, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, http.StatusUnauthorized, currentUserTestFunc(res.Token).Code)
+
+		rr = apiTokenTestFunc("DELETE", "/user/tokens/"+res.ApiToken.ID, lr.Token, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		input := controllerUtils.CreateApiTokenInput{Name: "script", Scopes: []string{"admin"}}
+		assert.Equal(t, http.StatusBadRequest, apiTokenTestFunc("POST", "/user/tokens", lr.Token, input).Code)
+		input = controllerUtils.CreateApiTokenInput{Name: "script"}
+		assert.Equal(t, http.StatusBadRequest, apiTokenTestFunc("POST", "/user/tokens", lr.Token, input).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		all := createPersonalTokenTestFunc(t, lr.Token, models.ApiTokenScopes...)
+		input := controllerUtils.CreateApiTokenInput{Name: "script", Scopes: []string{models.ScopeUsersRead}}
+		assert.Equal(t, http.StatusForbidden, apiTokenTestFunc("POST", "/user/tokens", all.Token, input).Code)
+	})
+}
+
+func TestApiTokenScopeOnAllRoutes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// scopeを持っていないAPI tokenではすべてのrouteで 403
+
+	lr := signUpAndLogin(t)
+	usersRead := createPersonalTokenTestFunc(t, lr.Token, models.ScopeUsersRead)
+	chatRead := createPersonalTokenTestFunc(t, lr.Token, models.ScopeChatRead)
+
+	for _, route := range protectedRoutes {
+		apiToken := usersRead.Token
+		if route.scope == models.ScopeUsersRead {
+			apiToken = chatRead.Token
+		}
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(route.method, route.path, nil)
+		req.Header.Set("Authorization", apiToken)
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code, route.method+" "+route.path)
+	}
+}
+
+func TestBotToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 adminはbot tokenを作成でき、botはworkspaceのmemberとして操作できる 200
+	// 2 権限のないuserの場合 403
+	// 3 revokeしたtokenの場合 401
+
+	owner := signUpAndLogin(t)
+	member := signUpAndLogin(t)
+	w, channelId := createWorkspaceWithGeneral(t, owner)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, 4, member.UserId, owner.Token).Code)
+
+	input := controllerUtils.CreateApiTokenInput{Name: "deploy bot", Scopes: []string{models.ScopeChatWrite, models.ScopeChatRead}}
+	rr := apiTokenTestFunc("POST", "/workspace/bot_tokens/"+strconv.Itoa(w.ID), owner.Token, input)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	bot := CreateApiTokenResponse{}
+	json.Unmarshal(rr.Body.Bytes(), &bot)
+	assert.True(t, bot.ApiToken.IsBot)
+
+	t.Run("1", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, addUserInChannelTestFunc(channelId, bot.ApiToken.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, sendMessageTestFunc("deployed", channelId, bot.Token).Code)
+		assert.Equal(t, http.StatusForbidden, GetUsersInWorkspaceTestFunc(w.ID, bot.Token).Code)
+
+		rr := apiTokenTestFunc("GET", "/workspace/bot_tokens/"+strconv.Itoa(w.ID), owner.Token, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		tokens := make([]models.ApiToken, 0)
+		json.Unmarshal(rr.Body.Bytes(), &tokens)
+		assert.Equal(t, 1, len(tokens))
+	})
+
+	t.Run("2", func(t *testing.T) {
+		rr := apiTokenTestFunc("POST", "/workspace/bot_tokens/"+strconv.Itoa(w.ID), member.Token, input)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		rr = apiTokenTestFunc("DELETE", "/workspace/bot_tokens/"+strconv.Itoa(w.ID)+"/"+bot.ApiToken.ID, member.Token, nil)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		rr := apiTokenTestFunc("DELETE", "/workspace/bot_tokens/"+strconv.Itoa(w.ID)+"/"+bot.ApiToken.ID, owner.Token, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, http.StatusUnauthorized, sendMessageTestFunc("deployed", channelId, bot.Token).Code)
+	})
+}
@@ -2,16 +2,171 @@ package controllers
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 
+	"backend/models"
 	"backend/token"
+	"backend/utils"
 )
 
-func Authenticate(c *gin.Context) (uint32, error) {
+// loginで発行されたtokenはすべての操作ができる
+const ScopeAll = "*"
+
+const principalKey = "principal"
+
+// 認証済みのrequestを送ったuserの情報
+// API tokenで認証された場合はSessionIdが空になる
+type Principal struct {
+	UserId    uint32
+	SessionId string
+	TokenId   string
+	Scopes    []string
+}
+
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == ScopeAll || s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := Authenticate(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+			return
+		}
+		// API呼び出しを操作としてpresenceに記録する
+		if err := models.TouchPresence(principal.UserId); err != nil {
+			fmt.Println(err)
+		}
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+func RequireScope(scope string) gin.HandlerFunc {
+	// API tokenで認証された場合は必要なscopeを持っているか確認する
+	return func(c *gin.Context) {
+		if !CurrentPrincipal(c).HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "missing scope: " + scope})
+			return
+		}
+		c.Next()
+	}
+}
+
+func RequireSession() gin.HandlerFunc {
+	// account設定などはloginしたsessionからのみ操作できる
+	return func(c *gin.Context) {
+		if !CurrentPrincipal(c).HasScope(ScopeAll) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"message": "api token is not allowed"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func CurrentPrincipal(c *gin.Context) Principal {
+	// AuthMiddlewareを通ったrequestでのみ使う
+	return c.MustGet(principalKey).(Principal)
+}
+
+func Authenticate(c *gin.Context) (Principal, error) {
 	tokenString := token.GetTokenFromContext(c)
 	if tokenString == "" {
-		return 0, fmt.Errorf("token not found from context")
+		return Principal{}, fmt.Errorf("token not found from context")
+	}
+
+	// API tokenはprefixで区別する
+	if token.IsApiToken(tokenString) {
+		return authenticateApiToken(tokenString)
+	}
+
+	claims, err := AuthenticateClaims(c, tokenString)
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{
+		UserId:    claims.UserId,
+		SessionId: claims.SessionId,
+		Scopes:    []string{ScopeAll},
+	}, nil
+}
+
+func AuthenticateClaims(c *gin.Context, tokenString string) (token.Claims, error) {
+	claims, err := token.ParseToken(tokenString)
+	if err != nil {
+		return token.Claims{}, err
+	}
+
+	// logoutなどで無効になったsessionのtokenは受け付けない
+	s, err := models.GetSessionById(claims.SessionId)
+	if err != nil {
+		return token.Claims{}, fmt.Errorf("session not found")
+	}
+	if s.UserId != claims.UserId || !s.IsActive() {
+		return token.Claims{}, fmt.Errorf("session is revoked or expired")
+	}
+
+	// 最後にアクセスした時刻とIPを記録する
+	if err := s.Touch(c.ClientIP()); err != nil {
+		fmt.Println(err)
+	}
+	return claims, nil
+}
+
+func authenticateApiToken(tokenString string) (Principal, error) {
+	tokenId, err := token.GetTokenIdFromApiToken(tokenString)
+	if err != nil {
+		return Principal{}, err
+	}
+	t, err := models.GetApiTokenById(tokenId)
+	if err != nil || !token.CompareApiToken(t.TokenHash, tokenString) {
+		return Principal{}, fmt.Errorf("invalid api token")
+	}
+	if !t.IsActive() {
+		return Principal{}, fmt.Errorf("api token is revoked")
+	}
+
+	// 無効化されたuserのtokenは受け付けない
+	u, err := models.GetUserById(t.UserId)
+	if err != nil || u.IsDeactivated() {
+		return Principal{}, fmt.Errorf("user is deactivated")
+	}
+
+	// 最後に使われた時刻を記録する
+	if err := t.Touch(); err != nil {
+		fmt.Println(err)
+	}
+	return Principal{
+		UserId:  t.UserId,
+		TokenId: t.ID,
+		Scopes:  t.ScopeList(),
+	}, nil
+}
+
+func createSession(c *gin.Context, userId uint32, deviceName string) (models.Session, string, error) {
+	// sessionを作成してrefresh tokenを返す
+	sessionId, err := utils.GenerateRandomString(16)
+	if err != nil {
+		return models.Session{}, "", err
+	}
+	refreshToken, err := token.GenerateRefreshToken(sessionId)
+	if err != nil {
+		return models.Session{}, "", err
+	}
+	s := models.NewSession(sessionId, userId, token.HashRefreshToken(refreshToken), token.RefreshTokenExpiresAt())
+	s.DeviceName = deviceName
+	s.UserAgent = c.Request.UserAgent()
+	s.IpAddress = c.ClientIP()
+	if err := s.Create().Error; err != nil {
+		return models.Session{}, "", err
 	}
-	return token.GetUserIdFromToken(tokenString)
+	return *s, refreshToken, nil
 }
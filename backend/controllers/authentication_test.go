@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/models"
+)
+
+// 認証が必要なrouteとAPI tokenで必要なscope
+// scopeが空のrouteはloginしたsessionからのみ操作できる
+var protectedRoutes = []struct {
+	method string
+	path   string
+	scope  string
+}{
+	{"GET", "/api/user/currentUser", models.ScopeUsersRead},
+	{"POST", "/api/user/logout", ""},
+	{"GET", "/api/user/sessions", ""},
+	{"DELETE", "/api/user/sessions", ""},
+	{"DELETE", "/api/user/sessions/1", ""},
+	{"POST", "/api/user/two_factor/setup", ""},
+	{"POST", "/api/user/two_factor/enable", ""},
+	{"POST", "/api/user/two_factor/disable", ""},
+	{"PATCH", "/api/user/password", ""},
+	{"POST", "/api/user/email/resend", ""},
+	{"GET", "/api/user/profile", models.ScopeUsersRead},
+	{"PATCH", "/api/user/profile", models.ScopeUsersWrite},
+	{"POST", "/api/user/presence/heartbeat", models.ScopeUsersWrite},
+	{"PUT", "/api/user/presence", models.ScopeUsersWrite},
+	{"GET", "/api/user/presence", models.ScopeUsersRead},
+	{"PUT", "/api/user/status", models.ScopeUsersWrite},
+	{"DELETE", "/api/user/status", models.ScopeUsersWrite},
+	{"POST", "/api/user/deactivate", ""},
+	{"DELETE", "/api/user", ""},
+	{"POST", "/api/user/tokens", ""},
+	{"GET", "/api/user/tokens", ""},
+	{"DELETE", "/api/user/tokens/1", ""},
+	{"POST", "/api/workspace/create", models.ScopeWorkspacesWrite},
+	{"POST", "/api/workspace/add_user", models.ScopeWorkspacesWrite},
+	{"PATCH", "/api/workspace/rename/1", models.ScopeWorkspacesWrite},
+	{"DELETE", "/api/workspace/delete/1", ""},
+	{"POST", "/api/workspace/restore/1", ""},
+	{"DELETE", "/api/workspace/delete_user", models.ScopeWorkspacesWrite},
+	{"POST", "/api/workspace/leave/1", models.ScopeWorkspacesWrite},
+	{"GET", "/api/workspace/get_by_user", models.ScopeWorkspacesRead},
+	{"GET", "/api/workspace/get_users/1", models.ScopeUsersRead},
+	{"PATCH", "/api/workspace/profile/1", models.ScopeUsersWrite},
+	{"POST", "/api/workspace/deactivate_user", models.ScopeWorkspacesWrite},
+	{"POST", "/api/workspace/reactivate_user", models.ScopeWorkspacesWrite},
+	{"POST", "/api/workspace/change_role", models.ScopeWorkspacesWrite},
+	{"POST", "/api/workspace/guest_expiration", models.ScopeWorkspacesWrite},
+	{"POST", "/api/workspace/transfer_primary_owner", ""},
+	{"GET", "/api/workspace/settings/1", models.ScopeWorkspacesRead},
+	{"PATCH", "/api/workspace/settings/1", models.ScopeWorkspacesWrite},
+	{"POST", "/api/workspace/bot_tokens/1", ""},
+	{"GET", "/api/workspace/bot_tokens/1", ""},
+	{"DELETE", "/api/workspace/bot_tokens/1/1", ""},
+	{"POST", "/api/workspace/invites/1", models.ScopeWorkspacesWrite},
+	{"GET", "/api/workspace/invites/1", models.ScopeWorkspacesRead},
+	{"DELETE", "/api/workspace/invites/1/1", models.ScopeWorkspacesWrite},
+	{"POST", "/api/workspace/join", models.ScopeWorkspacesWrite},
+	{"GET", "/api/workspace/default_channels/1", models.ScopeChannelsRead},
+	{"POST", "/api/workspace/default_channels/1/1", models.ScopeWorkspacesWrite},
+	{"DELETE", "/api/workspace/default_channels/1/1", models.ScopeWorkspacesWrite},
+	{"POST", "/api/workspace/default_channels/1/1/backfill", models.ScopeWorkspacesWrite},
+	{"GET", "/api/workspace/roles/1", models.ScopeWorkspacesRead},
+	{"POST", "/api/workspace/roles/1", models.ScopeWorkspacesWrite},
+	{"PATCH", "/api/workspace/roles/1/1", models.ScopeWorkspacesWrite},
+	{"DELETE", "/api/workspace/roles/1/1", models.ScopeWorkspacesWrite},
+	{"GET", "/api/workspace/user_groups/1", models.ScopeUsersRead},
+	{"POST", "/api/workspace/user_groups/1", models.ScopeWorkspacesWrite},
+	{"PATCH", "/api/workspace/user_groups/1/1", models.ScopeWorkspacesWrite},
+	{"DELETE", "/api/workspace/user_groups/1/1", models.ScopeWorkspacesWrite},
+	{"POST", "/api/channel/create", models.ScopeChannelsWrite},
+	{"POST", "/api/channel/add_user", models.ScopeChannelsWrite},
+	{"DELETE", "/api/channel/delete_user/1", models.ScopeChannelsWrite},
+	{"POST", "/api/channel/leave/1", models.ScopeChannelsWrite},
+	{"DELETE", "/api/channel/delete", models.ScopeChannelsWrite},
+	{"GET", "/api/channel/get_by_user_and_workspace/1", models.ScopeChannelsRead},
+	{"POST", "/api/message/send", models.ScopeChatWrite},
+	{"GET", "/api/message/get_from_channel/1", models.ScopeChatRead},
+	{"POST", "/api/dm/send", models.ScopeChatWrite},
+	{"GET", "/api/dm/1", models.ScopeChatRead},
+	{"PATCH", "/api/dm/1", models.ScopeChatWrite},
+	{"DELETE", "/api/dm/1", models.ScopeChatWrite},
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 認証が必要なrouteにtokenなしでrequestした場合 401
+	// 2 不正なtokenでrequestした場合 401
+	// 3 認証が不要なrouteはtokenなしでもrequestできる
+
+	t.Run("1", func(t *testing.T) {
+		for _, route := range protectedRoutes {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(route.method, route.path, nil)
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusUnauthorized, rr.Code, route.path)
+		}
+	})
+
+	t.Run("2", func(t *testing.T) {
+		for _, route := range protectedRoutes {
+			rr := httptest.NewRecorder()
+			req, _ := http.NewRequest(route.method, route.path, nil)
+			req.Header.Set("Authorization", "wrong token")
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusUnauthorized, rr.Code, route.path)
+		}
+	})
+
+	t.Run("3", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		assert.Equal(t, http.StatusOK, signUpTestFunc(name, "pass").Code)
+		assert.Equal(t, http.StatusOK, loginTestFunc(name, "pass").Code)
+
+		rr := httptest.NewRecorder()
+		jsonInput, _ := json.Marshal(map[string]string{"refresh_token": "wrong.token"})
+		req, _ := http.NewRequest("POST", "/api/user/refresh", bytes.NewBuffer(jsonInput))
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, "{\"message\":\"session not found\"}", rr.Body.String())
+	})
+}
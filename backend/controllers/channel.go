@@ -11,12 +11,7 @@ import (
 )
 
 func CreateChannel(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
-		return
-	}
+	userId := CurrentPrincipal(c).UserId
 
 	// bodyの情報を取得
 	in, err := controllerUtils.InputAndValidateCreateChannel(c)
@@ -49,6 +44,17 @@ func CreateChannel(c *gin.Context) {
 		return
 	}
 
+	// workspaceの設定でchannelの作成を許可されたroleか確認
+	b, err = controllerUtils.HasPermissionCreatingChannel(ch.WorkspaceId, userId, ch.IsPrivate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission creating channel"})
+		return
+	}
+
 	// channels tableに情報を保存
 	if err := ch.Create(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
@@ -68,12 +74,7 @@ func CreateChannel(c *gin.Context) {
 }
 
 func AddUserInChannel(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
-		return
-	}
+	userId := CurrentPrincipal(c).UserId
 
 	// bodyの情報を取得
 	in, err := controllerUtils.InputAndValidateAddUserInChannel(c)
@@ -98,7 +99,8 @@ func AddUserInChannel(c *gin.Context) {
 	}
 
 	// 追加されるuserがworkspaceに参加しているかを確認
-	if !controllerUtils.IsExistWAUByWorkspaceIdAndUserId(ch.WorkspaceId, cau.UserId) {
+	wau, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(ch.WorkspaceId, cau.UserId)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "added user not found in workspace"})
 		return
 	}
@@ -126,6 +128,17 @@ func AddUserInChannel(c *gin.Context) {
 		return
 	}
 
+	// single-channel guestは複数のchannelに参加できない
+	b, err = controllerUtils.CanJoinChannelAsGuest(wau)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "single-channel guest can only join one channel"})
+		return
+	}
+
 	// channels_and_users tableに登録
 	if err := cau.Create(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
@@ -136,12 +149,7 @@ func AddUserInChannel(c *gin.Context) {
 }
 
 func DeleteUserFromChannel(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
-		return
-	}
+	userId := CurrentPrincipal(c).UserId
 
 	// urlからパラメータを取得
 	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
@@ -189,7 +197,7 @@ func DeleteUserFromChannel(c *gin.Context) {
 	}
 
 	// channelのnameがgeneralでないことを確認
-	if ch.Name == "general" {
+	if controllerUtils.IsGeneralChannel(ch) {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "don't delete general channel"})
 		return
 	}
@@ -221,14 +229,44 @@ func DeleteUserFromChannel(c *gin.Context) {
 	c.JSON(http.StatusOK, cau)
 }
 
-func DeleteChannel(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
+func LeaveChannel(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	channelId, err := strconv.Atoi(c.Param("channel_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// channelの情報を取得
+	ch, err := models.GetChannelById(channelId)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		c.JSON(http.StatusNotFound, gin.H{"message": "channel not found"})
 		return
 	}
 
+	// requestしたuserがchannelに参加していることを確認
+	if !controllerUtils.IsExistWAUByWorkspaceIdAndUserId(ch.WorkspaceId, userId) || !models.IsExistCAUByChannelIdAndUserId(ch.ID, userId) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in channel"})
+		return
+	}
+
+	// generalからは抜けられない
+	if controllerUtils.IsGeneralChannel(ch) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "don't leave general channel"})
+		return
+	}
+
+	if err := controllerUtils.LeaveChannel(ch, userId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.NewChannelsAndUses(ch.ID, userId, false))
+}
+
+func DeleteChannel(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
 	// bodyの情報を取得
 	var ch models.Channel
 	if err := c.ShouldBindJSON(&ch); err != nil {
@@ -250,7 +288,7 @@ func DeleteChannel(c *gin.Context) {
 	}
 
 	// deleteする権限があるかを確認
-	if !controllerUtils.HasPermissionDeletingChannel(wau) {
+	if b, err := controllerUtils.Authorize(wau.WorkspaceId, userId, models.CapChannelsDelete); !b || err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"message": "no permission deleting channel"})
 		return
 	}
@@ -273,18 +311,23 @@ func DeleteChannel(c *gin.Context) {
 		return
 	}
 
+	// default channelとuser groupの設定から削除
+	if err := models.DeleteDefaultChannelsByChannelId(ch.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if err := models.DeleteUserGroupChannelsByChannelId(ch.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
 	// TODO roll back func
 
 	c.JSON(http.StatusOK, ch)
 }
 
 func GetChannelsByUser(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
-		return
-	}
+	userId := CurrentPrincipal(c).UserId
 
 	// urlからworkspace_idを取得
 	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
@@ -20,7 +20,7 @@ var channelRouter = SetupRouter()
 
 func createChannelTestFunc(name, description string, isPrivate *bool, jwtToken string, workspaceId int) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
-	ch := controllerUtils.CreateChannelInput{name, description, isPrivate, workspaceId}
+	ch := controllerUtils.CreateChannelInput{Name: name, Description: description, IsPrivate: isPrivate, WorkspaceId: workspaceId}
 	jsonInput, _ := json.Marshal(ch)
 	req, err := http.NewRequest("POST", "/api/channel/create", bytes.NewBuffer(jsonInput))
 	if err != nil {
@@ -60,6 +60,14 @@ func deleteChannelTestFunc(channelId, workspaceId int, jwtToken string) *httptes
 	return rr
 }
 
+func leaveChannelTestFunc(channelId int, jwtToken string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/channel/leave/"+strconv.Itoa(channelId), nil)
+	req.Header.Set("Authorization", jwtToken)
+	channelRouter.ServeHTTP(rr, req)
+	return rr
+}
+
 func getChannelsByUserTestFunc(workspaceId int, jwtToken string) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/api/channel/get_by_user_and_workspace/"+strconv.Itoa(workspaceId), nil)
@@ -1439,3 +1447,76 @@ func TestGetChannelsByUser(t *testing.T) {
 		assert.Equal(t, "{\"message\":\"request user not found in workspace\"}", rr.Body.String())
 	})
 }
+
+func TestLeaveChannel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 channelに参加していない場合 404
+	// 2 generalからは抜けられない 400
+	// 3 private channelの最後の管理者が抜ける場合は残ったuserが管理者になる 200
+	// 4 private channelから全員が抜けるとarchiveされる 200
+	// 5 public channelから抜ける場合 200
+
+	owner := signUpAndLoginTestFunc(t)
+	member := signUpAndLoginTestFunc(t)
+	outsider := signUpAndLoginTestFunc(t)
+
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleFullMember, member.UserId, owner.Token).Code)
+
+	isPrivate := true
+	rr = createChannelTestFunc(randomstring.EnglishFrequencyString(30), "", &isPrivate, owner.Token, w.ID)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	private := new(models.Channel)
+	json.Unmarshal(rr.Body.Bytes(), private)
+	assert.Equal(t, http.StatusOK, addUserInChannelTestFunc(private.ID, member.UserId, owner.Token).Code)
+
+	isPrivate = false
+	rr = createChannelTestFunc(randomstring.EnglishFrequencyString(30), "", &isPrivate, owner.Token, w.ID)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	public := new(models.Channel)
+	json.Unmarshal(rr.Body.Bytes(), public)
+	assert.Equal(t, http.StatusOK, addUserInChannelTestFunc(public.ID, member.UserId, owner.Token).Code)
+
+	t.Run("1", func(t *testing.T) {
+		rr := leaveChannelTestFunc(private.ID, outsider.Token)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "{\"message\":\"user not found in channel\"}", rr.Body.String())
+	})
+
+	t.Run("2", func(t *testing.T) {
+		channels, err := models.GetChannelsByWorkspaceId(w.ID)
+		assert.Empty(t, err)
+		for _, ch := range channels {
+			if ch.Name == "general" {
+				assert.Equal(t, http.StatusBadRequest, leaveChannelTestFunc(ch.ID, owner.Token).Code)
+			}
+		}
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.False(t, models.IsAdminUserInChannel(private.ID, member.UserId))
+		assert.Equal(t, http.StatusOK, leaveChannelTestFunc(private.ID, owner.Token).Code)
+		assert.False(t, models.IsExistCAUByChannelIdAndUserId(private.ID, owner.UserId))
+		assert.True(t, models.IsAdminUserInChannel(private.ID, member.UserId))
+		assert.Equal(t, http.StatusNotFound, leaveChannelTestFunc(private.ID, owner.Token).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, leaveChannelTestFunc(private.ID, member.Token).Code)
+		ch, err := models.GetChannelById(private.ID)
+		assert.Empty(t, err)
+		assert.True(t, ch.IsArchive)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, leaveChannelTestFunc(public.ID, member.Token).Code)
+		assert.False(t, models.IsExistCAUByChannelIdAndUserId(public.ID, member.UserId))
+		assert.True(t, models.IsAdminUserInChannel(public.ID, owner.UserId))
+	})
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+func GetDefaultChannels(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserがworkspaceに参加しているかを確認
+	isGuest, err := controllerUtils.IsGuestInWorkspace(workspaceId, userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+
+	// guestは参加していないchannelを見られない
+	if isGuest {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	channels, err := controllerUtils.GetDefaultChannels(workspaceId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, channels)
+}
+
+func bindDefaultChannel(c *gin.Context) (models.Channel, bool) {
+	// path parameterのchannelを取得し、requestしたuserがworkspaceの設定を変更できるか確認する
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return models.Channel{}, false
+	}
+	channelId, err := strconv.Atoi(c.Param("channel_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return models.Channel{}, false
+	}
+
+	// requestしたuserがworkspaceの設定を変更できるかチェック
+	b, err := controllerUtils.Authorize(workspaceId, userId, models.CapWorkspaceSettings)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return models.Channel{}, false
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return models.Channel{}, false
+	}
+
+	// channelがworkspaceに存在することを確認
+	ch := models.Channel{ID: channelId, WorkspaceId: workspaceId}
+	if err := ch.GetChannelByIdAndWorkspaceId(); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "channel not found in workspace"})
+		return models.Channel{}, false
+	}
+	return ch, true
+}
+
+func AddDefaultChannel(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	ch, ok := bindDefaultChannel(c)
+	if !ok {
+		return
+	}
+
+	// private channelとarchiveされたchannelは設定できない
+	if !controllerUtils.IsAvailableAsDefaultChannel(ch) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "private or archived channel can't be default"})
+		return
+	}
+
+	// generalと既に設定されているchannelは重複して登録しない
+	b, err := models.IsDefaultChannel(ch.WorkspaceId, ch.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if b || controllerUtils.IsGeneralChannel(ch) {
+		c.JSON(http.StatusConflict, gin.H{"message": "already default channel"})
+		return
+	}
+
+	dc := models.NewDefaultChannel(ch.WorkspaceId, ch.ID, userId)
+	if err := dc.Create().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, dc)
+}
+
+func RemoveDefaultChannel(c *gin.Context) {
+	ch, ok := bindDefaultChannel(c)
+	if !ok {
+		return
+	}
+
+	// generalは常に自動で参加するchannel
+	if controllerUtils.IsGeneralChannel(ch) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "general channel is always default"})
+		return
+	}
+
+	res := models.DeleteDefaultChannel(ch.WorkspaceId, ch.ID)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "default channel not found"})
+		return
+	}
+	c.JSON(http.StatusOK, ch)
+}
+
+func BackfillDefaultChannel(c *gin.Context) {
+	ch, ok := bindDefaultChannel(c)
+	if !ok {
+		return
+	}
+
+	// default channelに設定されているchannelのみ対象にする
+	channels, err := controllerUtils.GetDefaultChannels(ch.WorkspaceId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	isDefault := false
+	for _, dc := range channels {
+		if dc.ID == ch.ID {
+			isDefault = true
+		}
+	}
+	if !isDefault {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "channel is not default channel"})
+		return
+	}
+
+	// workspaceの既存のmemberをchannelに参加させる
+	cnt, err := controllerUtils.BackfillDefaultChannel(ch)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"channel": ch, "joined_count": cnt})
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/models"
+)
+
+func defaultChannelTestFunc(method string, workspaceId, channelId int, jwtToken string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, "/api/workspace/default_channels/"+strconv.Itoa(workspaceId)+"/"+strconv.Itoa(channelId), nil)
+	req.Header.Set("Authorization", jwtToken)
+	workspaceRouter.ServeHTTP(rr, req)
+	return rr
+}
+
+func getDefaultChannelsTestFunc(workspaceId int, jwtToken string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/workspace/default_channels/"+strconv.Itoa(workspaceId), nil)
+	req.Header.Set("Authorization", jwtToken)
+	workspaceRouter.ServeHTTP(rr, req)
+	return rr
+}
+
+func backfillDefaultChannelTestFunc(workspaceId, channelId int, jwtToken string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/workspace/default_channels/"+strconv.Itoa(workspaceId)+"/"+strconv.Itoa(channelId)+"/backfill", nil)
+	req.Header.Set("Authorization", jwtToken)
+	workspaceRouter.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestDefaultChannels(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 権限がない場合 403, private channelの場合 400, generalは既に設定されている 409
+	// 2 正常な場合 200 (generalと設定したchannelが取得できる), 重複して設定した場合 409
+	// 3 workspaceに追加されたuserはgeneralとdefault channelに参加する
+	// 4 backfillで既存のmemberがdefault channelに参加する 200, default channelでない場合 400
+	// 5 generalは解除できない 400, 解除した場合 200, 設定されていない場合 404
+
+	owner := signUpAndLoginTestFunc(t)
+	member := signUpAndLoginTestFunc(t)
+	newMember := signUpAndLoginTestFunc(t)
+
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleFullMember, member.UserId, owner.Token).Code)
+
+	createChannel := func(isPrivate bool) models.Channel {
+		rr := createChannelTestFunc(randomstring.EnglishFrequencyString(30), "", &isPrivate, owner.Token, w.ID)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		ch := new(models.Channel)
+		json.Unmarshal(rr.Body.Bytes(), ch)
+		return *ch
+	}
+	getChannelIds := func(rr *httptest.ResponseRecorder) map[int]bool {
+		channels := make([]models.Channel, 0)
+		json.Unmarshal(rr.Body.Bytes(), &channels)
+		res := make(map[int]bool)
+		for _, ch := range channels {
+			res[ch.ID] = true
+		}
+		return res
+	}
+
+	channels, err := models.GetChannelsByWorkspaceId(w.ID)
+	assert.Empty(t, err)
+	general := channels[0]
+	public := createChannel(false)
+	private := createChannel(true)
+
+	t.Run("1", func(t *testing.T) {
+		assert.True(t, models.IsExistCAUByChannelIdAndUserId(general.ID, member.UserId))
+		assert.Equal(t, http.StatusForbidden, defaultChannelTestFunc("POST", w.ID, public.ID, member.Token).Code)
+		assert.Equal(t, http.StatusBadRequest, defaultChannelTestFunc("POST", w.ID, private.ID, owner.Token).Code)
+		assert.Equal(t, http.StatusConflict, defaultChannelTestFunc("POST", w.ID, general.ID, owner.Token).Code)
+		assert.Equal(t, http.StatusNotFound, defaultChannelTestFunc("POST", w.ID, -1, owner.Token).Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, defaultChannelTestFunc("POST", w.ID, public.ID, owner.Token).Code)
+		assert.Equal(t, http.StatusConflict, defaultChannelTestFunc("POST", w.ID, public.ID, owner.Token).Code)
+
+		rr := getDefaultChannelsTestFunc(w.ID, member.Token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, map[int]bool{general.ID: true, public.ID: true}, getChannelIds(rr))
+		assert.Equal(t, http.StatusNotFound, getDefaultChannelsTestFunc(w.ID, newMember.Token).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleFullMember, newMember.UserId, owner.Token).Code)
+		rr := getChannelsByUserTestFunc(w.ID, newMember.Token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, map[int]bool{general.ID: true, public.ID: true}, getChannelIds(rr))
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.False(t, models.IsExistCAUByChannelIdAndUserId(public.ID, member.UserId))
+		rr := backfillDefaultChannelTestFunc(w.ID, public.ID, owner.Token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), "\"joined_count\":1")
+		assert.True(t, models.IsExistCAUByChannelIdAndUserId(public.ID, member.UserId))
+
+		assert.Equal(t, http.StatusForbidden, backfillDefaultChannelTestFunc(w.ID, public.ID, member.Token).Code)
+		assert.Equal(t, http.StatusBadRequest, backfillDefaultChannelTestFunc(w.ID, private.ID, owner.Token).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, defaultChannelTestFunc("DELETE", w.ID, general.ID, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, defaultChannelTestFunc("DELETE", w.ID, public.ID, owner.Token).Code)
+		assert.Equal(t, http.StatusNotFound, defaultChannelTestFunc("DELETE", w.ID, public.ID, owner.Token).Code)
+
+		rr := getDefaultChannelsTestFunc(w.ID, owner.Token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, map[int]bool{general.ID: true}, getChannelIds(rr))
+	})
+}
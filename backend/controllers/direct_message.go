@@ -13,12 +13,7 @@ import (
 )
 
 func SendDM(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
-		return
-	}
+	userId := CurrentPrincipal(c).UserId
 
 	// bodyの情報を取得
 	in, err := controllerUtils.InputAndValidateSendDM(c)
@@ -38,6 +33,19 @@ func SendDM(c *gin.Context) {
 		return
 	}
 
+	// guestとのDMは同じchannelに参加しているuserのみ
+	for _, ids := range [][2]uint32{{userId, in.ReceiveUserId}, {in.ReceiveUserId, userId}} {
+		b, err := controllerUtils.CanSendDMAsGuest(in.WorkspaceId, ids[0], ids[1])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if !b {
+			c.JSON(http.StatusForbidden, gin.H{"message": "guest can only send DM to channel members"})
+			return
+		}
+	}
+
 	// 2人のuserのdm_line_idを取得する(存在しなければ作成する)
 	dl, err := models.GetDLByUserIdsAndWorkspaceId(userId, in.ReceiveUserId, in.WorkspaceId)
 	if err != nil {
@@ -53,23 +61,25 @@ func SendDM(c *gin.Context) {
 		}
 	}
 
-	// direct_messages tableにデータを保存する
+	// @handleをuser groupのmemberに展開する(DMの相手のみ対象)
+	mentionedUserIds, err := controllerUtils.ExpandUserGroupMentions(in.WorkspaceId, in.Text, userId, []uint32{in.ReceiveUserId})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// direct_messages tableにデータとmentionを保存する
 	dm := models.NewDirectMessage(in.Text, userId, dl.ID)
-	if err := dm.Create().Error; err != nil {
+	if err := dm.CreateWithMentions(mentionedUserIds); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, dm)
+	c.JSON(http.StatusOK, controllerUtils.DMWithMentions{DirectMessage: *dm, MentionedUserIds: mentionedUserIds})
 }
 
 func GetDMsInLine(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
-		return
-	}
+	userId := CurrentPrincipal(c).UserId
 
 	// urlからdm_line_idを取得する
 	dmLineId, err := utils.StringToUint(c.Param("dm_line_id"))
@@ -95,8 +105,14 @@ func GetDMsInLine(c *gin.Context) {
 		return
 	}
 
+	// 削除されたworkspaceや脱退したworkspaceのDMは取得できない
+	if !controllerUtils.IsExistWAUByWorkspaceIdAndUserId(dl.WorkspaceId, userId) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+
 	// direct_messages tableから情報を取得
-	dms, err := models.GetAllDMsByDLId(dl.ID)
+	dms, err := controllerUtils.GetDMsWithMentionsByDLId(dl.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
@@ -106,12 +122,7 @@ func GetDMsInLine(c *gin.Context) {
 }
 
 func EditDM(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
-		return
-	}
+	userId := CurrentPrincipal(c).UserId
 
 	// urlからdm_idを取得
 	dmId, err := utils.StringToUint(c.Param("dm_id"))
@@ -144,24 +155,44 @@ func EditDM(c *gin.Context) {
 		return
 	}
 
-	// direct_messages tableをupdate
-	dm, err := models.UpdateDM(dmId, in.Text)
+	// 変更後のtextの@handleをDMの相手のgroupのmemberに展開し直す
+	dm, err := models.GetDMById(dmId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	dl, err := models.GetDLById(dm.DMLineId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !controllerUtils.IsExistWAUByWorkspaceIdAndUserId(dl.WorkspaceId, userId) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+	receiveUserId := dl.UserId1
+	if receiveUserId == userId {
+		receiveUserId = dl.UserId2
+	}
+	mentionedUserIds, err := controllerUtils.ExpandUserGroupMentions(dl.WorkspaceId, in.Text, userId, []uint32{receiveUserId})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, dm)
-}
-
-func DeleteDM(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
+	// direct_messages tableとmentionをupdate
+	dm, err = models.UpdateDMWithMentions(dmId, in.Text, mentionedUserIds)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
+	c.JSON(http.StatusOK, controllerUtils.DMWithMentions{DirectMessage: dm, MentionedUserIds: mentionedUserIds})
+}
+
+func DeleteDM(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
 	// urlからdm_idを取得
 	dmId, err := utils.StringToUint(c.Param("dm_id"))
 	if err != nil {
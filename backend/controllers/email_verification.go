@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/config"
+	"backend/controllerUtils"
+	"backend/mailer"
+	"backend/models"
+	"backend/utils"
+)
+
+// email確認用のtokenの有効期限
+const emailVerificationLifespan = 24 * time.Hour
+
+func sendVerificationMail(u models.User) error {
+	// 以前に送信したlinkは使えないようにする
+	if err := models.InvalidateEmailVerificationsByUserId(u.ID); err != nil {
+		return err
+	}
+
+	// 確認用のtokenを作成してhash値のみ保存する
+	verificationToken, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return err
+	}
+	ev := models.NewEmailVerification(utils.HashToken(verificationToken), u.ID, u.Email, time.Now().Add(emailVerificationLifespan))
+	if err := ev.Create().Error; err != nil {
+		return err
+	}
+
+	// mailで確認用のlinkを送信
+	link := fmt.Sprintf("%s/verify_email?token=%s", config.Config.FrontendBaseUrl, url.QueryEscape(verificationToken))
+	m := mailer.Mail{
+		To:      u.Email,
+		Subject: "メールアドレスの確認",
+		Body:    fmt.Sprintf("%s さん\n\n以下のリンクからメールアドレスを確認してください。リンクの有効期限は24時間です。\n%s\n\n心当たりがない場合はこのメールを無視してください。\n", u.Name, link),
+	}
+	return mailer.Send(m)
+}
+
+func VerifyEmail(c *gin.Context) {
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateVerifyEmail(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// tokenが有効か確認
+	ev, err := models.GetEmailVerificationByTokenHash(utils.HashToken(in.Token))
+	if err != nil || !ev.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid or expired token"})
+		return
+	}
+
+	// tokenを使用済みにする
+	ok, err := ev.Use()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid or expired token"})
+		return
+	}
+
+	// 送信した後にemailが変更されている場合は確認済みにしない
+	u, err := models.GetUserById(ev.UserId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid or expired token"})
+		return
+	}
+	if !strings.EqualFold(u.Email, ev.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid or expired token"})
+		return
+	}
+	if !u.IsEmailVerified() {
+		if err := u.VerifyEmail(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+func ResendVerificationEmail(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	u, err := models.GetUserById(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if u.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "email is not registered"})
+		return
+	}
+	if u.IsEmailVerified() {
+		c.JSON(http.StatusConflict, gin.H{"message": "email is already verified"})
+		return
+	}
+
+	if err := sendVerificationMail(u); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "verification email sent"})
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/controllerUtils"
+	"backend/mailer"
+	"backend/models"
+)
+
+func verifyEmailTestFunc(verificationToken string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(controllerUtils.VerifyEmailInput{Token: verificationToken})
+	req, _ := http.NewRequest("POST", "/api/user/email/verify", bytes.NewBuffer(jsonInput))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func resendVerificationEmailTestFunc(jwtToken string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/user/email/resend", nil)
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestEmailVerification(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 emailを指定してsignUpすると確認用のmailが送信され、確認前はworkspaceに追加できない 403
+	// 2 確認用のtokenで確認するとworkspaceに追加できる 200
+	// 3 使用済みのtoken, 存在しないtokenの場合 400
+	// 4 再送すると以前のtokenは使えなくなる 200
+	// 5 確認済みの場合の再送 409, emailがない場合の再送 400
+	// 6 確認済みのemailを必須にしたworkspaceにはemailがないuserを追加できない 403
+
+	m := mailer.NewMemoryMailer()
+	defaultMailer := mailer.DefaultMailer
+	mailer.DefaultMailer = m
+	defer func() { mailer.DefaultMailer = defaultMailer }()
+
+	// workspaceを作成するuser
+	ownerName := randomstring.EnglishFrequencyString(30)
+	assert.Equal(t, http.StatusOK, signUpTestFunc(ownerName, "pass").Code)
+	owner := new(LoginResponse)
+	json.Unmarshal(loginTestFunc(ownerName, "pass").Body.Bytes(), owner)
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+
+	name := randomstring.EnglishFrequencyString(30)
+	email := randomstring.EnglishFrequencyString(20) + "@example.com"
+	var verificationToken string
+	u := new(models.User)
+
+	t.Run("1", func(t *testing.T) {
+		rr := signUpWithEmailTestFunc(name, email, "pass")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		json.Unmarshal(rr.Body.Bytes(), u)
+		assert.Nil(t, u.EmailVerifiedAt)
+		verificationToken = resetTokenFromMail(t, m, email)
+
+		assert.Equal(t, http.StatusForbidden, addUserWorkspaceTestFunc(w.ID, 4, u.ID, owner.Token).Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, verifyEmailTestFunc(verificationToken).Code)
+		res, err := models.GetUserById(u.ID)
+		assert.Empty(t, err)
+		assert.True(t, res.IsEmailVerified())
+
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, 4, u.ID, owner.Token).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, verifyEmailTestFunc(verificationToken).Code)
+		assert.Equal(t, http.StatusBadRequest, verifyEmailTestFunc("wrong token").Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		email := randomstring.EnglishFrequencyString(20) + "@example.com"
+		assert.Equal(t, http.StatusOK, signUpWithEmailTestFunc(name, email, "pass").Code)
+		oldToken := resetTokenFromMail(t, m, email)
+		lr := new(LoginResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr)
+
+		assert.Equal(t, http.StatusOK, resendVerificationEmailTestFunc(lr.Token).Code)
+		newToken := resetTokenFromMail(t, m, email)
+		assert.NotEqual(t, oldToken, newToken)
+		assert.Equal(t, http.StatusBadRequest, verifyEmailTestFunc(oldToken).Code)
+		assert.Equal(t, http.StatusOK, verifyEmailTestFunc(newToken).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		lr := new(LoginResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr)
+		assert.Equal(t, http.StatusConflict, resendVerificationEmailTestFunc(lr.Token).Code)
+
+		assert.Equal(t, http.StatusBadRequest, resendVerificationEmailTestFunc(owner.Token).Code)
+	})
+
+	t.Run("6", func(t *testing.T) {
+		noEmailName := randomstring.EnglishFrequencyString(30)
+		rr := signUpTestFunc(noEmailName, "pass")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		noEmail := new(models.User)
+		json.Unmarshal(rr.Body.Bytes(), noEmail)
+
+		required := true
+		rr = updateWorkspaceSettingTestFunc(w.ID, owner.Token, controllerUtils.UpdateWorkspaceSettingInput{RequireVerifiedEmail: &required})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, http.StatusForbidden, addUserWorkspaceTestFunc(w.ID, 4, noEmail.ID, owner.Token).Code)
+
+		required = false
+		rr = updateWorkspaceSettingTestFunc(w.ID, owner.Token, controllerUtils.UpdateWorkspaceSettingInput{RequireVerifiedEmail: &required})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, 4, noEmail.ID, owner.Token).Code)
+	})
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/token"
+)
+
+func GetJWKS(c *gin.Context) {
+	// 他のserviceがtokenを検証できるように公開鍵を返す
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, token.DefaultKeyring.JWKS())
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"backend/config"
+	"backend/token"
+)
+
+func getJWKSTestFunc() *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestJWKS(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 HS256の鍵のみの場合は空のJWKS 200
+	// 2 RS256の鍵にrotateした後も以前のtokenを使え、新しいtokenの公開鍵がJWKSに含まれる 200
+
+	// 他のtestに影響しないようにkeyringを差し替える
+	defaultKeyring := token.DefaultKeyring
+	defer func() { token.DefaultKeyring = defaultKeyring }()
+	token.DefaultKeyring = token.NewKeyring(time.Hour)
+	token.DefaultKeyring.AddKey(token.NewHMACKey("default", []byte(config.Config.SecretKey)))
+
+	t.Run("1", func(t *testing.T) {
+		rr := getJWKSTestFunc()
+		assert.Equal(t, http.StatusOK, rr.Code)
+		set := new(token.JWKSet)
+		json.Unmarshal(rr.Body.Bytes(), set)
+		assert.Equal(t, 0, len(set.Keys))
+	})
+
+	t.Run("2", func(t *testing.T) {
+		lr := signUpAndLogin(t)
+
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.Empty(t, err)
+		token.DefaultKeyring.Rotate(token.NewRSAKey("rsa-1", key))
+		assert.Equal(t, http.StatusOK, currentUserTestFunc(lr.Token).Code)
+
+		rr := loginTestFunc(lr.Username, "pass")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		newLr := new(LoginResponse)
+		json.Unmarshal(rr.Body.Bytes(), newLr)
+		assert.Equal(t, http.StatusOK, currentUserTestFunc(newLr.Token).Code)
+
+		rr = getJWKSTestFunc()
+		assert.Equal(t, http.StatusOK, rr.Code)
+		set := new(token.JWKSet)
+		json.Unmarshal(rr.Body.Bytes(), set)
+		assert.Equal(t, 1, len(set.Keys))
+		assert.Equal(t, "rsa-1", set.Keys[0].Kid)
+		assert.Equal(t, "RS256", set.Keys[0].Alg)
+	})
+}
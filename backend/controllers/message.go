@@ -11,12 +11,7 @@ import (
 )
 
 func SendMessage(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
-		return
-	}
+	userId := CurrentPrincipal(c).UserId
 
 	// bodyの情報を取得
 	in, err := controllerUtils.InputAndValidateSendMessage(c)
@@ -40,23 +35,53 @@ func SendMessage(c *gin.Context) {
 		return
 	}
 
-	// message情報をDBに登録
-	if err := m.Create(); err != nil {
+	ch, err := models.GetChannelById(m.ChannelId)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, m)
-}
+	// workspaceの設定で@channelが禁止されていないか確認
+	if controllerUtils.ContainsChannelMention(m.Text) {
+		b, err := controllerUtils.CanChannelMention(ch.WorkspaceId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if !b {
+			c.JSON(http.StatusForbidden, gin.H{"message": "@channel is not allowed in this workspace"})
+			return
+		}
+	}
 
-func GetAllMessagesFromChannel(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
+	// @handleをchannelに参加しているuser groupのmemberに展開する
+	caus, err := models.GetCAUsByChannelId(ch.ID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	memberIds := make([]uint32, 0, len(caus))
+	for _, cau := range caus {
+		memberIds = append(memberIds, cau.UserId)
+	}
+	mentionedUserIds, err := controllerUtils.ExpandUserGroupMentions(ch.WorkspaceId, m.Text, userId, memberIds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
+	// message情報とmentionをDBに登録
+	if err := m.CreateWithMentions(mentionedUserIds); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, controllerUtils.MessageWithMentions{Message: *m, MentionedUserIds: mentionedUserIds})
+}
+
+func GetAllMessagesFromChannel(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
 	// path parameterからchannel_idを取得する
 	channelId, err := strconv.Atoi(c.Param("channel_id"))
 	if err != nil {
@@ -64,6 +89,12 @@ func GetAllMessagesFromChannel(c *gin.Context) {
 		return
 	}
 
+	// 削除されたworkspaceや脱退したworkspaceのchannelは取得できない
+	if b, err := controllerUtils.IsExistChannelAndUserInSameWorkspace(channelId, userId); !b || err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in channel"})
+		return
+	}
+
 	// channelにuserが所属していることを確認
 	if b, err := controllerUtils.IsExistCAUByChannelIdAndUserId(channelId, userId); !b || err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in channel"})
@@ -71,7 +102,7 @@ func GetAllMessagesFromChannel(c *gin.Context) {
 	}
 
 	// DBからデータを取得
-	messages, err := models.GetMessagesByChannelId(channelId)
+	messages, err := controllerUtils.GetMessagesWithMentionsByChannelId(channelId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/config"
+	"backend/controllerUtils"
+	"backend/mailer"
+	"backend/models"
+	"backend/utils"
+)
+
+// password再設定用のtokenの有効期限
+const passwordResetLifespan = time.Hour
+
+func ChangePassword(c *gin.Context) {
+	principal := CurrentPrincipal(c)
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateChangePassword(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// 現在のpasswordを確認
+	u, err := models.GetUserById(principal.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !utils.CheckPassword(u.PassWord, in.CurrentPassword) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "wrong password"})
+		return
+	}
+
+	// passwordを更新
+	if err := u.UpdatePassword(in.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestに使われたsession以外はloginし直してもらう
+	if err := models.RevokeOtherSessionsByUserId(u.ID, principal.SessionId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if err := models.InvalidatePasswordResetsByUserId(u.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+}
+
+func SetPassword(c *gin.Context) {
+	principal := CurrentPrincipal(c)
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateSetPassword(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// SSOで作成されたuserのみ, 現在のpasswordなしでpasswordを設定できる
+	u, err := models.GetUserById(principal.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	b, err := u.HasPassword()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if b {
+		c.JSON(http.StatusConflict, gin.H{"message": "password is already set"})
+		return
+	}
+
+	if err := u.UpdatePassword(in.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "password set"})
+}
+
+func ForgotPassword(c *gin.Context) {
+	// userが存在するかどうかは返さない
+	res := gin.H{"message": "if the email is registered, a reset link has been sent"}
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateForgotPassword(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	u, err := models.GetUserByEmail(in.Email)
+	if err != nil {
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	// 再設定用のtokenを作成してhash値のみ保存する
+	resetToken, err := utils.GenerateRandomString(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	pr := models.NewPasswordReset(utils.HashToken(resetToken), u.ID, time.Now().Add(passwordResetLifespan))
+	if err := pr.Create().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// mailで再設定用のlinkを送信
+	link := fmt.Sprintf("%s/reset_password?token=%s", config.Config.FrontendBaseUrl, url.QueryEscape(resetToken))
+	m := mailer.Mail{
+		To:      u.Email,
+		Subject: "パスワードの再設定",
+		Body:    fmt.Sprintf("%s さん\n\n以下のリンクからパスワードを再設定してください。リンクの有効期限は1時間です。\n%s\n\n心当たりがない場合はこのメールを無視してください。\n", u.Name, link),
+	}
+	if err := mailer.Send(m); err != nil {
+		fmt.Println(err)
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func ResetPassword(c *gin.Context) {
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateResetPassword(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// tokenが有効か確認
+	pr, err := models.GetPasswordResetByTokenHash(utils.HashToken(in.Token))
+	if err != nil || !pr.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid or expired token"})
+		return
+	}
+
+	// tokenを使用済みにする
+	ok, err := pr.Use()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid or expired token"})
+		return
+	}
+
+	// passwordを更新
+	u, err := models.GetUserById(pr.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if err := u.UpdatePassword(in.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// すべてのsessionと他の再設定用tokenを無効にする
+	if err := models.RevokeSessionsByUserId(u.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if err := models.InvalidatePasswordResetsByUserId(u.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset"})
+}
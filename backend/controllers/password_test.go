@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/controllerUtils"
+	"backend/mailer"
+)
+
+func passwordTestFunc(method, path, jwtToken string, input interface{}) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest(method, "/api/user/password"+path, bytes.NewBuffer(jsonInput))
+	if jwtToken != "" {
+		req.Header.Set("Authorization", jwtToken)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// 送信されたmailの本文から再設定用のtokenを取り出す
+func resetTokenFromMail(t *testing.T, m *mailer.MemoryMailer, email string) string {
+	mail, ok := m.LastMailTo(email)
+	assert.True(t, ok)
+	i := strings.Index(mail.Body, "token=")
+	assert.NotEqual(t, -1, i)
+	rest := mail.Body[i+len("token="):]
+	if j := strings.IndexAny(rest, "\n "); j != -1 {
+		rest = rest[:j]
+	}
+	resetToken, err := url.QueryUnescape(rest)
+	assert.NoError(t, err)
+	return resetToken
+}
+
+func TestChangePassword(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 正常な場合 200 (他のsessionは無効になる)
+	// 2 現在のpasswordが間違っている場合 403
+	// 3 new_passwordが空の場合 400
+
+	name := randomstring.EnglishFrequencyString(30)
+	assert.Equal(t, http.StatusOK, signUpTestFunc(name, "pass").Code)
+	lr := new(LoginResponse)
+	json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr)
+	other := new(LoginResponse)
+	json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), other)
+
+	t.Run("1", func(t *testing.T) {
+		input := controllerUtils.ChangePasswordInput{CurrentPassword: "pass", NewPassword: "newpass"}
+		rr := passwordTestFunc("PATCH", "", lr.Token, input)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		assert.Equal(t, http.StatusOK, currentUserTestFunc(lr.Token).Code)
+		assert.Equal(t, http.StatusUnauthorized, currentUserTestFunc(other.Token).Code)
+		assert.NotEqual(t, http.StatusOK, loginTestFunc(name, "pass").Code)
+		assert.Equal(t, http.StatusOK, loginTestFunc(name, "newpass").Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		input := controllerUtils.ChangePasswordInput{CurrentPassword: "wrong", NewPassword: "newpass2"}
+		rr := passwordTestFunc("PATCH", "", lr.Token, input)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		input := controllerUtils.ChangePasswordInput{CurrentPassword: "newpass"}
+		rr := passwordTestFunc("PATCH", "", lr.Token, input)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestForgotAndResetPassword(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 正常な場合 200 (すべてのsessionが無効になる)
+	// 2 使用済みのtokenの場合 400
+	// 3 存在しないemailの場合も 200 でmailは送信されない
+	// 4 存在しないtokenの場合 400
+	// 5 passwordを変更した後に発行済みのtokenを使った場合 400
+
+	m := mailer.NewMemoryMailer()
+	defaultMailer := mailer.DefaultMailer
+	mailer.DefaultMailer = m
+	defer func() { mailer.DefaultMailer = defaultMailer }()
+
+	name := randomstring.EnglishFrequencyString(30)
+	email := randomstring.EnglishFrequencyString(20) + "@example.com"
+	assert.Equal(t, http.StatusOK, signUpWithEmailTestFunc(name, email, "pass").Code)
+	lr := new(LoginResponse)
+	json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr)
+
+	var resetToken string
+
+	t.Run("1", func(t *testing.T) {
+		rr := passwordTestFunc("POST", "/forgot", "", controllerUtils.ForgotPasswordInput{Email: email})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		resetToken = resetTokenFromMail(t, m, email)
+
+		input := controllerUtils.ResetPasswordInput{Token: resetToken, NewPassword: "newpass"}
+		rr = passwordTestFunc("POST", "/reset", "", input)
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, currentUserTestFunc(lr.Token).Code)
+		assert.NotEqual(t, http.StatusOK, loginTestFunc(name, "pass").Code)
+		assert.Equal(t, http.StatusOK, loginTestFunc(name, "newpass").Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		input := controllerUtils.ResetPasswordInput{Token: resetToken, NewPassword: "again"}
+		rr := passwordTestFunc("POST", "/reset", "", input)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		unknown := randomstring.EnglishFrequencyString(20) + "@example.com"
+		rr := passwordTestFunc("POST", "/forgot", "", controllerUtils.ForgotPasswordInput{Email: unknown})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		_, ok := m.LastMailTo(unknown)
+		assert.False(t, ok)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		input := controllerUtils.ResetPasswordInput{Token: "wrong token", NewPassword: "newpass"}
+		rr := passwordTestFunc("POST", "/reset", "", input)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		passwordTestFunc("POST", "/forgot", "", controllerUtils.ForgotPasswordInput{Email: email})
+		oldToken := resetTokenFromMail(t, m, email)
+		input := controllerUtils.ChangePasswordInput{CurrentPassword: "newpass", NewPassword: "changed"}
+		nl := new(LoginResponse)
+		json.Unmarshal(loginTestFunc(name, "newpass").Body.Bytes(), nl)
+		assert.Equal(t, http.StatusOK, passwordTestFunc("PATCH", "", nl.Token, input).Code)
+
+		rr := passwordTestFunc("POST", "/reset", "", controllerUtils.ResetPasswordInput{Token: oldToken, NewPassword: "x"})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+func Heartbeat(c *gin.Context) {
+	// 最終操作時刻はAuthMiddlewareで更新されるので自分のpresenceを返すだけ
+	userId := CurrentPrincipal(c).UserId
+
+	res, err := controllerUtils.GetPresencesByUserIds([]uint32{userId})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res[0])
+}
+
+func SetPresence(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateSetPresence(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err := models.SetManualAway(userId, in.Presence == "away"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	res, err := controllerUtils.GetPresencesByUserIds([]uint32{userId})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res[0])
+}
+
+func GetPresences(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// queryの情報を取得
+	userIds, err := controllerUtils.InputAndValidateQueryPresence(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// 同じworkspaceに所属していないuserのpresenceは返さない
+	visible, err := controllerUtils.GetVisibleUserIds(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	ids := make([]uint32, 0, len(userIds))
+	for _, id := range userIds {
+		if visible[id] {
+			ids = append(ids, id)
+		}
+	}
+
+	res, err := controllerUtils.GetPresencesByUserIds(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func SetStatus(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateSetStatus(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err := models.SetCustomStatus(userId, in.Emoji, in.Text, in.ExpiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	res, err := controllerUtils.GetPresencesByUserIds([]uint32{userId})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res[0])
+}
+
+func ClearStatus(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	if err := models.ClearCustomStatus(userId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "status cleared"})
+}
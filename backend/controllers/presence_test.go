@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+func presenceTestFunc(method, path, jwtToken string, input interface{}) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest(method, "/api/user"+path, bytes.NewBuffer(jsonInput))
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func getPresencesTestFunc(jwtToken string, userIds ...uint32) []controllerUtils.UserPresence {
+	q := ""
+	for i, id := range userIds {
+		if i > 0 {
+			q += ","
+		}
+		q += fmt.Sprint(id)
+	}
+	rr := presenceTestFunc("GET", "/presence?user_ids="+q, jwtToken, nil)
+	res := make([]controllerUtils.UserPresence, 0)
+	json.Unmarshal(rr.Body.Bytes(), &res)
+	return res
+}
+
+func TestPresence(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 APIにアクセスするとactive, 手動でawayにできる 200
+	// 2 同じworkspaceにいないuserのpresenceは返さない
+	// 3 user_idsが不正な場合 400
+	// 4 presenceが不正な場合 400
+
+	lr := signUpAndLogin(t)
+	member := signUpAndLogin(t)
+	stranger := signUpAndLogin(t)
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), lr.Token, lr.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, 4, member.UserId, lr.Token).Code)
+
+	t.Run("1", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, presenceTestFunc("POST", "/presence/heartbeat", member.Token, nil).Code)
+		res := getPresencesTestFunc(lr.Token, member.UserId)
+		assert.Equal(t, 1, len(res))
+		assert.Equal(t, models.PresenceActive, res[0].Presence)
+
+		rr := presenceTestFunc("PUT", "/presence", member.Token, controllerUtils.SetPresenceInput{Presence: "away"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		res = getPresencesTestFunc(lr.Token, member.UserId)
+		assert.Equal(t, models.PresenceAway, res[0].Presence)
+
+		rr = presenceTestFunc("PUT", "/presence", member.Token, controllerUtils.SetPresenceInput{Presence: "auto"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		res = getPresencesTestFunc(lr.Token, member.UserId)
+		assert.Equal(t, models.PresenceActive, res[0].Presence)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		res := getPresencesTestFunc(lr.Token, lr.UserId, stranger.UserId)
+		assert.Equal(t, 1, len(res))
+		assert.Equal(t, lr.UserId, res[0].UserId)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, presenceTestFunc("GET", "/presence?user_ids=a,b", lr.Token, nil).Code)
+		assert.Equal(t, http.StatusBadRequest, presenceTestFunc("GET", "/presence", lr.Token, nil).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		rr := presenceTestFunc("PUT", "/presence", lr.Token, controllerUtils.SetPresenceInput{Presence: "busy"})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestCustomStatus(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 statusを設定するとmember一覧に表示される 200
+	// 2 statusを削除できる 200
+	// 3 emojiとtextがどちらもない場合 400
+	// 4 expires_atが過去の場合 400
+
+	lr := signUpAndLogin(t)
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), lr.Token, lr.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+
+	t.Run("1", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour)
+		input := controllerUtils.SetStatusInput{Emoji: ":spiral_calendar_pad:", Text: "in a meeting", ExpiresAt: &expiresAt}
+		assert.Equal(t, http.StatusOK, presenceTestFunc("PUT", "/status", lr.Token, input).Code)
+
+		rr := GetUsersInWorkspaceTestFunc(w.ID, lr.Token)
+		res := make([]controllerUtils.UserInfoInWorkspace, 0)
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		assert.Equal(t, 1, len(res))
+		assert.Equal(t, "in a meeting", res[0].StatusText)
+		assert.Equal(t, ":spiral_calendar_pad:", res[0].StatusEmoji)
+		assert.Equal(t, models.PresenceActive, res[0].Presence)
+		assert.NotNil(t, res[0].StatusExpiresAt)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, presenceTestFunc("DELETE", "/status", lr.Token, nil).Code)
+		res := getPresencesTestFunc(lr.Token, lr.UserId)
+		assert.Equal(t, "", res[0].StatusText)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		rr := presenceTestFunc("PUT", "/status", lr.Token, controllerUtils.SetStatusInput{})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		expiresAt := time.Now().Add(-time.Hour)
+		input := controllerUtils.SetStatusInput{Text: "old", ExpiresAt: &expiresAt}
+		rr := presenceTestFunc("PUT", "/status", lr.Token, input)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
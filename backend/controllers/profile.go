@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+func GetProfile(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	p, err := models.GetProfileByUserId(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func UpdateProfile(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateUpdateProfile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	p, err := models.GetProfileByUserId(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// 指定された項目のみ更新する
+	fields := []struct {
+		value *string
+		dest  *string
+	}{
+		{in.DisplayName, &p.DisplayName},
+		{in.RealName, &p.RealName},
+		{in.Title, &p.Title},
+		{in.Phone, &p.Phone},
+		{in.Timezone, &p.Timezone},
+		{in.AvatarUrl, &p.AvatarUrl},
+		{in.Pronouns, &p.Pronouns},
+	}
+	for _, f := range fields {
+		if f.value != nil {
+			*f.dest = *f.value
+		}
+	}
+
+	if err := p.Save().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func UpdateWorkspaceProfile(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateUpdateWorkspaceProfile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserがworkspaceに存在しているか確認
+	if !controllerUtils.IsExistWAUByWorkspaceIdAndUserId(workspaceId, userId) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+
+	// 空文字の場合はworkspaceでの表示名を削除する
+	if in.DisplayName == "" {
+		if err := models.DeleteWorkspaceProfile(workspaceId, userId); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, models.NewWorkspaceProfile(workspaceId, userId, ""))
+		return
+	}
+
+	wp := models.NewWorkspaceProfile(workspaceId, userId, in.DisplayName)
+	if err := wp.Save().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, wp)
+}
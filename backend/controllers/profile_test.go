@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+func updateProfileTestFunc(jwtToken string, input interface{}) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest("PATCH", "/api/user/profile", bytes.NewBuffer(jsonInput))
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func updateWorkspaceProfileTestFunc(workspaceId int, jwtToken string, input controllerUtils.UpdateWorkspaceProfileInput) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest("PATCH", "/api/workspace/profile/"+strconv.Itoa(workspaceId), bytes.NewBuffer(jsonInput))
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+// userを作成してloginする
+func signUpAndLogin(t *testing.T) *LoginResponse {
+	name := randomstring.EnglishFrequencyString(30)
+	assert.Equal(t, http.StatusOK, signUpTestFunc(name, "pass").Code)
+	lr := new(LoginResponse)
+	json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr)
+	return lr
+}
+
+func TestUpdateProfile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 正常な場合 200 (指定しなかった項目は変更されない)
+	// 2 timezoneが不正な場合 400
+	// 3 avatar_urlが不正な場合 400
+	// 4 phoneが不正な場合 400
+	// 5 長すぎる場合 400
+
+	lr := signUpAndLogin(t)
+
+	t.Run("1", func(t *testing.T) {
+		rr := updateProfileTestFunc(lr.Token, map[string]string{
+			"display_name": "taro",
+			"title":        "engineer",
+			"timezone":     "Asia/Tokyo",
+			"avatar_url":   "https://example.com/avatar.png",
+			"phone":        "+81 90-0000-0000",
+		})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		rr = updateProfileTestFunc(lr.Token, map[string]string{"pronouns": "he/him", "title": ""})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		rr = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/user/profile", nil)
+		req.Header.Set("Authorization", lr.Token)
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		p := new(models.Profile)
+		json.Unmarshal(rr.Body.Bytes(), p)
+		assert.Equal(t, "taro", p.DisplayName)
+		assert.Equal(t, "", p.Title)
+		assert.Equal(t, "Asia/Tokyo", p.Timezone)
+		assert.Equal(t, "https://example.com/avatar.png", p.AvatarUrl)
+		assert.Equal(t, "he/him", p.Pronouns)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		rr := updateProfileTestFunc(lr.Token, map[string]string{"timezone": "Mars/Olympus"})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		rr := updateProfileTestFunc(lr.Token, map[string]string{"avatar_url": "javascript:alert(1)"})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		rr := updateProfileTestFunc(lr.Token, map[string]string{"phone": "call me"})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		rr := updateProfileTestFunc(lr.Token, map[string]string{"display_name": randomstring.EnglishFrequencyString(81)})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestUpdateWorkspaceProfile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 workspaceでの表示名がmember一覧に反映される 200
+	// 2 空文字で上書きを解除する 200
+	// 3 workspaceにいないuserの場合 404
+
+	lr := signUpAndLogin(t)
+	other := signUpAndLogin(t)
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), lr.Token, lr.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+	assert.Equal(t, http.StatusOK, updateProfileTestFunc(lr.Token, map[string]string{"display_name": "global", "title": "boss"}).Code)
+
+	findSelf := func() controllerUtils.UserInfoInWorkspace {
+		rr := GetUsersInWorkspaceTestFunc(w.ID, lr.Token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		res := make([]controllerUtils.UserInfoInWorkspace, 0)
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		for _, ui := range res {
+			if ui.ID == lr.UserId {
+				return ui
+			}
+		}
+		return controllerUtils.UserInfoInWorkspace{}
+	}
+
+	t.Run("1", func(t *testing.T) {
+		assert.Equal(t, "global", findSelf().DisplayName)
+		rr := updateWorkspaceProfileTestFunc(w.ID, lr.Token, controllerUtils.UpdateWorkspaceProfileInput{DisplayName: "local"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		ui := findSelf()
+		assert.Equal(t, "local", ui.DisplayName)
+		assert.Equal(t, "boss", ui.Title)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		rr := updateWorkspaceProfileTestFunc(w.ID, lr.Token, controllerUtils.UpdateWorkspaceProfileInput{DisplayName: ""})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "global", findSelf().DisplayName)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		rr := updateWorkspaceProfileTestFunc(w.ID, other.Token, controllerUtils.UpdateWorkspaceProfileInput{DisplayName: "x"})
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/config"
+	"backend/ratelimit"
+)
+
+// config.iniの[ratelimit]で上書きできるbucketごとの制限
+var defaultRateLimits = map[string]string{
+	// 認証が不要なrouteへのIPごとの制限
+	"auth": "20/1m",
+	// 認証が必要なrouteへのuserごとの制限
+	"api": "300/1m",
+	// message, DMの送信のuserごとの制限
+	"message": "30/1m",
+	// loginの失敗によるaccountのロック (失敗が増えるほど長くロックする)
+	"login_failure": "5/15m,10/1h,20/24h",
+}
+
+var loginLockout = ratelimit.Lockout{Name: "login", Rules: rateLimitRules("login_failure")}
+
+func rateLimitRules(bucket string) []ratelimit.Rule {
+	s, ok := config.Config.RateLimits[bucket]
+	if !ok {
+		s = defaultRateLimits[bucket]
+	}
+	rules, err := ratelimit.ParseRules(s)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+func abortTooManyRequests(c *gin.Context, retryAfter time.Duration, message string) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"message": message})
+}
+
+func rateLimit(bucket string, subject func(c *gin.Context) string) gin.HandlerFunc {
+	rules := rateLimitRules(bucket)
+	return func(c *gin.Context) {
+		// bucket, route, IPまたはuserごとに制限する
+		key := fmt.Sprintf("%s:%s:%s", bucket, c.FullPath(), subject(c))
+		for i, rule := range rules {
+			res, err := ratelimit.DefaultLimiter.Allow(fmt.Sprintf("%s:%d", key, i), rule)
+			if err != nil {
+				// limiterに障害があってもrequestは受け付ける
+				fmt.Println(err)
+				continue
+			}
+			if !res.Allowed {
+				abortTooManyRequests(c, res.RetryAfter, "too many requests")
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func RateLimitByIP(bucket string) gin.HandlerFunc {
+	return rateLimit(bucket, func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	})
+}
+
+func RateLimitByUser(bucket string) gin.HandlerFunc {
+	// AuthMiddlewareの後で使う
+	return rateLimit(bucket, func(c *gin.Context) string {
+		return fmt.Sprintf("user:%d", CurrentPrincipal(c).UserId)
+	})
+}
+
+func isLockedOut(c *gin.Context, l ratelimit.Lockout, id string) bool {
+	// ロックされている場合は429を返す
+	retryAfter, err := l.Check(id)
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+	if retryAfter > 0 {
+		abortTooManyRequests(c, retryAfter, "too many failed attempts")
+		return true
+	}
+	return false
+}
+
+func recordFailure(l ratelimit.Lockout, id string) {
+	if err := l.Fail(id); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func resetFailures(l ratelimit.Lockout, id string) {
+	if err := l.Reset(id); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/controllerUtils"
+	"backend/ratelimit"
+)
+
+func init() {
+	// 他のtestは同じIPから大量にrequestを送るので、rate limitのtest以外では制限しない
+	ratelimit.DefaultLimiter = ratelimit.NewNopLimiter()
+}
+
+func refreshFromIPTestFunc(ip string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(controllerUtils.RefreshTokenInput{RefreshToken: "wrong.token"})
+	req, _ := http.NewRequest("POST", "/api/user/refresh", bytes.NewBuffer(jsonInput))
+	req.RemoteAddr = ip + ":12345"
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func loginFromIPTestFunc(input controllerUtils.SignUpAndLoginInput, ip string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest("POST", "/api/user/login", bytes.NewBuffer(jsonInput))
+	req.RemoteAddr = ip + ":12345"
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func assertTooManyRequests(t *testing.T, rr *httptest.ResponseRecorder) {
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	retryAfter, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+	assert.Empty(t, err)
+	assert.Greater(t, retryAfter, 0)
+}
+
+func TestRateLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 loginに続けて失敗するとaccountがロックされる 429
+	// 2 loginに成功すると失敗の回数がリセットされる
+	// 3 認証が不要なrouteはIPごとに制限される 429
+	// 4 messageの送信はuserごとに制限される 429
+	// 5 2段階認証のcodeに続けて失敗するとロックされる 429
+	// 6 accountの再有効化もloginと失敗の回数を共有してロックされる 429
+	// 7 usernameとemailは同じaccountとして数える 429, 他のIPからはloginできる 200
+
+	// subtestごとに新しいlimiterを使う
+	defaultLimiter := ratelimit.DefaultLimiter
+	defer func() { ratelimit.DefaultLimiter = defaultLimiter }()
+
+	t.Run("1", func(t *testing.T) {
+		ratelimit.DefaultLimiter = ratelimit.NewMemoryLimiter()
+		lr := signUpAndLogin(t)
+		other := signUpAndLogin(t)
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, http.StatusUnauthorized, loginTestFunc(lr.Username, "wrong").Code)
+		}
+		assertTooManyRequests(t, loginTestFunc(lr.Username, "pass"))
+		assert.Equal(t, http.StatusOK, loginTestFunc(other.Username, "pass").Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		ratelimit.DefaultLimiter = ratelimit.NewMemoryLimiter()
+		lr := signUpAndLogin(t)
+		for i := 0; i < 4; i++ {
+			assert.Equal(t, http.StatusUnauthorized, loginTestFunc(lr.Username, "wrong").Code)
+		}
+		assert.Equal(t, http.StatusOK, loginTestFunc(lr.Username, "pass").Code)
+		for i := 0; i < 4; i++ {
+			assert.Equal(t, http.StatusUnauthorized, loginTestFunc(lr.Username, "wrong").Code)
+		}
+		assert.Equal(t, http.StatusOK, loginTestFunc(lr.Username, "pass").Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		ratelimit.DefaultLimiter = ratelimit.NewMemoryLimiter()
+		for i := 0; i < 20; i++ {
+			assert.Equal(t, http.StatusUnauthorized, refreshFromIPTestFunc("203.0.113.1").Code)
+		}
+		assertTooManyRequests(t, refreshFromIPTestFunc("203.0.113.1"))
+		assert.Equal(t, http.StatusUnauthorized, refreshFromIPTestFunc("203.0.113.2").Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		ratelimit.DefaultLimiter = ratelimit.NewMemoryLimiter()
+		lr := signUpAndLogin(t)
+		_, channelId := createWorkspaceWithGeneral(t, lr)
+		for i := 0; i < 30; i++ {
+			assert.Equal(t, http.StatusOK, sendMessageTestFunc("message", channelId, lr.Token).Code)
+		}
+		assertTooManyRequests(t, sendMessageTestFunc("message", channelId, lr.Token))
+
+		other := signUpAndLogin(t)
+		_, otherChannelId := createWorkspaceWithGeneral(t, other)
+		assert.Equal(t, http.StatusOK, sendMessageTestFunc("message", otherChannelId, other.Token).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		ratelimit.DefaultLimiter = ratelimit.NewMemoryLimiter()
+		name := randomstring.EnglishFrequencyString(30)
+		createTwoFactorUser(t, name)
+		res := new(TwoFactorLoginResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), res)
+		for i := 0; i < 5; i++ {
+			rr := loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{ChallengeToken: res.ChallengeToken, RecoveryCode: "wrong-code"})
+			assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		}
+		assertTooManyRequests(t, loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{ChallengeToken: res.ChallengeToken, RecoveryCode: "wrong-code"}))
+	})
+
+	t.Run("6", func(t *testing.T) {
+		ratelimit.DefaultLimiter = ratelimit.NewMemoryLimiter()
+		lr := signUpAndLogin(t)
+		input := controllerUtils.SignUpAndLoginInput{Name: lr.Username, Password: "wrong"}
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusUnauthorized, accountTestFunc("POST", "/reactivate", "", input).Code)
+		}
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusUnauthorized, loginTestFunc(lr.Username, "wrong").Code)
+		}
+		input.Password = "pass"
+		assertTooManyRequests(t, accountTestFunc("POST", "/reactivate", "", input))
+		assertTooManyRequests(t, loginTestFunc(lr.Username, "pass"))
+	})
+
+	t.Run("7", func(t *testing.T) {
+		ratelimit.DefaultLimiter = ratelimit.NewMemoryLimiter()
+		name := randomstring.EnglishFrequencyString(30)
+		email := randomstring.EnglishFrequencyString(20) + "@example.com"
+		assert.Equal(t, http.StatusOK, signUpWithEmailTestFunc(name, email, "pass").Code)
+		for i := 0; i < 5; i++ {
+			input := controllerUtils.SignUpAndLoginInput{Name: name, Password: "wrong"}
+			if i%2 == 0 {
+				input = controllerUtils.SignUpAndLoginInput{Email: email, Password: "wrong"}
+			}
+			assert.Equal(t, http.StatusUnauthorized, loginFromIPTestFunc(input, "203.0.113.1").Code)
+		}
+		assertTooManyRequests(t, loginFromIPTestFunc(controllerUtils.SignUpAndLoginInput{Name: name, Password: "pass"}, "203.0.113.1"))
+		assertTooManyRequests(t, loginFromIPTestFunc(controllerUtils.SignUpAndLoginInput{Email: email, Password: "pass"}, "203.0.113.1"))
+		assert.Equal(t, http.StatusOK, loginFromIPTestFunc(controllerUtils.SignUpAndLoginInput{Name: name, Password: "pass"}, "203.0.113.2").Code)
+	})
+}
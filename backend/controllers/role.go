@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+func GetRoles(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserがworkspaceに参加しているかを確認
+	if !controllerUtils.IsExistWAUByWorkspaceIdAndUserId(workspaceId, userId) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+
+	roles, err := controllerUtils.GetRolesWithCapabilities(workspaceId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, roles)
+}
+
+func authorizeManagingRoles(c *gin.Context, workspaceId int, capabilities []string) bool {
+	// roleを管理する権限があり、付与するcapabilityを自分も持っているか確認する
+	userId := CurrentPrincipal(c).UserId
+	b, err := controllerUtils.Authorize(workspaceId, userId, models.CapRolesManage)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return false
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return false
+	}
+	b, err = controllerUtils.HasAllCapabilities(workspaceId, userId, capabilities)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return false
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "can't grant capability you don't have"})
+		return false
+	}
+	return true
+}
+
+func CreateRole(c *gin.Context) {
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateCreateRole(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if !authorizeManagingRoles(c, workspaceId, in.Capabilities) {
+		return
+	}
+
+	// 同じ名前のroleがworkspaceに存在しないか確認
+	b, err := models.IsExistRoleNameInWorkspace(workspaceId, in.Name, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if b {
+		c.JSON(http.StatusConflict, gin.H{"message": "already exist same name role in workspace"})
+		return
+	}
+
+	r := models.NewCustomRole(workspaceId, in.Name)
+	if err := r.Create(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if err := models.SetRoleCapabilities(r.ID, in.Capabilities); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, controllerUtils.RoleWithCapabilities{Role: *r, Capabilities: in.Capabilities})
+}
+
+func UpdateRole(c *gin.Context) {
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	roleId, err := strconv.Atoi(c.Param("role_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateUpdateRole(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	capabilities := make([]string, 0)
+	if in.Capabilities != nil {
+		capabilities = *in.Capabilities
+	}
+	if !authorizeManagingRoles(c, workspaceId, capabilities) {
+		return
+	}
+
+	// built-in roleは変更できない
+	r, err := controllerUtils.GetCustomRoleInWorkspace(workspaceId, roleId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "role not found"})
+		return
+	}
+
+	if in.Name != nil {
+		b, err := models.IsExistRoleNameInWorkspace(workspaceId, *in.Name, r.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if b {
+			c.JSON(http.StatusConflict, gin.H{"message": "already exist same name role in workspace"})
+			return
+		}
+		if err := r.Rename(*in.Name); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+	}
+	if in.Capabilities != nil {
+		if err := models.SetRoleCapabilities(r.ID, *in.Capabilities); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+	}
+
+	res, err := models.GetCapabilitiesByRole(r)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, controllerUtils.RoleWithCapabilities{Role: r, Capabilities: res})
+}
+
+func DeleteRole(c *gin.Context) {
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	roleId, err := strconv.Atoi(c.Param("role_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if !authorizeManagingRoles(c, workspaceId, nil) {
+		return
+	}
+
+	// built-in roleは削除できない
+	r, err := controllerUtils.GetCustomRoleInWorkspace(workspaceId, roleId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "role not found"})
+		return
+	}
+
+	// memberや有効な招待で使われているroleは削除できない
+	b, err := models.IsRoleInUse(r.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		b, err = models.IsRoleUsedByPendingInvite(r.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+	}
+	if b {
+		c.JSON(http.StatusConflict, gin.H{"message": "role is in use"})
+		return
+	}
+
+	if err := r.Delete(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, r)
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+func roleTestFunc(method string, workspaceId, roleId int, jwtToken string, input interface{}) *httptest.ResponseRecorder {
+	path := "/roles/" + strconv.Itoa(workspaceId)
+	if roleId != 0 {
+		path += "/" + strconv.Itoa(roleId)
+	}
+	return workspaceInviteTestFunc(method, path, jwtToken, input)
+}
+
+func TestRoles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 workspaceのmemberはroleの一覧を取得できる 200, memberでない場合 404
+	// 2 roles.manageがない場合 403, 不正なcapability 400, 既存のroleと同じ名前 409, 正常な場合 200
+	// 3 custom roleを付与されたuserはcapabilityに応じた操作ができる
+	// 4 自分が持っていないcapabilityは付与できない 403
+	// 5 更新した場合 200, built-in roleは変更できない 404
+	// 6 使われているroleは削除できない 409, 削除した場合 200
+
+	owner := signUpAndLoginTestFunc(t)
+	admin := signUpAndLoginTestFunc(t)
+	member := signUpAndLoginTestFunc(t)
+	target := signUpAndLoginTestFunc(t)
+	outsider := signUpAndLoginTestFunc(t)
+
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleAdmin, admin.UserId, owner.Token).Code)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleFullMember, member.UserId, owner.Token).Code)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleMultiChannelGuest, target.UserId, owner.Token).Code)
+
+	getRoles := func(jwtToken string) []controllerUtils.RoleWithCapabilities {
+		rr := roleTestFunc("GET", w.ID, 0, jwtToken, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		roles := make([]controllerUtils.RoleWithCapabilities, 0)
+		json.Unmarshal(rr.Body.Bytes(), &roles)
+		return roles
+	}
+
+	role := new(controllerUtils.RoleWithCapabilities)
+
+	t.Run("1", func(t *testing.T) {
+		roles := getRoles(member.Token)
+		assert.Equal(t, 6, len(roles))
+		assert.Equal(t, models.RoleAdmin, roles[2].ID)
+		assert.Contains(t, roles[2].Capabilities, models.CapMembersRemove)
+		assert.NotContains(t, roles[2].Capabilities, models.CapRolesManage)
+		assert.Equal(t, http.StatusNotFound, roleTestFunc("GET", w.ID, 0, outsider.Token, nil).Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		in := controllerUtils.CreateRoleInput{Name: name, Capabilities: []string{models.CapRolesManage, models.CapMembersRemove}}
+		assert.Equal(t, http.StatusForbidden, roleTestFunc("POST", w.ID, 0, admin.Token, in).Code)
+		assert.Equal(t, http.StatusNotFound, roleTestFunc("POST", w.ID, 0, outsider.Token, in).Code)
+		assert.Equal(t, http.StatusBadRequest, roleTestFunc("POST", w.ID, 0, owner.Token, controllerUtils.CreateRoleInput{Name: name, Capabilities: []string{"wrong"}}).Code)
+		assert.Equal(t, http.StatusBadRequest, roleTestFunc("POST", w.ID, 0, owner.Token, controllerUtils.CreateRoleInput{Name: " "}).Code)
+		assert.Equal(t, http.StatusConflict, roleTestFunc("POST", w.ID, 0, owner.Token, controllerUtils.CreateRoleInput{Name: "Full members"}).Code)
+
+		rr := roleTestFunc("POST", w.ID, 0, owner.Token, in)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		json.Unmarshal(rr.Body.Bytes(), role)
+		assert.Equal(t, name, role.Name)
+		assert.Equal(t, w.ID, role.WorkspaceId)
+		assert.ElementsMatch(t, in.Capabilities, role.Capabilities)
+		assert.Equal(t, http.StatusConflict, roleTestFunc("POST", w.ID, 0, owner.Token, in).Code)
+		assert.Equal(t, 7, len(getRoles(member.Token)))
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, deleteUserFromWorkspaceTestFunc(w.ID, target.UserId, member.Token).Code)
+		assert.Equal(t, http.StatusOK, changeRoleInWorkspaceTestFunc(w.ID, member.UserId, role.ID, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, deleteUserFromWorkspaceTestFunc(w.ID, target.UserId, member.Token).Code)
+
+		// 他のworkspaceのcustom roleは付与できない
+		rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		other := new(models.Workspace)
+		json.Unmarshal(rr.Body.Bytes(), other)
+		assert.Equal(t, http.StatusBadRequest, addUserWorkspaceTestFunc(other.ID, role.ID, target.UserId, owner.Token).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		in := controllerUtils.CreateRoleInput{Name: randomstring.EnglishFrequencyString(30), Capabilities: []string{models.CapChannelsDelete}}
+		assert.Equal(t, http.StatusForbidden, roleTestFunc("POST", w.ID, 0, member.Token, in).Code)
+		in.Capabilities = []string{models.CapMembersRemove}
+		assert.Equal(t, http.StatusOK, roleTestFunc("POST", w.ID, 0, member.Token, in).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		capabilities := []string{models.CapRolesManage}
+		assert.Equal(t, http.StatusNotFound, roleTestFunc("PATCH", w.ID, models.RoleAdmin, owner.Token, controllerUtils.UpdateRoleInput{Name: &name}).Code)
+		assert.Equal(t, http.StatusBadRequest, roleTestFunc("PATCH", w.ID, role.ID, owner.Token, controllerUtils.UpdateRoleInput{}).Code)
+		builtInName := "Workspace Admins"
+		assert.Equal(t, http.StatusConflict, roleTestFunc("PATCH", w.ID, role.ID, owner.Token, controllerUtils.UpdateRoleInput{Name: &builtInName}).Code)
+
+		rr := roleTestFunc("PATCH", w.ID, role.ID, owner.Token, controllerUtils.UpdateRoleInput{Name: &name, Capabilities: &capabilities})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		res := new(controllerUtils.RoleWithCapabilities)
+		json.Unmarshal(rr.Body.Bytes(), res)
+		assert.Equal(t, name, res.Name)
+		assert.Equal(t, capabilities, res.Capabilities)
+
+		// capabilityがなくなったので削除できない
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleMultiChannelGuest, target.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusForbidden, deleteUserFromWorkspaceTestFunc(w.ID, target.UserId, member.Token).Code)
+	})
+
+	t.Run("6", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, roleTestFunc("DELETE", w.ID, models.RoleFullMember, owner.Token, nil).Code)
+		assert.Equal(t, http.StatusConflict, roleTestFunc("DELETE", w.ID, role.ID, owner.Token, nil).Code)
+		assert.Equal(t, http.StatusOK, changeRoleInWorkspaceTestFunc(w.ID, member.UserId, models.RoleFullMember, owner.Token).Code)
+		assert.Equal(t, http.StatusForbidden, roleTestFunc("DELETE", w.ID, role.ID, member.Token, nil).Code)
+		assert.Equal(t, http.StatusOK, roleTestFunc("DELETE", w.ID, role.ID, owner.Token, nil).Code)
+		assert.Equal(t, http.StatusNotFound, roleTestFunc("DELETE", w.ID, role.ID, owner.Token, nil).Code)
+		assert.Equal(t, 7, len(getRoles(member.Token)))
+	})
+}
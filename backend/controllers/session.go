@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+func GetSessions(c *gin.Context) {
+	principal := CurrentPrincipal(c)
+
+	// userの有効なsessionを取得
+	sessions, err := controllerUtils.GetSessionsByUserId(principal.UserId, principal.SessionId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+func RevokeSession(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// urlからsession_idを取得
+	sessionId := c.Param("session_id")
+
+	// sessionを取得
+	s, err := models.GetSessionById(sessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// 他のuserのsessionは存在しないものとして扱う
+	if s.UserId != userId {
+		c.JSON(http.StatusNotFound, gin.H{"message": "session not found"})
+		return
+	}
+
+	// 既に無効なsessionか確認
+	if !s.IsActive() {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "session is already revoked or expired"})
+		return
+	}
+
+	if err := s.Revoke(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+func RevokeOtherSessions(c *gin.Context) {
+	principal := CurrentPrincipal(c)
+
+	// requestに使われたsession以外をすべて無効にする
+	if err := models.RevokeOtherSessionsByUserId(principal.UserId, principal.SessionId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "revoked other sessions"})
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/controllerUtils"
+)
+
+func loginWithDeviceTestFunc(name, password, deviceName, userAgent string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	input := controllerUtils.SignUpAndLoginInput{
+		Name:       name,
+		Password:   password,
+		DeviceName: deviceName,
+	}
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest("POST", "/api/user/login", bytes.NewBuffer(jsonInput))
+	req.Header.Set("User-Agent", userAgent)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func getSessionsTestFunc(jwtToken string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/user/sessions", nil)
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func revokeSessionTestFunc(jwtToken, sessionId string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/user/sessions/"+sessionId, nil)
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func revokeOtherSessionsTestFunc(jwtToken string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/user/sessions", nil)
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetSessions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// loginしたdeviceの情報が記録され、自分のsessionのみ取得できる
+
+	name := randomstring.EnglishFrequencyString(30)
+	assert.Equal(t, http.StatusOK, signUpTestFunc(name, "pass").Code)
+	lr1 := new(LoginResponse)
+	json.Unmarshal(loginWithDeviceTestFunc(name, "pass", "laptop", "test-agent-1").Body.Bytes(), lr1)
+	lr2 := new(LoginResponse)
+	json.Unmarshal(loginWithDeviceTestFunc(name, "pass", "phone", "test-agent-2").Body.Bytes(), lr2)
+
+	otherName := randomstring.EnglishFrequencyString(30)
+	assert.Equal(t, http.StatusOK, signUpTestFunc(otherName, "pass").Code)
+	assert.Equal(t, http.StatusOK, loginTestFunc(otherName, "pass").Code)
+
+	rr := getSessionsTestFunc(lr1.Token)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var sessions []controllerUtils.SessionInfo
+	json.Unmarshal(rr.Body.Bytes(), &sessions)
+	assert.Equal(t, 2, len(sessions))
+	currentCount := 0
+	for _, s := range sessions {
+		assert.Equal(t, lr1.UserId, s.UserId)
+		assert.NotEmpty(t, s.LastSeenAt)
+		if s.IsCurrent {
+			currentCount++
+			assert.Equal(t, "laptop", s.DeviceName)
+			assert.Equal(t, "test-agent-1", s.UserAgent)
+		} else {
+			assert.Equal(t, "phone", s.DeviceName)
+			assert.Equal(t, "test-agent-2", s.UserAgent)
+		}
+	}
+	assert.Equal(t, 1, currentCount)
+}
+
+func TestRevokeSession(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 自分の他のsessionを無効にできる 200
+	// 2 他のuserのsessionは無効にできない 404
+	// 3 存在しないsession 404
+	// 4 既に無効なsession 400
+
+	name := randomstring.EnglishFrequencyString(30)
+	assert.Equal(t, http.StatusOK, signUpTestFunc(name, "pass").Code)
+	otherName := randomstring.EnglishFrequencyString(30)
+	assert.Equal(t, http.StatusOK, signUpTestFunc(otherName, "pass").Code)
+
+	login := func(name string) (string, string) {
+		lr := new(LoginResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr)
+		var sessions []controllerUtils.SessionInfo
+		json.Unmarshal(getSessionsTestFunc(lr.Token).Body.Bytes(), &sessions)
+		for _, s := range sessions {
+			if s.IsCurrent {
+				return lr.Token, s.ID
+			}
+		}
+		return lr.Token, ""
+	}
+
+	token1, _ := login(name)
+	token2, sessionId2 := login(name)
+	otherToken, otherSessionId := login(otherName)
+
+	t.Run("1", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, revokeSessionTestFunc(token1, sessionId2).Code)
+		assert.Equal(t, http.StatusUnauthorized, currentUserTestFunc(token2).Code)
+		assert.Equal(t, http.StatusOK, currentUserTestFunc(token1).Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, revokeSessionTestFunc(token1, otherSessionId).Code)
+		assert.Equal(t, http.StatusOK, currentUserTestFunc(otherToken).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, revokeSessionTestFunc(token1, randomstring.EnglishFrequencyString(30)).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, revokeSessionTestFunc(token1, sessionId2).Code)
+	})
+}
+
+func TestRevokeOtherSessions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// requestしたsession以外が無効になる
+
+	name := randomstring.EnglishFrequencyString(30)
+	assert.Equal(t, http.StatusOK, signUpTestFunc(name, "pass").Code)
+	tokens := make([]string, 3)
+	for i := range tokens {
+		lr := new(LoginResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr)
+		tokens[i] = lr.Token
+	}
+
+	assert.Equal(t, http.StatusOK, revokeOtherSessionsTestFunc(tokens[0]).Code)
+	assert.Equal(t, http.StatusOK, currentUserTestFunc(tokens[0]).Code)
+	assert.Equal(t, http.StatusUnauthorized, currentUserTestFunc(tokens[1]).Code)
+	assert.Equal(t, http.StatusUnauthorized, currentUserTestFunc(tokens[2]).Code)
+
+	var sessions []controllerUtils.SessionInfo
+	json.Unmarshal(getSessionsTestFunc(tokens[0]).Body.Bytes(), &sessions)
+	assert.Equal(t, 1, len(sessions))
+}
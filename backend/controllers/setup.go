@@ -15,43 +15,118 @@ func SetupRouter() *gin.Engine {
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PATCH", "DELETE"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
 		AllowHeaders:     []string{"Origin", "Access-Control-Allow-Headers", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 	fmt.Println(models.DbConnection)
+
+	// tokenの検証に使う公開鍵
+	r.GET("/.well-known/jwks.json", GetJWKS)
+
 	api := r.Group("/api")
 
-	user := api.Group("/user")
-	user.POST("/signUp", SignUp)
-	user.POST("/login", Login)
-	user.GET("/currentUser", GetCurrentUser)
-
-	workspace := api.Group("/workspace")
-	workspace.POST("/create", CreateWorkspace)
-	workspace.POST("/add_user", AddUserInWorkspace)
-	workspace.PATCH("/rename/:workspace_id", RenameWorkspaceName)
-	workspace.DELETE("/delete_user", DeleteUserFromWorkSpace)
-	workspace.GET("/get_by_user", GetWorkspacesByUserId)
-	workspace.GET("/get_users/:workspace_id", GetUsersInWorkspace)
-
-	channel := api.Group("/channel")
-	channel.POST("/create", CreateChannel)
-	channel.POST("/add_user", AddUserInChannel)
-	channel.DELETE("/delete_user/:workspace_id", DeleteUserFromChannel)
-	channel.DELETE("/delete", DeleteChannel)
-	channel.GET("/get_by_user_and_workspace/:workspace_id", GetChannelsByUser)
-
-	message := api.Group("/message")
-	message.POST("/send", SendMessage)
-	message.GET("/get_from_channel/:channel_id", GetAllMessagesFromChannel)
-
-	dm := api.Group("/dm")
-	dm.POST("/send", SendDM)
-	dm.GET("/:dm_line_id", GetDMsInLine)
-	dm.PATCH("/:dm_id", EditDM)
-	dm.DELETE("/:dm_id", DeleteDM)
+	// 認証が不要なroute
+	public := api.Group("/user")
+	public.Use(RateLimitByIP("auth"))
+	public.POST("/signUp", SignUp)
+	public.POST("/login", Login)
+	public.POST("/login/two_factor", LoginTwoFactor)
+	public.POST("/refresh", RefreshToken)
+	public.POST("/password/forgot", ForgotPassword)
+	public.POST("/password/reset", ResetPassword)
+	public.POST("/email/verify", VerifyEmail)
+	public.POST("/reactivate", ReactivateAccount)
+	public.GET("/sso/:provider/start", StartSSO)
+	public.POST("/sso/:provider/callback", SSOCallback)
+
+	// 以下のrouteはすべて認証が必要
+	authorized := api.Group("")
+	authorized.Use(AuthMiddleware(), RateLimitByUser("api"))
+
+	// API tokenで認証された場合は各routeに必要なscopeを確認する
+	// account設定に関するrouteはloginしたsessionからのみ操作できる
+	user := authorized.Group("/user")
+	user.GET("/currentUser", RequireScope(models.ScopeUsersRead), GetCurrentUser)
+	user.POST("/logout", RequireSession(), Logout)
+	user.GET("/sessions", RequireSession(), GetSessions)
+	user.DELETE("/sessions", RequireSession(), RevokeOtherSessions)
+	user.DELETE("/sessions/:session_id", RequireSession(), RevokeSession)
+	user.POST("/two_factor/setup", RequireSession(), SetupTwoFactor)
+	user.POST("/two_factor/enable", RequireSession(), EnableTwoFactor)
+	user.POST("/two_factor/disable", RequireSession(), DisableTwoFactor)
+	user.PATCH("/password", RequireSession(), ChangePassword)
+	user.POST("/password", RequireSession(), SetPassword)
+	user.POST("/email/resend", RequireSession(), ResendVerificationEmail)
+	user.GET("/profile", RequireScope(models.ScopeUsersRead), GetProfile)
+	user.PATCH("/profile", RequireScope(models.ScopeUsersWrite), UpdateProfile)
+	user.POST("/presence/heartbeat", RequireScope(models.ScopeUsersWrite), Heartbeat)
+	user.PUT("/presence", RequireScope(models.ScopeUsersWrite), SetPresence)
+	user.GET("/presence", RequireScope(models.ScopeUsersRead), GetPresences)
+	user.PUT("/status", RequireScope(models.ScopeUsersWrite), SetStatus)
+	user.DELETE("/status", RequireScope(models.ScopeUsersWrite), ClearStatus)
+	user.POST("/deactivate", RequireSession(), DeactivateAccount)
+	user.DELETE("", RequireSession(), DeleteAccount)
+	user.POST("/tokens", RequireSession(), CreatePersonalToken)
+	user.GET("/tokens", RequireSession(), GetPersonalTokens)
+	user.DELETE("/tokens/:token_id", RequireSession(), RevokePersonalToken)
+
+	workspace := authorized.Group("/workspace")
+	workspace.POST("/create", RequireScope(models.ScopeWorkspacesWrite), CreateWorkspace)
+	workspace.POST("/add_user", RequireScope(models.ScopeWorkspacesWrite), AddUserInWorkspace)
+	workspace.PATCH("/rename/:workspace_id", RequireScope(models.ScopeWorkspacesWrite), RenameWorkspaceName)
+	workspace.DELETE("/delete/:workspace_id", RequireSession(), DeleteWorkspace)
+	workspace.POST("/restore/:workspace_id", RequireSession(), RestoreWorkspace)
+	workspace.DELETE("/delete_user", RequireScope(models.ScopeWorkspacesWrite), DeleteUserFromWorkSpace)
+	workspace.POST("/leave/:workspace_id", RequireScope(models.ScopeWorkspacesWrite), LeaveWorkspace)
+	workspace.GET("/get_by_user", RequireScope(models.ScopeWorkspacesRead), GetWorkspacesByUserId)
+	workspace.GET("/get_users/:workspace_id", RequireScope(models.ScopeUsersRead), GetUsersInWorkspace)
+	workspace.PATCH("/profile/:workspace_id", RequireScope(models.ScopeUsersWrite), UpdateWorkspaceProfile)
+	workspace.POST("/deactivate_user", RequireScope(models.ScopeWorkspacesWrite), DeactivateUserInWorkspace)
+	workspace.POST("/reactivate_user", RequireScope(models.ScopeWorkspacesWrite), ReactivateUserInWorkspace)
+	workspace.POST("/change_role", RequireScope(models.ScopeWorkspacesWrite), ChangeRoleInWorkspace)
+	workspace.POST("/guest_expiration", RequireScope(models.ScopeWorkspacesWrite), SetGuestExpiration)
+	workspace.POST("/transfer_primary_owner", RequireSession(), TransferPrimaryOwnership)
+	workspace.GET("/settings/:workspace_id", RequireScope(models.ScopeWorkspacesRead), GetWorkspaceSetting)
+	workspace.PATCH("/settings/:workspace_id", RequireScope(models.ScopeWorkspacesWrite), UpdateWorkspaceSetting)
+	workspace.POST("/bot_tokens/:workspace_id", RequireSession(), CreateBotToken)
+	workspace.GET("/bot_tokens/:workspace_id", RequireSession(), GetBotTokens)
+	workspace.DELETE("/bot_tokens/:workspace_id/:token_id", RequireSession(), RevokeBotToken)
+	workspace.POST("/invites/:workspace_id", RequireScope(models.ScopeWorkspacesWrite), CreateWorkspaceInvite)
+	workspace.GET("/invites/:workspace_id", RequireScope(models.ScopeWorkspacesRead), GetWorkspaceInvites)
+	workspace.DELETE("/invites/:workspace_id/:invite_id", RequireScope(models.ScopeWorkspacesWrite), RevokeWorkspaceInvite)
+	workspace.POST("/join", RequireScope(models.ScopeWorkspacesWrite), AcceptWorkspaceInvite)
+	workspace.GET("/default_channels/:workspace_id", RequireScope(models.ScopeChannelsRead), GetDefaultChannels)
+	workspace.POST("/default_channels/:workspace_id/:channel_id", RequireScope(models.ScopeWorkspacesWrite), AddDefaultChannel)
+	workspace.DELETE("/default_channels/:workspace_id/:channel_id", RequireScope(models.ScopeWorkspacesWrite), RemoveDefaultChannel)
+	workspace.POST("/default_channels/:workspace_id/:channel_id/backfill", RequireScope(models.ScopeWorkspacesWrite), BackfillDefaultChannel)
+	workspace.GET("/roles/:workspace_id", RequireScope(models.ScopeWorkspacesRead), GetRoles)
+	workspace.POST("/roles/:workspace_id", RequireScope(models.ScopeWorkspacesWrite), CreateRole)
+	workspace.PATCH("/roles/:workspace_id/:role_id", RequireScope(models.ScopeWorkspacesWrite), UpdateRole)
+	workspace.DELETE("/roles/:workspace_id/:role_id", RequireScope(models.ScopeWorkspacesWrite), DeleteRole)
+	workspace.GET("/user_groups/:workspace_id", RequireScope(models.ScopeUsersRead), GetUserGroups)
+	workspace.POST("/user_groups/:workspace_id", RequireScope(models.ScopeWorkspacesWrite), CreateUserGroup)
+	workspace.PATCH("/user_groups/:workspace_id/:user_group_id", RequireScope(models.ScopeWorkspacesWrite), UpdateUserGroup)
+	workspace.DELETE("/user_groups/:workspace_id/:user_group_id", RequireScope(models.ScopeWorkspacesWrite), DeleteUserGroup)
+
+	channel := authorized.Group("/channel")
+	channel.POST("/create", RequireScope(models.ScopeChannelsWrite), CreateChannel)
+	channel.POST("/add_user", RequireScope(models.ScopeChannelsWrite), AddUserInChannel)
+	channel.DELETE("/delete_user/:workspace_id", RequireScope(models.ScopeChannelsWrite), DeleteUserFromChannel)
+	channel.POST("/leave/:channel_id", RequireScope(models.ScopeChannelsWrite), LeaveChannel)
+	channel.DELETE("/delete", RequireScope(models.ScopeChannelsWrite), DeleteChannel)
+	channel.GET("/get_by_user_and_workspace/:workspace_id", RequireScope(models.ScopeChannelsRead), GetChannelsByUser)
+
+	message := authorized.Group("/message")
+	message.POST("/send", RequireScope(models.ScopeChatWrite), RateLimitByUser("message"), SendMessage)
+	message.GET("/get_from_channel/:channel_id", RequireScope(models.ScopeChatRead), GetAllMessagesFromChannel)
+
+	dm := authorized.Group("/dm")
+	dm.POST("/send", RequireScope(models.ScopeChatWrite), RateLimitByUser("message"), SendDM)
+	dm.GET("/:dm_line_id", RequireScope(models.ScopeChatRead), GetDMsInLine)
+	dm.PATCH("/:dm_id", RequireScope(models.ScopeChatWrite), EditDM)
+	dm.DELETE("/:dm_id", RequireScope(models.ScopeChatWrite), DeleteDM)
 	return r
 }
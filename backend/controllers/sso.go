@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/controllerUtils"
+	"backend/models"
+	"backend/oidc"
+	"backend/token"
+	"backend/utils"
+)
+
+// SSOのloginを開始してからcallbackまでの有効期限
+const ssoStateLifespan = 10 * time.Minute
+
+func StartSSO(c *gin.Context) {
+	// providerを取得
+	p, ok := oidc.GetProvider(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"message": "sso provider not found"})
+		return
+	}
+
+	// state, nonce, PKCEのcode_verifierを作成
+	state, err := utils.GenerateRandomString(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	nonce, err := utils.GenerateRandomString(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	codeVerifier, err := oidc.GenerateCodeVerifier()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	authorizationUrl, err := p.AuthCodeURL(state, nonce, codeVerifier)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"message": err.Error()})
+		return
+	}
+
+	// callbackで使うのでstateのhash値と一緒に保存する
+	s := models.NewOidcState(utils.HashToken(state), p.Name, codeVerifier, nonce, time.Now().Add(ssoStateLifespan))
+	s.DeviceName = c.Query("device_name")
+	if err := s.Create().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"authorization_url": authorizationUrl})
+}
+
+func SSOCallback(c *gin.Context) {
+	// providerを取得
+	p, ok := oidc.GetProvider(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"message": "sso provider not found"})
+		return
+	}
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateSSOCallback(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// stateが有効か確認して使用済みにする
+	s, err := models.GetOidcStateByStateHash(utils.HashToken(in.State))
+	if err != nil || s.Provider != p.Name || !s.IsValid() {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid or expired state"})
+		return
+	}
+	used, err := s.Use()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !used {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid or expired state"})
+		return
+	}
+
+	// codeをid_tokenと交換して検証する
+	idToken, err := p.Exchange(in.Code, s.CodeVerifier)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+	claims, err := p.VerifyIdToken(idToken, s.Nonce)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+
+	// 紐付けられたuserを取得し、存在しなければ作成する
+	u, err := controllerUtils.FindOrCreateUserByIdentity(p.Name, claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// 無効化されたaccountにはloginできない
+	if u.IsDeactivated() {
+		c.JSON(http.StatusForbidden, gin.H{"message": "account is deactivated"})
+		return
+	}
+
+	if err := controllerUtils.JoinSSOWorkspace(p.WorkspaceId, u.ID); err != nil {
+		fmt.Println(err)
+	}
+
+	// 2段階認証が有効な場合はtokenの代わりにchallenge tokenを返す
+	enabled, err := models.IsTwoFactorEnabled(u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if enabled {
+		challengeToken, err := token.GenerateTwoFactorChallengeToken(u.ID, s.DeviceName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, gin.H{"two_factor_required": true, "challenge_token": challengeToken, "user_id": u.ID, "username": u.Name})
+		return
+	}
+
+	respondNewSession(c, u, s.DeviceName)
+}
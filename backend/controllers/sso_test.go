@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/controllerUtils"
+	"backend/models"
+	"backend/oidc"
+)
+
+func startSSOTestFunc(provider string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/user/sso/"+provider+"/start?device_name=sso", nil)
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func ssoCallbackTestFunc(provider string, input controllerUtils.SSOCallbackInput) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest("POST", "/api/user/sso/"+provider+"/callback", bytes.NewBuffer(jsonInput))
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+// mock providerでloginしてcallbackのinputを返す
+func authorizeSSOTestFunc(t *testing.T, m *oidc.MockProvider, provider string, u oidc.MockUser) controllerUtils.SSOCallbackInput {
+	m.SetNextUser(u)
+	rr := startSSOTestFunc(provider)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var res struct {
+		AuthorizationUrl string `json:"authorization_url"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &res)
+	code, state, err := m.Authorize(res.AuthorizationUrl)
+	assert.Empty(t, err)
+	return controllerUtils.SSOCallbackInput{Code: code, State: state}
+}
+
+func ssoLoginTestFunc(t *testing.T, m *oidc.MockProvider, provider string, u oidc.MockUser) (*httptest.ResponseRecorder, *LoginResponse) {
+	rr := ssoCallbackTestFunc(provider, authorizeSSOTestFunc(t, m, provider, u))
+	lr := new(LoginResponse)
+	json.Unmarshal(rr.Body.Bytes(), lr)
+	return rr, lr
+}
+
+func TestSSO(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 初めてloginした場合はuserを作成し、設定されたworkspaceに参加する 200
+	// 2 同じaccountで再度loginした場合は同じuser 200
+	// 3 確認済みのemailが一致するuserに紐付ける 200, userのemailが未確認の場合は紐付けずに新しいuserを作成する 200
+	// 4 未確認のemailの場合は紐付けずに新しいuserを作成する 200
+	// 5 同じstateを2回使った場合 401
+	// 6 存在しないproviderの場合 404
+	// 7 無効化されたuserの場合 403
+	// 8 同じusernameのuserが存在する場合は別のusernameで作成する 200
+	// 9 SSOで作成したuserはpasswordを設定するまでpasswordで確認できない 403, 設定済みの場合 409
+
+	m, err := oidc.NewMockProvider("slackclone")
+	assert.Empty(t, err)
+	defer m.Close()
+
+	owner := signUpAndLogin(t)
+	w, _ := createWorkspaceWithGeneral(t, owner)
+	provider := randomstring.EnglishFrequencyString(10)
+	oidc.RegisterProvider(oidc.NewProvider(m.Config(provider, "http://localhost:3000/sso/callback", w.ID)))
+
+	subject := randomstring.EnglishFrequencyString(30)
+	var userId uint32
+
+	t.Run("1", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		rr, lr := ssoLoginTestFunc(t, m, provider, oidc.MockUser{Subject: subject, PreferredUsername: name, Name: "SSO User"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEqual(t, "", lr.Token)
+		assert.Equal(t, name, lr.Username)
+		assert.Equal(t, http.StatusOK, currentUserTestFunc(lr.Token).Code)
+		userId = lr.UserId
+
+		roleId, err := models.GetRoleIdByWorkspaceIdAndUserId(w.ID, lr.UserId)
+		assert.Empty(t, err)
+		assert.Equal(t, 4, roleId)
+
+		p, err := models.GetProfileByUserId(lr.UserId)
+		assert.Empty(t, err)
+		assert.Equal(t, "SSO User", p.RealName)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		rr, lr := ssoLoginTestFunc(t, m, provider, oidc.MockUser{Subject: subject})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, userId, lr.UserId)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		email := randomstring.HumanFriendlyEnglishString(10) + "@example.com"
+		rr := signUpWithEmailTestFunc(name, email, "pass")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		u := new(models.User)
+		json.Unmarshal(rr.Body.Bytes(), u)
+
+		// 他人が先に同じemailでsign upした場合などは確認が済んでいないので紐付けない
+		rr, lr := ssoLoginTestFunc(t, m, provider, oidc.MockUser{Subject: randomstring.EnglishFrequencyString(30), Email: email, EmailVerified: true})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEqual(t, u.ID, lr.UserId)
+		res, err := models.GetUserById(u.ID)
+		assert.Empty(t, err)
+		assert.False(t, res.IsEmailVerified())
+
+		assert.Empty(t, res.VerifyEmail())
+		rr, lr = ssoLoginTestFunc(t, m, provider, oidc.MockUser{Subject: randomstring.EnglishFrequencyString(30), Email: email, EmailVerified: true})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, u.ID, lr.UserId)
+		assert.Equal(t, name, lr.Username)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		email := randomstring.HumanFriendlyEnglishString(10) + "@example.com"
+		rr := signUpWithEmailTestFunc(name, email, "pass")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		u := new(models.User)
+		json.Unmarshal(rr.Body.Bytes(), u)
+
+		rr, lr := ssoLoginTestFunc(t, m, provider, oidc.MockUser{Subject: randomstring.EnglishFrequencyString(30), Email: email, EmailVerified: false})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEqual(t, u.ID, lr.UserId)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		input := authorizeSSOTestFunc(t, m, provider, oidc.MockUser{Subject: subject})
+		assert.Equal(t, http.StatusOK, ssoCallbackTestFunc(provider, input).Code)
+		assert.Equal(t, http.StatusUnauthorized, ssoCallbackTestFunc(provider, input).Code)
+
+		input.State = randomstring.EnglishFrequencyString(30)
+		assert.Equal(t, http.StatusUnauthorized, ssoCallbackTestFunc(provider, input).Code)
+	})
+
+	t.Run("6", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, startSSOTestFunc(randomstring.EnglishFrequencyString(10)).Code)
+	})
+
+	t.Run("7", func(t *testing.T) {
+		s := randomstring.EnglishFrequencyString(30)
+		rr, lr := ssoLoginTestFunc(t, m, provider, oidc.MockUser{Subject: s, PreferredUsername: randomstring.EnglishFrequencyString(30)})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		u, err := models.GetUserById(lr.UserId)
+		assert.Empty(t, err)
+		assert.Empty(t, u.Deactivate())
+
+		rr, _ = ssoLoginTestFunc(t, m, provider, oidc.MockUser{Subject: s})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("8", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		assert.Equal(t, http.StatusOK, signUpTestFunc(name, "pass").Code)
+		rr, lr := ssoLoginTestFunc(t, m, provider, oidc.MockUser{Subject: randomstring.EnglishFrequencyString(30), PreferredUsername: name})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEqual(t, name, lr.Username)
+		assert.Contains(t, lr.Username, name)
+	})
+
+	t.Run("9", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		rr, lr := ssoLoginTestFunc(t, m, provider, oidc.MockUser{Subject: randomstring.EnglishFrequencyString(30), PreferredUsername: name})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		b, err := controllerUtils.IsCorrectPassword(lr.UserId, "")
+		assert.Empty(t, err)
+		assert.False(t, b)
+		rr = accountTestFunc("POST", "/deactivate", lr.Token, controllerUtils.PasswordInput{Password: "newPass"})
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		assert.Equal(t, http.StatusBadRequest, passwordTestFunc("POST", "", lr.Token, controllerUtils.SetPasswordInput{}).Code)
+		assert.Equal(t, http.StatusOK, passwordTestFunc("POST", "", lr.Token, controllerUtils.SetPasswordInput{NewPassword: "newPass"}).Code)
+		b, err = controllerUtils.IsCorrectPassword(lr.UserId, "newPass")
+		assert.Empty(t, err)
+		assert.True(t, b)
+		assert.Equal(t, http.StatusOK, loginTestFunc(name, "newPass").Code)
+
+		assert.Equal(t, http.StatusConflict, passwordTestFunc("POST", "", lr.Token, controllerUtils.SetPasswordInput{NewPassword: "otherPass"}).Code)
+		assert.Equal(t, http.StatusConflict, passwordTestFunc("POST", "", owner.Token, controllerUtils.SetPasswordInput{NewPassword: "otherPass"}).Code)
+	})
+}
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"backend/controllerUtils"
+	"backend/models"
+	"backend/token"
+	"backend/totp"
+)
+
+func SetupTwoFactor(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidatePassword(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// passwordを再確認
+	b, err := controllerUtils.IsCorrectPassword(userId, in.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "wrong password"})
+		return
+	}
+
+	// 既に有効になっていないか確認
+	enabled, err := models.IsTwoFactorEnabled(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if enabled {
+		c.JSON(http.StatusConflict, gin.H{"message": "two factor authentication is already enabled"})
+		return
+	}
+
+	// secretを作成して保存(enableされるまでは無効)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	tf := models.NewTwoFactor(userId, secret)
+	if err := tf.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	u, err := models.GetUserById(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_uri": totp.ProvisioningURI(secret, u.Name)})
+}
+
+func EnableTwoFactor(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateEnableTwoFactor(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// passwordを再確認
+	b, err := controllerUtils.IsCorrectPassword(userId, in.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "wrong password"})
+		return
+	}
+
+	// setupが済んでいるか確認
+	tf, err := models.GetTwoFactorByUserId(userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "two factor authentication is not set up"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if tf.IsEnabled {
+		c.JSON(http.StatusConflict, gin.H{"message": "two factor authentication is already enabled"})
+		return
+	}
+
+	// 認証アプリのcodeが正しいか確認
+	step, ok := totp.Validate(tf.Secret, in.Code, time.Now(), tf.LastUsedStep)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid code"})
+		return
+	}
+
+	// recovery codeを作成してhash値のみ保存する
+	codes, err := totp.GenerateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = totp.HashRecoveryCode(code)
+	}
+	if err := models.ReplaceRecoveryCodes(userId, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	if err := tf.Enable(step); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// recovery codeを返すのはこの1回のみ
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func DisableTwoFactor(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidatePassword(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// passwordを再確認
+	b, err := controllerUtils.IsCorrectPassword(userId, in.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "wrong password"})
+		return
+	}
+
+	// 有効になっているか確認
+	enabled, err := models.IsTwoFactorEnabled(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "two factor authentication is not enabled"})
+		return
+	}
+
+	// secretとrecovery codeを削除
+	if err := models.DeleteTwoFactorByUserId(userId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two factor authentication disabled"})
+}
+
+func recordTwoFactorFailure(tf *models.TwoFactor, now time.Time) {
+	if err := tf.RecordFailure(now); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func LoginTwoFactor(c *gin.Context) {
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateLoginTwoFactor(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// challenge tokenからuserを特定
+	claims, err := token.ParseTwoFactorChallengeToken(in.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+	tf, err := models.GetTwoFactorByUserId(claims.UserId)
+	if err != nil || !tf.IsEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "two factor authentication is not enabled"})
+		return
+	}
+
+	// codeの総当たりを防ぐため失敗が続いているuserはロックする
+	now := time.Now()
+	if retryAfter := tf.LockedFor(now); retryAfter > 0 {
+		abortTooManyRequests(c, retryAfter, "too many failed attempts")
+		return
+	}
+
+	if in.Code != "" {
+		// 認証アプリのcodeを確認し、同じcodeを再利用できないようにする
+		step, ok := totp.Validate(tf.Secret, in.Code, now, tf.LastUsedStep)
+		if !ok || tf.UpdateLastUsedStep(step) != nil {
+			recordTwoFactorFailure(&tf, now)
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid code"})
+			return
+		}
+	} else {
+		// recovery codeは1回だけ使える
+		ok, err := models.UseRecoveryCode(claims.UserId, totp.HashRecoveryCode(in.RecoveryCode))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if !ok {
+			recordTwoFactorFailure(&tf, now)
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid recovery code"})
+			return
+		}
+	}
+	if err := tf.ResetFailures(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	u, err := models.GetUserById(claims.UserId)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+	if u.IsDeactivated() {
+		c.JSON(http.StatusForbidden, gin.H{"message": "account is deactivated"})
+		return
+	}
+
+	respondNewSession(c, u, claims.DeviceName)
+}
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/controllerUtils"
+	"backend/models"
+	"backend/totp"
+)
+
+type SetupTwoFactorResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthUri string `json:"otpauth_uri"`
+}
+
+type EnableTwoFactorResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorLoginResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	Token             string `json:"token"`
+}
+
+func twoFactorTestFunc(path, jwtToken string, input interface{}) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest("POST", "/api/user/two_factor/"+path, bytes.NewBuffer(jsonInput))
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func loginTwoFactorTestFunc(input controllerUtils.LoginTwoFactorInput) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest("POST", "/api/user/login/two_factor", bytes.NewBuffer(jsonInput))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// 2段階認証を有効にしたuserを作成してsecretとrecovery codeを返す
+func createTwoFactorUser(t *testing.T, name string) (string, []string) {
+	assert.Equal(t, http.StatusOK, signUpTestFunc(name, "pass").Code)
+	lr := new(LoginResponse)
+	json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr)
+
+	rr := twoFactorTestFunc("setup", lr.Token, controllerUtils.PasswordInput{Password: "pass"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	setup := new(SetupTwoFactorResponse)
+	json.Unmarshal(rr.Body.Bytes(), setup)
+
+	code, _ := totp.GenerateCode(setup.Secret, totp.Step(time.Now())-1)
+	rr = twoFactorTestFunc("enable", lr.Token, controllerUtils.EnableTwoFactorInput{Password: "pass", Code: code})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	enable := new(EnableTwoFactorResponse)
+	json.Unmarshal(rr.Body.Bytes(), enable)
+	return setup.Secret, enable.RecoveryCodes
+}
+
+func TestSetupAndEnableTwoFactor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 正常な場合 200
+	// 2 passwordが間違っている場合 403
+	// 3 codeが間違っている場合 400
+	// 4 setupせずにenableした場合 404
+	// 5 既に有効な場合 409
+
+	t.Run("1 5", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		_, codes := createTwoFactorUser(t, name)
+		assert.Equal(t, totp.RecoveryCodeCount, len(codes))
+
+		lr := new(TwoFactorLoginResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr)
+		assert.True(t, lr.TwoFactorRequired)
+		assert.Empty(t, lr.Token)
+		assert.NotEmpty(t, lr.ChallengeToken)
+	})
+
+	name := randomstring.EnglishFrequencyString(30)
+	assert.Equal(t, http.StatusOK, signUpTestFunc(name, "pass").Code)
+	lr := new(LoginResponse)
+	json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr)
+
+	t.Run("2", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, twoFactorTestFunc("setup", lr.Token, controllerUtils.PasswordInput{Password: "wrongPass"}).Code)
+		assert.Equal(t, http.StatusBadRequest, twoFactorTestFunc("setup", lr.Token, controllerUtils.PasswordInput{}).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, twoFactorTestFunc("enable", lr.Token, controllerUtils.EnableTwoFactorInput{Password: "pass", Code: "123456"}).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		rr := twoFactorTestFunc("setup", lr.Token, controllerUtils.PasswordInput{Password: "pass"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		setup := new(SetupTwoFactorResponse)
+		json.Unmarshal(rr.Body.Bytes(), setup)
+		code, _ := totp.GenerateCode(setup.Secret, totp.Step(time.Now())-10)
+		assert.Equal(t, http.StatusBadRequest, twoFactorTestFunc("enable", lr.Token, controllerUtils.EnableTwoFactorInput{Password: "pass", Code: code}).Code)
+
+		// 有効になっていないのでloginでtokenが返される
+		res := new(TwoFactorLoginResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), res)
+		assert.False(t, res.TwoFactorRequired)
+		assert.NotEmpty(t, res.Token)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		secret, _ := createTwoFactorUser(t, name)
+		challenge := new(TwoFactorLoginResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), challenge)
+		code, _ := totp.GenerateCode(secret, totp.Step(time.Now()))
+		res := new(LoginResponse)
+		json.Unmarshal(loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{ChallengeToken: challenge.ChallengeToken, Code: code}).Body.Bytes(), res)
+		assert.Equal(t, http.StatusConflict, twoFactorTestFunc("setup", res.Token, controllerUtils.PasswordInput{Password: "pass"}).Code)
+	})
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 codeでloginできる 200
+	// 2 同じcodeは二度使えない 401
+	// 3 recovery codeでloginできるが二度は使えない
+	// 4 不正なchallenge_tokenやcode 401
+	// 5 bodyが足りない場合 400
+	// 6 続けて失敗した場合は正しいcodeでもloginできない 429
+
+	name := randomstring.EnglishFrequencyString(30)
+	secret, recoveryCodes := createTwoFactorUser(t, name)
+	getChallenge := func() string {
+		res := new(TwoFactorLoginResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), res)
+		return res.ChallengeToken
+	}
+	code, _ := totp.GenerateCode(secret, totp.Step(time.Now()))
+
+	t.Run("1", func(t *testing.T) {
+		rr := loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{ChallengeToken: getChallenge(), Code: code})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		res := new(LoginResponse)
+		json.Unmarshal(rr.Body.Bytes(), res)
+		assert.NotEmpty(t, res.Token)
+		assert.Equal(t, http.StatusOK, currentUserTestFunc(res.Token).Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		rr := loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{ChallengeToken: getChallenge(), Code: code})
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		input := controllerUtils.LoginTwoFactorInput{ChallengeToken: getChallenge(), RecoveryCode: recoveryCodes[0]}
+		assert.Equal(t, http.StatusOK, loginTwoFactorTestFunc(input).Code)
+		assert.Equal(t, http.StatusUnauthorized, loginTwoFactorTestFunc(input).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{ChallengeToken: "wrong token", Code: code}).Code)
+		assert.Equal(t, http.StatusUnauthorized, loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{ChallengeToken: getChallenge(), Code: "000000x"}).Code)
+		assert.Equal(t, http.StatusUnauthorized, loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{ChallengeToken: getChallenge(), RecoveryCode: "wrong-code"}).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{ChallengeToken: getChallenge()}).Code)
+		assert.Equal(t, http.StatusBadRequest, loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{Code: code}).Code)
+	})
+
+	t.Run("6", func(t *testing.T) {
+		// 成功すると失敗回数は数え直す
+		assert.Equal(t, http.StatusOK, loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{ChallengeToken: getChallenge(), RecoveryCode: recoveryCodes[1]}).Code)
+		for i := 0; i < models.MaxTwoFactorFailures; i++ {
+			assert.Equal(t, http.StatusUnauthorized, loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{ChallengeToken: getChallenge(), RecoveryCode: "wrong-code"}).Code)
+		}
+		input := controllerUtils.LoginTwoFactorInput{ChallengeToken: getChallenge(), RecoveryCode: recoveryCodes[2]}
+		rr := loginTwoFactorTestFunc(input)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+	})
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 passwordが間違っている場合 403
+	// 2 正常な場合 200 その後は2段階認証なしでloginできる
+	// 3 有効になっていない場合 400
+
+	name := randomstring.EnglishFrequencyString(30)
+	secret, _ := createTwoFactorUser(t, name)
+	challenge := new(TwoFactorLoginResponse)
+	json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), challenge)
+	code, _ := totp.GenerateCode(secret, totp.Step(time.Now()))
+	lr := new(LoginResponse)
+	json.Unmarshal(loginTwoFactorTestFunc(controllerUtils.LoginTwoFactorInput{ChallengeToken: challenge.ChallengeToken, Code: code}).Body.Bytes(), lr)
+
+	t.Run("1", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, twoFactorTestFunc("disable", lr.Token, controllerUtils.PasswordInput{Password: "wrongPass"}).Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, twoFactorTestFunc("disable", lr.Token, controllerUtils.PasswordInput{Password: "pass"}).Code)
+		res := new(TwoFactorLoginResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), res)
+		assert.False(t, res.TwoFactorRequired)
+		assert.NotEmpty(t, res.Token)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, twoFactorTestFunc("disable", lr.Token, controllerUtils.PasswordInput{Password: "pass"}).Code)
+	})
+}
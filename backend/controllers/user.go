@@ -1,8 +1,10 @@
 package controllers
 
 import (
-	"math/rand"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
@@ -12,8 +14,6 @@ import (
 )
 
 func SignUp(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-
 	// bodyの情報を取得
 	// if err := c.ShouldBindJSON(&u); err != nil {
 	// 	c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
@@ -26,65 +26,228 @@ func SignUp(c *gin.Context) {
 	// 	return
 	// }
 
-	ui, err := controllerUtils.InputSignUpAndLogin(c)
+	ui, err := controllerUtils.InputAndValidateSignUp(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
 
-	// IDを確定
-	u := models.NewUser(rand.Uint32(), ui.Name, ui.Password)
+	// IDはdbに登録するときに確定する
+	u := models.NewUser(0, ui.Name, ui.Password)
+	u.Email = ui.Email
 
-	// 既に同じuserNameとpasswordの組み合わせのユーザーが存在しないかを確認
-	if controllerUtils.IsExistUserSameUsernameAndPassword(u.Name, u.PassWord) {
-		c.JSON(http.StatusConflict, gin.H{"message": "already exist same username and password"})
+	// 既に同じuserNameのユーザーが存在しないかを確認(大文字小文字は区別しない)
+	if controllerUtils.IsExistUserSameUsername(u.Name) {
+		c.JSON(http.StatusConflict, gin.H{"message": "already exist same username"})
+		return
+	}
+
+	// emailが指定されている場合は同じemailのユーザーが存在しないかを確認
+	if u.Email != "" && controllerUtils.IsExistUserSameEmail(u.Email) {
+		c.JSON(http.StatusConflict, gin.H{"message": "already exist same email"})
 		return
 	}
 
 	// dbに登録
+	// 確認の後に同じusernameやemailで登録された場合も409を返す
 	if err := u.Create(); err != nil {
+		if errors.Is(err, models.ErrDuplicateUserName) || errors.Is(err, models.ErrDuplicateUserEmail) {
+			c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
+	// emailが指定されている場合は確認用のlinkを送信する
+	// 送信に失敗しても再送できるのでaccountの作成は成功とする
+	if u.Email != "" {
+		if err := sendVerificationMail(*u); err != nil {
+			fmt.Println(err)
+		}
+	}
+
 	c.IndentedJSON(http.StatusOK, u)
 }
 
+func loginLockoutId(c *gin.Context, in controllerUtils.SignUpAndLoginInput) string {
+	// passwordを確認するrouteは同じaccountの失敗回数を共有する
+	// usernameとemailのどちらでも同じaccountとして数え, 他人がaccountをロックできないようにIPごとに分ける
+	var u models.User
+	var err error
+	if in.Name != "" {
+		u, err = models.GetUserByName(in.Name)
+	} else {
+		u, err = models.GetUserByEmail(in.Email)
+	}
+	subject := fmt.Sprintf("user:%d", u.ID)
+	if err != nil {
+		// 存在しないuserの場合も同じように数える
+		subject = "name:" + strings.ToLower(in.Name)
+		if in.Name == "" {
+			subject = "email:" + strings.ToLower(in.Email)
+		}
+	}
+	return subject + ":ip:" + c.ClientIP()
+}
+
 func Login(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
 	// bodyの情報を取得
 
-	input, err := controllerUtils.InputSignUpAndLogin(c)
+	input, err := controllerUtils.InputAndValidateLogin(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
 
-	// usernameとpasswordからIDを特定
-	u, err := models.GetUserByNameAndPassword(input.Name, input.Password)
+	// 失敗が続いているaccountはロックする
+	lockoutId := loginLockoutId(c, input)
+	if isLockedOut(c, loginLockout, lockoutId) {
+		return
+	}
+
+	// usernameまたはemailとpasswordからIDを特定
+	var u models.User
+	if input.Name != "" {
+		u, err = models.GetUserByNameAndPassword(input.Name, input.Password)
+	} else {
+		u, err = models.GetUserByEmailAndPassword(input.Email, input.Password)
+	}
 	if err != nil {
+		recordFailure(loginLockout, lockoutId)
 		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
 		return
 	}
+	resetFailures(loginLockout, lockoutId)
+
+	// 無効化されたaccountにはloginできない
+	if u.IsDeactivated() {
+		c.JSON(http.StatusForbidden, gin.H{"message": "account is deactivated"})
+		return
+	}
+
+	// 平文のまま保存されているpasswordであればhash化して保存し直す
+	if u.HasLegacyPassword() {
+		if err := u.UpdatePassword(input.Password); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+	}
+
+	// 2段階認証が有効な場合はtokenの代わりにchallenge tokenを返す
+	enabled, err := models.IsTwoFactorEnabled(u.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if enabled {
+		challengeToken, err := token.GenerateTwoFactorChallengeToken(u.ID, input.DeviceName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		c.IndentedJSON(http.StatusOK, gin.H{"two_factor_required": true, "challenge_token": challengeToken, "user_id": u.ID, "username": u.Name})
+		return
+	}
+
+	respondNewSession(c, u, input.DeviceName)
+}
+
+func respondNewSession(c *gin.Context, u models.User, deviceName string) {
+	// sessionとrefreshTokenを作成
+	session, refreshToken, err := createSession(c, u.ID, deviceName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
 
 	// jwtTokenを作成
-	token, err := token.GenerateToken(u.ID)
+	jwtToken, err := token.GenerateToken(u.ID, session.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
-	c.IndentedJSON(http.StatusOK, gin.H{"token": token, "user_id": u.ID, "username": u.Name})
+	c.IndentedJSON(http.StatusOK, gin.H{"token": jwtToken, "refresh_token": refreshToken, "user_id": u.ID, "username": u.Name})
 }
 
-func GetCurrentUser(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
+func RefreshToken(c *gin.Context) {
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateRefreshToken(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
 
+	// refreshTokenからsessionを取得
+	sessionId, err := token.GetSessionIdFromRefreshToken(in.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+	session, err := models.GetSessionById(sessionId)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "session not found"})
+		return
+	}
+
+	// sessionが有効か確認
+	if !session.IsActive() {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "session is revoked or expired"})
+		return
+	}
+
+	// 使用済みのrefreshTokenが使われた場合は盗まれた可能性があるのでsessionごと無効にする
+	if !token.CompareRefreshToken(session.RefreshTokenHash, in.RefreshToken) {
+		if err := session.Revoke(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid refresh token"})
+		return
+	}
+
+	// refreshTokenを新しいものに入れ替える
+	refreshToken, err := token.GenerateRefreshToken(session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if err := session.RotateRefreshToken(token.HashRefreshToken(refreshToken), token.RefreshTokenExpiresAt()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// jwtTokenを作成
+	accessToken, err := token.GenerateToken(session.UserId, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{"token": accessToken, "refresh_token": refreshToken, "user_id": session.UserId})
+}
+
+func Logout(c *gin.Context) {
+	principal := CurrentPrincipal(c)
+
+	// requestに使われたsessionを無効にする
+	session, err := models.GetSessionById(principal.SessionId)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		return
+	}
+	if err := session.Revoke(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+func GetCurrentUser(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
 	user, err := models.GetUserById(userId)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
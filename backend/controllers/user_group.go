@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+func GetUserGroups(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserがworkspaceに参加しているかを確認
+	isGuest, err := controllerUtils.IsGuestInWorkspace(workspaceId, userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+
+	// guestはmemberの一覧を見られない
+	if isGuest {
+		c.JSON(http.StatusForbidden, gin.H{"message": "guest can't browse members"})
+		return
+	}
+
+	res, err := controllerUtils.GetUserGroupInfosByWorkspaceId(workspaceId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func authorizeManagingUserGroups(c *gin.Context, workspaceId int) bool {
+	userId := CurrentPrincipal(c).UserId
+	b, err := controllerUtils.Authorize(workspaceId, userId, models.CapUserGroupsManage)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return false
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return false
+	}
+	return true
+}
+
+func validateUserGroupTargets(c *gin.Context, workspaceId int, memberIds []uint32, channelIds []int) bool {
+	// memberはguestでないworkspaceのuser, channelはworkspaceのpublic channelのみ
+	for _, id := range memberIds {
+		wau, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(workspaceId, id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "member not found in workspace"})
+			return false
+		}
+		if models.IsGuestRole(wau.RoleId) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "guest can't be added to user group"})
+			return false
+		}
+	}
+	for _, id := range channelIds {
+		ch, err := models.GetChannelById(id)
+		if err != nil || ch.WorkspaceId != workspaceId {
+			c.JSON(http.StatusNotFound, gin.H{"message": "channel not found in workspace"})
+			return false
+		}
+		if !controllerUtils.IsAvailableAsDefaultChannel(ch) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "private or archived channel can't be attached to user group"})
+			return false
+		}
+	}
+	return true
+}
+
+func bindUserGroup(c *gin.Context) (models.UserGroup, bool) {
+	// path parameterのuser groupを取得し、requestしたuserがuser groupを管理できるか確認する
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return models.UserGroup{}, false
+	}
+	userGroupId, err := strconv.ParseUint(c.Param("user_group_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return models.UserGroup{}, false
+	}
+
+	if !authorizeManagingUserGroups(c, workspaceId) {
+		return models.UserGroup{}, false
+	}
+
+	// 他のworkspaceのgroupは存在しないものとして扱う
+	ug, err := models.GetUserGroupById(uint(userGroupId))
+	if err != nil || ug.WorkspaceId != workspaceId {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user group not found"})
+		return models.UserGroup{}, false
+	}
+	return ug, true
+}
+
+func CreateUserGroup(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateCreateUserGroup(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	if !authorizeManagingUserGroups(c, workspaceId) {
+		return
+	}
+	if !validateUserGroupTargets(c, workspaceId, in.MemberIds, in.ChannelIds) {
+		return
+	}
+
+	// 同じhandleのgroupがworkspaceに存在しないか確認
+	b, err := models.IsExistUserGroupHandle(workspaceId, in.Handle, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if b {
+		c.JSON(http.StatusConflict, gin.H{"message": "already exist same handle user group in workspace"})
+		return
+	}
+
+	ug := models.NewUserGroup(workspaceId, in.Handle, in.Name, in.Description, userId)
+	if err := ug.Create().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if err := models.SetUserGroupMembers(ug.ID, in.MemberIds); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if err := models.SetUserGroupChannels(ug.ID, in.ChannelIds); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// groupのmemberをchannelに参加させる
+	if _, err := controllerUtils.JoinUserGroupChannels(ug.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	res, err := controllerUtils.GetUserGroupInfo(*ug)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func UpdateUserGroup(c *gin.Context) {
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateUpdateUserGroup(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	ug, ok := bindUserGroup(c)
+	if !ok {
+		return
+	}
+
+	memberIds := make([]uint32, 0)
+	if in.MemberIds != nil {
+		memberIds = *in.MemberIds
+	}
+	channelIds := make([]int, 0)
+	if in.ChannelIds != nil {
+		channelIds = *in.ChannelIds
+	}
+	if !validateUserGroupTargets(c, ug.WorkspaceId, memberIds, channelIds) {
+		return
+	}
+
+	if in.Handle != nil {
+		b, err := models.IsExistUserGroupHandle(ug.WorkspaceId, *in.Handle, ug.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if b {
+			c.JSON(http.StatusConflict, gin.H{"message": "already exist same handle user group in workspace"})
+			return
+		}
+		ug.Handle = *in.Handle
+	}
+	if in.Name != nil {
+		ug.Name = *in.Name
+	}
+	if in.Description != nil {
+		ug.Description = *in.Description
+	}
+	if err := ug.Save().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if in.MemberIds != nil {
+		if err := models.SetUserGroupMembers(ug.ID, memberIds); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+	}
+	if in.ChannelIds != nil {
+		if err := models.SetUserGroupChannels(ug.ID, channelIds); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+	}
+
+	// 追加されたmemberやchannelに参加させる
+	if _, err := controllerUtils.JoinUserGroupChannels(ug.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	res, err := controllerUtils.GetUserGroupInfo(ug)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func DeleteUserGroup(c *gin.Context) {
+	ug, ok := bindUserGroup(c)
+	if !ok {
+		return
+	}
+
+	// groupを削除してもmemberはchannelに残る
+	if err := ug.Delete(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ug)
+}
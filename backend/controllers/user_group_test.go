@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/controllerUtils"
+	"backend/models"
+)
+
+func userGroupTestFunc(method string, workspaceId int, userGroupId uint, jwtToken string, input interface{}) *httptest.ResponseRecorder {
+	path := "/user_groups/" + strconv.Itoa(workspaceId)
+	if userGroupId != 0 {
+		path += "/" + strconv.FormatUint(uint64(userGroupId), 10)
+	}
+	return workspaceInviteTestFunc(method, path, jwtToken, input)
+}
+
+func TestUserGroups(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 権限がない場合 403, 不正なhandle 400, guestや他のworkspaceのuser 400 404, private channel 400, 正常な場合 200
+	// 2 groupのmemberはgroupのchannelに参加する, 同じhandle 409
+	// 3 channel messageとDMの@handleはchannelのmemberやDMの相手のgroupのmemberに展開され、messageと一緒に取得できる, guestは展開できない, DMを編集すると展開し直す
+	// 4 更新した場合 200 (追加されたmemberはchannelに参加する), 他のworkspaceのgroup 404
+	// 5 一覧を取得できる 200, guestは取得できない 403
+	// 6 削除した場合 200, 削除済みの場合 404
+
+	owner := signUpAndLoginTestFunc(t)
+	alice := signUpAndLoginTestFunc(t)
+	bob := signUpAndLoginTestFunc(t)
+	guest := signUpAndLoginTestFunc(t)
+	outsider := signUpAndLoginTestFunc(t)
+
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleFullMember, alice.UserId, owner.Token).Code)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleFullMember, bob.UserId, owner.Token).Code)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleMultiChannelGuest, guest.UserId, owner.Token).Code)
+
+	createChannel := func(isPrivate bool) models.Channel {
+		rr := createChannelTestFunc(randomstring.EnglishFrequencyString(30), "", &isPrivate, owner.Token, w.ID)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		ch := new(models.Channel)
+		json.Unmarshal(rr.Body.Bytes(), ch)
+		return *ch
+	}
+	public := createChannel(false)
+	private := createChannel(true)
+	other := createChannel(false)
+
+	handle := fmt.Sprintf("team-%d", rand.Int31())
+	ug := new(controllerUtils.UserGroupInfo)
+
+	t.Run("1", func(t *testing.T) {
+		in := controllerUtils.CreateUserGroupInput{Handle: handle, Name: "Backend", MemberIds: []uint32{alice.UserId}, ChannelIds: []int{public.ID}}
+		assert.Equal(t, http.StatusForbidden, userGroupTestFunc("POST", w.ID, 0, alice.Token, in).Code)
+		assert.Equal(t, http.StatusNotFound, userGroupTestFunc("POST", w.ID, 0, outsider.Token, in).Code)
+		for _, h := range []string{"", "Back End", "here"} {
+			assert.Equal(t, http.StatusBadRequest, userGroupTestFunc("POST", w.ID, 0, owner.Token, controllerUtils.CreateUserGroupInput{Handle: h, Name: "Backend"}).Code)
+		}
+		assert.Equal(t, http.StatusBadRequest, userGroupTestFunc("POST", w.ID, 0, owner.Token, controllerUtils.CreateUserGroupInput{Handle: handle}).Code)
+		assert.Equal(t, http.StatusBadRequest, userGroupTestFunc("POST", w.ID, 0, owner.Token, controllerUtils.CreateUserGroupInput{Handle: handle, Name: "Backend", MemberIds: []uint32{guest.UserId}}).Code)
+		assert.Equal(t, http.StatusNotFound, userGroupTestFunc("POST", w.ID, 0, owner.Token, controllerUtils.CreateUserGroupInput{Handle: handle, Name: "Backend", MemberIds: []uint32{outsider.UserId}}).Code)
+		assert.Equal(t, http.StatusBadRequest, userGroupTestFunc("POST", w.ID, 0, owner.Token, controllerUtils.CreateUserGroupInput{Handle: handle, Name: "Backend", ChannelIds: []int{private.ID}}).Code)
+		assert.Equal(t, http.StatusNotFound, userGroupTestFunc("POST", w.ID, 0, owner.Token, controllerUtils.CreateUserGroupInput{Handle: handle, Name: "Backend", ChannelIds: []int{-1}}).Code)
+
+		in.Handle = "@" + handle
+		rr := userGroupTestFunc("POST", w.ID, 0, owner.Token, in)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		json.Unmarshal(rr.Body.Bytes(), ug)
+		assert.Equal(t, handle, ug.Handle)
+		assert.Equal(t, []uint32{alice.UserId}, ug.MemberIds)
+		assert.Equal(t, []int{public.ID}, ug.ChannelIds)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.True(t, models.IsExistCAUByChannelIdAndUserId(public.ID, alice.UserId))
+		assert.False(t, models.IsExistCAUByChannelIdAndUserId(public.ID, bob.UserId))
+		in := controllerUtils.CreateUserGroupInput{Handle: handle, Name: "Backend"}
+		assert.Equal(t, http.StatusConflict, userGroupTestFunc("POST", w.ID, 0, owner.Token, in).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		rr := sendMessageTestFunc("hi @"+handle+" and @unknown", public.ID, owner.Token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		m := new(controllerUtils.MessageWithMentions)
+		json.Unmarshal(rr.Body.Bytes(), m)
+		assert.Equal(t, []uint32{alice.UserId}, m.MentionedUserIds)
+
+		// 送信者自身は含めない
+		rr = sendMessageTestFunc("@"+handle, public.ID, alice.Token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		json.Unmarshal(rr.Body.Bytes(), m)
+		assert.Equal(t, 0, len(m.MentionedUserIds))
+
+		// channelに参加していないmemberは含めない
+		rr = sendMessageTestFunc("@"+handle, other.ID, owner.Token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		json.Unmarshal(rr.Body.Bytes(), m)
+		assert.Equal(t, 0, len(m.MentionedUserIds))
+
+		// guestは展開できない
+		assert.Equal(t, http.StatusOK, addUserInChannelTestFunc(public.ID, guest.UserId, owner.Token).Code)
+		rr = sendMessageTestFunc("@"+handle, public.ID, guest.Token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		json.Unmarshal(rr.Body.Bytes(), m)
+		assert.Equal(t, 0, len(m.MentionedUserIds))
+
+		rr = getMessagesByChannelIdTestFunc(public.ID, owner.Token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		messages := make([]controllerUtils.MessageWithMentions, 0)
+		json.Unmarshal(rr.Body.Bytes(), &messages)
+		mentions := make(map[string][]uint32)
+		for _, m := range messages {
+			mentions[m.Text] = m.MentionedUserIds
+		}
+		assert.Equal(t, []uint32{alice.UserId}, mentions["hi @"+handle+" and @unknown"])
+		assert.Equal(t, []uint32{}, mentions["@"+handle])
+
+		// DMの相手でないmemberは含めない
+		rr = sendDMTestFunc("ping @"+handle, bob.Token, owner.UserId, w.ID)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		dm := new(controllerUtils.DMWithMentions)
+		json.Unmarshal(rr.Body.Bytes(), dm)
+		assert.Equal(t, 0, len(dm.MentionedUserIds))
+
+		rr = sendDMTestFunc("ping @"+handle, bob.Token, alice.UserId, w.ID)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		json.Unmarshal(rr.Body.Bytes(), dm)
+		assert.Equal(t, []uint32{alice.UserId}, dm.MentionedUserIds)
+
+		rr = getDMsInLineTestFunc(dm.DMLineId, alice.Token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		dms := make([]controllerUtils.DMWithMentions, 0)
+		json.Unmarshal(rr.Body.Bytes(), &dms)
+		assert.Equal(t, 1, len(dms))
+		assert.Equal(t, dm.ID, dms[0].ID)
+		assert.Equal(t, []uint32{alice.UserId}, dms[0].MentionedUserIds)
+
+		// 編集すると新しいtextから展開し直す
+		rr = editDMTestFunc(dm.ID, bob.Token, "ping")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		json.Unmarshal(rr.Body.Bytes(), dm)
+		assert.Equal(t, 0, len(dm.MentionedUserIds))
+		rr = getDMsInLineTestFunc(dm.DMLineId, alice.Token)
+		json.Unmarshal(rr.Body.Bytes(), &dms)
+		assert.Equal(t, []uint32{}, dms[0].MentionedUserIds)
+
+		rr = editDMTestFunc(dm.ID, bob.Token, "ping again @"+handle)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		json.Unmarshal(rr.Body.Bytes(), dm)
+		assert.Equal(t, []uint32{alice.UserId}, dm.MentionedUserIds)
+		rr = getDMsInLineTestFunc(dm.DMLineId, alice.Token)
+		json.Unmarshal(rr.Body.Bytes(), &dms)
+		assert.Equal(t, []uint32{alice.UserId}, dms[0].MentionedUserIds)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		name := "Backend Team"
+		memberIds := []uint32{alice.UserId, bob.UserId}
+		channelIds := []int{public.ID, other.ID}
+		in := controllerUtils.UpdateUserGroupInput{Name: &name, MemberIds: &memberIds, ChannelIds: &channelIds}
+		assert.Equal(t, http.StatusBadRequest, userGroupTestFunc("PATCH", w.ID, ug.ID, owner.Token, controllerUtils.UpdateUserGroupInput{}).Code)
+		assert.Equal(t, http.StatusForbidden, userGroupTestFunc("PATCH", w.ID, ug.ID, bob.Token, in).Code)
+
+		rr := userGroupTestFunc("PATCH", w.ID, ug.ID, owner.Token, in)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		res := new(controllerUtils.UserGroupInfo)
+		json.Unmarshal(rr.Body.Bytes(), res)
+		assert.Equal(t, name, res.Name)
+		assert.Equal(t, handle, res.Handle)
+		assert.ElementsMatch(t, memberIds, res.MemberIds)
+		assert.ElementsMatch(t, channelIds, res.ChannelIds)
+		for _, chId := range channelIds {
+			for _, userId := range memberIds {
+				assert.True(t, models.IsExistCAUByChannelIdAndUserId(chId, userId))
+			}
+		}
+
+		// 他のworkspaceのgroupは存在しないものとして扱う
+		rr = createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		w2 := new(models.Workspace)
+		json.Unmarshal(rr.Body.Bytes(), w2)
+		assert.Equal(t, http.StatusNotFound, userGroupTestFunc("PATCH", w2.ID, ug.ID, owner.Token, in).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		rr := userGroupTestFunc("GET", w.ID, 0, bob.Token, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		res := make([]controllerUtils.UserGroupInfo, 0)
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		assert.Equal(t, 1, len(res))
+		assert.Equal(t, ug.ID, res[0].ID)
+		assert.Equal(t, http.StatusForbidden, userGroupTestFunc("GET", w.ID, 0, guest.Token, nil).Code)
+		assert.Equal(t, http.StatusNotFound, userGroupTestFunc("GET", w.ID, 0, outsider.Token, nil).Code)
+	})
+
+	t.Run("6", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, userGroupTestFunc("DELETE", w.ID, ug.ID, bob.Token, nil).Code)
+		assert.Equal(t, http.StatusOK, userGroupTestFunc("DELETE", w.ID, ug.ID, owner.Token, nil).Code)
+		assert.Equal(t, http.StatusNotFound, userGroupTestFunc("DELETE", w.ID, ug.ID, owner.Token, nil).Code)
+		assert.True(t, models.IsExistCAUByChannelIdAndUserId(other.ID, bob.UserId))
+	})
+}
@@ -3,15 +3,18 @@ package controllers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xyproto/randomstring"
 
+	"backend/config"
 	"backend/controllerUtils"
 	"backend/models"
 )
@@ -52,15 +55,41 @@ func loginTestFunc(name, password string) *httptest.ResponseRecorder {
 	return w
 }
 
+func signUpWithEmailTestFunc(name, email, password string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	input := controllerUtils.SignUpAndLoginInput{
+		Name:     name,
+		Email:    email,
+		Password: password,
+	}
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest("POST", "/api/user/signUp", bytes.NewBuffer(jsonInput))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func loginWithEmailTestFunc(email, password string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	input := controllerUtils.SignUpAndLoginInput{
+		Email:    email,
+		Password: password,
+	}
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest("POST", "/api/user/login", bytes.NewBuffer(jsonInput))
+	router.ServeHTTP(w, req)
+	return w
+}
+
 func TestLogin(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode.")
 	}
 
 	// 1 正常な場合 200
-	// 2 usernameが同一のuserをpasswordで区別できているか 200
+	// 2 usernameの大文字小文字が異なっていてもloginできる 200
 	// 3 usernameかpasswordのどちらかがbodyに含まれていない場合 400
 	// 4 usernameとpasswordが一致するユーザーが存在しない場合 401
+	// 5 emailとpasswordでloginできる 200
 
 	// 1
 	t.Run("1", func(t *testing.T) {
@@ -95,33 +124,19 @@ func TestLogin(t *testing.T) {
 		}
 	})
 	t.Run("2", func(t *testing.T) {
-		ids := make([]uint32, 10)
 		name := randomstring.EnglishFrequencyString(30)
-		passwords := make([]string, 10)
-		for i := 0; i < 10; i++ {
-			passwords[i] = randomstring.EnglishFrequencyString(30)
-		}
-		for i, pass := range passwords {
-			rr := signUpTestFunc(name, pass)
-			assert.Equal(t, http.StatusOK, rr.Code)
-			byteArray, _ := ioutil.ReadAll(rr.Body)
-			jsonBody := ([]byte)(byteArray)
-			u := new(models.User)
-			json.Unmarshal(jsonBody, u)
-			ids[i] = u.ID
-			assert.Equal(t, name, u.Name)
-		}
-		for i, pass := range passwords {
-			rr := loginTestFunc(name, pass)
-			assert.Equal(t, http.StatusOK, rr.Code)
-			byteArray, _ := ioutil.ReadAll(rr.Body)
-			jsonBody := ([]byte)(byteArray)
-			lr := new(LoginResponse)
-			json.Unmarshal(jsonBody, lr)
-			assert.NotEmpty(t, lr.Token)
-			assert.Equal(t, name, lr.Username)
-			assert.Equal(t, ids[i], lr.UserId)
-		}
+		rr := signUpTestFunc(name, "pass")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		u := new(models.User)
+		json.Unmarshal(rr.Body.Bytes(), u)
+
+		rr = loginTestFunc(strings.ToUpper(name), "pass")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		lr := new(LoginResponse)
+		json.Unmarshal(rr.Body.Bytes(), lr)
+		assert.NotEmpty(t, lr.Token)
+		assert.Equal(t, name, lr.Username)
+		assert.Equal(t, u.ID, lr.UserId)
 	})
 
 	t.Run("3", func(t *testing.T) {
@@ -162,6 +177,26 @@ func TestLogin(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("5", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		email := name + "@example.com"
+		rr := signUpWithEmailTestFunc(name, email, "pass")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		u := new(models.User)
+		json.Unmarshal(rr.Body.Bytes(), u)
+		assert.Equal(t, email, u.Email)
+
+		rr = loginWithEmailTestFunc(strings.ToUpper(email), "pass")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		lr := new(LoginResponse)
+		json.Unmarshal(rr.Body.Bytes(), lr)
+		assert.NotEmpty(t, lr.Token)
+		assert.Equal(t, u.ID, lr.UserId)
+
+		assert.Equal(t, http.StatusUnauthorized, loginWithEmailTestFunc(email, "wrongPass").Code)
+		assert.Equal(t, http.StatusUnauthorized, loginWithEmailTestFunc("wrong"+email, "pass").Code)
+	})
 }
 
 func TestSignUp(t *testing.T) {
@@ -170,9 +205,12 @@ func TestSignUp(t *testing.T) {
 	}
 
 	// 1 普通の場合 200
-	// 2 usernameがuniqueでない場合 200
+	// 2 passwordが異なる場合 200
 	// 3 usernameかpasswordがbodyに含まれていない場合 400
-	// 4 usernameとpasswordが同一のuserが既に存在している場合 409
+	// 4 usernameが同一のuserが既に存在している場合 409
+	// 5 大文字小文字のみが異なるusernameのuserが既に存在している場合 409
+	// 6 emailが同一のuserが既に存在している場合 409
+	// 7 emailやusernameの形式が正しくない場合 400
 
 	// 1
 	t.Run("1", func(t *testing.T) {
@@ -216,8 +254,198 @@ func TestSignUp(t *testing.T) {
 		password := "pass"
 		rr := signUpTestFunc(username, password)
 		assert.Equal(t, http.StatusOK, rr.Code)
-		rr = signUpTestFunc(username, password)
+		rr = signUpTestFunc(username, "otherPass")
 		assert.Equal(t, http.StatusConflict, rr.Code)
-		assert.Equal(t, "{\"message\":\"already exist same username and password\"}", rr.Body.String())
+		assert.Equal(t, "{\"message\":\"already exist same username\"}", rr.Body.String())
+	})
+
+	// 5
+	t.Run("5", func(t *testing.T) {
+		username := randomstring.EnglishFrequencyString(30)
+		assert.Equal(t, http.StatusOK, signUpTestFunc(username, "pass").Code)
+		assert.Equal(t, http.StatusConflict, signUpTestFunc(strings.ToUpper(username), "pass").Code)
+	})
+
+	// 6
+	t.Run("6", func(t *testing.T) {
+		email := randomstring.EnglishFrequencyString(30) + "@example.com"
+		assert.Equal(t, http.StatusOK, signUpWithEmailTestFunc(randomstring.EnglishFrequencyString(30), email, "pass").Code)
+		rr := signUpWithEmailTestFunc(randomstring.EnglishFrequencyString(30), strings.ToUpper(email), "pass")
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		assert.Equal(t, "{\"message\":\"already exist same email\"}", rr.Body.String())
+	})
+
+	// 7
+	t.Run("7", func(t *testing.T) {
+		username := randomstring.EnglishFrequencyString(30)
+		assert.Equal(t, http.StatusBadRequest, signUpWithEmailTestFunc(username, "not an email", "pass").Code)
+		assert.Equal(t, http.StatusBadRequest, signUpTestFunc(username+"@example.com", "pass").Code)
+		assert.Equal(t, http.StatusBadRequest, signUpTestFunc(randomstring.EnglishFrequencyString(81), "pass").Code)
+		assert.Equal(t, http.StatusBadRequest, signUpTestFunc("   ", "pass").Code)
+	})
+}
+
+func TestPasswordNotInResponse(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 signUpのresponseにpasswordが含まれない
+	// 2 currentUserのresponseにpasswordが含まれない
+
+	name := randomstring.EnglishFrequencyString(30)
+	rr := signUpTestFunc(name, "pass")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "password")
+
+	rr = loginTestFunc(name, "pass")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	lr := new(LoginResponse)
+	json.Unmarshal(rr.Body.Bytes(), lr)
+
+	rr = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/user/currentUser", nil)
+	req.Header.Set("Authorization", lr.Token)
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "password")
+}
+
+func TestLoginWithLegacyPassword(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 平文でpasswordが保存されているuserでもloginでき、loginするとhash化される
+	name := randomstring.EnglishFrequencyString(30)
+	cmd := fmt.Sprintf(`INSERT INTO %s (name, password) VALUES ($1, $2)`, config.Config.UserTableName)
+	res, err := models.DbConnection.Exec(cmd, name, "pass")
+	assert.Empty(t, err)
+	id, err := res.LastInsertId()
+	assert.Empty(t, err)
+
+	assert.Equal(t, http.StatusOK, loginTestFunc(name, "pass").Code)
+	u, err := models.GetUserById(uint32(id))
+	assert.Empty(t, err)
+	assert.False(t, u.HasLegacyPassword())
+
+	assert.Equal(t, http.StatusOK, loginTestFunc(name, "pass").Code)
+	assert.Equal(t, http.StatusUnauthorized, loginTestFunc(name, "wrongPass").Code)
+}
+
+type RefreshResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	UserId       uint32 `json:"user_id"`
+}
+
+func refreshTestFunc(refreshToken string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(controllerUtils.RefreshTokenInput{RefreshToken: refreshToken})
+	req, _ := http.NewRequest("POST", "/api/user/refresh", bytes.NewBuffer(jsonInput))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func logoutTestFunc(jwtToken string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/user/logout", nil)
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func currentUserTestFunc(jwtToken string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/user/currentUser", nil)
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRefreshToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 正常な場合 200
+	// 2 一度使ったrefresh_tokenを再度使うとsessionごと無効になる 401
+	// 3 refresh_tokenがbodyに含まれていない場合 400
+	// 4 不正なrefresh_tokenの場合 401
+
+	name := randomstring.EnglishFrequencyString(30)
+	assert.Equal(t, http.StatusOK, signUpTestFunc(name, "pass").Code)
+
+	t.Run("1", func(t *testing.T) {
+		rr := loginTestFunc(name, "pass")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		lr := new(RefreshResponse)
+		json.Unmarshal(rr.Body.Bytes(), lr)
+		assert.NotEmpty(t, lr.RefreshToken)
+
+		rr = refreshTestFunc(lr.RefreshToken)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		res := new(RefreshResponse)
+		json.Unmarshal(rr.Body.Bytes(), res)
+		assert.NotEmpty(t, res.Token)
+		assert.NotEqual(t, lr.RefreshToken, res.RefreshToken)
+		assert.Equal(t, lr.UserId, res.UserId)
+		assert.Equal(t, http.StatusOK, currentUserTestFunc(res.Token).Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		rr := loginTestFunc(name, "pass")
+		lr := new(RefreshResponse)
+		json.Unmarshal(rr.Body.Bytes(), lr)
+
+		rr = refreshTestFunc(lr.RefreshToken)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		res := new(RefreshResponse)
+		json.Unmarshal(rr.Body.Bytes(), res)
+
+		assert.Equal(t, http.StatusUnauthorized, refreshTestFunc(lr.RefreshToken).Code)
+		assert.Equal(t, http.StatusUnauthorized, refreshTestFunc(res.RefreshToken).Code)
+		assert.Equal(t, http.StatusUnauthorized, currentUserTestFunc(res.Token).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, refreshTestFunc("").Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, refreshTestFunc("wrong token").Code)
+		assert.Equal(t, http.StatusUnauthorized, refreshTestFunc("wrong.token").Code)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 logoutしたtokenとrefresh_tokenは使えない
+	// 2 別のsessionのtokenは使える
+	// 3 tokenがない場合 401
+
+	name := randomstring.EnglishFrequencyString(30)
+	assert.Equal(t, http.StatusOK, signUpTestFunc(name, "pass").Code)
+
+	t.Run("1 2", func(t *testing.T) {
+		lr1 := new(RefreshResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr1)
+		lr2 := new(RefreshResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr2)
+
+		assert.Equal(t, http.StatusOK, currentUserTestFunc(lr1.Token).Code)
+		assert.Equal(t, http.StatusOK, logoutTestFunc(lr1.Token).Code)
+		assert.Equal(t, http.StatusUnauthorized, currentUserTestFunc(lr1.Token).Code)
+		assert.Equal(t, http.StatusUnauthorized, refreshTestFunc(lr1.RefreshToken).Code)
+		assert.Equal(t, http.StatusUnauthorized, logoutTestFunc(lr1.Token).Code)
+
+		assert.Equal(t, http.StatusOK, currentUserTestFunc(lr2.Token).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, logoutTestFunc("").Code)
 	})
 }
@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -11,12 +14,7 @@ import (
 )
 
 func CreateWorkspace(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	primaryOwnerId, err := Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
-		return
-	}
+	primaryOwnerId := CurrentPrincipal(c).UserId
 	// bodyの情報を取得
 	in, err := controllerUtils.InputAndValidateCreateWorkspace(c)
 	if err != nil {
@@ -39,43 +37,42 @@ func CreateWorkspace(c *gin.Context) {
 	}
 
 	// workspace_and_users tableにもuserを保存する
-	wau := models.NewWorkspaceAndUsers(w.ID, w.PrimaryOwnerId, 1)
+	// 以降で失敗した場合は作成途中のworkspaceを削除する
+	wau := models.NewWorkspaceAndUsers(w.ID, w.PrimaryOwnerId, models.RolePrimaryOwner)
 	err = wau.Create()
 	if err != nil {
-		// TODO deleteWorkspaceを実行する
+		rollbackCreateWorkspace(w.ID)
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 
 	// general channelを作成する
-	ch := models.NewChannel(0, "general", "all users join", false, false, w.ID)
+	ch := models.NewChannel(0, controllerUtils.GeneralChannelName, "all users join", false, false, w.ID)
 	if err := ch.Create(); err != nil {
+		rollbackCreateWorkspace(w.ID)
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
-		// TODO deleteWorkspaceを実行する
-		// TODO deleteWorkspaceAndUsersを実行する
 		return
 	}
 
 	// general channelにuserを追加する
 	cau := models.NewChannelsAndUses(ch.ID, primaryOwnerId, true)
 	if err := cau.Create(); err != nil {
+		rollbackCreateWorkspace(w.ID)
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
-		// TODO deleteWorkspaceを実行する
-		// TODO deleteWorkspaceAndUsersを実行する
-		// TODO delete general channel
 		return
 	}
 
 	c.IndentedJSON(http.StatusOK, w)
 }
 
-func AddUserInWorkspace(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
-		return
+func rollbackCreateWorkspace(workspaceId int) {
+	if err := models.PurgeWorkspace(workspaceId); err != nil {
+		fmt.Println(err)
 	}
+}
+
+func AddUserInWorkspace(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
 
 	// bodyの情報を受け取る
 	in, err := controllerUtils.InputAndValidateAddUserInWorkspace(c)
@@ -87,8 +84,8 @@ func AddUserInWorkspace(c *gin.Context) {
 	// WorkspaceAndUser structを作成
 	wau := models.NewWorkspaceAndUsers(in.WorkspaceId, in.UserId, in.RoleId)
 
-	// roleId = 1でないかを確認
-	if wau.RoleId == 1 {
+	// primary ownerとして追加していないかを確認
+	if wau.RoleId == models.RolePrimaryOwner {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "can't add roleId = 1"})
 		return
 	}
@@ -99,28 +96,73 @@ func AddUserInWorkspace(c *gin.Context) {
 		return
 	}
 
-	// userIdがそのworkspaceで追加する権限を持っているかを判定(roleId == 1 or roleId == 2 or roleId == 3)
-	if !controllerUtils.HasPermissionAddUserInWorkspace(userId, wau.WorkspaceId) {
+	// userIdがそのworkspaceで追加する権限を持っているかを判定
+	if b, err := controllerUtils.Authorize(wau.WorkspaceId, userId, models.CapMembersAdd); !b || err != nil {
 		c.JSON(http.StatusForbidden, gin.H{"message": "Unauthorized add user in workspace"})
 		return
 	}
 
+	// 自分より強い権限のroleでは追加できない
+	requestRoleId, err := models.GetRoleIdByWorkspaceIdAndUserId(wau.WorkspaceId, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	b, err := controllerUtils.CanGrantRole(wau.WorkspaceId, requestRoleId, wau.RoleId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "can't add user with higher role"})
+		return
+	}
+
+	// emailの確認が済んでいないuserは追加できない
+	b, err = controllerUtils.CanJoinWorkspace(wau.WorkspaceId, wau.UserId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found"})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "email is not verified"})
+		return
+	}
+	b, err = controllerUtils.HasAllowedEmailDomain(wau.WorkspaceId, wau.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "email domain is not allowed"})
+		return
+	}
+
 	// dbに保存する
 	err = wau.Create()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
+
+	// guestの有効期限を設定する
+	if in.ExpiresAt != nil {
+		if err := models.SetExpiresAtInWorkspace(wau.WorkspaceId, wau.UserId, in.ExpiresAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+	}
+
+	// generalなどのchannelに参加させる
+	if err := controllerUtils.JoinDefaultChannels(*wau); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
 	c.IndentedJSON(http.StatusOK, wau)
 }
 
 func RenameWorkspaceName(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
-		return
-	}
+	userId := CurrentPrincipal(c).UserId
 
 	// path parameterの値を取得
 	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
@@ -137,8 +179,8 @@ func RenameWorkspaceName(c *gin.Context) {
 	}
 	w := models.NewWorkspace(workspaceId, in.WorkspaceName, userId)
 
-	// requestしているuserがそのworkspaceのrole = 1 or role = 2 or role = 3かどうかを判定
-	b, err := controllerUtils.HasPermissionRenamingWorkspaceName(w.ID, userId)
+	// requestしているuserがworkspaceの名前を変更できるかを判定
+	b, err := controllerUtils.Authorize(w.ID, userId, models.CapWorkspaceRename)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
@@ -158,12 +200,7 @@ func RenameWorkspaceName(c *gin.Context) {
 }
 
 func DeleteUserFromWorkSpace(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
-		return
-	}
+	userId := CurrentPrincipal(c).UserId
 
 	// bodyの情報を取得
 	in, err := controllerUtils.InputAndValidateDeleteUserFromWorkspace(c)
@@ -179,8 +216,8 @@ func DeleteUserFromWorkSpace(c *gin.Context) {
 		return
 	}
 
-	// requestしたuserがそのworkspaceのrole = 1 or role = 2 or role = 3かどうかチェック
-	b, err := controllerUtils.HasPermissionDeletingUserFromWorkspace(wau.WorkspaceId, userId)
+	// requestしたuserがworkspaceからuserを削除できるかチェック
+	b, err := controllerUtils.Authorize(wau.WorkspaceId, userId, models.CapMembersRemove)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
@@ -190,26 +227,62 @@ func DeleteUserFromWorkSpace(c *gin.Context) {
 		return
 	}
 
-	// 削除されるユーザーがPrimaryOwnerすなわち role = 1でないかチェック
-	if wau.RoleId == 1 {
+	// 削除されるユーザーがPrimaryOwnerでないかチェック
+	if wau.RoleId == models.RolePrimaryOwner {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "not delete primary owner"})
 		return
 	}
 
-	if err := wau.DeleteWorkspaceAndUser(); err != nil {
+	// 自分より弱い権限のuserのみ削除できる
+	requestRoleId, err := models.GetRoleIdByWorkspaceIdAndUserId(wau.WorkspaceId, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if b, err := controllerUtils.CanManageMember(requestRoleId, wau.RoleId); !b || err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	// 自分で抜ける場合と同様にchannelやuser groupからも削除する
+	if err := controllerUtils.LeaveWorkspace(wau); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, wau)
 }
 
-func GetWorkspacesByUserId(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
+func LeaveWorkspace(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserがworkspaceに参加しているかを確認
+	wau, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(workspaceId, userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+
+	// primary ownerは権限を譲渡するまで抜けられない
+	if wau.RoleId == models.RolePrimaryOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "primary owner must transfer ownership before leaving"})
+		return
+	}
+
+	if err := controllerUtils.LeaveWorkspace(wau); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, wau)
+}
+
+func GetWorkspacesByUserId(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
 
 	// workspace structの配列を取得する
 	workspaces, err := controllerUtils.GetWorkspacesByUserId(userId)
@@ -220,12 +293,143 @@ func GetWorkspacesByUserId(c *gin.Context) {
 }
 
 func GetUsersInWorkspace(c *gin.Context) {
-	c.Header("Access-Control-Allow-Origin", "*")
-	userId, err := Authenticate(c)
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
 	}
+
+	// requestしたuserがworkspaceに存在しているか確認
+	isGuest, err := controllerUtils.IsGuestInWorkspace(workspaceId, userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+
+	// guestはmemberの一覧を見られない
+	if isGuest {
+		c.JSON(http.StatusForbidden, gin.H{"message": "guest can't browse members"})
+		return
+	}
+
+	// userの情報を取得する
+	res, err := controllerUtils.GetUserInWorkspace(workspaceId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func DeactivateUserInWorkspace(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateWorkspaceMember(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// 無効化されるuserがワークスペースに存在するかを確認
+	wau, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(in.WorkspaceId, in.UserId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserがworkspaceのuserを無効化できるかチェック
+	b, err := controllerUtils.Authorize(wau.WorkspaceId, userId, models.CapMembersDeactivate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	// 無効化されるユーザーがPrimaryOwnerでないかチェック
+	if wau.RoleId == models.RolePrimaryOwner {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "not deactivate primary owner"})
+		return
+	}
+
+	// 自分より弱い権限のuserのみ無効化できる
+	requestRoleId, err := models.GetRoleIdByWorkspaceIdAndUserId(wau.WorkspaceId, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if b, err := controllerUtils.CanManageMember(requestRoleId, wau.RoleId); !b || err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	if err := models.SetDeactivatedInWorkspace(wau.WorkspaceId, wau.UserId, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, wau)
+}
+
+func ReactivateUserInWorkspace(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateWorkspaceMember(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserがworkspaceのuserを無効化できるかチェック
+	b, err := controllerUtils.Authorize(in.WorkspaceId, userId, models.CapMembersDeactivate)
+	if err != nil || !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	// 無効化されたuserがワークスペースに存在するかを確認
+	deactivated, err := models.IsDeactivatedInWorkspace(in.WorkspaceId, in.UserId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": err.Error()})
+		return
+	}
+	if !deactivated {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "user is not deactivated"})
+		return
+	}
+
+	// 有効期限を過ぎて無効化されたguestは有効期限をなくす
+	expiresAt, err := models.GetExpiresAtInWorkspace(in.WorkspaceId, in.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		if err := models.SetExpiresAtInWorkspace(in.WorkspaceId, in.UserId, nil); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+	}
+
+	if err := models.SetDeactivatedInWorkspace(in.WorkspaceId, in.UserId, false); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	wau, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(in.WorkspaceId, in.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, wau)
+}
+
+func GetWorkspaceSetting(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
 	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
@@ -238,12 +442,331 @@ func GetUsersInWorkspace(c *gin.Context) {
 		return
 	}
 
-	// userの情報を取得する
-	res, err := controllerUtils.GetUserInWorkspace(workspaceId)
+	ws, err := models.GetWorkspaceSettingByWorkspaceId(workspaceId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, ws)
+}
 
-	c.JSON(http.StatusOK, res)
+func UpdateWorkspaceSetting(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateUpdateWorkspaceSetting(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserがworkspaceの設定を変更できるかチェック
+	b, err := controllerUtils.Authorize(workspaceId, userId, models.CapWorkspaceSettings)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	ws, err := models.GetWorkspaceSettingByWorkspaceId(workspaceId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if in.DeletedUserMessagePolicy != nil {
+		ws.DeletedUserMessagePolicy = *in.DeletedUserMessagePolicy
+	}
+	if in.RequireVerifiedEmail != nil {
+		ws.RequireVerifiedEmail = *in.RequireVerifiedEmail
+	}
+	if in.PublicChannelCreatePolicy != nil {
+		ws.PublicChannelCreatePolicy = *in.PublicChannelCreatePolicy
+	}
+	if in.PrivateChannelCreatePolicy != nil {
+		ws.PrivateChannelCreatePolicy = *in.PrivateChannelCreatePolicy
+	}
+	if in.InvitePolicy != nil {
+		ws.InvitePolicy = *in.InvitePolicy
+	}
+	if in.AllowChannelMention != nil {
+		ws.AllowChannelMention = *in.AllowChannelMention
+	}
+	if in.MessageRetentionDays != nil {
+		ws.MessageRetentionDays = *in.MessageRetentionDays
+	}
+	if in.AllowedEmailDomains != nil {
+		ws.AllowedEmailDomains = strings.Join(*in.AllowedEmailDomains, " ")
+	}
+	if in.Description != nil {
+		ws.Description = *in.Description
+	}
+	if in.IconUrl != nil {
+		ws.IconUrl = *in.IconUrl
+	}
+	if err := ws.Save().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ws)
+}
+
+func ChangeRoleInWorkspace(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateChangeRoleInWorkspace(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserと変更されるuserがworkspaceに存在するか確認
+	requestRoleId, err := models.GetRoleIdByWorkspaceIdAndUserId(in.WorkspaceId, userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+	wau, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(in.WorkspaceId, in.UserId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "target user not found in workspace"})
+		return
+	}
+
+	// roleを変更する権限があるかを確認
+	if b, err := controllerUtils.Authorize(in.WorkspaceId, userId, models.CapMembersChangeRole); !b || err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	// 自分より弱い権限のuserに、自分以下の権限のみ付与できる
+	b, err := controllerUtils.CanChangeRole(in.WorkspaceId, requestRoleId, wau.RoleId, in.RoleId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	// guestに変更する場合は, guestの制限を超えるchannelやuser groupから先に外す必要がある
+	if models.IsGuestRole(in.RoleId) {
+		b, err := controllerUtils.ExceedsGuestChannelLimit(wau, in.RoleId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if b {
+			c.JSON(http.StatusConflict, gin.H{"message": "single-channel guest can only join one channel"})
+			return
+		}
+		b, err = models.IsUserGroupMemberInWorkspace(wau.WorkspaceId, wau.UserId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+		if b {
+			c.JSON(http.StatusConflict, gin.H{"message": "guest can't be a member of user groups"})
+			return
+		}
+	}
+
+	if err := wau.UpdateRoleId(in.RoleId); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// guestでなくなったuserの有効期限をなくす
+	if !models.IsGuestRole(in.RoleId) {
+		if err := models.SetExpiresAtInWorkspace(wau.WorkspaceId, wau.UserId, nil); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, wau)
+}
+
+func SetGuestExpiration(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateSetGuestExpiration(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserがworkspaceのuserを無効化できるかチェック
+	b, err := controllerUtils.Authorize(in.WorkspaceId, userId, models.CapMembersDeactivate)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	// 有効期限はguestにのみ設定できる
+	wau, err := models.GetWorkspaceAndUserByWorkspaceIdAndUserId(in.WorkspaceId, in.UserId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "target user not found in workspace"})
+		return
+	}
+	if !models.IsGuestRole(wau.RoleId) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "user is not guest"})
+		return
+	}
+
+	if err := models.SetExpiresAtInWorkspace(wau.WorkspaceId, wau.UserId, in.ExpiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"workspace_id": wau.WorkspaceId, "user_id": wau.UserId, "role_id": wau.RoleId, "expires_at": in.ExpiresAt})
+}
+
+func TransferPrimaryOwnership(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateTransferPrimaryOwnership(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// primary ownerのみ譲渡できる
+	w, err := models.GetWorkspaceById(in.WorkspaceId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "workspace not found"})
+		return
+	}
+	if w.PrimaryOwnerId != userId {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not primary owner"})
+		return
+	}
+	if in.UserId == userId {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "already primary owner"})
+		return
+	}
+
+	// 取り消せない操作なのでpasswordを確認する
+	b, err := controllerUtils.IsCorrectPassword(userId, in.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "wrong password"})
+		return
+	}
+
+	// 譲渡されるuserがworkspaceに存在するか確認
+	if !controllerUtils.IsExistWAUByWorkspaceIdAndUserId(in.WorkspaceId, in.UserId) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "target user not found in workspace"})
+		return
+	}
+
+	// guestはprimary ownerになれない
+	b, err = controllerUtils.IsGuestInWorkspace(in.WorkspaceId, in.UserId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if b {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "guest can't be primary owner"})
+		return
+	}
+
+	if err := models.TransferPrimaryOwnership(w.ID, userId, in.UserId); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+		return
+	}
+	w.PrimaryOwnerId = in.UserId
+	c.JSON(http.StatusOK, w)
+}
+
+func DeleteWorkspace(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidatePassword(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// primary ownerのみ削除できる
+	w, err := models.GetWorkspaceById(workspaceId)
+	if err != nil || w.IsDeleted() {
+		c.JSON(http.StatusNotFound, gin.H{"message": "workspace not found"})
+		return
+	}
+	if w.PrimaryOwnerId != userId {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not primary owner"})
+		return
+	}
+
+	// passwordを再確認
+	b, err := controllerUtils.IsCorrectPassword(userId, in.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "wrong password"})
+		return
+	}
+
+	// 猶予期間の後にbackgroundで完全に削除する
+	if err := w.SoftDelete(); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"workspace": w, "purge_at": controllerUtils.WorkspacePurgeAt(w)})
+}
+
+func RestoreWorkspace(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// 削除されたworkspaceのmemberは取得できないのでworkspaceのprimary ownerと比較する
+	w, err := models.GetWorkspaceById(workspaceId)
+	if err != nil || !w.IsDeleted() {
+		c.JSON(http.StatusNotFound, gin.H{"message": "deleted workspace not found"})
+		return
+	}
+	if w.PrimaryOwnerId != userId {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not primary owner"})
+		return
+	}
+
+	// 猶予期間を過ぎたものは復元できない
+	if !time.Now().Before(controllerUtils.WorkspacePurgeAt(w)) {
+		c.JSON(http.StatusGone, gin.H{"message": "grace period has expired"})
+		return
+	}
+
+	if err := w.Restore(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, w)
 }
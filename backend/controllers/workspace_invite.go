@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"backend/config"
+	"backend/controllerUtils"
+	"backend/mailer"
+	"backend/models"
+	"backend/utils"
+)
+
+func CreateWorkspaceInvite(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateCreateWorkspaceInvite(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserが招待できるかチェック(workspaceの設定で招待を許可されたroleも含む)
+	b, err := controllerUtils.Authorize(workspaceId, userId, models.CapMembersInvite)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+	// guestは参加するchannelと有効期限を指定する必要があるので招待では追加できない
+	if models.IsGuestRole(in.RoleId) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "guest can't be invited"})
+		return
+	}
+	// 自分より強い権限のroleでは招待できない
+	requestRoleId, err := models.GetRoleIdByWorkspaceIdAndUserId(workspaceId, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	b, err = controllerUtils.CanGrantRole(workspaceId, requestRoleId, in.RoleId)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "can't invite user with higher role"})
+		return
+	}
+	w, err := models.GetWorkspaceById(workspaceId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "workspace not found"})
+		return
+	}
+	// 許可されていないdomainのemailには招待を送らない
+	ws, err := models.GetWorkspaceSettingByWorkspaceId(workspaceId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if in.Email != "" && !ws.IsAllowedEmail(in.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "email domain is not allowed"})
+		return
+	}
+
+	// 招待codeを作成してhash値のみ保存する
+	code, err := utils.GenerateRandomString(16)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	expiresAt := time.Now().Add(time.Hour * time.Duration(in.ExpiresInHours))
+	wi := models.NewWorkspaceInvite(workspaceId, utils.HashToken(code), in.RoleId, in.MaxUses, expiresAt, userId)
+	wi.Email = in.Email
+	if err := wi.Create().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// emailが指定されている場合はmailで招待linkを送信
+	link := fmt.Sprintf("%s/invite?code=%s", config.Config.FrontendBaseUrl, url.QueryEscape(code))
+	if wi.Email != "" {
+		m := mailer.Mail{
+			To:      wi.Email,
+			Subject: fmt.Sprintf("%s への招待", w.Name),
+			Body:    fmt.Sprintf("ワークスペース %s に招待されました。\n\n以下のリンクから参加してください。リンクの有効期限は%s までです。\n%s\n\n心当たりがない場合はこのメールを無視してください。\n", w.Name, expiresAt.Format("2006-01-02 15:04"), link),
+		}
+		if err := mailer.Send(m); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	// codeはこのresponseでのみ返す
+	c.JSON(http.StatusOK, gin.H{"code": code, "invite_link": link, "invite": wi})
+}
+
+func GetWorkspaceInvites(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserが招待できるかチェック(workspaceの設定で招待を許可されたroleも含む)
+	b, err := controllerUtils.Authorize(workspaceId, userId, models.CapMembersInvite)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	res, err := models.GetPendingWorkspaceInvitesByWorkspaceId(workspaceId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func RevokeWorkspaceInvite(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+	workspaceId, err := strconv.Atoi(c.Param("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+	inviteId, err := strconv.ParseUint(c.Param("invite_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// requestしたuserが招待できるかチェック(workspaceの設定で招待を許可されたroleも含む)
+	b, err := controllerUtils.Authorize(workspaceId, userId, models.CapMembersInvite)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "user not found in workspace"})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "not permission"})
+		return
+	}
+
+	// 他のworkspaceの招待は存在しないものとして扱う
+	wi, err := models.GetWorkspaceInviteById(uint(inviteId))
+	if err != nil || wi.WorkspaceId != workspaceId {
+		c.JSON(http.StatusNotFound, gin.H{"message": "invite not found"})
+		return
+	}
+	if wi.RevokedAt == nil {
+		if err := wi.Revoke(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "invite revoked"})
+}
+
+func AcceptWorkspaceInvite(c *gin.Context) {
+	userId := CurrentPrincipal(c).UserId
+
+	// bodyの情報を取得
+	in, err := controllerUtils.InputAndValidateAcceptWorkspaceInvite(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
+
+	// 招待が有効か確認
+	// guestの招待は以前のversionで作成されたものも使えない
+	wi, err := models.GetWorkspaceInviteByCodeHash(utils.HashToken(in.Code))
+	if err != nil || !wi.IsValid() || models.IsGuestRole(wi.RoleId) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid or expired invite"})
+		return
+	}
+
+	// 削除されたworkspaceには参加できない
+	if !controllerUtils.IsExistWorkspaceById(wi.WorkspaceId) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "workspace not found"})
+		return
+	}
+
+	// emailを指定した招待はそのemailのuserのみ使える
+	u, err := models.GetUserById(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if wi.Email != "" && !strings.EqualFold(wi.Email, u.Email) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "invite is for another email"})
+		return
+	}
+
+	// 既に参加している場合, workspaceで無効化されている場合は参加できない
+	deactivated, err := models.IsDeactivatedInWorkspace(wi.WorkspaceId, userId)
+	if err == nil {
+		if deactivated {
+			c.JSON(http.StatusForbidden, gin.H{"message": "user is deactivated in workspace"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"message": "already joined workspace"})
+		return
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+
+	// emailの確認が済んでいないuserは参加できない
+	b, err := controllerUtils.CanJoinWorkspace(wi.WorkspaceId, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "email is not verified"})
+		return
+	}
+	// workspaceで許可されたdomainのemailを持つuserのみ参加できる
+	b, err = controllerUtils.HasAllowedEmailDomain(wi.WorkspaceId, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !b {
+		c.JSON(http.StatusForbidden, gin.H{"message": "email domain is not allowed"})
+		return
+	}
+
+	// 招待を使用済みにしてworkspaceに参加する
+	ok, err := wi.Accept(userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "invalid or expired invite"})
+		return
+	}
+
+	// defaultのchannelに参加する
+	wau := models.NewWorkspaceAndUsers(wi.WorkspaceId, userId, wi.RoleId)
+	if err := controllerUtils.JoinDefaultChannels(*wau); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, wau)
+}
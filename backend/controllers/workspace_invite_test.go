@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+
+	"backend/controllerUtils"
+	"backend/mailer"
+	"backend/models"
+)
+
+type CreateWorkspaceInviteResponse struct {
+	Code       string                 `json:"code"`
+	InviteLink string                 `json:"invite_link"`
+	Invite     models.WorkspaceInvite `json:"invite"`
+}
+
+func workspaceInviteTestFunc(method, path, jwtToken string, input interface{}) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest(method, "/api/workspace"+path, bytes.NewBuffer(jsonInput))
+	req.Header.Set("Authorization", jwtToken)
+	router.ServeHTTP(rr, req)
+	return rr
+}
+
+func createWorkspaceInviteTestFunc(t *testing.T, workspaceId int, jwtToken string, input controllerUtils.CreateWorkspaceInviteInput) CreateWorkspaceInviteResponse {
+	rr := workspaceInviteTestFunc("POST", "/invites/"+strconv.Itoa(workspaceId), jwtToken, input)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	res := CreateWorkspaceInviteResponse{}
+	json.Unmarshal(rr.Body.Bytes(), &res)
+	return res
+}
+
+func acceptWorkspaceInviteTestFunc(code, jwtToken string) *httptest.ResponseRecorder {
+	return workspaceInviteTestFunc("POST", "/join", jwtToken, controllerUtils.AcceptWorkspaceInviteInput{Code: code})
+}
+
+// signUpしてloginしたuserを返す
+func signUpAndLoginTestFunc(t *testing.T) *LoginResponse {
+	name := randomstring.EnglishFrequencyString(30)
+	assert.Equal(t, http.StatusOK, signUpTestFunc(name, "pass").Code)
+	lr := new(LoginResponse)
+	json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr)
+	return lr
+}
+
+func TestWorkspaceInvite(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 admin以上のuserは招待を作成できる 200, 一般memberの場合 403, workspaceにいないuserの場合 404, role_idが1やguestの場合 400
+	// 2 招待codeで参加するとworkspaceとgeneral channelに参加できる 200, 既に参加している場合 409
+	// 3 max_usesの回数を超えて使えない 400
+	// 4 emailを指定した招待はmailが送信され、そのemailのuserのみ参加できる
+	// 5 招待の一覧を取得して取り消せる, 取り消した招待は使えない 400
+	// 6 他のworkspaceの招待は取り消せない 404
+
+	m := mailer.NewMemoryMailer()
+	defaultMailer := mailer.DefaultMailer
+	mailer.DefaultMailer = m
+	defer func() { mailer.DefaultMailer = defaultMailer }()
+
+	owner := signUpAndLoginTestFunc(t)
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+	channels, err := models.GetChannelsByWorkspaceId(w.ID)
+	assert.Empty(t, err)
+	general := channels[0]
+
+	member := signUpAndLoginTestFunc(t)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, 4, member.UserId, owner.Token).Code)
+
+	var invite CreateWorkspaceInviteResponse
+
+	t.Run("1", func(t *testing.T) {
+		invite = createWorkspaceInviteTestFunc(t, w.ID, owner.Token, controllerUtils.CreateWorkspaceInviteInput{MaxUses: 2})
+		assert.NotEmpty(t, invite.Code)
+		assert.Contains(t, invite.InviteLink, invite.Code)
+		assert.Equal(t, 4, invite.Invite.RoleId)
+
+		path := "/invites/" + strconv.Itoa(w.ID)
+		assert.Equal(t, http.StatusForbidden, workspaceInviteTestFunc("POST", path, member.Token, controllerUtils.CreateWorkspaceInviteInput{}).Code)
+		other := signUpAndLoginTestFunc(t)
+		assert.Equal(t, http.StatusNotFound, workspaceInviteTestFunc("POST", path, other.Token, controllerUtils.CreateWorkspaceInviteInput{}).Code)
+		assert.Equal(t, http.StatusBadRequest, workspaceInviteTestFunc("POST", path, owner.Token, controllerUtils.CreateWorkspaceInviteInput{RoleId: 1}).Code)
+		for _, roleId := range []int{models.RoleMultiChannelGuest, models.RoleSingleChannelGuest} {
+			assert.Equal(t, http.StatusBadRequest, workspaceInviteTestFunc("POST", path, owner.Token, controllerUtils.CreateWorkspaceInviteInput{RoleId: roleId}).Code)
+		}
+	})
+
+	t.Run("2", func(t *testing.T) {
+		u := signUpAndLoginTestFunc(t)
+		assert.Equal(t, http.StatusOK, acceptWorkspaceInviteTestFunc(invite.Code, u.Token).Code)
+		roleId, err := models.GetRoleIdByWorkspaceIdAndUserId(w.ID, u.UserId)
+		assert.Empty(t, err)
+		assert.Equal(t, 4, roleId)
+		assert.True(t, models.IsExistCAUByChannelIdAndUserId(general.ID, u.UserId))
+
+		assert.Equal(t, http.StatusConflict, acceptWorkspaceInviteTestFunc(invite.Code, u.Token).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, acceptWorkspaceInviteTestFunc(invite.Code, signUpAndLoginTestFunc(t).Token).Code)
+		assert.Equal(t, http.StatusBadRequest, acceptWorkspaceInviteTestFunc(invite.Code, signUpAndLoginTestFunc(t).Token).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		email := randomstring.EnglishFrequencyString(20) + "@example.com"
+		assert.Equal(t, http.StatusOK, signUpWithEmailTestFunc(name, email, "pass").Code)
+		assert.Equal(t, http.StatusOK, verifyEmailTestFunc(resetTokenFromMail(t, m, email)).Code)
+		lr := new(LoginResponse)
+		json.Unmarshal(loginTestFunc(name, "pass").Body.Bytes(), lr)
+
+		res := createWorkspaceInviteTestFunc(t, w.ID, owner.Token, controllerUtils.CreateWorkspaceInviteInput{Email: email, RoleId: 3})
+		assert.Equal(t, 1, res.Invite.MaxUses)
+		mail, ok := m.LastMailTo(email)
+		assert.True(t, ok)
+		assert.Contains(t, mail.Body, res.InviteLink)
+		code := res.Code
+
+		assert.Equal(t, http.StatusForbidden, acceptWorkspaceInviteTestFunc(code, signUpAndLoginTestFunc(t).Token).Code)
+		assert.Equal(t, http.StatusOK, acceptWorkspaceInviteTestFunc(code, lr.Token).Code)
+		roleId, err := models.GetRoleIdByWorkspaceIdAndUserId(w.ID, lr.UserId)
+		assert.Empty(t, err)
+		assert.Equal(t, 3, roleId)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		res := createWorkspaceInviteTestFunc(t, w.ID, owner.Token, controllerUtils.CreateWorkspaceInviteInput{})
+		rr := workspaceInviteTestFunc("GET", "/invites/"+strconv.Itoa(w.ID), owner.Token, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		pending := make([]models.WorkspaceInvite, 0)
+		json.Unmarshal(rr.Body.Bytes(), &pending)
+		ids := make([]uint, 0)
+		for _, wi := range pending {
+			ids = append(ids, wi.ID)
+		}
+		assert.Contains(t, ids, res.Invite.ID)
+		assert.NotContains(t, ids, invite.Invite.ID)
+
+		path := "/invites/" + strconv.Itoa(w.ID) + "/" + strconv.Itoa(int(res.Invite.ID))
+		assert.Equal(t, http.StatusForbidden, workspaceInviteTestFunc("DELETE", path, member.Token, nil).Code)
+		assert.Equal(t, http.StatusOK, workspaceInviteTestFunc("DELETE", path, owner.Token, nil).Code)
+		assert.Equal(t, http.StatusBadRequest, acceptWorkspaceInviteTestFunc(res.Code, signUpAndLoginTestFunc(t).Token).Code)
+	})
+
+	t.Run("6", func(t *testing.T) {
+		other := signUpAndLoginTestFunc(t)
+		rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), other.Token, other.UserId)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		ow := new(models.Workspace)
+		json.Unmarshal(rr.Body.Bytes(), ow)
+
+		res := createWorkspaceInviteTestFunc(t, w.ID, owner.Token, controllerUtils.CreateWorkspaceInviteInput{})
+		path := "/invites/" + strconv.Itoa(ow.ID) + "/" + strconv.Itoa(int(res.Invite.ID))
+		assert.Equal(t, http.StatusNotFound, workspaceInviteTestFunc("DELETE", path, other.Token, nil).Code)
+	})
+}
@@ -10,10 +10,12 @@ import (
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xyproto/randomstring"
 
+	"backend/config"
 	"backend/controllerUtils"
 	"backend/models"
 )
@@ -89,6 +91,27 @@ func GetUsersInWorkspaceTestFunc(workspaceId int, jwtToken string) *httptest.Res
 	return rr
 }
 
+func workspaceMemberTestFunc(path string, workspaceId int, userId uint32, jwtToken string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(controllerUtils.WorkspaceMemberInput{
+		WorkspaceId: workspaceId,
+		UserId:      userId,
+	})
+	req, _ := http.NewRequest("POST", "/api/workspace/"+path, bytes.NewBuffer(jsonInput))
+	req.Header.Add("Authorization", jwtToken)
+	workspaceRouter.ServeHTTP(rr, req)
+	return rr
+}
+
+func updateWorkspaceSettingTestFunc(workspaceId int, jwtToken string, input controllerUtils.UpdateWorkspaceSettingInput) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(input)
+	req, _ := http.NewRequest("PATCH", "/api/workspace/settings/"+strconv.Itoa(workspaceId), bytes.NewBuffer(jsonInput))
+	req.Header.Add("Authorization", jwtToken)
+	workspaceRouter.ServeHTTP(rr, req)
+	return rr
+}
+
 func TestCreateWorkspace(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode.")
@@ -487,11 +510,12 @@ func TestDeleteUserFromWorkSpace(t *testing.T) {
 		t.Skip("skipping test in short mode.")
 	}
 
-	// 1. 正常時 200
+	// 1. 正常時 200 (workspaceのchannelからも削除される)
 	// 2. bodyにworkspaceId, userId, roleIdのいずれかが含まれていない場合 400
 	// 3. requestしたuserのrole = 4の場合 403
 	// 4. 削除されるユーザーのrole = 1の場合 400
 	// 5. 該当するUserがいない場合 404
+	// 6. adminがownerやadminを削除しようとした場合 403
 
 	// 1
 	t.Run("1", func(t *testing.T) {
@@ -523,6 +547,9 @@ func TestDeleteUserFromWorkSpace(t *testing.T) {
 		json.Unmarshal(([]byte)(byteArray), w)
 
 		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, deleteUserRoleId, dlr.UserId, olr.Token).Code)
+		channels, err := models.GetChannelsByWorkspaceId(w.ID)
+		assert.Empty(t, err)
+		assert.True(t, models.IsExistCAUByChannelIdAndUserId(channels[0].ID, dlr.UserId))
 
 		rr = deleteUserFromWorkspaceTestFunc(w.ID, dlr.UserId, olr.Token)
 		assert.Equal(t, http.StatusOK, rr.Code)
@@ -532,6 +559,9 @@ func TestDeleteUserFromWorkSpace(t *testing.T) {
 		assert.Equal(t, dlr.UserId, wau.UserId)
 		assert.Equal(t, w.ID, wau.WorkspaceId)
 		assert.Equal(t, deleteUserRoleId, wau.RoleId)
+		for _, ch := range channels {
+			assert.False(t, models.IsExistCAUByChannelIdAndUserId(ch.ID, dlr.UserId))
+		}
 	})
 
 	// 2
@@ -695,6 +725,22 @@ func TestDeleteUserFromWorkSpace(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, rr.Code)
 		assert.Equal(t, "{\"message\":\"sql: no rows in result set\"}", rr.Body.String())
 	})
+
+	// 6
+	t.Run("6", func(t *testing.T) {
+		owner := signUpAndLogin(t)
+		admin := signUpAndLogin(t)
+		otherOwner := signUpAndLogin(t)
+		otherAdmin := signUpAndLogin(t)
+		w, _ := createWorkspaceWithGeneral(t, owner)
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleAdmin, admin.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleOwner, otherOwner.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleAdmin, otherAdmin.UserId, owner.Token).Code)
+
+		assert.Equal(t, http.StatusForbidden, deleteUserFromWorkspaceTestFunc(w.ID, otherOwner.UserId, admin.Token).Code)
+		assert.Equal(t, http.StatusForbidden, deleteUserFromWorkspaceTestFunc(w.ID, otherAdmin.UserId, admin.Token).Code)
+		assert.Equal(t, http.StatusOK, deleteUserFromWorkspaceTestFunc(w.ID, otherAdmin.UserId, owner.Token).Code)
+	})
 }
 
 func TestGetWorkspacesById(t *testing.T) {
@@ -838,6 +884,14 @@ func TestGetUsersInWorkspace(t *testing.T) {
 			RoleId: 1,
 		})
 
+		// profileとpresenceは別のtestで確認する
+		for i := range res {
+			res[i] = controllerUtils.UserInfoInWorkspace{
+				ID:     res[i].ID,
+				Name:   res[i].Name,
+				RoleId: res[i].RoleId,
+			}
+		}
 		for _, ui := range userInfos {
 			assert.Contains(t, res, ui)
 		}
@@ -874,3 +928,659 @@ func TestGetUsersInWorkspace(t *testing.T) {
 		assert.Equal(t, "{\"message\":\"user not found in workspace\"}", rr.Body.String())
 	})
 }
+
+func TestDeactivateUserInWorkspace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1. 無効化されたuserはworkspaceにアクセスできず、再有効化するとアクセスできる 200
+	// 2. requestしたuserに権限がない場合 403
+	// 3. primary ownerを無効化しようとした場合 400
+	// 4. 無効化されていないuserを再有効化しようとした場合 400
+	// 5. adminがownerやadminを無効化しようとした場合 403
+
+	owner := signUpAndLogin(t)
+	member := signUpAndLogin(t)
+	w, _ := createWorkspaceWithGeneral(t, owner)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, 4, member.UserId, owner.Token).Code)
+
+	t.Run("1", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, workspaceMemberTestFunc("deactivate_user", w.ID, member.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusNotFound, GetUsersInWorkspaceTestFunc(w.ID, member.Token).Code)
+
+		rr := GetUsersInWorkspaceTestFunc(w.ID, owner.Token)
+		res := make([]controllerUtils.UserInfoInWorkspace, 0)
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		assert.Equal(t, 1, len(res))
+
+		assert.Equal(t, http.StatusOK, workspaceMemberTestFunc("reactivate_user", w.ID, member.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, GetUsersInWorkspaceTestFunc(w.ID, member.Token).Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, workspaceMemberTestFunc("deactivate_user", w.ID, owner.UserId, member.Token).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		admin := signUpAndLogin(t)
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, 3, admin.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusBadRequest, workspaceMemberTestFunc("deactivate_user", w.ID, owner.UserId, admin.Token).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, workspaceMemberTestFunc("reactivate_user", w.ID, member.UserId, owner.Token).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		admin := signUpAndLogin(t)
+		otherOwner := signUpAndLogin(t)
+		otherAdmin := signUpAndLogin(t)
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleAdmin, admin.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleOwner, otherOwner.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleAdmin, otherAdmin.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusForbidden, workspaceMemberTestFunc("deactivate_user", w.ID, otherOwner.UserId, admin.Token).Code)
+		assert.Equal(t, http.StatusForbidden, workspaceMemberTestFunc("deactivate_user", w.ID, otherAdmin.UserId, admin.Token).Code)
+		assert.Equal(t, http.StatusOK, workspaceMemberTestFunc("deactivate_user", w.ID, otherAdmin.UserId, owner.Token).Code)
+	})
+}
+
+func changeRoleInWorkspaceTestFunc(workspaceId int, userId uint32, roleId int, jwtToken string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(controllerUtils.ChangeRoleInWorkspaceInput{
+		WorkspaceId: workspaceId,
+		UserId:      userId,
+		RoleId:      roleId,
+	})
+	req, _ := http.NewRequest("POST", "/api/workspace/change_role", bytes.NewBuffer(jsonInput))
+	req.Header.Add("Authorization", jwtToken)
+	workspaceRouter.ServeHTTP(rr, req)
+	return rr
+}
+
+func transferPrimaryOwnershipTestFunc(workspaceId int, userId uint32, password, jwtToken string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(controllerUtils.TransferPrimaryOwnershipInput{
+		WorkspaceId: workspaceId,
+		UserId:      userId,
+		Password:    password,
+	})
+	req, _ := http.NewRequest("POST", "/api/workspace/transfer_primary_owner", bytes.NewBuffer(jsonInput))
+	req.Header.Add("Authorization", jwtToken)
+	workspaceRouter.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestChangeRoleInWorkspace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 primary ownerはmemberをowner, admin, full memberに変更できる 200
+	// 2 ownerはadminをownerに昇格できるが、他のownerは降格できない 403
+	// 3 adminはfull memberをadminに昇格できるが、ownerには昇格できない 403
+	// 4 full memberはroleを変更できない 403
+	// 5 primary ownerのroleは変更できない 403, role_idが1の場合 400
+	// 6 workspaceにいないuserの場合 404
+
+	owner := signUpAndLoginTestFunc(t)
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+
+	users := make([]*LoginResponse, 4)
+	for i := range users {
+		users[i] = signUpAndLoginTestFunc(t)
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleFullMember, users[i].UserId, owner.Token).Code)
+	}
+	roleOf := func(userId uint32) int {
+		roleId, _ := models.GetRoleIdByWorkspaceIdAndUserId(w.ID, userId)
+		return roleId
+	}
+
+	t.Run("1", func(t *testing.T) {
+		for _, roleId := range []int{models.RoleOwner, models.RoleAdmin, models.RoleFullMember, models.RoleOwner} {
+			assert.Equal(t, http.StatusOK, changeRoleInWorkspaceTestFunc(w.ID, users[0].UserId, roleId, owner.Token).Code)
+			assert.Equal(t, roleId, roleOf(users[0].UserId))
+		}
+	})
+
+	t.Run("2", func(t *testing.T) {
+		// users[0]はowner
+		assert.Equal(t, http.StatusOK, changeRoleInWorkspaceTestFunc(w.ID, users[1].UserId, models.RoleAdmin, users[0].Token).Code)
+		assert.Equal(t, http.StatusOK, changeRoleInWorkspaceTestFunc(w.ID, users[1].UserId, models.RoleOwner, users[0].Token).Code)
+		assert.Equal(t, models.RoleOwner, roleOf(users[1].UserId))
+		assert.Equal(t, http.StatusForbidden, changeRoleInWorkspaceTestFunc(w.ID, users[1].UserId, models.RoleFullMember, users[0].Token).Code)
+		assert.Equal(t, models.RoleOwner, roleOf(users[1].UserId))
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, changeRoleInWorkspaceTestFunc(w.ID, users[2].UserId, models.RoleAdmin, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, changeRoleInWorkspaceTestFunc(w.ID, users[3].UserId, models.RoleAdmin, users[2].Token).Code)
+		assert.Equal(t, http.StatusForbidden, changeRoleInWorkspaceTestFunc(w.ID, users[3].UserId, models.RoleFullMember, users[2].Token).Code)
+		assert.Equal(t, http.StatusOK, changeRoleInWorkspaceTestFunc(w.ID, users[3].UserId, models.RoleFullMember, owner.Token).Code)
+		assert.Equal(t, http.StatusForbidden, changeRoleInWorkspaceTestFunc(w.ID, users[3].UserId, models.RoleOwner, users[2].Token).Code)
+		assert.Equal(t, models.RoleFullMember, roleOf(users[3].UserId))
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, changeRoleInWorkspaceTestFunc(w.ID, users[2].UserId, models.RoleFullMember, users[3].Token).Code)
+		assert.Equal(t, http.StatusForbidden, changeRoleInWorkspaceTestFunc(w.ID, users[3].UserId, models.RoleAdmin, users[3].Token).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, changeRoleInWorkspaceTestFunc(w.ID, owner.UserId, models.RoleFullMember, users[0].Token).Code)
+		assert.Equal(t, http.StatusForbidden, changeRoleInWorkspaceTestFunc(w.ID, owner.UserId, models.RoleOwner, owner.Token).Code)
+		assert.Equal(t, http.StatusBadRequest, changeRoleInWorkspaceTestFunc(w.ID, users[3].UserId, models.RolePrimaryOwner, owner.Token).Code)
+		assert.Equal(t, models.RolePrimaryOwner, roleOf(owner.UserId))
+	})
+
+	t.Run("6", func(t *testing.T) {
+		other := signUpAndLoginTestFunc(t)
+		assert.Equal(t, http.StatusNotFound, changeRoleInWorkspaceTestFunc(w.ID, other.UserId, models.RoleAdmin, owner.Token).Code)
+		assert.Equal(t, http.StatusNotFound, changeRoleInWorkspaceTestFunc(w.ID, users[3].UserId, models.RoleAdmin, other.Token).Code)
+	})
+}
+
+func TestTransferPrimaryOwnership(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 primary ownerでないuserの場合 403
+	// 2 passwordが間違っている場合 403
+	// 3 workspaceにいないuserの場合 404, guestの場合 400
+	// 4 正常な場合 200 (以前のprimary ownerはownerになる)
+
+	owner := signUpAndLoginTestFunc(t)
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+	member := signUpAndLoginTestFunc(t)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleAdmin, member.UserId, owner.Token).Code)
+
+	t.Run("1", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, transferPrimaryOwnershipTestFunc(w.ID, member.UserId, "pass", member.Token).Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, transferPrimaryOwnershipTestFunc(w.ID, member.UserId, "wrongPass", owner.Token).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		other := signUpAndLoginTestFunc(t)
+		assert.Equal(t, http.StatusNotFound, transferPrimaryOwnershipTestFunc(w.ID, other.UserId, "pass", owner.Token).Code)
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleMultiChannelGuest, other.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusBadRequest, transferPrimaryOwnershipTestFunc(w.ID, other.UserId, "pass", owner.Token).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, transferPrimaryOwnershipTestFunc(w.ID, member.UserId, "pass", owner.Token).Code)
+		res, err := models.GetWorkspaceById(w.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, member.UserId, res.PrimaryOwnerId)
+		roleId, _ := models.GetRoleIdByWorkspaceIdAndUserId(w.ID, member.UserId)
+		assert.Equal(t, models.RolePrimaryOwner, roleId)
+		roleId, _ = models.GetRoleIdByWorkspaceIdAndUserId(w.ID, owner.UserId)
+		assert.Equal(t, models.RoleOwner, roleId)
+
+		assert.Equal(t, http.StatusForbidden, transferPrimaryOwnershipTestFunc(w.ID, owner.UserId, "pass", owner.Token).Code)
+	})
+}
+
+func deleteWorkspaceTestFunc(workspaceId int, password, jwtToken string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(controllerUtils.PasswordInput{Password: password})
+	req, _ := http.NewRequest("DELETE", "/api/workspace/delete/"+strconv.Itoa(workspaceId), bytes.NewBuffer(jsonInput))
+	req.Header.Add("Authorization", jwtToken)
+	workspaceRouter.ServeHTTP(rr, req)
+	return rr
+}
+
+func restoreWorkspaceTestFunc(workspaceId int, jwtToken string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/workspace/restore/"+strconv.Itoa(workspaceId), nil)
+	req.Header.Add("Authorization", jwtToken)
+	workspaceRouter.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestDeleteWorkspace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 primary ownerでないuserの場合 403
+	// 2 passwordが間違っている場合 403
+	// 3 正常な場合 200 (workspaceとchannelのmessageが取得できなくなる), 既に削除されている場合 404
+	// 4 primary ownerは猶予期間の間は復元できる 200, primary ownerでない場合 403, 削除されていない場合 404
+	// 5 猶予期間を過ぎると復元できず 410, 完全に削除される
+
+	owner := signUpAndLoginTestFunc(t)
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+	channels, err := models.GetChannelsByWorkspaceId(w.ID)
+	assert.Empty(t, err)
+	general := channels[0]
+	assert.Equal(t, http.StatusOK, sendMessageTestFunc(randomstring.EnglishFrequencyString(30), general.ID, owner.Token).Code)
+
+	member := signUpAndLoginTestFunc(t)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleOwner, member.UserId, owner.Token).Code)
+
+	t.Run("1", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, deleteWorkspaceTestFunc(w.ID, "pass", member.Token).Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, deleteWorkspaceTestFunc(w.ID, "wrongPass", owner.Token).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, deleteWorkspaceTestFunc(w.ID, "pass", owner.Token).Code)
+
+		workspaces := make([]models.Workspace, 0)
+		json.Unmarshal(getWorkspacesByUserIdTestFunc(owner.UserId, owner.Token).Body.Bytes(), &workspaces)
+		for _, res := range workspaces {
+			assert.NotEqual(t, w.ID, res.ID)
+		}
+		assert.Equal(t, http.StatusNotFound, GetUsersInWorkspaceTestFunc(w.ID, owner.Token).Code)
+		assert.Equal(t, http.StatusNotFound, getMessagesByChannelIdTestFunc(general.ID, owner.Token).Code)
+		assert.Equal(t, http.StatusNotFound, deleteWorkspaceTestFunc(w.ID, "pass", owner.Token).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, restoreWorkspaceTestFunc(w.ID, member.Token).Code)
+		assert.Equal(t, http.StatusOK, restoreWorkspaceTestFunc(w.ID, owner.Token).Code)
+		assert.Equal(t, http.StatusNotFound, restoreWorkspaceTestFunc(w.ID, owner.Token).Code)
+
+		assert.Equal(t, http.StatusOK, GetUsersInWorkspaceTestFunc(w.ID, member.Token).Code)
+		assert.Equal(t, http.StatusOK, getMessagesByChannelIdTestFunc(general.ID, owner.Token).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		gracePeriod := config.Config.WorkspaceDeletionGraceHour
+		config.Config.WorkspaceDeletionGraceHour = 0
+		defer func() { config.Config.WorkspaceDeletionGraceHour = gracePeriod }()
+
+		assert.Equal(t, http.StatusOK, deleteWorkspaceTestFunc(w.ID, "pass", owner.Token).Code)
+		assert.Equal(t, http.StatusGone, restoreWorkspaceTestFunc(w.ID, owner.Token).Code)
+
+		n, err := controllerUtils.PurgeDeletedWorkspaces(time.Now().Add(time.Second))
+		assert.Empty(t, err)
+		assert.GreaterOrEqual(t, n, 1)
+		_, err = models.GetWorkspaceById(w.ID)
+		assert.NotEmpty(t, err)
+		_, err = models.GetChannelById(general.ID)
+		assert.NotEmpty(t, err)
+		messages, err := models.GetMessagesByChannelId(general.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, 0, len(messages))
+	})
+}
+
+func leaveWorkspaceTestFunc(workspaceId int, jwtToken string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/workspace/leave/"+strconv.Itoa(workspaceId), nil)
+	req.Header.Add("Authorization", jwtToken)
+	workspaceRouter.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestLeaveWorkspace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 workspaceに参加していない場合 404
+	// 2 primary ownerは抜けられない 400
+	// 3 正常な場合 200 (workspaceのchannelからも抜け、private channelの管理者は引き継がれる)
+	// 4 primary ownerを譲渡した後は抜けられる 200
+
+	owner := signUpAndLoginTestFunc(t)
+	member := signUpAndLoginTestFunc(t)
+	outsider := signUpAndLoginTestFunc(t)
+
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleFullMember, member.UserId, owner.Token).Code)
+
+	isPrivate := true
+	rr = createChannelTestFunc(randomstring.EnglishFrequencyString(30), "", &isPrivate, member.Token, w.ID)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	ch := new(models.Channel)
+	json.Unmarshal(rr.Body.Bytes(), ch)
+	assert.Equal(t, http.StatusOK, addUserInChannelTestFunc(ch.ID, owner.UserId, member.Token).Code)
+
+	t.Run("1", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, leaveWorkspaceTestFunc(w.ID, outsider.Token).Code)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		rr := leaveWorkspaceTestFunc(w.ID, owner.Token)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Equal(t, "{\"message\":\"primary owner must transfer ownership before leaving\"}", rr.Body.String())
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, leaveWorkspaceTestFunc(w.ID, member.Token).Code)
+		assert.False(t, controllerUtils.IsExistWAUByWorkspaceIdAndUserId(w.ID, member.UserId))
+		assert.False(t, models.IsExistCAUByChannelIdAndUserId(ch.ID, member.UserId))
+		assert.True(t, models.IsAdminUserInChannel(ch.ID, owner.UserId))
+		assert.Equal(t, http.StatusNotFound, leaveWorkspaceTestFunc(w.ID, member.Token).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleOwner, member.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, transferPrimaryOwnershipTestFunc(w.ID, member.UserId, "pass", owner.Token).Code)
+		assert.Equal(t, http.StatusOK, leaveWorkspaceTestFunc(w.ID, owner.Token).Code)
+		assert.False(t, controllerUtils.IsExistWAUByWorkspaceIdAndUserId(w.ID, owner.UserId))
+	})
+}
+
+func getWorkspaceSettingTestFunc(workspaceId int, jwtToken string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/workspace/settings/"+strconv.Itoa(workspaceId), nil)
+	req.Header.Add("Authorization", jwtToken)
+	workspaceRouter.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestWorkspaceSetting(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 ownerとadmin以外は変更できない 403, 不正な値の場合 400, 正常な場合 200
+	// 2 channelを作成できるroleを制限できる
+	// 3 招待できるroleを変更できる
+	// 4 @channelを禁止できる
+	// 5 参加できるuserのemailのdomainを制限できる
+	// 6 保存期間を過ぎたmessageは削除される
+
+	owner := signUpAndLoginTestFunc(t)
+	member := signUpAndLoginTestFunc(t)
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleFullMember, member.UserId, owner.Token).Code)
+	channels, err := models.GetChannelsByWorkspaceId(w.ID)
+	assert.Empty(t, err)
+	general := channels[0]
+
+	t.Run("1", func(t *testing.T) {
+		rr := getWorkspaceSettingTestFunc(w.ID, member.Token)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		ws := new(models.WorkspaceSetting)
+		json.Unmarshal(rr.Body.Bytes(), ws)
+		assert.Equal(t, *models.NewWorkspaceSetting(w.ID), *ws)
+
+		description := "our workspace"
+		iconUrl := "https://example.com/icon.png"
+		input := controllerUtils.UpdateWorkspaceSettingInput{Description: &description, IconUrl: &iconUrl}
+		assert.Equal(t, http.StatusForbidden, updateWorkspaceSettingTestFunc(w.ID, member.Token, input).Code)
+
+		policy := "members"
+		assert.Equal(t, http.StatusBadRequest, updateWorkspaceSettingTestFunc(w.ID, owner.Token, controllerUtils.UpdateWorkspaceSettingInput{InvitePolicy: &policy}).Code)
+		days := -1
+		assert.Equal(t, http.StatusBadRequest, updateWorkspaceSettingTestFunc(w.ID, owner.Token, controllerUtils.UpdateWorkspaceSettingInput{MessageRetentionDays: &days}).Code)
+		domains := []string{"user@example.com"}
+		assert.Equal(t, http.StatusBadRequest, updateWorkspaceSettingTestFunc(w.ID, owner.Token, controllerUtils.UpdateWorkspaceSettingInput{AllowedEmailDomains: &domains}).Code)
+		invalidUrl := "javascript:alert(1)"
+		assert.Equal(t, http.StatusBadRequest, updateWorkspaceSettingTestFunc(w.ID, owner.Token, controllerUtils.UpdateWorkspaceSettingInput{IconUrl: &invalidUrl}).Code)
+
+		rr = updateWorkspaceSettingTestFunc(w.ID, owner.Token, input)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		json.Unmarshal(getWorkspaceSettingTestFunc(w.ID, member.Token).Body.Bytes(), ws)
+		assert.Equal(t, description, ws.Description)
+		assert.Equal(t, iconUrl, ws.IconUrl)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		policy := models.PolicyAdmins
+		rr := updateWorkspaceSettingTestFunc(w.ID, owner.Token, controllerUtils.UpdateWorkspaceSettingInput{PublicChannelCreatePolicy: &policy})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		isPrivate := false
+		assert.Equal(t, http.StatusForbidden, createChannelTestFunc(randomstring.EnglishFrequencyString(30), "", &isPrivate, member.Token, w.ID).Code)
+		assert.Equal(t, http.StatusOK, createChannelTestFunc(randomstring.EnglishFrequencyString(30), "", &isPrivate, owner.Token, w.ID).Code)
+		isPrivate = true
+		assert.Equal(t, http.StatusOK, createChannelTestFunc(randomstring.EnglishFrequencyString(30), "", &isPrivate, member.Token, w.ID).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		input := controllerUtils.CreateWorkspaceInviteInput{RoleId: models.RoleFullMember}
+		assert.Equal(t, http.StatusForbidden, workspaceInviteTestFunc("POST", "/invites/"+strconv.Itoa(w.ID), member.Token, input).Code)
+
+		policy := models.PolicyEveryone
+		rr := updateWorkspaceSettingTestFunc(w.ID, owner.Token, controllerUtils.UpdateWorkspaceSettingInput{InvitePolicy: &policy})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, http.StatusOK, workspaceInviteTestFunc("POST", "/invites/"+strconv.Itoa(w.ID), member.Token, input).Code)
+		input.RoleId = models.RoleAdmin
+		assert.Equal(t, http.StatusForbidden, workspaceInviteTestFunc("POST", "/invites/"+strconv.Itoa(w.ID), member.Token, input).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, sendMessageTestFunc("@channel hello", general.ID, member.Token).Code)
+
+		allow := false
+		rr := updateWorkspaceSettingTestFunc(w.ID, owner.Token, controllerUtils.UpdateWorkspaceSettingInput{AllowChannelMention: &allow})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		rr = sendMessageTestFunc("@channel hello", general.ID, member.Token)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Equal(t, "{\"message\":\"@channel is not allowed in this workspace\"}", rr.Body.String())
+		assert.Equal(t, http.StatusForbidden, sendMessageTestFunc("hi @here", general.ID, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, sendMessageTestFunc("mail to user@channel.example.com", general.ID, member.Token).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		domains := []string{"Example.com", "@example.org"}
+		rr := updateWorkspaceSettingTestFunc(w.ID, owner.Token, controllerUtils.UpdateWorkspaceSettingInput{AllowedEmailDomains: &domains})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		ws := new(models.WorkspaceSetting)
+		json.Unmarshal(rr.Body.Bytes(), ws)
+		assert.Equal(t, "example.com example.org", ws.AllowedEmailDomains)
+
+		noEmail := signUpAndLoginTestFunc(t)
+		rr = addUserWorkspaceTestFunc(w.ID, models.RoleFullMember, noEmail.UserId, owner.Token)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Equal(t, "{\"message\":\"email domain is not allowed\"}", rr.Body.String())
+
+		name := randomstring.EnglishFrequencyString(30)
+		assert.Equal(t, http.StatusOK, signUpWithEmailTestFunc(name, name+"@example.com", "pass").Code)
+		users, err := models.GetUsersByName(name)
+		assert.Empty(t, err)
+		assert.Empty(t, users[0].VerifyEmail())
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleFullMember, users[0].ID, owner.Token).Code)
+
+		input := controllerUtils.CreateWorkspaceInviteInput{Email: name + "@example.net", RoleId: models.RoleFullMember}
+		assert.Equal(t, http.StatusBadRequest, workspaceInviteTestFunc("POST", "/invites/"+strconv.Itoa(w.ID), owner.Token, input).Code)
+	})
+
+	t.Run("6", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, sendMessageTestFunc("old message", general.ID, owner.Token).Code)
+		days := 1
+		rr := updateWorkspaceSettingTestFunc(w.ID, owner.Token, controllerUtils.UpdateWorkspaceSettingInput{MessageRetentionDays: &days})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		_, err := controllerUtils.PurgeExpiredMessages(time.Now())
+		assert.Empty(t, err)
+		messages, err := models.GetMessagesByChannelId(general.ID)
+		assert.Empty(t, err)
+		assert.NotEqual(t, 0, len(messages))
+
+		_, err = controllerUtils.PurgeExpiredMessages(time.Now().AddDate(0, 0, 2))
+		assert.Empty(t, err)
+		messages, err = models.GetMessagesByChannelId(general.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, 0, len(messages))
+	})
+}
+
+func setGuestExpirationTestFunc(workspaceId int, userId uint32, expiresAt *time.Time, jwtToken string) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	jsonInput, _ := json.Marshal(controllerUtils.SetGuestExpirationInput{
+		WorkspaceId: workspaceId,
+		UserId:      userId,
+		ExpiresAt:   expiresAt,
+	})
+	req, _ := http.NewRequest("POST", "/api/workspace/guest_expiration", bytes.NewBuffer(jsonInput))
+	req.Header.Add("Authorization", jwtToken)
+	workspaceRouter.ServeHTTP(rr, req)
+	return rr
+}
+
+func getRoleIdInWorkspace(t *testing.T, workspaceId int, userId uint32) int {
+	roleId, err := models.GetRoleIdByWorkspaceIdAndUserId(workspaceId, userId)
+	assert.Empty(t, err)
+	return roleId
+}
+
+func TestGuests(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 guestはgeneralに参加しない, guest以外に有効期限を指定した場合 400
+	// 2 guestはmemberの一覧とdefault channelを見られない 403, channelを作成できない 403
+	// 3 single-channel guestは1つのchannelにのみ参加できる 400, multi-channel guestは複数のchannelに参加できる
+	// 4 guestとのDMは同じchannelに参加しているuserのみ 403
+	// 5 guestは同じchannelに参加しているuserのpresenceのみ取得できる
+	// 6 有効期限の設定 guestでない場合 400, 権限がない場合 403, 過去の時刻 400, 正常な場合 200
+	// 7 有効期限を過ぎたguestは無効化され、再度有効化すると有効期限がなくなる
+	// 8 guestでなくなると有効期限がなくなる
+	// 9 guestの制限を超えるchannelやuser groupに参加しているuserはguestに変更できない 409
+
+	owner := signUpAndLoginTestFunc(t)
+	member := signUpAndLoginTestFunc(t)
+	single := signUpAndLoginTestFunc(t)
+	multi := signUpAndLoginTestFunc(t)
+
+	rr := createWorkSpaceTestFunc(randomstring.EnglishFrequencyString(30), owner.Token, owner.UserId)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	w := new(models.Workspace)
+	json.Unmarshal(rr.Body.Bytes(), w)
+	assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleFullMember, member.UserId, owner.Token).Code)
+
+	isPrivate := false
+	createChannel := func() models.Channel {
+		rr := createChannelTestFunc(randomstring.EnglishFrequencyString(30), "", &isPrivate, owner.Token, w.ID)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		ch := new(models.Channel)
+		json.Unmarshal(rr.Body.Bytes(), ch)
+		return *ch
+	}
+	channelA := createChannel()
+	channelB := createChannel()
+
+	t.Run("1", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour)
+		in := controllerUtils.AddUserInWorkspaceInput{WorkspaceId: w.ID, UserId: single.UserId, RoleId: models.RoleFullMember, ExpiresAt: &expiresAt}
+		assert.Equal(t, http.StatusBadRequest, workspaceInviteTestFunc("POST", "/add_user", owner.Token, in).Code)
+		in.RoleId = models.RoleSingleChannelGuest
+		assert.Equal(t, http.StatusOK, workspaceInviteTestFunc("POST", "/add_user", owner.Token, in).Code)
+		assert.Equal(t, http.StatusOK, addUserWorkspaceTestFunc(w.ID, models.RoleMultiChannelGuest, multi.UserId, owner.Token).Code)
+
+		for _, guest := range []*LoginResponse{single, multi} {
+			rr := getChannelsByUserTestFunc(w.ID, guest.Token)
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, "[]", rr.Body.String())
+		}
+		got, err := models.GetExpiresAtInWorkspace(w.ID, single.UserId)
+		assert.Empty(t, err)
+		assert.True(t, expiresAt.Equal(*got))
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, GetUsersInWorkspaceTestFunc(w.ID, member.Token).Code)
+		assert.Equal(t, http.StatusForbidden, GetUsersInWorkspaceTestFunc(w.ID, multi.Token).Code)
+		assert.Equal(t, http.StatusForbidden, getDefaultChannelsTestFunc(w.ID, multi.Token).Code)
+		assert.Equal(t, http.StatusForbidden, createChannelTestFunc(randomstring.EnglishFrequencyString(30), "", &isPrivate, multi.Token, w.ID).Code)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, addUserInChannelTestFunc(channelA.ID, single.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusBadRequest, addUserInChannelTestFunc(channelB.ID, single.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, addUserInChannelTestFunc(channelA.ID, multi.UserId, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, addUserInChannelTestFunc(channelB.ID, multi.UserId, owner.Token).Code)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		text := randomstring.EnglishFrequencyString(30)
+		assert.Equal(t, http.StatusForbidden, sendDMTestFunc(text, single.Token, member.UserId, w.ID).Code)
+		assert.Equal(t, http.StatusForbidden, sendDMTestFunc(text, member.Token, single.UserId, w.ID).Code)
+		assert.Equal(t, http.StatusOK, sendDMTestFunc(text, single.Token, owner.UserId, w.ID).Code)
+		assert.Equal(t, http.StatusOK, sendDMTestFunc(text, multi.Token, single.UserId, w.ID).Code)
+		assert.Equal(t, http.StatusOK, sendDMTestFunc(text, member.Token, owner.UserId, w.ID).Code)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		res := getPresencesTestFunc(single.Token, owner.UserId, member.UserId, multi.UserId)
+		ids := make([]uint32, 0)
+		for _, p := range res {
+			ids = append(ids, p.UserId)
+		}
+		assert.Equal(t, []uint32{owner.UserId, multi.UserId}, ids)
+		assert.Equal(t, 3, len(getPresencesTestFunc(member.Token, owner.UserId, single.UserId, multi.UserId)))
+	})
+
+	t.Run("6", func(t *testing.T) {
+		future := time.Now().Add(time.Hour)
+		past := time.Now().Add(-time.Hour)
+		assert.Equal(t, http.StatusBadRequest, setGuestExpirationTestFunc(w.ID, member.UserId, &future, owner.Token).Code)
+		assert.Equal(t, http.StatusForbidden, setGuestExpirationTestFunc(w.ID, multi.UserId, &future, member.Token).Code)
+		assert.Equal(t, http.StatusBadRequest, setGuestExpirationTestFunc(w.ID, multi.UserId, &past, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, setGuestExpirationTestFunc(w.ID, multi.UserId, &future, owner.Token).Code)
+		got, err := models.GetExpiresAtInWorkspace(w.ID, multi.UserId)
+		assert.Empty(t, err)
+		assert.True(t, future.Equal(*got))
+		assert.Equal(t, http.StatusOK, setGuestExpirationTestFunc(w.ID, multi.UserId, nil, owner.Token).Code)
+		got, err = models.GetExpiresAtInWorkspace(w.ID, multi.UserId)
+		assert.Empty(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("7", func(t *testing.T) {
+		past := time.Now().Add(-time.Minute)
+		assert.Empty(t, models.SetExpiresAtInWorkspace(w.ID, single.UserId, &past))
+		_, err := controllerUtils.DeactivateExpiredGuests(time.Now())
+		assert.Empty(t, err)
+		assert.Equal(t, http.StatusNotFound, getChannelsByUserTestFunc(w.ID, single.Token).Code)
+
+		assert.Equal(t, http.StatusOK, workspaceMemberTestFunc("reactivate_user", w.ID, single.UserId, owner.Token).Code)
+		got, err := models.GetExpiresAtInWorkspace(w.ID, single.UserId)
+		assert.Empty(t, err)
+		assert.Nil(t, got)
+		_, err = controllerUtils.DeactivateExpiredGuests(time.Now())
+		assert.Empty(t, err)
+		assert.Equal(t, http.StatusOK, getChannelsByUserTestFunc(w.ID, single.Token).Code)
+	})
+
+	t.Run("8", func(t *testing.T) {
+		future := time.Now().Add(time.Hour)
+		assert.Equal(t, http.StatusOK, setGuestExpirationTestFunc(w.ID, multi.UserId, &future, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, changeRoleInWorkspaceTestFunc(w.ID, multi.UserId, models.RoleFullMember, owner.Token).Code)
+		got, err := models.GetExpiresAtInWorkspace(w.ID, multi.UserId)
+		assert.Empty(t, err)
+		assert.Nil(t, got)
+		assert.Equal(t, http.StatusOK, GetUsersInWorkspaceTestFunc(w.ID, multi.Token).Code)
+	})
+
+	t.Run("9", func(t *testing.T) {
+		// multiはchannelAとchannelBに参加している
+		assert.Equal(t, http.StatusConflict, changeRoleInWorkspaceTestFunc(w.ID, multi.UserId, models.RoleSingleChannelGuest, owner.Token).Code)
+		assert.Equal(t, http.StatusOK, changeRoleInWorkspaceTestFunc(w.ID, multi.UserId, models.RoleMultiChannelGuest, owner.Token).Code)
+		assert.Equal(t, models.RoleMultiChannelGuest, getRoleIdInWorkspace(t, w.ID, multi.UserId))
+
+		in := controllerUtils.CreateUserGroupInput{Handle: fmt.Sprintf("guests-%d", rand.Int31()), Name: "Group", MemberIds: []uint32{member.UserId}}
+		assert.Equal(t, http.StatusOK, userGroupTestFunc("POST", w.ID, 0, owner.Token, in).Code)
+		assert.Equal(t, http.StatusConflict, changeRoleInWorkspaceTestFunc(w.ID, member.UserId, models.RoleMultiChannelGuest, owner.Token).Code)
+		assert.Equal(t, models.RoleFullMember, getRoleIdInWorkspace(t, w.ID, member.UserId))
+	})
+}
@@ -0,0 +1,17 @@
+package mailer
+
+import (
+	"fmt"
+)
+
+// 送信せずに標準出力に表示する(開発用)
+type LogMailer struct{}
+
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+func (lm *LogMailer) Send(m Mail) error {
+	fmt.Printf("------ mail ------\nTo: %s\nSubject: %s\n\n%s\n------------------\n", m.To, m.Subject, m.Body)
+	return nil
+}
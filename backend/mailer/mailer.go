@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"backend/config"
+)
+
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(m Mail) error
+}
+
+// controllersから使うmailer
+// testではMemoryMailerに差し替える
+var DefaultMailer Mailer
+
+func init() {
+	DefaultMailer = NewFromConfig()
+}
+
+func NewFromConfig() Mailer {
+	switch config.Config.MailDriver {
+	case "smtp":
+		return NewSMTPMailer(
+			config.Config.SmtpHost,
+			config.Config.SmtpPort,
+			config.Config.SmtpUsername,
+			config.Config.SmtpPassword,
+			config.Config.MailFrom,
+		)
+	case "memory":
+		return NewMemoryMailer()
+	default:
+		return NewLogMailer()
+	}
+}
+
+func Send(m Mail) error {
+	return DefaultMailer.Send(m)
+}
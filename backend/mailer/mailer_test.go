@@ -0,0 +1,65 @@
+package mailer
+
+import (
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	mm := NewMemoryMailer()
+	assert.Empty(t, mm.Send(Mail{To: "a@example.com", Subject: "1", Body: "body"}))
+	assert.Empty(t, mm.Send(Mail{To: "b@example.com", Subject: "2", Body: "body"}))
+	assert.Empty(t, mm.Send(Mail{To: "a@example.com", Subject: "3", Body: "body"}))
+	assert.Equal(t, 3, len(mm.Sent()))
+
+	m, ok := mm.LastMailTo("a@example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "3", m.Subject)
+	_, ok = mm.LastMailTo("c@example.com")
+	assert.False(t, ok)
+}
+
+func TestSMTPMailer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 正常な場合
+	// 2 headerに改行が含まれる場合 error
+	// 3 設定が足りない場合 error
+
+	var (
+		sentAddr string
+		sentTo   []string
+		sentMsg  string
+	)
+	sm := NewSMTPMailer("smtp.example.com", "587", "user", "pass", "noreply@example.com")
+	sm.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		sentAddr = addr
+		sentTo = to
+		sentMsg = string(msg)
+		return nil
+	}
+
+	t.Run("1", func(t *testing.T) {
+		assert.Empty(t, sm.Send(Mail{To: "a@example.com", Subject: "subject", Body: "body"}))
+		assert.Equal(t, "smtp.example.com:587", sentAddr)
+		assert.Equal(t, []string{"a@example.com"}, sentTo)
+		assert.True(t, strings.HasPrefix(sentMsg, "From: noreply@example.com\r\nTo: a@example.com\r\nSubject: subject\r\n"))
+		assert.True(t, strings.HasSuffix(sentMsg, "\r\n\r\nbody"))
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.NotEmpty(t, sm.Send(Mail{To: "a@example.com\r\nBcc: b@example.com", Subject: "subject", Body: "body"}))
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.NotEmpty(t, NewSMTPMailer("", "587", "", "", "").Send(Mail{To: "a@example.com"}))
+	})
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"sync"
+)
+
+// 送信したmailをmemoryに保存する(test用)
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Mail
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{sent: make([]Mail, 0)}
+}
+
+func (mm *MemoryMailer) Send(m Mail) error {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.sent = append(mm.sent, m)
+	return nil
+}
+
+func (mm *MemoryMailer) Sent() []Mail {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	res := make([]Mail, len(mm.sent))
+	copy(res, mm.sent)
+	return res
+}
+
+func (mm *MemoryMailer) LastMailTo(to string) (Mail, bool) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	for i := len(mm.sent) - 1; i >= 0; i-- {
+		if mm.sent[i].To == to {
+			return mm.sent[i], true
+		}
+	}
+	return Mail{}, false
+}
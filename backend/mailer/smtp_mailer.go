@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	// testで差し替えられるようにする
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewSMTPMailer(host, port, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		From:     from,
+		sendMail: smtp.SendMail,
+	}
+}
+
+func (sm *SMTPMailer) Send(m Mail) error {
+	if sm.Host == "" || sm.From == "" {
+		return fmt.Errorf("smtp host or from address not configured")
+	}
+	// header injectionを防ぐ
+	for _, v := range []string{m.To, m.Subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return fmt.Errorf("invalid mail header")
+		}
+	}
+
+	var auth smtp.Auth
+	if sm.Username != "" {
+		auth = smtp.PlainAuth("", sm.Username, sm.Password, sm.Host)
+	}
+	msg := strings.Join([]string{
+		"From: " + sm.From,
+		"To: " + m.To,
+		"Subject: " + m.Subject,
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		m.Body,
+	}, "\r\n")
+	return sm.sendMail(net.JoinHostPort(sm.Host, sm.Port), auth, sm.From, []string{m.To}, []byte(msg))
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"time"
+
+	"backend/config"
+	"backend/controllerUtils"
 	"backend/controllers"
 )
 
 func main() {
+	// 削除されたworkspaceを猶予期間の後に完全に削除する
+	go controllerUtils.RunPurger(time.Minute * time.Duration(config.Config.WorkspacePurgeIntervalMinute))
+
 	r := controllers.SetupRouter()
 	r.Run(":8080")
 }
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"backend/config"
+	"backend/utils"
+)
+
+// API tokenに付与できるscope
+const (
+	ScopeUsersRead       = "users:read"
+	ScopeUsersWrite      = "users:write"
+	ScopeWorkspacesRead  = "workspaces:read"
+	ScopeWorkspacesWrite = "workspaces:write"
+	ScopeChannelsRead    = "channels:read"
+	ScopeChannelsWrite   = "channels:write"
+	ScopeChatRead        = "chat:read"
+	ScopeChatWrite       = "chat:write"
+)
+
+var ApiTokenScopes = []string{
+	ScopeUsersRead,
+	ScopeUsersWrite,
+	ScopeWorkspacesRead,
+	ScopeWorkspacesWrite,
+	ScopeChannelsRead,
+	ScopeChannelsWrite,
+	ScopeChatRead,
+	ScopeChatWrite,
+}
+
+// last_used_atを更新する間隔
+const apiTokenTouchInterval = time.Minute
+
+type ApiToken struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	UserId      uint32     `json:"user_id" gorm:"not null; index"`
+	WorkspaceId int        `json:"workspace_id" gorm:"not null; default:0; index"`
+	CreatedBy   uint32     `json:"created_by" gorm:"not null"`
+	Name        string     `json:"name" gorm:"not null"`
+	TokenHash   string     `json:"-" gorm:"not null"`
+	Scopes      string     `json:"scopes" gorm:"not null"`
+	IsBot       bool       `json:"is_bot" gorm:"not null; default:false"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"not null"`
+}
+
+func NewApiToken(id string, userId, createdBy uint32, name, tokenHash string, scopes []string) *ApiToken {
+	return &ApiToken{
+		ID:        id,
+		UserId:    userId,
+		CreatedBy: createdBy,
+		Name:      name,
+		TokenHash: tokenHash,
+		Scopes:    strings.Join(scopes, " "),
+	}
+}
+
+func (t *ApiToken) Create() *gorm.DB {
+	return db.Create(t)
+}
+
+func CreateBot(bot *User, workspaceId int, p *Profile, t *ApiToken) error {
+	// bot用のuser, workspaceへの参加, profile, tokenを同じtransactionで作成する
+	hash, err := utils.HashPassword(bot.PassWord)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var id uint32
+		cmd := fmt.Sprintf("INSERT INTO %s (name, password) VALUES (?, ?) RETURNING id", config.Config.UserTableName)
+		if err := tx.Raw(cmd, bot.Name, hash).Scan(&id).Error; err != nil {
+			return duplicateUserError(err)
+		}
+		cmd = fmt.Sprintf("INSERT INTO %s (workspace_id, user_id, role_id) VALUES (?, ?, ?)", config.Config.WorkspaceAndUserTableName)
+		if err := tx.Exec(cmd, workspaceId, id, RoleFullMember).Error; err != nil {
+			return err
+		}
+		p.UserId = id
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		t.UserId = id
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+		bot.ID = id
+		bot.PassWord = hash
+		return nil
+	})
+}
+
+func (t *ApiToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+func (t *ApiToken) IsActive() bool {
+	return t.RevokedAt == nil
+}
+
+func GetApiTokenById(id string) (ApiToken, error) {
+	var t ApiToken
+	err := db.First(&t, "id = ?", id).Error
+	return t, err
+}
+
+func GetActivePersonalTokensByUserId(userId uint32) ([]ApiToken, error) {
+	var result []ApiToken
+	err := db.Where("user_id = ? AND is_bot = ? AND revoked_at IS NULL", userId, false).Order("created_at desc").Find(&result).Error
+	return result, err
+}
+
+func GetActiveBotTokensByWorkspaceId(workspaceId int) ([]ApiToken, error) {
+	var result []ApiToken
+	err := db.Where("workspace_id = ? AND is_bot = ? AND revoked_at IS NULL", workspaceId, true).Order("created_at desc").Find(&result).Error
+	return result, err
+}
+
+func (t *ApiToken) Touch() error {
+	// 毎回のrequestで書き込まないように一定時間経過した場合のみ更新する
+	if t.LastUsedAt != nil && time.Since(*t.LastUsedAt) < apiTokenTouchInterval {
+		return nil
+	}
+	now := time.Now()
+	t.LastUsedAt = &now
+	return db.Model(t).Update("last_used_at", now).Error
+}
+
+func (t *ApiToken) Revoke() error {
+	now := time.Now()
+	t.RevokedAt = &now
+	return db.Model(t).Update("revoked_at", now).Error
+}
+
+func RevokeApiTokensByUserId(userId uint32) error {
+	return db.Model(&ApiToken{}).Where("user_id = ? AND revoked_at IS NULL", userId).Update("revoked_at", time.Now()).Error
+}
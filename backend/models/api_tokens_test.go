@@ -0,0 +1,104 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+)
+
+func TestApiToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 作成して取得できる
+	// 2 revokeしたtokenは一覧に含まれない
+	// 3 userのtokenをまとめてrevokeできる
+
+	userId := rand.Uint32()
+	newToken := func() *ApiToken {
+		at := NewApiToken(randomstring.EnglishFrequencyString(20), userId, userId, "script", randomstring.EnglishFrequencyString(30), []string{ScopeChatRead, ScopeChatWrite})
+		assert.Empty(t, at.Create().Error)
+		return at
+	}
+
+	t.Run("1", func(t *testing.T) {
+		at := newToken()
+		res, err := GetApiTokenById(at.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, []string{ScopeChatRead, ScopeChatWrite}, res.ScopeList())
+		assert.True(t, res.IsActive())
+
+		assert.Empty(t, res.Touch())
+		res, _ = GetApiTokenById(at.ID)
+		assert.NotNil(t, res.LastUsedAt)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		at := newToken()
+		before, err := GetActivePersonalTokensByUserId(userId)
+		assert.Empty(t, err)
+		assert.Empty(t, at.Revoke())
+		after, err := GetActivePersonalTokensByUserId(userId)
+		assert.Empty(t, err)
+		assert.Equal(t, len(before)-1, len(after))
+	})
+
+	t.Run("3", func(t *testing.T) {
+		newToken()
+		assert.Empty(t, RevokeApiTokensByUserId(userId))
+		res, err := GetActivePersonalTokensByUserId(userId)
+		assert.Empty(t, err)
+		assert.Equal(t, 0, len(res))
+	})
+}
+
+func TestCreateBot(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 bot用のuser, workspaceへの参加, profile, tokenが作成される
+	// 2 tokenの作成に失敗した場合はuserも作成されない
+
+	workspaceId := rand.Int()
+	createdBy := rand.Uint32()
+	newBot := func(tokenId string) (*User, *ApiToken, error) {
+		bot := NewUser(0, "bot-"+randomstring.EnglishFrequencyString(20), randomstring.EnglishFrequencyString(30))
+		p := NewProfile(0)
+		p.DisplayName = "script"
+		at := NewApiToken(tokenId, 0, createdBy, "script", randomstring.EnglishFrequencyString(30), []string{ScopeChatWrite})
+		at.WorkspaceId = workspaceId
+		at.IsBot = true
+		return bot, at, CreateBot(bot, workspaceId, p, at)
+	}
+
+	var tokenId string
+	t.Run("1", func(t *testing.T) {
+		bot, at, err := newBot(randomstring.EnglishFrequencyString(20))
+		assert.Empty(t, err)
+		assert.NotEqual(t, uint32(0), bot.ID)
+		tokenId = at.ID
+
+		_, err = GetUserById(bot.ID)
+		assert.Empty(t, err)
+		wau, err := GetWorkspaceAndUserByWorkspaceIdAndUserId(workspaceId, bot.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, RoleFullMember, wau.RoleId)
+		p, err := GetProfileByUserId(bot.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, "script", p.DisplayName)
+		res, err := GetApiTokenById(at.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, bot.ID, res.UserId)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		bot, _, err := newBot(tokenId)
+		assert.NotEmpty(t, err)
+		_, err = GetUserByName(bot.Name)
+		assert.NotEmpty(t, err)
+	})
+}
@@ -33,55 +33,69 @@ func init() {
 	fmt.Println("------------------")
 
 	// create users table
-	cmd := fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			id INT PRIMARY KEY NOT NULL,
-			name STRING NOT NULL,
-			password STRING NOT NULL
-		)
-	`, config.Config.UserTableName)
+	cmd := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (%s)`, config.Config.UserTableName, usersTableColumns)
 	_, err = DbConnection.Exec(cmd)
 	fmt.Println(err)
 
+	// email, email_verified_at, deactivated_at columnが存在しない古いusers tableにcolumnを追加する
+	err = addColumnIfNotExists(config.Config.UserTableName, "email", "STRING")
+	fmt.Println(err)
+	err = addColumnIfNotExists(config.Config.UserTableName, "email_verified_at", "DATETIME")
+	fmt.Println(err)
+	err = addColumnIfNotExists(config.Config.UserTableName, "deactivated_at", "DATETIME")
+	fmt.Println(err)
+	// SSOで作成されたuserはpasswordを設定するまでpasswordを使えない
+	err = addColumnIfNotExists(config.Config.UserTableName, "has_password", "BOOLEAN NOT NULL DEFAULT 1")
+	fmt.Println(err)
+
 	// create workspace table
-	cmd = fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			id INT PRIMARY KEY NOT NULL,
-			name STRING NOT NULL UNIQUE,
-			workspace_primary_owner_id STRING not NULL
-		)
-	`, config.Config.WorkspaceTableName)
+	cmd = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (%s)`, config.Config.WorkspaceTableName, workspacesTableColumns)
 	_, err = DbConnection.Exec(cmd)
 	fmt.Println(err)
 
+	// deleted_at columnが存在しない古いworkspaces tableにcolumnを追加する
+	err = addColumnIfNotExists(config.Config.WorkspaceTableName, "deleted_at", "DATETIME")
+	fmt.Println(err)
+
 	// create workspace and user table
 	cmd = fmt.Sprintf(`
 		CREATE TABLE IF NOT EXISTS %s (
 			workspace_id INT NOT NULL,
 			user_id INT NOT NULL,
 			role_id INT NOT NULL,
+			is_deactivated BOOLEAN NOT NULL DEFAULT 0,
+			expires_at TIMESTAMP,
 			PRIMARY KEY (workspace_id, user_id)
 		)
 	`, config.Config.WorkspaceAndUserTableName)
 	_, err = DbConnection.Exec(cmd)
 	fmt.Println(err)
 
+	// is_deactivated columnが存在しない古いtableにcolumnを追加する
+	err = addColumnIfNotExists(config.Config.WorkspaceAndUserTableName, "is_deactivated", "BOOLEAN NOT NULL DEFAULT 0")
+	fmt.Println(err)
+
+	// guestの有効期限のcolumnを追加する
+	err = addColumnIfNotExists(config.Config.WorkspaceAndUserTableName, "expires_at", "TIMESTAMP")
+	fmt.Println(err)
+
 	// create role table
-	cmd = fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s (
-			id INT PRIMARY KEY NOT NULL,
-			name STRING NOT NULL
-		)
-	`, config.Config.RoleTableName)
+	cmd = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (%s)`, config.Config.RoleTableName, rolesTableColumns)
 	_, err = DbConnection.Exec(cmd)
 	fmt.Println(err)
 
-	// insert 4 roles in roles table
+	// workspace_id columnが存在しない古いroles tableにcolumnを追加する
+	err = addColumnIfNotExists(config.Config.RoleTableName, "workspace_id", "INT NOT NULL DEFAULT 0")
+	fmt.Println(err)
+
+	// insert 6 roles in roles table
 	roleNames := []string{
 		"Workspace Primary Owner",
 		"Workspace Owners",
 		"Workspace Admins",
 		"Full members",
+		"Multi-Channel Guests",
+		"Single-Channel Guests",
 	}
 	for i, n := range roleNames {
 		r := NewRole(i+1, n)
@@ -92,17 +106,7 @@ func init() {
 	}
 
 	// create channels table
-	cmd = fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s 
-		(
-			id INT PRIMARY KEY NOT NULL,
-			name STRING NOT NULL,
-			description STRING,
-			is_private BOOLEAN NOT NULL,
-			is_archive BOOLEAN NOT NULL,
-			workspace_id INT NOT NULL
-		)
-	`, config.Config.ChannelsTableName)
+	cmd = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (%s)`, config.Config.ChannelsTableName, channelsTableColumns)
 	_, err = DbConnection.Exec(cmd)
 	fmt.Println(err)
 
@@ -120,16 +124,7 @@ func init() {
 	fmt.Println(err)
 
 	// create messages table
-	cmd = fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS %s 
-		(
-			id INT PRIMARY KEY NOT NULL,
-			text STRING NOT NULL,
-			date STRING NOT NULL,
-			channel_id INT NOT NULL,
-			user_id INT NOT NULL
-		)
-	`, config.Config.MessagesTableName)
+	cmd = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (%s)`, config.Config.MessagesTableName, messagesTableColumns)
 	_, err = DbConnection.Exec(cmd)
 	fmt.Println(err)
 	
@@ -159,4 +154,97 @@ func init() {
 	// `, config.Config.DMLinesTableName)
 	// db.Exec(cmd)
 	db.AutoMigrate(&DMLine{})
+
+	// create sessions table
+	db.AutoMigrate(&Session{})
+
+	// create two_factors and recovery_codes table
+	db.AutoMigrate(&TwoFactor{})
+	db.AutoMigrate(&RecoveryCode{})
+
+	// create password_resets table
+	db.AutoMigrate(&PasswordReset{})
+
+	// create email_verifications table
+	db.AutoMigrate(&EmailVerification{})
+
+	// create profiles and workspace_profiles table
+	db.AutoMigrate(&Profile{})
+	db.AutoMigrate(&WorkspaceProfile{})
+
+	// create presences table
+	db.AutoMigrate(&Presence{})
+
+	// create workspace_settings table
+	db.AutoMigrate(&WorkspaceSetting{})
+
+	// create api_tokens table
+	db.AutoMigrate(&ApiToken{})
+
+	// create workspace_invites table
+	db.AutoMigrate(&WorkspaceInvite{})
+
+	// create default_channels table
+	db.AutoMigrate(&DefaultChannel{})
+
+	// create role_capabilities table
+	db.AutoMigrate(&RoleCapability{})
+
+	// create user_groups, user_group_members and user_group_channels table
+	db.AutoMigrate(&UserGroup{})
+	db.AutoMigrate(&UserGroupMember{})
+	db.AutoMigrate(&UserGroupChannel{})
+
+	// create message_mentions and dm_mentions table
+	db.AutoMigrate(&MessageMention{})
+	db.AutoMigrate(&DMMention{})
+
+	// create oidc_states and user_identities table
+	db.AutoMigrate(&OidcState{})
+	db.AutoMigrate(&UserIdentity{})
+
+	// idをdatabaseで採番するように以前のversionのtableを作り直す
+	err = migrateAutoIncrementIds()
+	fmt.Println(err)
+
+	// 以前のversionで登録された重複したusernameとemailを解消してからuniqueにする
+	// uniqueを保証できない場合は起動しない
+	if err := dedupeUsers(config.Config.UserTableName); err != nil {
+		panic(fmt.Sprintf("failed to dedupe %s: %v", config.Config.UserTableName, err))
+	}
+	if err := createUserIndexes(); err != nil {
+		panic(fmt.Sprintf("failed to create unique indexes on %s: %v", config.Config.UserTableName, err))
+	}
+}
+
+func addColumnIfNotExists(tableName, columnName, definition string) error {
+	rows, err := DbConnection.Query(fmt.Sprintf("PRAGMA table_info(%s)", tableName))
+	if err != nil {
+		return err
+	}
+	columns := make([]string, 0)
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			dfltValue  sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &dfltValue, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		columns = append(columns, name)
+	}
+	rows.Close()
+
+	for _, c := range columns {
+		if c == columnName {
+			return nil
+		}
+	}
+	_, err = DbConnection.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableName, columnName, definition))
+	return err
 }
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// roleに与えられる操作の権限
+const (
+	CapWorkspaceRename       = "workspace.rename"
+	CapWorkspaceSettings     = "workspace.settings"
+	CapMembersAdd            = "members.add"
+	CapMembersInvite         = "members.invite"
+	CapMembersRemove         = "members.remove"
+	CapMembersDeactivate     = "members.deactivate"
+	CapMembersChangeRole     = "members.change_role"
+	CapRolesManage           = "roles.manage"
+	CapBotTokensManage       = "bot_tokens.manage"
+	CapChannelsCreatePublic  = "channels.create_public"
+	CapChannelsCreatePrivate = "channels.create_private"
+	CapChannelsRemoveMember  = "channels.remove_member"
+	CapChannelsDelete        = "channels.delete"
+	CapUserGroupsManage      = "user_groups.manage"
+)
+
+var Capabilities = []string{
+	CapWorkspaceRename,
+	CapWorkspaceSettings,
+	CapMembersAdd,
+	CapMembersInvite,
+	CapMembersRemove,
+	CapMembersDeactivate,
+	CapMembersChangeRole,
+	CapRolesManage,
+	CapBotTokensManage,
+	CapChannelsCreatePublic,
+	CapChannelsCreatePrivate,
+	CapChannelsRemoveMember,
+	CapChannelsDelete,
+	CapUserGroupsManage,
+}
+
+// built-in roleのcapability
+// channelの作成と招待はworkspaceの設定で変更できる
+var builtInRoleCapabilities = map[int][]string{
+	RolePrimaryOwner: Capabilities,
+	RoleOwner:        Capabilities,
+	RoleAdmin: {
+		CapWorkspaceRename,
+		CapWorkspaceSettings,
+		CapMembersAdd,
+		CapMembersInvite,
+		CapMembersRemove,
+		CapMembersDeactivate,
+		CapMembersChangeRole,
+		CapBotTokensManage,
+		CapChannelsCreatePublic,
+		CapChannelsCreatePrivate,
+		CapChannelsRemoveMember,
+		CapChannelsDelete,
+		CapUserGroupsManage,
+	},
+	RoleFullMember: {
+		CapChannelsCreatePublic,
+		CapChannelsCreatePrivate,
+	},
+}
+
+// custom roleのcapability
+type RoleCapability struct {
+	RoleId     int    `json:"role_id" gorm:"primaryKey; autoIncrement:false"`
+	Capability string `json:"capability" gorm:"primaryKey"`
+}
+
+func IsValidCapability(capability string) bool {
+	for _, c := range Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+func GetCapabilitiesByRole(r Role) ([]string, error) {
+	if r.IsBuiltIn() {
+		result := make([]string, len(builtInRoleCapabilities[r.ID]))
+		copy(result, builtInRoleCapabilities[r.ID])
+		return result, nil
+	}
+	result := make([]string, 0)
+	err := db.Model(&RoleCapability{}).Where("role_id = ?", r.ID).Order("capability").Pluck("capability", &result).Error
+	return result, err
+}
+
+func SetRoleCapabilities(roleId int, capabilities []string) error {
+	// 既存のcapabilityを置き換える
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", roleId).Delete(&RoleCapability{}).Error; err != nil {
+			return err
+		}
+		for _, c := range capabilities {
+			if err := tx.Create(&RoleCapability{RoleId: roleId, Capability: c}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func DeleteRoleCapabilitiesByRoleId(roleId int) error {
+	return db.Where("role_id = ?", roleId).Delete(&RoleCapability{}).Error
+}
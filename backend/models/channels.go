@@ -26,32 +26,19 @@ func NewChannel(id int, name, description string, isPrivate, isArchive bool, wor
 	}
 }
 
-func (c *Channel) SetId() error {
-	cmd := fmt.Sprintf("SELECT id FROM %s", config.Config.ChannelsTableName)
-	rows, err := DbConnection.Query(cmd)
+func (c *Channel) Create() error {
+	// idはdatabaseが採番する
+	cmd := fmt.Sprintf("INSERT INTO %s (name, description, is_private, is_archive, workspace_id) VALUES ($1, $2, $3, $4, $5)", config.Config.ChannelsTableName)
+	res, err := DbConnection.Exec(cmd, c.Name, c.Description, c.IsPrivate, c.IsArchive, c.WorkspaceId)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
-	maxId := 0
-	for rows.Next() {
-		var id int
-		rows.Scan(&id)
-		if id > maxId {
-			maxId = id
-		}
-	}
-	c.ID = maxId + 1
-	return nil
-}
-
-func (c *Channel) Create() error {
-	if err := c.SetId(); err != nil {
+	id, err := res.LastInsertId()
+	if err != nil {
 		return err
 	}
-	cmd := fmt.Sprintf("INSERT INTO %s (id, name, description, is_private, is_archive, workspace_id) VALUES ($1, $2, $3, $4, $5, $6)", config.Config.ChannelsTableName)
-	_, err := DbConnection.Exec(cmd, c.ID, c.Name, c.Description, c.IsPrivate, c.IsArchive, c.WorkspaceId)
-	return err
+	c.ID = int(id)
+	return nil
 }
 
 func (c *Channel) IsExistSameNameChannelInWorkspace(workspaceId int) (bool, error) {
@@ -126,3 +113,12 @@ func GetChannelsByWorkspaceId(workspaceId int) ([]Channel, error) {
 	}
 	return channels, nil
 }
+
+func (c *Channel) Archive() error {
+	cmd := fmt.Sprintf("UPDATE %s SET is_archive = $1 WHERE id = $2", config.Config.ChannelsTableName)
+	if _, err := DbConnection.Exec(cmd, true, c.ID); err != nil {
+		return err
+	}
+	c.IsArchive = true
+	return nil
+}
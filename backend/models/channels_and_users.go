@@ -93,3 +93,31 @@ func GetCAUsByUserId(userId uint32) ([]ChannelsAndUsers, error) {
 	}
 	return caus, err
 }
+
+func GetCAUsByChannelId(channelId int) ([]ChannelsAndUsers, error) {
+	// 参加した順に並べる
+	caus := make([]ChannelsAndUsers, 0)
+	cmd := fmt.Sprintf("SELECT channel_id, user_id, is_admin FROM %s WHERE channel_id = $1 ORDER BY rowid", config.Config.ChannelsAndUserTableName)
+	rows, err := DbConnection.Query(cmd, channelId)
+	if err != nil {
+		return caus, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cau ChannelsAndUsers
+		if err := rows.Scan(&cau.ChannelId, &cau.UserId, &cau.IsAdmin); err != nil {
+			return caus, err
+		}
+		caus = append(caus, cau)
+	}
+	return caus, nil
+}
+
+func (cau *ChannelsAndUsers) UpdateIsAdmin(isAdmin bool) error {
+	cmd := fmt.Sprintf("UPDATE %s SET is_admin = $1 WHERE channel_id = $2 AND user_id = $3", config.Config.ChannelsAndUserTableName)
+	if _, err := DbConnection.Exec(cmd, isAdmin, cau.ChannelId, cau.UserId); err != nil {
+		return err
+	}
+	cau.IsAdmin = isAdmin
+	return nil
+}
@@ -159,3 +159,23 @@ func TestGetCAUsByUserId(t *testing.T) {
 		assert.Equal(t, 0, len(res))
 	})
 }
+
+func TestGetCAUsByChannelIdAndUpdateIsAdmin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	channelId := rand.Int()
+	admin := NewChannelsAndUses(channelId, rand.Uint32(), true)
+	member := NewChannelsAndUses(channelId, rand.Uint32(), false)
+	assert.Empty(t, admin.Create())
+	assert.Empty(t, member.Create())
+
+	caus, err := GetCAUsByChannelId(channelId)
+	assert.Empty(t, err)
+	assert.Equal(t, []ChannelsAndUsers{*admin, *member}, caus)
+
+	assert.Empty(t, member.UpdateIsAdmin(true))
+	assert.True(t, IsAdminUserInChannel(channelId, member.UserId))
+	assert.Empty(t, member.UpdateIsAdmin(false))
+	assert.False(t, IsAdminUserInChannel(channelId, member.UserId))
+}
@@ -86,3 +86,17 @@ func TestGetChannelsByWorkspaceId(t *testing.T) {
 		assert.Equal(t, 0, len(chs))
 	})
 }
+
+func TestArchiveChannel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	c := NewChannel(0, randomstring.EnglishFrequencyString(30), "", true, false, rand.Int())
+	assert.Empty(t, c.Create())
+	assert.Empty(t, c.Archive())
+	assert.True(t, c.IsArchive)
+
+	res, err := GetChannelById(c.ID)
+	assert.Empty(t, err)
+	assert.True(t, res.IsArchive)
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// workspaceに参加したuserが自動で参加するchannel
+// generalはここに登録されていなくても常に含まれる
+type DefaultChannel struct {
+	WorkspaceId int       `json:"workspace_id" gorm:"primaryKey; autoIncrement:false"`
+	ChannelId   int       `json:"channel_id" gorm:"primaryKey; autoIncrement:false; index"`
+	CreatedBy   uint32    `json:"created_by" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"not null"`
+}
+
+func NewDefaultChannel(workspaceId, channelId int, createdBy uint32) *DefaultChannel {
+	return &DefaultChannel{
+		WorkspaceId: workspaceId,
+		ChannelId:   channelId,
+		CreatedBy:   createdBy,
+	}
+}
+
+func (dc *DefaultChannel) Create() *gorm.DB {
+	return db.Create(dc)
+}
+
+func GetDefaultChannelIdsByWorkspaceId(workspaceId int) ([]int, error) {
+	channelIds := make([]int, 0)
+	err := db.Model(&DefaultChannel{}).Where("workspace_id = ?", workspaceId).Order("created_at").Pluck("channel_id", &channelIds).Error
+	return channelIds, err
+}
+
+func IsDefaultChannel(workspaceId, channelId int) (bool, error) {
+	var cnt int64
+	err := db.Model(&DefaultChannel{}).Where("workspace_id = ? AND channel_id = ?", workspaceId, channelId).Count(&cnt).Error
+	return cnt > 0, err
+}
+
+func DeleteDefaultChannel(workspaceId, channelId int) *gorm.DB {
+	return db.Where("workspace_id = ? AND channel_id = ?", workspaceId, channelId).Delete(&DefaultChannel{})
+}
+
+func DeleteDefaultChannelsByChannelId(channelId int) error {
+	return db.Where("channel_id = ?", channelId).Delete(&DefaultChannel{}).Error
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultChannel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 登録した順に取得できる, 同じchannelは重複して登録できない
+	// 2 workspaceとchannelを指定して削除できる
+	// 3 channelが削除された場合はまとめて削除できる
+
+	workspaceId := int(rand.Int31())
+	channelIds := []int{int(rand.Int31()), int(rand.Int31())}
+	for _, channelId := range channelIds {
+		assert.Empty(t, NewDefaultChannel(workspaceId, channelId, rand.Uint32()).Create().Error)
+	}
+
+	t.Run("1", func(t *testing.T) {
+		res, err := GetDefaultChannelIdsByWorkspaceId(workspaceId)
+		assert.Empty(t, err)
+		assert.Equal(t, channelIds, res)
+
+		b, err := IsDefaultChannel(workspaceId, channelIds[0])
+		assert.Empty(t, err)
+		assert.True(t, b)
+		b, err = IsDefaultChannel(int(rand.Int31()), channelIds[0])
+		assert.Empty(t, err)
+		assert.False(t, b)
+
+		assert.NotEmpty(t, NewDefaultChannel(workspaceId, channelIds[0], rand.Uint32()).Create().Error)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Equal(t, int64(1), DeleteDefaultChannel(workspaceId, channelIds[0]).RowsAffected)
+		assert.Equal(t, int64(0), DeleteDefaultChannel(workspaceId, channelIds[0]).RowsAffected)
+		res, err := GetDefaultChannelIdsByWorkspaceId(workspaceId)
+		assert.Empty(t, err)
+		assert.Equal(t, channelIds[1:], res)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Empty(t, DeleteDefaultChannelsByChannelId(channelIds[1]))
+		res, err := GetDefaultChannelIdsByWorkspaceId(workspaceId)
+		assert.Empty(t, err)
+		assert.Equal(t, 0, len(res))
+	})
+}
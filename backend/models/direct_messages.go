@@ -67,5 +67,26 @@ func DeleteDM(id uint) (DirectMessage, error) {
 	if err != nil {
 		return dm, err
 	}
-	return dm, db.Delete(&DirectMessage{}, id).Error
+	// mentionも削除する
+	return dm, db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("direct_message_id = ?", id).Delete(&DMMention{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&DirectMessage{}, id).Error
+	})
+}
+
+func DeleteDMsBeforeInWorkspace(workspaceId int, before time.Time) (int64, error) {
+	dmLineIds := db.Model(&DMLine{}).Select("id").Where("workspace_id = ?", workspaceId)
+	var cnt int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		dmIds := tx.Model(&DirectMessage{}).Select("id").Where("created_at < ? AND dm_line_id IN (?)", before, dmLineIds)
+		if err := tx.Where("direct_message_id IN (?)", dmIds).Delete(&DMMention{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("created_at < ? AND dm_line_id IN (?)", before, dmLineIds).Delete(&DirectMessage{})
+		cnt = res.RowsAffected
+		return res.Error
+	})
+	return cnt, err
 }
@@ -1,16 +1,14 @@
 package models
 
 import (
-	"fmt"
-
 	"gorm.io/gorm"
 )
 
 type DMLine struct {
-	ID          uint   `json:"id" gorm:"unique"`
-	WorkspaceId int    `json:"workspace_id" gorm:"not null"`
-	UserId1     uint32 `json:"user_id_1" gorm:"primaryKey; column:user_id_1"`
-	UserId2     uint32 `json:"user_id_2" gorm:"primaryKey; column:user_id_2"`
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	WorkspaceId int    `json:"workspace_id" gorm:"not null; uniqueIndex:idx_dm_lines_workspace_users"`
+	UserId1     uint32 `json:"user_id_1" gorm:"not null; column:user_id_1; uniqueIndex:idx_dm_lines_workspace_users"`
+	UserId2     uint32 `json:"user_id_2" gorm:"not null; column:user_id_2; uniqueIndex:idx_dm_lines_workspace_users"`
 }
 
 func NewDMLine(workspaceId int, userId1, userId2 uint32) *DMLine {
@@ -21,21 +19,8 @@ func NewDMLine(workspaceId int, userId1, userId2 uint32) *DMLine {
 	}
 }
 
-func (dl *DMLine) SetID() {
-	rows, err := db.Model(&DMLine{}).Rows()
-	if err != nil {
-		fmt.Println(err)
-	}
-	cnt := 0
-	defer rows.Close()
-	for rows.Next() {
-		cnt++
-	}
-	dl.ID = uint(cnt + 1)
-}
-
 func (dl *DMLine) Create() *gorm.DB {
-	dl.SetID()
+	// idはdatabaseが採番する
 	if !(dl.UserId1 <= dl.UserId2) {
 		dl.UserId1, dl.UserId2 = dl.UserId2, dl.UserId1
 	}
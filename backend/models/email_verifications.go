@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type EmailVerification struct {
+	TokenHash string     `json:"-" gorm:"primaryKey"`
+	UserId    uint32     `json:"user_id" gorm:"not null; index"`
+	Email     string     `json:"email" gorm:"not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"not null"`
+}
+
+func NewEmailVerification(tokenHash string, userId uint32, email string, expiresAt time.Time) *EmailVerification {
+	return &EmailVerification{
+		TokenHash: tokenHash,
+		UserId:    userId,
+		Email:     email,
+		ExpiresAt: expiresAt,
+	}
+}
+
+func (ev *EmailVerification) Create() *gorm.DB {
+	return db.Create(ev)
+}
+
+func GetEmailVerificationByTokenHash(tokenHash string) (EmailVerification, error) {
+	var ev EmailVerification
+	err := db.First(&ev, "token_hash = ?", tokenHash).Error
+	return ev, err
+}
+
+func (ev *EmailVerification) IsValid() bool {
+	return ev.UsedAt == nil && time.Now().Before(ev.ExpiresAt)
+}
+
+func (ev *EmailVerification) Use() (bool, error) {
+	// 同時にrequestされても1回しか使えないようにする
+	result := db.Model(&EmailVerification{}).Where("token_hash = ? AND used_at IS NULL", ev.TokenHash).Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func InvalidateEmailVerificationsByUserId(userId uint32) error {
+	return db.Model(&EmailVerification{}).Where("user_id = ? AND used_at IS NULL", userId).Update("used_at", time.Now()).Error
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+)
+
+func TestEmailVerification(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 作成して1回だけ使える
+	// 2 期限切れのものは無効
+	// 3 userのものをまとめて無効にできる
+
+	t.Run("1", func(t *testing.T) {
+		ev := NewEmailVerification(randomstring.EnglishFrequencyString(30), rand.Uint32(), "a@example.com", time.Now().Add(time.Hour))
+		assert.Empty(t, ev.Create().Error)
+		res, err := GetEmailVerificationByTokenHash(ev.TokenHash)
+		assert.Empty(t, err)
+		assert.True(t, res.IsValid())
+		assert.Equal(t, "a@example.com", res.Email)
+
+		b, err := res.Use()
+		assert.Empty(t, err)
+		assert.True(t, b)
+		b, err = res.Use()
+		assert.Empty(t, err)
+		assert.False(t, b)
+
+		res, err = GetEmailVerificationByTokenHash(ev.TokenHash)
+		assert.Empty(t, err)
+		assert.False(t, res.IsValid())
+
+		_, err = GetEmailVerificationByTokenHash(randomstring.EnglishFrequencyString(30))
+		assert.NotEmpty(t, err)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		ev := NewEmailVerification(randomstring.EnglishFrequencyString(30), rand.Uint32(), "a@example.com", time.Now().Add(-time.Hour))
+		assert.Empty(t, ev.Create().Error)
+		res, err := GetEmailVerificationByTokenHash(ev.TokenHash)
+		assert.Empty(t, err)
+		assert.False(t, res.IsValid())
+	})
+
+	t.Run("3", func(t *testing.T) {
+		userId := rand.Uint32()
+		hashes := make([]string, 3)
+		for i := range hashes {
+			ev := NewEmailVerification(randomstring.EnglishFrequencyString(30), userId, "a@example.com", time.Now().Add(time.Hour))
+			assert.Empty(t, ev.Create().Error)
+			hashes[i] = ev.TokenHash
+		}
+		assert.Empty(t, InvalidateEmailVerificationsByUserId(userId))
+		for _, h := range hashes {
+			res, err := GetEmailVerificationByTokenHash(h)
+			assert.Empty(t, err)
+			assert.False(t, res.IsValid())
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"backend/config"
+)
+
+// @handleを展開してmentionされたuser
+type MessageMention struct {
+	MessageId int    `json:"message_id" gorm:"primaryKey; autoIncrement:false"`
+	UserId    uint32 `json:"user_id" gorm:"primaryKey; autoIncrement:false; index"`
+}
+
+type DMMention struct {
+	DirectMessageId uint   `json:"direct_message_id" gorm:"primaryKey; autoIncrement:false"`
+	UserId          uint32 `json:"user_id" gorm:"primaryKey; autoIncrement:false; index"`
+}
+
+func (m *Message) CreateWithMentions(mentionedUserIds []uint32) error {
+	// messageとmentionを同じtransactionで登録する. idはdatabaseが採番する
+	tx, err := DbConnection.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	m.SetDate()
+	cmd := fmt.Sprintf("INSERT INTO %s (text, date, channel_id, user_id) VALUES ($1, $2, $3, $4)", config.Config.MessagesTableName)
+	res, err := tx.Exec(cmd, m.Text, m.Date, m.ChannelId, m.UserId)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	cmd = fmt.Sprintf("INSERT INTO %s (message_id, user_id) VALUES ($1, $2)", gormTableName(&MessageMention{}))
+	for _, userId := range mentionedUserIds {
+		if _, err := tx.Exec(cmd, id, userId); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	m.ID = int(id)
+	return nil
+}
+
+func (dm *DirectMessage) CreateWithMentions(mentionedUserIds []uint32) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(dm).Error; err != nil {
+			return err
+		}
+		for _, userId := range mentionedUserIds {
+			if err := tx.Create(&DMMention{DirectMessageId: dm.ID, UserId: userId}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func UpdateDMWithMentions(id uint, text string, mentionedUserIds []uint32) (DirectMessage, error) {
+	// textを変更し, mentionを新しいtextから展開したものに置き換える
+	var result DirectMessage
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&DirectMessage{}).Where("id = ?", id).Update("text", text).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("direct_message_id = ?", id).Delete(&DMMention{}).Error; err != nil {
+			return err
+		}
+		for _, userId := range mentionedUserIds {
+			if err := tx.Create(&DMMention{DirectMessageId: id, UserId: userId}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.First(&result, id).Error
+	})
+	return result, err
+}
+
+func GetMessageMentionsByChannelId(channelId int) (map[int][]uint32, error) {
+	// channelのmessageごとにmentionされたuserのidを返す
+	res := make(map[int][]uint32)
+	var mentions []MessageMention
+	messageIds := fmt.Sprintf("SELECT id FROM %s WHERE channel_id = ?", config.Config.MessagesTableName)
+	err := db.Where("message_id IN ("+messageIds+")", channelId).Order("rowid").Find(&mentions).Error
+	for _, mm := range mentions {
+		res[mm.MessageId] = append(res[mm.MessageId], mm.UserId)
+	}
+	return res, err
+}
+
+func GetDMMentionsByDLId(dmLineId uint) (map[uint][]uint32, error) {
+	// dm_lineのDMごとにmentionされたuserのidを返す
+	res := make(map[uint][]uint32)
+	var mentions []DMMention
+	dmIds := db.Model(&DirectMessage{}).Select("id").Where("dm_line_id = ?", dmLineId)
+	err := db.Where("direct_message_id IN (?)", dmIds).Order("rowid").Find(&mentions).Error
+	for _, dmm := range mentions {
+		res[dmm.DirectMessageId] = append(res[dmm.DirectMessageId], dmm.UserId)
+	}
+	return res, err
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+)
+
+func TestMessageMentions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 messageとmentionを登録し、channelのmessageごとに取得できる
+	// 2 mentionがないmessageは含まれない
+
+	channelId := rand.Int()
+	userIds := []uint32{rand.Uint32(), rand.Uint32()}
+	m1 := NewMessage(randomstring.EnglishFrequencyString(30), channelId, rand.Uint32())
+	m2 := NewMessage(randomstring.EnglishFrequencyString(30), channelId, rand.Uint32())
+
+	t.Run("1", func(t *testing.T) {
+		assert.Empty(t, m1.CreateWithMentions(userIds))
+		assert.NotEqual(t, 0, m1.ID)
+		mentions, err := GetMessageMentionsByChannelId(channelId)
+		assert.Empty(t, err)
+		assert.Equal(t, userIds, mentions[m1.ID])
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Empty(t, m2.CreateWithMentions(nil))
+		mentions, err := GetMessageMentionsByChannelId(channelId)
+		assert.Empty(t, err)
+		assert.Equal(t, 1, len(mentions))
+		_, ok := mentions[m2.ID]
+		assert.False(t, ok)
+	})
+}
+
+func TestDMMentions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 DMとmentionを登録し、dm_lineのDMごとに取得できる
+	// 2 DMを編集するとmentionが置き換えられる
+	// 3 DMを削除するとmentionも削除される
+
+	dmLineId := uint(rand.Uint32())
+	userId := rand.Uint32()
+	dm := NewDirectMessage(randomstring.EnglishFrequencyString(30), rand.Uint32(), dmLineId)
+
+	t.Run("1", func(t *testing.T) {
+		assert.Empty(t, dm.CreateWithMentions([]uint32{userId}))
+		assert.NotEqual(t, uint(0), dm.ID)
+		mentions, err := GetDMMentionsByDLId(dmLineId)
+		assert.Empty(t, err)
+		assert.Equal(t, []uint32{userId}, mentions[dm.ID])
+	})
+
+	t.Run("2", func(t *testing.T) {
+		newUserId := rand.Uint32()
+		text := randomstring.EnglishFrequencyString(30)
+		res, err := UpdateDMWithMentions(dm.ID, text, []uint32{newUserId})
+		assert.Empty(t, err)
+		assert.Equal(t, dm.ID, res.ID)
+		assert.Equal(t, text, res.Text)
+		mentions, err := GetDMMentionsByDLId(dmLineId)
+		assert.Empty(t, err)
+		assert.Equal(t, []uint32{newUserId}, mentions[dm.ID])
+	})
+
+	t.Run("3", func(t *testing.T) {
+		_, err := DeleteDM(dm.ID)
+		assert.Empty(t, err)
+		var cnt int64
+		assert.Empty(t, db.Model(&DMMention{}).Where("direct_message_id = ?", dm.ID).Count(&cnt).Error)
+		assert.Equal(t, int64(0), cnt)
+	})
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"time"
 
 	"backend/config"
 	"backend/utils"
@@ -25,37 +26,12 @@ func NewMessage(text string, channelId int, userId uint32) *Message {
 	}
 }
 
-func (m *Message) SetID() error {
-	cmd := fmt.Sprintf("SELECT id FROM %s", config.Config.MessagesTableName)
-	rows, err := DbConnection.Query(cmd)
-	if err != nil {
-		return err
-	}
-	defer rows.Close()
-	maxId := 0
-	for rows.Next() {
-		var id int
-		rows.Scan(&id)
-		if id > maxId {
-			maxId = id
-		}
-	}
-	m.ID = maxId + 1
-	return nil
-}
-
 func (m *Message) SetDate() {
 	m.Date = utils.GetCurrentTime()
 }
 
 func (m *Message) Create() error {
-	if err := m.SetID(); err != nil {
-		return err
-	}
-	m.SetDate()
-	cmd := fmt.Sprintf("INSERT INTO %s (id, text, date, channel_id, user_id) VALUES ($1, $2, $3, $4, $5)", config.Config.MessagesTableName)
-	_, err := DbConnection.Exec(cmd, m.ID, m.Text, m.Date, m.ChannelId, m.UserId)
-	return err
+	return m.CreateWithMentions(nil)
 }
 
 func GetMessagesByChannelId(channelId int) ([]Message, error) {
@@ -82,3 +58,28 @@ func GetMessagesByChannelId(channelId int) ([]Message, error) {
 	}
 	return res, nil
 }
+
+func DeleteMessagesBeforeInWorkspace(workspaceId int, before time.Time) (int64, error) {
+	// dateは文字列で保存しているので同じformatで比較する
+	// mentionも同じtransactionで削除する
+	where := fmt.Sprintf("date < $1 AND channel_id IN (SELECT id FROM %s WHERE workspace_id = $2)", config.Config.ChannelsTableName)
+	tx, err := DbConnection.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	cmd := fmt.Sprintf("DELETE FROM %s WHERE message_id IN (SELECT id FROM %s WHERE %s)", gormTableName(&MessageMention{}), config.Config.MessagesTableName, where)
+	if _, err := tx.Exec(cmd, before.Format(utils.TimeFormat), workspaceId); err != nil {
+		return 0, err
+	}
+	cmd = fmt.Sprintf("DELETE FROM %s WHERE %s", config.Config.MessagesTableName, where)
+	res, err := tx.Exec(cmd, before.Format(utils.TimeFormat), workspaceId)
+	if err != nil {
+		return 0, err
+	}
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return cnt, tx.Commit()
+}
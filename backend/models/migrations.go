@@ -0,0 +1,317 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"backend/config"
+)
+
+// idはすべてdatabaseのautoincrementで採番する
+// AUTOINCREMENTを指定して削除されたrowのidが再利用されないようにする
+const (
+	usersTableColumns = `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name STRING NOT NULL,
+			password STRING NOT NULL,
+			email STRING,
+			email_verified_at DATETIME,
+			deactivated_at DATETIME,
+			has_password BOOLEAN NOT NULL DEFAULT 1`
+	workspacesTableColumns = `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name STRING NOT NULL UNIQUE,
+			workspace_primary_owner_id STRING not NULL,
+			deleted_at DATETIME`
+	channelsTableColumns = `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name STRING NOT NULL,
+			description STRING,
+			is_private BOOLEAN NOT NULL,
+			is_archive BOOLEAN NOT NULL,
+			workspace_id INT NOT NULL`
+	rolesTableColumns = `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name STRING NOT NULL,
+			workspace_id INT NOT NULL DEFAULT 0`
+	messagesTableColumns = `
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			text STRING NOT NULL,
+			date STRING NOT NULL,
+			channel_id INT NOT NULL,
+			user_id INT NOT NULL`
+)
+
+func dedupeUsers(tableName string) error {
+	// unique indexを作る前に, 大文字小文字を区別せず重複したusernameとemailを解消する
+	// usernameは最初に登録されたuser以外の名前の末尾にidを付ける
+	// emailは確認済みのもの(同じ場合は最初に登録されたもの)のみ残し, 他のuserのemailは未設定にする
+	tx, err := DbConnection.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	type duplicate struct {
+		id   uint32
+		name string
+	}
+	cmd := fmt.Sprintf("SELECT u.id, u.name FROM %s u WHERE EXISTS (SELECT 1 FROM %s o WHERE o.name = u.name COLLATE NOCASE AND o.rowid < u.rowid) ORDER BY u.rowid", tableName, tableName)
+	rows, err := tx.Query(cmd)
+	if err != nil {
+		return err
+	}
+	duplicates := make([]duplicate, 0)
+	for rows.Next() {
+		var d duplicate
+		if err := rows.Scan(&d.id, &d.name); err != nil {
+			rows.Close()
+			return err
+		}
+		duplicates = append(duplicates, d)
+	}
+	rows.Close()
+	for _, d := range duplicates {
+		// 付けた名前が他のuserと重複する場合はさらにidを付ける
+		name := fmt.Sprintf("%s-%d", d.name, d.id)
+		for {
+			var cnt int
+			cmd := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE name = $1 COLLATE NOCASE", tableName)
+			if err := tx.QueryRow(cmd, name).Scan(&cnt); err != nil {
+				return err
+			}
+			if cnt == 0 {
+				break
+			}
+			name = fmt.Sprintf("%s-%d", name, d.id)
+		}
+		cmd := fmt.Sprintf("UPDATE %s SET name = $1 WHERE id = $2", tableName)
+		if _, err := tx.Exec(cmd, name, d.id); err != nil {
+			return err
+		}
+	}
+
+	cmd = fmt.Sprintf(`
+		UPDATE %s SET email = NULL, email_verified_at = NULL
+		WHERE email IS NOT NULL AND EXISTS (
+			SELECT 1 FROM %s o WHERE o.email = %s.email COLLATE NOCASE AND o.rowid != %s.rowid AND (
+				(o.email_verified_at IS NOT NULL AND %s.email_verified_at IS NULL) OR
+				((o.email_verified_at IS NULL) = (%s.email_verified_at IS NULL) AND o.rowid < %s.rowid)
+			)
+		)
+	`, tableName, tableName, tableName, tableName, tableName, tableName, tableName)
+	if _, err := tx.Exec(cmd); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func createUserIndexes() error {
+	// usernameは大文字小文字を区別せずunique, emailは設定されている場合のみunique
+	cmd := fmt.Sprintf(`
+		CREATE UNIQUE INDEX IF NOT EXISTS %s_name_unique ON %s (name COLLATE NOCASE)
+	`, config.Config.UserTableName, config.Config.UserTableName)
+	if _, err := DbConnection.Exec(cmd); err != nil {
+		return err
+	}
+	cmd = fmt.Sprintf(`
+		CREATE UNIQUE INDEX IF NOT EXISTS %s_email_unique ON %s (email COLLATE NOCASE) WHERE email IS NOT NULL
+	`, config.Config.UserTableName, config.Config.UserTableName)
+	_, err := DbConnection.Exec(cmd)
+	return err
+}
+
+func tableSchema(tx *sql.Tx, tableName string) (string, error) {
+	var schema string
+	err := tx.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = $1", tableName).Scan(&schema)
+	return schema, err
+}
+
+func gormTableName(model interface{}) string {
+	stmt := &gorm.Statement{DB: db}
+	stmt.Parse(model)
+	return stmt.Schema.Table
+}
+
+func rebuildTable(tx *sql.Tx, tableName, columnsDef, columns, selectCmd string) error {
+	// SQLiteではprimary keyを変更できないので新しいtableにコピーして置き換える
+	cmds := []string{
+		fmt.Sprintf("CREATE TABLE %s_new (%s)", tableName, columnsDef),
+		fmt.Sprintf("INSERT INTO %s_new (%s) %s", tableName, columns, selectCmd),
+		fmt.Sprintf("DROP TABLE %s", tableName),
+		fmt.Sprintf("ALTER TABLE %s_new RENAME TO %s", tableName, tableName),
+	}
+	for _, cmd := range cmds {
+		if _, err := tx.Exec(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type userIdColumn struct {
+	table  string
+	column string
+}
+
+func userIdColumns() []userIdColumn {
+	// user idを保存しているcolumn
+	return []userIdColumn{
+		{config.Config.WorkspaceTableName, "workspace_primary_owner_id"},
+		{config.Config.WorkspaceAndUserTableName, "user_id"},
+		{config.Config.ChannelsAndUserTableName, "user_id"},
+		{config.Config.MessagesTableName, "user_id"},
+		{gormTableName(&DirectMessage{}), "send_user_id"},
+		{gormTableName(&DMLine{}), "user_id_1"},
+		{gormTableName(&DMLine{}), "user_id_2"},
+		{gormTableName(&Session{}), "user_id"},
+		{gormTableName(&TwoFactor{}), "user_id"},
+		{gormTableName(&RecoveryCode{}), "user_id"},
+		{gormTableName(&PasswordReset{}), "user_id"},
+		{gormTableName(&Profile{}), "user_id"},
+		{gormTableName(&WorkspaceProfile{}), "user_id"},
+		{gormTableName(&Presence{}), "user_id"},
+		{gormTableName(&ApiToken{}), "user_id"},
+		{gormTableName(&ApiToken{}), "created_by"},
+		{gormTableName(&UserIdentity{}), "user_id"},
+		{gormTableName(&EmailVerification{}), "user_id"},
+		{gormTableName(&WorkspaceInvite{}), "created_by"},
+		{gormTableName(&DefaultChannel{}), "created_by"},
+		{gormTableName(&UserGroup{}), "created_by"},
+		{gormTableName(&UserGroupMember{}), "user_id"},
+		{gormTableName(&MessageMention{}), "user_id"},
+		{gormTableName(&DMMention{}), "user_id"},
+	}
+}
+
+func migrateUserIds(tx *sql.Tx) error {
+	// 以前はランダムなuint32をidにしていたので、連番になるように振り直す
+	// (そのままではautoincrementの次のidがuint32の範囲を超えてしまう)
+	// 発行済みのtokenのuser idは一致しなくなるので再度loginが必要になる
+	userTable := config.Config.UserTableName
+	cmd := fmt.Sprintf("CREATE TEMP TABLE user_id_map AS SELECT id AS old_id, ROW_NUMBER() OVER (ORDER BY rowid) AS new_id FROM %s", userTable)
+	if _, err := tx.Exec(cmd); err != nil {
+		return err
+	}
+	err := rebuildTable(tx, userTable, usersTableColumns,
+		"id, name, password, email, email_verified_at, deactivated_at, has_password",
+		fmt.Sprintf("SELECT m.new_id, u.name, u.password, u.email, u.email_verified_at, u.deactivated_at, u.has_password FROM %s u JOIN user_id_map m ON m.old_id = u.id ORDER BY m.new_id", userTable),
+	)
+	if err != nil {
+		return err
+	}
+
+	// 他のtableのuser idを置き換える
+	// primary keyが一時的に重複しないように一度負の値にしてから戻す
+	for _, c := range userIdColumns() {
+		cmds := []string{
+			fmt.Sprintf("UPDATE %s SET %s = -(SELECT new_id FROM user_id_map WHERE old_id = %s.%s) WHERE %s IN (SELECT old_id FROM user_id_map)", c.table, c.column, c.table, c.column, c.column),
+			fmt.Sprintf("UPDATE %s SET %s = -%s WHERE %s < 0", c.table, c.column, c.column, c.column),
+		}
+		for _, cmd := range cmds {
+			if _, err := tx.Exec(cmd); err != nil {
+				return err
+			}
+		}
+	}
+
+	// DM lineはuser_id_1 <= user_id_2になるように保存する
+	dmLineTable := gormTableName(&DMLine{})
+	cmd = fmt.Sprintf("UPDATE %s SET user_id_1 = user_id_2, user_id_2 = user_id_1 WHERE user_id_1 > user_id_2", dmLineTable)
+	if _, err := tx.Exec(cmd); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DROP TABLE user_id_map")
+	return err
+}
+
+func migrateDMLineIds(tx *sql.Tx) error {
+	// 以前はuser idの組をprimary keyにしてidを件数から決めていたので、idをprimary keyにする
+	tableName := gormTableName(&DMLine{})
+	return rebuildTable(tx, tableName,
+		"`id` integer,`workspace_id` integer NOT NULL,`user_id_1` integer NOT NULL,`user_id_2` integer NOT NULL,PRIMARY KEY (`id`)",
+		"id, workspace_id, user_id_1, user_id_2",
+		fmt.Sprintf("SELECT id, workspace_id, user_id_1, user_id_2 FROM %s", tableName),
+	)
+}
+
+func reserveBuiltInRoleIds(tx *sql.Tx) error {
+	// custom roleのidはbuilt-in role用に予約したidより大きい値からdatabaseが採番する
+	cmds := []string{
+		"INSERT INTO sqlite_sequence (name, seq) SELECT $1, $2 WHERE NOT EXISTS (SELECT 1 FROM sqlite_sequence WHERE name = $1)",
+		"UPDATE sqlite_sequence SET seq = $1 WHERE name = $2 AND seq < $1",
+	}
+	if _, err := tx.Exec(cmds[0], config.Config.RoleTableName, maxBuiltInRoleId); err != nil {
+		return err
+	}
+	_, err := tx.Exec(cmds[1], maxBuiltInRoleId, config.Config.RoleTableName)
+	return err
+}
+
+func migrateAutoIncrementIds() error {
+	tx, err := DbConnection.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	schema, err := tableSchema(tx, config.Config.UserTableName)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(schema, "AUTOINCREMENT") {
+		if err := migrateUserIds(tx); err != nil {
+			return err
+		}
+	}
+
+	// workspace, channel, messageは連番なのでidはそのまま使う
+	tables := []struct {
+		name       string
+		columnsDef string
+		columns    string
+	}{
+		{config.Config.WorkspaceTableName, workspacesTableColumns, "id, name, workspace_primary_owner_id, deleted_at"},
+		{config.Config.ChannelsTableName, channelsTableColumns, "id, name, description, is_private, is_archive, workspace_id"},
+		{config.Config.MessagesTableName, messagesTableColumns, "id, text, date, channel_id, user_id"},
+		{config.Config.RoleTableName, rolesTableColumns, "id, name, workspace_id"},
+	}
+	for _, t := range tables {
+		schema, err := tableSchema(tx, t.name)
+		if err != nil {
+			return err
+		}
+		if strings.Contains(schema, "AUTOINCREMENT") {
+			continue
+		}
+		if err := rebuildTable(tx, t.name, t.columnsDef, t.columns, fmt.Sprintf("SELECT %s FROM %s", t.columns, t.name)); err != nil {
+			return err
+		}
+	}
+
+	if err := reserveBuiltInRoleIds(tx); err != nil {
+		return err
+	}
+
+	schema, err = tableSchema(tx, gormTableName(&DMLine{}))
+	if err != nil {
+		return err
+	}
+	if strings.Contains(schema, "PRIMARY KEY (`user_id_1`") {
+		if err := migrateDMLineIds(tx); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// 作り直したtableのindexを作成する
+	// users tableのindexは起動時にcreateUserIndexesで作成する
+	return db.AutoMigrate(&DMLine{})
+}
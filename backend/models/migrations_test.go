@@ -0,0 +1,177 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+)
+
+func TestConcurrentCreateIds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 同時に作成しても重複しないidが採番される
+
+	n := 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	userIds := make(map[uint32]bool)
+	workspaceIds := make(map[int]bool)
+	channelIds := make(map[int]bool)
+	messageIds := make(map[int]bool)
+	dlIds := make(map[uint]bool)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u := NewUser(0, randomstring.EnglishFrequencyString(30), "pass")
+			w := NewWorkspace(0, randomstring.EnglishFrequencyString(30), rand.Uint32())
+			c := NewChannel(0, randomstring.EnglishFrequencyString(30), "", false, false, rand.Int())
+			m := NewMessage(randomstring.EnglishFrequencyString(30), rand.Int(), rand.Uint32())
+			dl := NewDMLine(rand.Int(), rand.Uint32(), rand.Uint32())
+			assert.Empty(t, u.Create())
+			assert.Empty(t, w.Create())
+			assert.Empty(t, c.Create())
+			assert.Empty(t, m.Create())
+			assert.Empty(t, dl.Create().Error)
+
+			mu.Lock()
+			defer mu.Unlock()
+			userIds[u.ID] = true
+			workspaceIds[w.ID] = true
+			channelIds[c.ID] = true
+			messageIds[m.ID] = true
+			dlIds[dl.ID] = true
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, n, len(userIds))
+	assert.Equal(t, n, len(workspaceIds))
+	assert.Equal(t, n, len(channelIds))
+	assert.Equal(t, n, len(messageIds))
+	assert.Equal(t, n, len(dlIds))
+	assert.False(t, userIds[0])
+	assert.False(t, workspaceIds[0])
+}
+
+func TestMigrateAutoIncrementIds(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 移行済みのtableに対しては何もしない
+	u := NewUser(0, randomstring.EnglishFrequencyString(30), "pass")
+	assert.Empty(t, u.Create())
+	assert.Empty(t, migrateAutoIncrementIds())
+	res, err := GetUserById(u.ID)
+	assert.Empty(t, err)
+	assert.Equal(t, u.Name, res.Name)
+}
+
+func TestUserIdColumns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// user idを保存しているcolumnはすべてidの振り直しの対象になっている
+	targets := make(map[string]bool)
+	for _, c := range userIdColumns() {
+		targets[c.table+"."+c.column] = true
+	}
+
+	rows, err := DbConnection.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	assert.Empty(t, err)
+	tables := make([]string, 0)
+	for rows.Next() {
+		var name string
+		assert.Empty(t, rows.Scan(&name))
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	for _, table := range tables {
+		rows, err := DbConnection.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+		assert.Empty(t, err)
+		for rows.Next() {
+			var column string
+			assert.Empty(t, rows.Scan(&column))
+			if strings.Contains(column, "user_id") || column == "created_by" || column == "workspace_primary_owner_id" {
+				assert.True(t, targets[table+"."+column], table+"."+column)
+			}
+		}
+		rows.Close()
+	}
+}
+
+func TestDedupeUsers(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 大文字小文字を区別せず重複したusernameは最初のuser以外の名前にidが付く
+	// 2 重複したemailは確認済みのもの(同じ場合は最初のもの)のみ残る
+
+	tableName := fmt.Sprintf("users_dedupe_test_%d", rand.Uint32())
+	_, err := DbConnection.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", tableName, usersTableColumns))
+	assert.Empty(t, err)
+	defer DbConnection.Exec(fmt.Sprintf("DROP TABLE %s", tableName))
+
+	users := []struct {
+		name     string
+		email    interface{}
+		verified bool
+	}{
+		{"alice", "alice@example.com", false},
+		{"Alice", "ALICE@example.com", true},
+		{"alice-2", nil, false},
+		{"bob", "bob@example.com", false},
+		{"carol", "Bob@example.com", false},
+	}
+	for _, u := range users {
+		var verifiedAt interface{}
+		if u.verified {
+			verifiedAt = time.Now()
+		}
+		_, err := DbConnection.Exec(fmt.Sprintf("INSERT INTO %s (name, password, email, email_verified_at) VALUES ($1, 'pass', $2, $3)", tableName), u.name, u.email, verifiedAt)
+		assert.Empty(t, err)
+	}
+	assert.Empty(t, dedupeUsers(tableName))
+
+	get := func(id int) (string, sql.NullString) {
+		var name string
+		var email sql.NullString
+		assert.Empty(t, DbConnection.QueryRow(fmt.Sprintf("SELECT name, email FROM %s WHERE id = $1", tableName), id).Scan(&name, &email))
+		return name, email
+	}
+
+	t.Run("1", func(t *testing.T) {
+		name, _ := get(1)
+		assert.Equal(t, "alice", name)
+		// alice-2は既に存在するのでさらにidを付ける
+		name, _ = get(2)
+		assert.Equal(t, "Alice-2-2", name)
+		name, _ = get(3)
+		assert.Equal(t, "alice-2", name)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		_, email := get(1)
+		assert.False(t, email.Valid)
+		_, email = get(2)
+		assert.Equal(t, "ALICE@example.com", email.String)
+		_, email = get(4)
+		assert.Equal(t, "bob@example.com", email.String)
+		_, email = get(5)
+		assert.False(t, email.Valid)
+	})
+}
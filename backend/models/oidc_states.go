@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SSOのloginを開始してからcallbackされるまでの情報
+type OidcState struct {
+	StateHash    string     `json:"-" gorm:"primaryKey"`
+	Provider     string     `json:"provider" gorm:"not null"`
+	CodeVerifier string     `json:"-" gorm:"not null"`
+	Nonce        string     `json:"-" gorm:"not null"`
+	DeviceName   string     `json:"device_name"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt       *time.Time `json:"used_at"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"not null"`
+}
+
+func NewOidcState(stateHash, provider, codeVerifier, nonce string, expiresAt time.Time) *OidcState {
+	return &OidcState{
+		StateHash:    stateHash,
+		Provider:     provider,
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		ExpiresAt:    expiresAt,
+	}
+}
+
+func (s *OidcState) Create() *gorm.DB {
+	return db.Create(s)
+}
+
+func GetOidcStateByStateHash(stateHash string) (OidcState, error) {
+	var s OidcState
+	err := db.First(&s, "state_hash = ?", stateHash).Error
+	return s, err
+}
+
+func (s *OidcState) IsValid() bool {
+	return s.UsedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+func (s *OidcState) Use() (bool, error) {
+	// stateは1回しか使えないようにする
+	result := db.Model(&OidcState{}).Where("state_hash = ? AND used_at IS NULL", s.StateHash).Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+)
+
+func TestOidcState(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 作成して1回だけ使える
+	// 2 期限切れのものは無効
+
+	t.Run("1", func(t *testing.T) {
+		s := NewOidcState(randomstring.EnglishFrequencyString(30), "mock", "verifier", "nonce", time.Now().Add(time.Minute))
+		assert.Empty(t, s.Create().Error)
+		res, err := GetOidcStateByStateHash(s.StateHash)
+		assert.Empty(t, err)
+		assert.True(t, res.IsValid())
+		assert.Equal(t, "verifier", res.CodeVerifier)
+		assert.Equal(t, "nonce", res.Nonce)
+
+		b, err := res.Use()
+		assert.Empty(t, err)
+		assert.True(t, b)
+		b, err = res.Use()
+		assert.Empty(t, err)
+		assert.False(t, b)
+
+		res, err = GetOidcStateByStateHash(s.StateHash)
+		assert.Empty(t, err)
+		assert.False(t, res.IsValid())
+	})
+
+	t.Run("2", func(t *testing.T) {
+		s := NewOidcState(randomstring.EnglishFrequencyString(30), "mock", "verifier", "nonce", time.Now().Add(-time.Minute))
+		assert.Empty(t, s.Create().Error)
+		res, err := GetOidcStateByStateHash(s.StateHash)
+		assert.Empty(t, err)
+		assert.False(t, res.IsValid())
+	})
+}
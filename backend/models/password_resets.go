@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type PasswordReset struct {
+	TokenHash string     `json:"-" gorm:"primaryKey"`
+	UserId    uint32     `json:"user_id" gorm:"not null; index"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"not null"`
+}
+
+func NewPasswordReset(tokenHash string, userId uint32, expiresAt time.Time) *PasswordReset {
+	return &PasswordReset{
+		TokenHash: tokenHash,
+		UserId:    userId,
+		ExpiresAt: expiresAt,
+	}
+}
+
+func (pr *PasswordReset) Create() *gorm.DB {
+	return db.Create(pr)
+}
+
+func GetPasswordResetByTokenHash(tokenHash string) (PasswordReset, error) {
+	var pr PasswordReset
+	err := db.First(&pr, "token_hash = ?", tokenHash).Error
+	return pr, err
+}
+
+func (pr *PasswordReset) IsValid() bool {
+	return pr.UsedAt == nil && time.Now().Before(pr.ExpiresAt)
+}
+
+func (pr *PasswordReset) Use() (bool, error) {
+	// 同時にrequestされても1回しか使えないようにする
+	result := db.Model(&PasswordReset{}).Where("token_hash = ? AND used_at IS NULL", pr.TokenHash).Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func InvalidatePasswordResetsByUserId(userId uint32) error {
+	return db.Model(&PasswordReset{}).Where("user_id = ? AND used_at IS NULL", userId).Update("used_at", time.Now()).Error
+}
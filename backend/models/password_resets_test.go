@@ -0,0 +1,66 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+)
+
+func TestPasswordReset(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 作成して1回だけ使える
+	// 2 期限切れのものは無効
+	// 3 userのものをまとめて無効にできる
+
+	t.Run("1", func(t *testing.T) {
+		pr := NewPasswordReset(randomstring.EnglishFrequencyString(30), rand.Uint32(), time.Now().Add(time.Hour))
+		assert.Empty(t, pr.Create().Error)
+		res, err := GetPasswordResetByTokenHash(pr.TokenHash)
+		assert.Empty(t, err)
+		assert.True(t, res.IsValid())
+
+		b, err := res.Use()
+		assert.Empty(t, err)
+		assert.True(t, b)
+		b, err = res.Use()
+		assert.Empty(t, err)
+		assert.False(t, b)
+
+		res, err = GetPasswordResetByTokenHash(pr.TokenHash)
+		assert.Empty(t, err)
+		assert.False(t, res.IsValid())
+
+		_, err = GetPasswordResetByTokenHash(randomstring.EnglishFrequencyString(30))
+		assert.NotEmpty(t, err)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		pr := NewPasswordReset(randomstring.EnglishFrequencyString(30), rand.Uint32(), time.Now().Add(-time.Hour))
+		assert.Empty(t, pr.Create().Error)
+		res, err := GetPasswordResetByTokenHash(pr.TokenHash)
+		assert.Empty(t, err)
+		assert.False(t, res.IsValid())
+	})
+
+	t.Run("3", func(t *testing.T) {
+		userId := rand.Uint32()
+		hashes := make([]string, 3)
+		for i := range hashes {
+			pr := NewPasswordReset(randomstring.EnglishFrequencyString(30), userId, time.Now().Add(time.Hour))
+			assert.Empty(t, pr.Create().Error)
+			hashes[i] = pr.TokenHash
+		}
+		assert.Empty(t, InvalidatePasswordResetsByUserId(userId))
+		for _, h := range hashes {
+			res, err := GetPasswordResetByTokenHash(h)
+			assert.Empty(t, err)
+			assert.False(t, res.IsValid())
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PresenceActive = "active"
+	PresenceAway   = "away"
+)
+
+// 最後の操作からこの時間が経つとawayになる
+const presenceAwayAfter = 10 * time.Minute
+
+// last_active_atを更新する間隔
+const presenceTouchInterval = time.Minute
+
+type Presence struct {
+	UserId          uint32     `json:"user_id" gorm:"primaryKey"`
+	LastActiveAt    time.Time  `json:"last_active_at"`
+	ManualAway      bool       `json:"manual_away" gorm:"not null; default:false"`
+	StatusEmoji     string     `json:"status_emoji"`
+	StatusText      string     `json:"status_text"`
+	StatusExpiresAt *time.Time `json:"status_expires_at" gorm:"index"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (p *Presence) State() string {
+	if p.ManualAway || time.Since(p.LastActiveAt) >= presenceAwayAfter {
+		return PresenceAway
+	}
+	return PresenceActive
+}
+
+func upsertPresence(p *Presence, columns ...string) *gorm.DB {
+	// presenceが存在しない場合は作成し、存在する場合は指定したcolumnのみ更新する
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns(append(columns, "updated_at")),
+	}).Create(p)
+}
+
+func TouchPresence(userId uint32) error {
+	// 毎回のrequestで書き込まないように一定時間経過した場合のみ更新する
+	now := time.Now()
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_active_at", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Lt{Column: clause.Column{Table: "presences", Name: "last_active_at"}, Value: now.Add(-presenceTouchInterval)},
+		}},
+	}).Create(&Presence{UserId: userId, LastActiveAt: now}).Error
+}
+
+func SetManualAway(userId uint32, away bool) error {
+	return upsertPresence(&Presence{UserId: userId, ManualAway: away}, "manual_away").Error
+}
+
+func SetCustomStatus(userId uint32, emoji, text string, expiresAt *time.Time) error {
+	p := &Presence{
+		UserId:          userId,
+		StatusEmoji:     emoji,
+		StatusText:      text,
+		StatusExpiresAt: expiresAt,
+	}
+	return upsertPresence(p, "status_emoji", "status_text", "status_expires_at").Error
+}
+
+func ClearCustomStatus(userId uint32) error {
+	return db.Model(&Presence{}).Where("user_id = ?", userId).Updates(map[string]interface{}{
+		"status_emoji":      "",
+		"status_text":       "",
+		"status_expires_at": nil,
+	}).Error
+}
+
+func ClearExpiredStatuses() error {
+	return db.Model(&Presence{}).Where("status_expires_at IS NOT NULL AND status_expires_at <= ?", time.Now()).Updates(map[string]interface{}{
+		"status_emoji":      "",
+		"status_text":       "",
+		"status_expires_at": nil,
+	}).Error
+}
+
+func GetPresenceByUserId(userId uint32) (Presence, error) {
+	res, err := GetPresencesByUserIds([]uint32{userId})
+	if err != nil {
+		return Presence{}, err
+	}
+	return res[userId], nil
+}
+
+func GetPresencesByUserIds(userIds []uint32) (map[uint32]Presence, error) {
+	// 一度もアクセスしていないuserは空のpresenceを返す
+	res := make(map[uint32]Presence)
+	for _, id := range userIds {
+		res[id] = Presence{UserId: id}
+	}
+	if len(userIds) == 0 {
+		return res, nil
+	}
+
+	// 期限切れのstatusは取得する前に削除する
+	if err := ClearExpiredStatuses(); err != nil {
+		return res, err
+	}
+
+	var presences []Presence
+	if err := db.Where("user_id IN ?", userIds).Find(&presences).Error; err != nil {
+		return res, err
+	}
+	for _, p := range presences {
+		res[p.UserId] = p
+	}
+	return res, nil
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresence(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 一度もアクセスしていないuserはaway
+	// 2 アクセスするとactive, 手動でawayにできる
+	// 3 期限切れのstatusは削除される
+	// 4 一定時間操作がないとaway
+
+	t.Run("1", func(t *testing.T) {
+		p, err := GetPresenceByUserId(rand.Uint32())
+		assert.Empty(t, err)
+		assert.Equal(t, PresenceAway, p.State())
+	})
+
+	t.Run("2", func(t *testing.T) {
+		userId := rand.Uint32()
+		assert.Empty(t, TouchPresence(userId))
+		assert.Empty(t, TouchPresence(userId))
+		p, err := GetPresenceByUserId(userId)
+		assert.Empty(t, err)
+		assert.Equal(t, PresenceActive, p.State())
+
+		assert.Empty(t, SetManualAway(userId, true))
+		p, _ = GetPresenceByUserId(userId)
+		assert.Equal(t, PresenceAway, p.State())
+
+		assert.Empty(t, SetManualAway(userId, false))
+		p, _ = GetPresenceByUserId(userId)
+		assert.Equal(t, PresenceActive, p.State())
+	})
+
+	t.Run("3", func(t *testing.T) {
+		userId1 := rand.Uint32()
+		userId2 := rand.Uint32()
+		past := time.Now().Add(-time.Minute)
+		future := time.Now().Add(time.Hour)
+		assert.Empty(t, SetCustomStatus(userId1, ":palm_tree:", "vacation", &past))
+		assert.Empty(t, SetCustomStatus(userId2, ":coffee:", "break", &future))
+
+		res, err := GetPresencesByUserIds([]uint32{userId1, userId2})
+		assert.Empty(t, err)
+		assert.Equal(t, "", res[userId1].StatusText)
+		assert.Nil(t, res[userId1].StatusExpiresAt)
+		assert.Equal(t, "break", res[userId2].StatusText)
+		assert.Equal(t, ":coffee:", res[userId2].StatusEmoji)
+
+		assert.Empty(t, ClearCustomStatus(userId2))
+		p, _ := GetPresenceByUserId(userId2)
+		assert.Equal(t, "", p.StatusText)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		p := Presence{LastActiveAt: time.Now().Add(-presenceAwayAfter)}
+		assert.Equal(t, PresenceAway, p.State())
+	})
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Profile struct {
+	UserId      uint32    `json:"user_id" gorm:"primaryKey"`
+	DisplayName string    `json:"display_name"`
+	RealName    string    `json:"real_name"`
+	Title       string    `json:"title"`
+	Phone       string    `json:"phone"`
+	Timezone    string    `json:"timezone"`
+	AvatarUrl   string    `json:"avatar_url"`
+	Pronouns    string    `json:"pronouns"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// workspaceごとに上書きする表示名
+type WorkspaceProfile struct {
+	WorkspaceId int       `json:"workspace_id" gorm:"primaryKey"`
+	UserId      uint32    `json:"user_id" gorm:"primaryKey"`
+	DisplayName string    `json:"display_name" gorm:"not null"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func NewProfile(userId uint32) *Profile {
+	return &Profile{UserId: userId}
+}
+
+func (p *Profile) Save() *gorm.DB {
+	return db.Save(p)
+}
+
+func GetProfileByUserId(userId uint32) (Profile, error) {
+	// profileを設定していないuserは空のprofileを返す
+	var p Profile
+	err := db.First(&p, "user_id = ?", userId).Error
+	if err == gorm.ErrRecordNotFound {
+		return Profile{UserId: userId}, nil
+	}
+	return p, err
+}
+
+func GetProfilesByUserIds(userIds []uint32) (map[uint32]Profile, error) {
+	res := make(map[uint32]Profile)
+	if len(userIds) == 0 {
+		return res, nil
+	}
+	var profiles []Profile
+	if err := db.Where("user_id IN ?", userIds).Find(&profiles).Error; err != nil {
+		return res, err
+	}
+	for _, p := range profiles {
+		res[p.UserId] = p
+	}
+	return res, nil
+}
+
+func NewWorkspaceProfile(workspaceId int, userId uint32, displayName string) *WorkspaceProfile {
+	return &WorkspaceProfile{
+		WorkspaceId: workspaceId,
+		UserId:      userId,
+		DisplayName: displayName,
+	}
+}
+
+func (wp *WorkspaceProfile) Save() *gorm.DB {
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(wp)
+}
+
+func DeleteWorkspaceProfile(workspaceId int, userId uint32) error {
+	return db.Where("workspace_id = ? AND user_id = ?", workspaceId, userId).Delete(&WorkspaceProfile{}).Error
+}
+
+func GetWorkspaceProfile(workspaceId int, userId uint32) (WorkspaceProfile, error) {
+	var wp WorkspaceProfile
+	err := db.First(&wp, "workspace_id = ? AND user_id = ?", workspaceId, userId).Error
+	return wp, err
+}
+
+func GetWorkspaceProfilesByWorkspaceId(workspaceId int) (map[uint32]WorkspaceProfile, error) {
+	res := make(map[uint32]WorkspaceProfile)
+	var wps []WorkspaceProfile
+	if err := db.Where("workspace_id = ?", workspaceId).Find(&wps).Error; err != nil {
+		return res, err
+	}
+	for _, wp := range wps {
+		res[wp.UserId] = wp
+	}
+	return res, nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+)
+
+func TestProfile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 設定していないuserは空のprofile
+	// 2 保存して取得できる
+	// 3 まとめて取得できる
+
+	t.Run("1", func(t *testing.T) {
+		userId := rand.Uint32()
+		p, err := GetProfileByUserId(userId)
+		assert.Empty(t, err)
+		assert.Equal(t, userId, p.UserId)
+		assert.Equal(t, "", p.DisplayName)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		p := NewProfile(rand.Uint32())
+		p.DisplayName = randomstring.EnglishFrequencyString(20)
+		p.Timezone = "Asia/Tokyo"
+		assert.Empty(t, p.Save().Error)
+
+		p.Title = "engineer"
+		assert.Empty(t, p.Save().Error)
+
+		res, err := GetProfileByUserId(p.UserId)
+		assert.Empty(t, err)
+		assert.Equal(t, p.DisplayName, res.DisplayName)
+		assert.Equal(t, "Asia/Tokyo", res.Timezone)
+		assert.Equal(t, "engineer", res.Title)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		ids := []uint32{rand.Uint32(), rand.Uint32()}
+		p := NewProfile(ids[0])
+		p.DisplayName = "a"
+		assert.Empty(t, p.Save().Error)
+
+		res, err := GetProfilesByUserIds(ids)
+		assert.Empty(t, err)
+		assert.Equal(t, 1, len(res))
+		assert.Equal(t, "a", res[ids[0]].DisplayName)
+	})
+}
+
+func TestWorkspaceProfile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 保存して上書きできる
+	// 2 削除できる
+
+	workspaceId := rand.Int()
+	userId := rand.Uint32()
+
+	t.Run("1", func(t *testing.T) {
+		assert.Empty(t, NewWorkspaceProfile(workspaceId, userId, "first").Save().Error)
+		assert.Empty(t, NewWorkspaceProfile(workspaceId, userId, "second").Save().Error)
+
+		res, err := GetWorkspaceProfilesByWorkspaceId(workspaceId)
+		assert.Empty(t, err)
+		assert.Equal(t, 1, len(res))
+		assert.Equal(t, "second", res[userId].DisplayName)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Empty(t, DeleteWorkspaceProfile(workspaceId, userId))
+		_, err := GetWorkspaceProfile(workspaceId, userId)
+		assert.NotEmpty(t, err)
+	})
+}
@@ -6,28 +6,144 @@ import (
 	"backend/config"
 )
 
+// roles tableに登録されているrole
+// idが小さいほど強い権限を持つ
+const (
+	RolePrimaryOwner       = 1
+	RoleOwner              = 2
+	RoleAdmin              = 3
+	RoleFullMember         = 4
+	RoleMultiChannelGuest  = 5
+	RoleSingleChannelGuest = 6
+)
+
+// built-in roleのために予約するidの最大値
+// custom roleのidはこれより大きい値からdatabaseが採番する
+const maxBuiltInRoleId = 100
+
+// workspace_idが0のroleはすべてのworkspaceで使うbuilt-in role
+// それ以外はworkspaceごとに作成されたcustom role
 type Role struct {
-	ID   int    `json:"id"`
-	Name string `json:"name"`
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	WorkspaceId int    `json:"workspace_id"`
 }
 
 func NewRole(id int, name string) *Role {
 	return &Role{ID: id, Name: name}
 }
 
+func NewCustomRole(workspaceId int, name string) *Role {
+	return &Role{Name: name, WorkspaceId: workspaceId}
+}
+
 func (r *Role) Create() error {
-	cmd := fmt.Sprintf("INSERT INTO %s (id, name) VALUES ($1, $2)", config.Config.RoleTableName)
-	_, err := DbConnection.Exec(cmd, r.ID, r.Name)
+	// built-in roleはidを指定して作成する
+	if r.ID != 0 {
+		cmd := fmt.Sprintf("INSERT INTO %s (id, name, workspace_id) VALUES ($1, $2, $3)", config.Config.RoleTableName)
+		_, err := DbConnection.Exec(cmd, r.ID, r.Name, r.WorkspaceId)
+		if err != nil {
+			fmt.Println(err.Error())
+		}
+		return err
+	}
+
+	// custom roleのidはdatabaseが採番する
+	// 削除されたroleのidは再利用されず, built-in role用に予約したidより大きい値になる
+	cmd := fmt.Sprintf("INSERT INTO %s (name, workspace_id) VALUES ($1, $2)", config.Config.RoleTableName)
+	res, err := DbConnection.Exec(cmd, r.Name, r.WorkspaceId)
 	if err != nil {
-		fmt.Println(err.Error())
+		return err
 	}
-	return err
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	r.ID = int(id)
+	return nil
 }
 
 func GetRoleById(id int) (Role, error) {
-	cmd := fmt.Sprintf("SELECT id, name FROM %s WHERE id = $1", config.Config.RoleTableName)
+	cmd := fmt.Sprintf("SELECT id, name, workspace_id FROM %s WHERE id = $1", config.Config.RoleTableName)
 	row := DbConnection.QueryRow(cmd, id)
 	var r Role
-	err := row.Scan(&r.ID, &r.Name)
+	err := row.Scan(&r.ID, &r.Name, &r.WorkspaceId)
 	return r, err
 }
+
+func GetRolesByWorkspaceId(workspaceId int) ([]Role, error) {
+	// built-in roleとworkspaceのcustom roleを返す
+	roles := make([]Role, 0)
+	cmd := fmt.Sprintf("SELECT id, name, workspace_id FROM %s WHERE workspace_id = 0 OR workspace_id = $1 ORDER BY id", config.Config.RoleTableName)
+	rows, err := DbConnection.Query(cmd, workspaceId)
+	if err != nil {
+		return roles, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r Role
+		if err := rows.Scan(&r.ID, &r.Name, &r.WorkspaceId); err != nil {
+			return roles, err
+		}
+		roles = append(roles, r)
+	}
+	return roles, nil
+}
+
+func (r *Role) IsBuiltIn() bool {
+	return r.WorkspaceId == 0
+}
+
+func (r *Role) IsAvailableInWorkspace(workspaceId int) bool {
+	return r.IsBuiltIn() || r.WorkspaceId == workspaceId
+}
+
+func IsGuestRole(roleId int) bool {
+	return roleId == RoleMultiChannelGuest || roleId == RoleSingleChannelGuest
+}
+
+func (r *Role) Rank() int {
+	// roleの強さ. 小さいほど強い
+	// custom roleは一般memberと同じ強さとして扱い、capabilityで個別に権限を与える
+	if r.IsBuiltIn() {
+		return r.ID
+	}
+	return RoleFullMember
+}
+
+func (r *Role) Rename(name string) error {
+	cmd := fmt.Sprintf("UPDATE %s SET name = $1 WHERE id = $2", config.Config.RoleTableName)
+	if _, err := DbConnection.Exec(cmd, name, r.ID); err != nil {
+		return err
+	}
+	r.Name = name
+	return nil
+}
+
+func (r *Role) Delete() error {
+	// built-in roleは削除できない
+	if r.IsBuiltIn() {
+		return fmt.Errorf("built-in role can't be deleted")
+	}
+	if err := DeleteRoleCapabilitiesByRoleId(r.ID); err != nil {
+		return err
+	}
+	cmd := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND workspace_id = $2", config.Config.RoleTableName)
+	_, err := DbConnection.Exec(cmd, r.ID, r.WorkspaceId)
+	return err
+}
+
+func IsRoleInUse(roleId int) (bool, error) {
+	cmd := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE role_id = $1", config.Config.WorkspaceAndUserTableName)
+	var cnt int
+	err := DbConnection.QueryRow(cmd, roleId).Scan(&cnt)
+	return cnt > 0, err
+}
+
+func IsExistRoleNameInWorkspace(workspaceId int, name string, excludeId int) (bool, error) {
+	// built-in roleとworkspaceのcustom roleで同じ名前は使えない
+	cmd := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE (workspace_id = 0 OR workspace_id = $1) AND name = $2 AND id != $3", config.Config.RoleTableName)
+	var cnt int
+	err := DbConnection.QueryRow(cmd, workspaceId, name, excludeId).Scan(&cnt)
+	return cnt > 0, err
+}
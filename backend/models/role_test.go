@@ -1,12 +1,14 @@
 package models
 
 import (
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
 )
 
-func TestIsExist4Roles(t *testing.T) {
+func TestIsExistBuiltInRoles(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping test in short mode.")
 	}
@@ -15,9 +17,87 @@ func TestIsExist4Roles(t *testing.T) {
 		"Workspace Owners",
 		"Workspace Admins",
 		"Full members",
+		"Multi-Channel Guests",
+		"Single-Channel Guests",
 	}
 	for i, n := range roleNames {
 		r, _ := GetRoleById(i + 1)
 		assert.Equal(t, n, r.Name)
 	}
 }
+
+func TestCustomRole(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	workspaceId := rand.Int()
+	r := NewCustomRole(workspaceId, randomstring.EnglishFrequencyString(30))
+	assert.Empty(t, r.Create())
+	assert.Greater(t, r.ID, maxBuiltInRoleId)
+	assert.Equal(t, false, r.IsBuiltIn())
+	assert.Equal(t, true, r.IsAvailableInWorkspace(workspaceId))
+	assert.Equal(t, false, r.IsAvailableInWorkspace(workspaceId+1))
+
+	// 同じworkspaceの名前は使えない
+	b, err := IsExistRoleNameInWorkspace(workspaceId, r.Name, 0)
+	assert.Empty(t, err)
+	assert.Equal(t, true, b)
+	b, err = IsExistRoleNameInWorkspace(workspaceId, r.Name, r.ID)
+	assert.Empty(t, err)
+	assert.Equal(t, false, b)
+	b, err = IsExistRoleNameInWorkspace(workspaceId, "Full members", 0)
+	assert.Empty(t, err)
+	assert.Equal(t, true, b)
+
+	// capabilityの設定
+	assert.Empty(t, SetRoleCapabilities(r.ID, []string{CapMembersInvite, CapChannelsDelete}))
+	capabilities, err := GetCapabilitiesByRole(*r)
+	assert.Empty(t, err)
+	assert.ElementsMatch(t, []string{CapMembersInvite, CapChannelsDelete}, capabilities)
+	assert.Empty(t, SetRoleCapabilities(r.ID, []string{CapWorkspaceRename}))
+	capabilities, err = GetCapabilitiesByRole(*r)
+	assert.Empty(t, err)
+	assert.Equal(t, []string{CapWorkspaceRename}, capabilities)
+
+	// workspaceのroleの一覧にはbuilt-in roleも含まれる
+	roles, err := GetRolesByWorkspaceId(workspaceId)
+	assert.Empty(t, err)
+	assert.Equal(t, 7, len(roles))
+	assert.Equal(t, r.ID, roles[6].ID)
+
+	// 使われているroleの確認
+	b, err = IsRoleInUse(r.ID)
+	assert.Empty(t, err)
+	assert.Equal(t, false, b)
+	wau := NewWorkspaceAndUsers(workspaceId, rand.Uint32(), r.ID)
+	assert.Empty(t, wau.Create())
+	b, err = IsRoleInUse(r.ID)
+	assert.Empty(t, err)
+	assert.Equal(t, true, b)
+
+	// rename
+	name := randomstring.EnglishFrequencyString(30)
+	assert.Empty(t, r.Rename(name))
+	res, err := GetRoleById(r.ID)
+	assert.Empty(t, err)
+	assert.Equal(t, name, res.Name)
+	assert.Equal(t, workspaceId, res.WorkspaceId)
+
+	// 削除するとcapabilityも削除される
+	assert.Empty(t, r.Delete())
+	_, err = GetRoleById(r.ID)
+	assert.NotEmpty(t, err)
+	capabilities, err = GetCapabilitiesByRole(*r)
+	assert.Empty(t, err)
+	assert.Equal(t, 0, len(capabilities))
+
+	// 削除されたroleのidは再利用されない
+	r2 := NewCustomRole(workspaceId, randomstring.EnglishFrequencyString(30))
+	assert.Empty(t, r2.Create())
+	assert.Greater(t, r2.ID, r.ID)
+
+	// built-in roleは削除できない
+	fm, err := GetRoleById(RoleFullMember)
+	assert.Empty(t, err)
+	assert.NotEmpty(t, fm.Delete())
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// last_seen_atを更新する間隔
+const sessionTouchInterval = time.Minute
+
+type Session struct {
+	ID               string     `json:"id" gorm:"primaryKey"`
+	UserId           uint32     `json:"user_id" gorm:"not null; index"`
+	RefreshTokenHash string     `json:"-" gorm:"not null"`
+	DeviceName       string     `json:"device_name"`
+	UserAgent        string     `json:"user_agent"`
+	IpAddress        string     `json:"ip_address"`
+	LastSeenAt       time.Time  `json:"last_seen_at"`
+	ExpiresAt        time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	CreatedAt        time.Time  `json:"created_at" gorm:"not null"`
+	UpdatedAt        time.Time  `json:"updated_at" gorm:"not null"`
+}
+
+func NewSession(id string, userId uint32, refreshTokenHash string, expiresAt time.Time) *Session {
+	return &Session{
+		ID:               id,
+		UserId:           userId,
+		RefreshTokenHash: refreshTokenHash,
+		LastSeenAt:       time.Now(),
+		ExpiresAt:        expiresAt,
+	}
+}
+
+func (s *Session) Create() *gorm.DB {
+	return db.Create(s)
+}
+
+func GetSessionById(id string) (Session, error) {
+	var s Session
+	err := db.First(&s, "id = ?", id).Error
+	return s, err
+}
+
+func GetActiveSessionsByUserId(userId uint32) ([]Session, error) {
+	var result []Session
+	err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userId, time.Now()).Order("last_seen_at desc").Find(&result).Error
+	return result, err
+}
+
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+func (s *Session) RotateRefreshToken(refreshTokenHash string, expiresAt time.Time) error {
+	s.RefreshTokenHash = refreshTokenHash
+	s.ExpiresAt = expiresAt
+	return db.Model(s).Updates(map[string]interface{}{
+		"refresh_token_hash": refreshTokenHash,
+		"expires_at":         expiresAt,
+	}).Error
+}
+
+func (s *Session) Touch(ipAddress string) error {
+	// 毎回のrequestで書き込まないように一定時間経過した場合のみ更新する
+	if time.Since(s.LastSeenAt) < sessionTouchInterval && s.IpAddress == ipAddress {
+		return nil
+	}
+	s.LastSeenAt = time.Now()
+	s.IpAddress = ipAddress
+	return db.Model(s).Updates(map[string]interface{}{
+		"last_seen_at": s.LastSeenAt,
+		"ip_address":   ipAddress,
+	}).Error
+}
+
+func (s *Session) Revoke() error {
+	now := time.Now()
+	s.RevokedAt = &now
+	return db.Model(s).Update("revoked_at", now).Error
+}
+
+func RevokeSessionsByUserId(userId uint32) error {
+	return db.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", userId).Update("revoked_at", time.Now()).Error
+}
+
+func RevokeOtherSessionsByUserId(userId uint32, sessionId string) error {
+	return db.Model(&Session{}).Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userId, sessionId).Update("revoked_at", time.Now()).Error
+}
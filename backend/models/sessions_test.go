@@ -0,0 +1,145 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+)
+
+func TestCreateSession(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	s := NewSession(randomstring.EnglishFrequencyString(30), rand.Uint32(), "hash", time.Now().Add(time.Hour))
+	assert.Empty(t, s.Create().Error)
+
+	res, err := GetSessionById(s.ID)
+	assert.Empty(t, err)
+	assert.Equal(t, s.UserId, res.UserId)
+	assert.Equal(t, "hash", res.RefreshTokenHash)
+	assert.True(t, res.IsActive())
+
+	// 同じidのsessionは作成できない
+	assert.NotEmpty(t, NewSession(s.ID, rand.Uint32(), "hash", time.Now().Add(time.Hour)).Create().Error)
+
+	_, err = GetSessionById(randomstring.EnglishFrequencyString(30))
+	assert.NotEmpty(t, err)
+}
+
+func TestSessionIsActive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	// 1 期限切れのsession
+	// 2 revokeされたsession
+
+	t.Run("1", func(t *testing.T) {
+		s := NewSession(randomstring.EnglishFrequencyString(30), rand.Uint32(), "hash", time.Now().Add(-time.Hour))
+		assert.Empty(t, s.Create().Error)
+		res, err := GetSessionById(s.ID)
+		assert.Empty(t, err)
+		assert.False(t, res.IsActive())
+	})
+
+	t.Run("2", func(t *testing.T) {
+		s := NewSession(randomstring.EnglishFrequencyString(30), rand.Uint32(), "hash", time.Now().Add(time.Hour))
+		assert.Empty(t, s.Create().Error)
+		assert.Empty(t, s.Revoke())
+		res, err := GetSessionById(s.ID)
+		assert.Empty(t, err)
+		assert.False(t, res.IsActive())
+	})
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	s := NewSession(randomstring.EnglishFrequencyString(30), rand.Uint32(), "hash", time.Now().Add(time.Hour))
+	assert.Empty(t, s.Create().Error)
+	assert.Empty(t, s.RotateRefreshToken("new hash", time.Now().Add(2*time.Hour)))
+
+	res, err := GetSessionById(s.ID)
+	assert.Empty(t, err)
+	assert.Equal(t, "new hash", res.RefreshTokenHash)
+	assert.True(t, res.ExpiresAt.After(time.Now().Add(time.Hour)))
+}
+
+func TestRevokeSessionsByUserId(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	userId := rand.Uint32()
+	ids := make([]string, 5)
+	for i := 0; i < 5; i++ {
+		s := NewSession(randomstring.EnglishFrequencyString(30), userId, "hash", time.Now().Add(time.Hour))
+		assert.Empty(t, s.Create().Error)
+		ids[i] = s.ID
+	}
+	other := NewSession(randomstring.EnglishFrequencyString(30), rand.Uint32(), "hash", time.Now().Add(time.Hour))
+	assert.Empty(t, other.Create().Error)
+
+	assert.Empty(t, RevokeSessionsByUserId(userId))
+	for _, id := range ids {
+		res, err := GetSessionById(id)
+		assert.Empty(t, err)
+		assert.False(t, res.IsActive())
+	}
+	res, err := GetSessionById(other.ID)
+	assert.Empty(t, err)
+	assert.True(t, res.IsActive())
+}
+
+func TestGetActiveSessionsByUserId(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	userId := rand.Uint32()
+	active := NewSession(randomstring.EnglishFrequencyString(30), userId, "hash", time.Now().Add(time.Hour))
+	assert.Empty(t, active.Create().Error)
+	expired := NewSession(randomstring.EnglishFrequencyString(30), userId, "hash", time.Now().Add(-time.Hour))
+	assert.Empty(t, expired.Create().Error)
+	revoked := NewSession(randomstring.EnglishFrequencyString(30), userId, "hash", time.Now().Add(time.Hour))
+	assert.Empty(t, revoked.Create().Error)
+	assert.Empty(t, revoked.Revoke())
+
+	res, err := GetActiveSessionsByUserId(userId)
+	assert.Empty(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, active.ID, res[0].ID)
+}
+
+func TestRevokeOtherSessionsByUserId(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	userId := rand.Uint32()
+	current := NewSession(randomstring.EnglishFrequencyString(30), userId, "hash", time.Now().Add(time.Hour))
+	assert.Empty(t, current.Create().Error)
+	other := NewSession(randomstring.EnglishFrequencyString(30), userId, "hash", time.Now().Add(time.Hour))
+	assert.Empty(t, other.Create().Error)
+
+	assert.Empty(t, RevokeOtherSessionsByUserId(userId, current.ID))
+	res, err := GetActiveSessionsByUserId(userId)
+	assert.Empty(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, current.ID, res[0].ID)
+}
+
+func TestTouchSession(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	s := NewSession(randomstring.EnglishFrequencyString(30), rand.Uint32(), "hash", time.Now().Add(time.Hour))
+	s.LastSeenAt = time.Now().Add(-time.Hour)
+	assert.Empty(t, s.Create().Error)
+
+	assert.Empty(t, s.Touch("127.0.0.1"))
+	res, err := GetSessionById(s.ID)
+	assert.Empty(t, err)
+	assert.Equal(t, "127.0.0.1", res.IpAddress)
+	assert.True(t, time.Since(res.LastSeenAt) < time.Minute)
+}
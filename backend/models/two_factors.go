@@ -0,0 +1,156 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// codeの総当たりを防ぐため, 続けて失敗した場合は一定時間loginできなくする
+const (
+	MaxTwoFactorFailures  = 5
+	TwoFactorLockDuration = 15 * time.Minute
+)
+
+type TwoFactor struct {
+	UserId         uint32     `json:"user_id" gorm:"primaryKey"`
+	Secret         string     `json:"-" gorm:"not null"`
+	IsEnabled      bool       `json:"is_enabled" gorm:"not null"`
+	LastUsedStep   int64      `json:"-" gorm:"not null"`
+	FailedAttempts int        `json:"-" gorm:"not null; default:0"`
+	LockedUntil    *time.Time `json:"-"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"not null"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"not null"`
+}
+
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserId    uint32     `json:"user_id" gorm:"not null; index"`
+	CodeHash  string     `json:"-" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"not null"`
+}
+
+func NewTwoFactor(userId uint32, secret string) *TwoFactor {
+	return &TwoFactor{
+		UserId:    userId,
+		Secret:    secret,
+		IsEnabled: false,
+	}
+}
+
+func (tf *TwoFactor) Save() error {
+	// まだ有効になっていない設定は新しいsecretで上書きする
+	return db.Save(tf).Error
+}
+
+func GetTwoFactorByUserId(userId uint32) (TwoFactor, error) {
+	var tf TwoFactor
+	err := db.First(&tf, "user_id = ?", userId).Error
+	return tf, err
+}
+
+func IsTwoFactorEnabled(userId uint32) (bool, error) {
+	tf, err := GetTwoFactorByUserId(userId)
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return tf.IsEnabled, nil
+}
+
+func (tf *TwoFactor) Enable(step int64) error {
+	tf.IsEnabled = true
+	tf.LastUsedStep = step
+	return db.Model(tf).Updates(map[string]interface{}{
+		"is_enabled":     true,
+		"last_used_step": step,
+	}).Error
+}
+
+func (tf *TwoFactor) UpdateLastUsedStep(step int64) error {
+	// 同じcodeを二度使えないように使用済みのstepを記録する
+	result := db.Model(&TwoFactor{}).Where("user_id = ? AND last_used_step < ?", tf.UserId, step).Update("last_used_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return gorm.ErrRecordNotFound
+	}
+	tf.LastUsedStep = step
+	return nil
+}
+
+func (tf *TwoFactor) LockedFor(now time.Time) time.Duration {
+	// ロックされていない場合は0を返す
+	if tf.LockedUntil == nil || !tf.LockedUntil.After(now) {
+		return 0
+	}
+	return tf.LockedUntil.Sub(now)
+}
+
+func (tf *TwoFactor) RecordFailure(now time.Time) error {
+	// 失敗回数が上限に達したらロックして回数を数え直す
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&TwoFactor{}).Where("user_id = ?", tf.UserId).Update("failed_attempts", gorm.Expr("failed_attempts + 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.First(tf, "user_id = ?", tf.UserId).Error; err != nil {
+			return err
+		}
+		if tf.FailedAttempts < MaxTwoFactorFailures {
+			return nil
+		}
+		lockedUntil := now.Add(TwoFactorLockDuration)
+		tf.FailedAttempts = 0
+		tf.LockedUntil = &lockedUntil
+		return tx.Model(&TwoFactor{}).Where("user_id = ?", tf.UserId).Updates(map[string]interface{}{
+			"failed_attempts": 0,
+			"locked_until":    lockedUntil,
+		}).Error
+	})
+}
+
+func (tf *TwoFactor) ResetFailures() error {
+	tf.FailedAttempts = 0
+	tf.LockedUntil = nil
+	return db.Model(&TwoFactor{}).Where("user_id = ?", tf.UserId).Updates(map[string]interface{}{
+		"failed_attempts": 0,
+		"locked_until":    nil,
+	}).Error
+}
+
+func DeleteTwoFactorByUserId(userId uint32) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&TwoFactor{}, "user_id = ?", userId).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&RecoveryCode{}, "user_id = ?", userId).Error
+	})
+}
+
+func ReplaceRecoveryCodes(userId uint32, codeHashes []string) error {
+	// 古いrecovery codeはすべて削除して新しいものに入れ替える
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&RecoveryCode{}, "user_id = ?", userId).Error; err != nil {
+			return err
+		}
+		for _, h := range codeHashes {
+			if err := tx.Create(&RecoveryCode{UserId: userId, CodeHash: h}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func UseRecoveryCode(userId uint32, codeHash string) (bool, error) {
+	// 未使用のcodeのみ使用済みにできる
+	result := db.Model(&RecoveryCode{}).Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, codeHash).Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
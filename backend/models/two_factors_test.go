@@ -0,0 +1,91 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwoFactor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	userId := rand.Uint32()
+
+	// setup直後は無効
+	tf := NewTwoFactor(userId, "SECRET")
+	assert.Empty(t, tf.Save())
+	b, err := IsTwoFactorEnabled(userId)
+	assert.Empty(t, err)
+	assert.False(t, b)
+
+	// secretを上書きできる
+	tf = NewTwoFactor(userId, "NEWSECRET")
+	assert.Empty(t, tf.Save())
+	res, err := GetTwoFactorByUserId(userId)
+	assert.Empty(t, err)
+	assert.Equal(t, "NEWSECRET", res.Secret)
+
+	assert.Empty(t, tf.Enable(10))
+	b, err = IsTwoFactorEnabled(userId)
+	assert.Empty(t, err)
+	assert.True(t, b)
+
+	// 使用済みのstep以前は記録できない
+	assert.NotEmpty(t, tf.UpdateLastUsedStep(10))
+	assert.Empty(t, tf.UpdateLastUsedStep(11))
+
+	// 続けて失敗するとロックされ, 成功すると解除される
+	now := time.Now()
+	for i := 0; i < MaxTwoFactorFailures-1; i++ {
+		assert.Empty(t, tf.RecordFailure(now))
+		assert.Equal(t, time.Duration(0), tf.LockedFor(now))
+	}
+	assert.Empty(t, tf.RecordFailure(now))
+	assert.Equal(t, TwoFactorLockDuration, tf.LockedFor(now))
+	res, err = GetTwoFactorByUserId(userId)
+	assert.Empty(t, err)
+	assert.Equal(t, time.Duration(0), res.LockedFor(now.Add(TwoFactorLockDuration)))
+	assert.True(t, res.LockedFor(now) > 0)
+	assert.Empty(t, tf.ResetFailures())
+	res, err = GetTwoFactorByUserId(userId)
+	assert.Empty(t, err)
+	assert.Equal(t, time.Duration(0), res.LockedFor(now))
+
+	assert.Empty(t, DeleteTwoFactorByUserId(userId))
+	b, err = IsTwoFactorEnabled(userId)
+	assert.Empty(t, err)
+	assert.False(t, b)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	userId := rand.Uint32()
+	assert.Empty(t, ReplaceRecoveryCodes(userId, []string{"a", "b", "c"}))
+
+	// 1回だけ使える
+	b, err := UseRecoveryCode(userId, "a")
+	assert.Empty(t, err)
+	assert.True(t, b)
+	b, err = UseRecoveryCode(userId, "a")
+	assert.Empty(t, err)
+	assert.False(t, b)
+
+	// 他のuserのcodeは使えない
+	b, err = UseRecoveryCode(rand.Uint32(), "b")
+	assert.Empty(t, err)
+	assert.False(t, b)
+
+	// 入れ替えると古いcodeは使えない
+	assert.Empty(t, ReplaceRecoveryCodes(userId, []string{"d"}))
+	b, err = UseRecoveryCode(userId, "b")
+	assert.Empty(t, err)
+	assert.False(t, b)
+	b, err = UseRecoveryCode(userId, "d")
+	assert.Empty(t, err)
+	assert.True(t, b)
+}
@@ -1,36 +1,89 @@
 package models
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
 
 	"backend/config"
+	"backend/utils"
 )
 
+// 削除されたuserのmessageを匿名化するときの送信者id
+const DeletedUserId uint32 = 0
+
 type User struct {
-	ID       uint32 `json:"id"`
-	Name     string `json:"name"`
-	PassWord string `json:"password"`
+	ID              uint32     `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PassWord        string     `json:"-"`
+	DeactivatedAt   *time.Time `json:"-"`
 }
 
+// 同時にsign upした場合などにunique indexで重複が検出されたときのerror
+var (
+	ErrDuplicateUserName  = errors.New("already exist same username")
+	ErrDuplicateUserEmail = errors.New("already exist same email")
+)
+
 func NewUser(id uint32, name, password string) *User {
 	return &User{ID: id, Name: name, PassWord: password}
 }
 
+func duplicateUserError(err error) error {
+	// unique indexの違反はどのcolumnが重複したかがわかるerrorに置き換える
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.ExtendedCode != sqlite3.ErrConstraintUnique {
+		return err
+	}
+	if strings.Contains(err.Error(), "email") {
+		return ErrDuplicateUserEmail
+	}
+	return ErrDuplicateUserName
+}
+
+func nullableEmail(email string) sql.NullString {
+	// emailは任意項目なので空文字の場合はNULLとして保存する
+	return sql.NullString{String: email, Valid: email != ""}
+}
+
 func (user *User) Create() error {
-	cmd := fmt.Sprintf(`INSERT INTO %s (id, name, password) VALUES ($1, $2, $3)`, config.Config.UserTableName)
-	_, err := DbConnection.Exec(cmd, user.ID, user.Name, user.PassWord)
+	// passwordはhash化してから保存する
+	hash, err := utils.HashPassword(user.PassWord)
+	if err != nil {
+		return err
+	}
+	user.PassWord = hash
+
+	// idはdatabaseが採番する
+	cmd := fmt.Sprintf(`INSERT INTO %s (name, password, email) VALUES ($1, $2, $3)`, config.Config.UserTableName)
+	res, err := DbConnection.Exec(cmd, user.Name, user.PassWord, nullableEmail(user.Email))
 	if err != nil {
 		fmt.Println(err)
+		return duplicateUserError(err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
 		return err
 	}
-	return err
+	if id > math.MaxUint32 {
+		return fmt.Errorf("user id is out of range")
+	}
+	user.ID = uint32(id)
+	return nil
 }
 
 func GetUserById(id uint32) (User, error) {
-	cmd := fmt.Sprintf(`SELECT id, name, password FROM %s WHERE id = $1`, config.Config.UserTableName)
+	cmd := fmt.Sprintf(`SELECT id, name, password, COALESCE(email, ''), email_verified_at, deactivated_at FROM %s WHERE id = $1`, config.Config.UserTableName)
 	row := DbConnection.QueryRow(cmd, id)
 	var user User
-	err := row.Scan(&user.ID, &user.Name, &user.PassWord)
+	err := row.Scan(&user.ID, &user.Name, &user.PassWord, &user.Email, &user.EmailVerifiedAt, &user.DeactivatedAt)
 	if err != nil {
 		return User{}, err
 	}
@@ -41,14 +94,230 @@ func GetUserById(id uint32) (User, error) {
 	return user, nil
 }
 
-func GetUserByNameAndPassword(username, password string) (User, error) {
-	cmd := fmt.Sprintf("SELECT id, name, password FROM %s WHERE name = $1 AND password = $2", config.Config.UserTableName)
-	row := DbConnection.QueryRow(cmd, username, password)
+func GetUsersByName(username string) ([]User, error) {
+	// usernameは大文字小文字を区別しない
+	users := make([]User, 0)
+	cmd := fmt.Sprintf("SELECT id, name, password, COALESCE(email, ''), email_verified_at, deactivated_at FROM %s WHERE name = $1 COLLATE NOCASE", config.Config.UserTableName)
+	rows, err := DbConnection.Query(cmd, username)
+	if err != nil {
+		return users, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.PassWord, &u.Email, &u.EmailVerifiedAt, &u.DeactivatedAt); err != nil {
+			return users, err
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func GetUserByName(username string) (User, error) {
+	// usernameは大文字小文字を区別しない
+	cmd := fmt.Sprintf("SELECT id, name, password, COALESCE(email, ''), email_verified_at, deactivated_at FROM %s WHERE name = $1 COLLATE NOCASE", config.Config.UserTableName)
+	row := DbConnection.QueryRow(cmd, username)
 	var u User
-	err := row.Scan(&u.ID, &u.Name, &u.PassWord)
+	err := row.Scan(&u.ID, &u.Name, &u.PassWord, &u.Email, &u.EmailVerifiedAt, &u.DeactivatedAt)
 	return u, err
 }
 
+func GetUserByEmail(email string) (User, error) {
+	cmd := fmt.Sprintf("SELECT id, name, password, COALESCE(email, ''), email_verified_at, deactivated_at FROM %s WHERE email = $1 COLLATE NOCASE", config.Config.UserTableName)
+	row := DbConnection.QueryRow(cmd, email)
+	var u User
+	err := row.Scan(&u.ID, &u.Name, &u.PassWord, &u.Email, &u.EmailVerifiedAt, &u.DeactivatedAt)
+	return u, err
+}
+
+func IsExistUserByName(username string) (bool, error) {
+	users, err := GetUsersByName(username)
+	if err != nil {
+		return false, err
+	}
+	return len(users) > 0, nil
+}
+
+func IsExistUserByEmail(email string) (bool, error) {
+	_, err := GetUserByEmail(email)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func GetUserByNameAndPassword(username, password string) (User, error) {
+	u, err := GetUserByName(username)
+	if err != nil {
+		return User{}, fmt.Errorf("wrong username or password")
+	}
+	if !utils.CheckPassword(u.PassWord, password) {
+		return User{}, fmt.Errorf("wrong username or password")
+	}
+	return u, nil
+}
+
+func GetUserByEmailAndPassword(email, password string) (User, error) {
+	u, err := GetUserByEmail(email)
+	if err != nil {
+		return User{}, fmt.Errorf("wrong email or password")
+	}
+	if !utils.CheckPassword(u.PassWord, password) {
+		return User{}, fmt.Errorf("wrong email or password")
+	}
+	return u, nil
+}
+
+func (user *User) HasLegacyPassword() bool {
+	return !utils.IsHashedPassword(user.PassWord)
+}
+
+func (user *User) UpdatePassword(password string) error {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	cmd := fmt.Sprintf("UPDATE %s SET password = $1, has_password = 1 WHERE id = $2", config.Config.UserTableName)
+	if _, err := DbConnection.Exec(cmd, hash, user.ID); err != nil {
+		return err
+	}
+	user.PassWord = hash
+	return nil
+}
+
+func (user *User) HasPassword() (bool, error) {
+	var b bool
+	cmd := fmt.Sprintf("SELECT has_password FROM %s WHERE id = $1", config.Config.UserTableName)
+	err := DbConnection.QueryRow(cmd, user.ID).Scan(&b)
+	return b, err
+}
+
+func (user *User) DisablePassword() error {
+	// SSOで作成したuserはpasswordを設定するまでpasswordで確認できないようにする
+	cmd := fmt.Sprintf("UPDATE %s SET has_password = 0 WHERE id = $1", config.Config.UserTableName)
+	_, err := DbConnection.Exec(cmd, user.ID)
+	return err
+}
+
+func (user *User) IsEmailVerified() bool {
+	return user.Email != "" && user.EmailVerifiedAt != nil
+}
+
+func (user *User) HasUnverifiedEmail() bool {
+	// emailを登録したが確認が済んでいない
+	return user.Email != "" && user.EmailVerifiedAt == nil
+}
+
+func (user *User) VerifyEmail() error {
+	now := time.Now()
+	cmd := fmt.Sprintf("UPDATE %s SET email_verified_at = $1 WHERE id = $2", config.Config.UserTableName)
+	if _, err := DbConnection.Exec(cmd, now, user.ID); err != nil {
+		return err
+	}
+	user.EmailVerifiedAt = &now
+	return nil
+}
+
+func (user *User) IsDeactivated() bool {
+	return user.DeactivatedAt != nil
+}
+
+func (user *User) Deactivate() error {
+	now := time.Now()
+	cmd := fmt.Sprintf("UPDATE %s SET deactivated_at = $1 WHERE id = $2", config.Config.UserTableName)
+	if _, err := DbConnection.Exec(cmd, now, user.ID); err != nil {
+		return err
+	}
+	user.DeactivatedAt = &now
+	return nil
+}
+
+func (user *User) Reactivate() error {
+	cmd := fmt.Sprintf("UPDATE %s SET deactivated_at = NULL WHERE id = $1", config.Config.UserTableName)
+	if _, err := DbConnection.Exec(cmd, user.ID); err != nil {
+		return err
+	}
+	user.DeactivatedAt = nil
+	return nil
+}
+
+func deletedUserName(userId uint32) string {
+	// sign upでは@を含むusernameを登録できないので, 削除済みuserの名前が実在のuserと重複することはない
+	return fmt.Sprintf("@deleted-user-%d", userId)
+}
+
+func (user *User) DeleteAccount(anonymizeWorkspaceIds []int) error {
+	// userに関するデータを削除し, 残したmessageから参照できるようにidは残して個人情報とpasswordを削除する
+	// 途中で失敗した場合に一部だけ削除された状態にならないように1つのtransactionで実行する
+	// passwordが空のuserはGetUserByIdなどで取得できなくなる
+	now := time.Now()
+	name := deletedUserName(user.ID)
+	dmLineIds := fmt.Sprintf("SELECT id FROM %s WHERE user_id_1 = $1 OR user_id_2 = $1", gormTableName(&DMLine{}))
+	type statement struct {
+		cmd  string
+		args []interface{}
+	}
+	cmds := []statement{
+		// loginできないようにする
+		{fmt.Sprintf("UPDATE %s SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL", gormTableName(&Session{})), []interface{}{now, user.ID}},
+		{fmt.Sprintf("UPDATE %s SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL", gormTableName(&PasswordReset{})), []interface{}{now, user.ID}},
+		{fmt.Sprintf("UPDATE %s SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL", gormTableName(&EmailVerification{})), []interface{}{now, user.ID}},
+		{fmt.Sprintf("UPDATE %s SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL", gormTableName(&ApiToken{})), []interface{}{now, user.ID}},
+	}
+	// 匿名化する設定のworkspaceのmessageの送信者を削除済みuserにする
+	for _, workspaceId := range anonymizeWorkspaceIds {
+		cmds = append(cmds, statement{
+			fmt.Sprintf("UPDATE %s SET user_id = $1 WHERE user_id = $2 AND channel_id IN (SELECT id FROM %s WHERE workspace_id = $3)", config.Config.MessagesTableName, config.Config.ChannelsTableName),
+			[]interface{}{DeletedUserId, user.ID, workspaceId},
+		})
+	}
+	cmds = append(cmds,
+		// channel, DM, workspace, user groupから削除する
+		statement{fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", config.Config.ChannelsAndUserTableName), []interface{}{user.ID}},
+		statement{fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", gormTableName(&MessageMention{})), []interface{}{user.ID}},
+		statement{fmt.Sprintf("DELETE FROM %s WHERE user_id = $1 OR direct_message_id IN (SELECT id FROM %s WHERE dm_line_id IN (%s))", gormTableName(&DMMention{}), gormTableName(&DirectMessage{}), dmLineIds), []interface{}{user.ID}},
+		statement{fmt.Sprintf("DELETE FROM %s WHERE dm_line_id IN (%s)", gormTableName(&DirectMessage{}), dmLineIds), []interface{}{user.ID}},
+		statement{fmt.Sprintf("DELETE FROM %s WHERE user_id_1 = $1 OR user_id_2 = $1", gormTableName(&DMLine{})), []interface{}{user.ID}},
+		statement{fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", config.Config.WorkspaceAndUserTableName), []interface{}{user.ID}},
+		statement{fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", gormTableName(&UserGroupMember{})), []interface{}{user.ID}},
+		// profileなどuserに紐づくデータを削除する
+		statement{fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", gormTableName(&Profile{})), []interface{}{user.ID}},
+		statement{fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", gormTableName(&WorkspaceProfile{})), []interface{}{user.ID}},
+		statement{fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", gormTableName(&Presence{})), []interface{}{user.ID}},
+		statement{fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", gormTableName(&TwoFactor{})), []interface{}{user.ID}},
+		statement{fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", gormTableName(&RecoveryCode{})), []interface{}{user.ID}},
+		statement{fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", gormTableName(&UserIdentity{})), []interface{}{user.ID}},
+		statement{
+			fmt.Sprintf("UPDATE %s SET name = $1, password = '', email = NULL, email_verified_at = NULL, deactivated_at = $2 WHERE id = $3", config.Config.UserTableName),
+			[]interface{}{name, now, user.ID},
+		},
+	)
+
+	tx, err := DbConnection.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, c := range cmds {
+		if _, err := tx.Exec(c.cmd, c.args...); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	user.Name = name
+	user.PassWord = ""
+	user.Email = ""
+	user.EmailVerifiedAt = nil
+	user.DeactivatedAt = &now
+	return nil
+}
+
 func GetUsers() ([]User, error) {
 	users := make([]User, 0)
 	cmd := fmt.Sprintf("SELECT id, name FROM %s", config.Config.UserTableName)
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// workspaceのuser group. @handleでmemberをまとめてmentionできる
+type UserGroup struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	WorkspaceId int       `json:"workspace_id" gorm:"not null; uniqueIndex:idx_user_groups_workspace_handle"`
+	Handle      string    `json:"handle" gorm:"not null; uniqueIndex:idx_user_groups_workspace_handle"`
+	Name        string    `json:"name" gorm:"not null"`
+	Description string    `json:"description" gorm:"not null; default:''"`
+	CreatedBy   uint32    `json:"created_by" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"not null"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"not null"`
+}
+
+type UserGroupMember struct {
+	UserGroupId uint   `json:"user_group_id" gorm:"primaryKey; autoIncrement:false"`
+	UserId      uint32 `json:"user_id" gorm:"primaryKey; autoIncrement:false; index"`
+}
+
+// groupのmemberが自動で参加するchannel
+type UserGroupChannel struct {
+	UserGroupId uint `json:"user_group_id" gorm:"primaryKey; autoIncrement:false"`
+	ChannelId   int  `json:"channel_id" gorm:"primaryKey; autoIncrement:false; index"`
+}
+
+func NewUserGroup(workspaceId int, handle, name, description string, createdBy uint32) *UserGroup {
+	return &UserGroup{
+		WorkspaceId: workspaceId,
+		Handle:      handle,
+		Name:        name,
+		Description: description,
+		CreatedBy:   createdBy,
+	}
+}
+
+func (ug *UserGroup) Create() *gorm.DB {
+	return db.Create(ug)
+}
+
+func (ug *UserGroup) Save() *gorm.DB {
+	return db.Save(ug)
+}
+
+func (ug *UserGroup) Delete() error {
+	// memberとchannelの設定も削除する
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_group_id = ?", ug.ID).Delete(&UserGroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_group_id = ?", ug.ID).Delete(&UserGroupChannel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(ug).Error
+	})
+}
+
+func GetUserGroupById(id uint) (UserGroup, error) {
+	var ug UserGroup
+	err := db.First(&ug, id).Error
+	return ug, err
+}
+
+func GetUserGroupsByWorkspaceId(workspaceId int) ([]UserGroup, error) {
+	result := make([]UserGroup, 0)
+	err := db.Where("workspace_id = ?", workspaceId).Order("handle").Find(&result).Error
+	return result, err
+}
+
+func GetUserGroupsByHandles(workspaceId int, handles []string) ([]UserGroup, error) {
+	result := make([]UserGroup, 0)
+	if len(handles) == 0 {
+		return result, nil
+	}
+	err := db.Where("workspace_id = ? AND handle IN ?", workspaceId, handles).Find(&result).Error
+	return result, err
+}
+
+func IsExistUserGroupHandle(workspaceId int, handle string, excludeId uint) (bool, error) {
+	var cnt int64
+	err := db.Model(&UserGroup{}).Where("workspace_id = ? AND handle = ? AND id != ?", workspaceId, handle, excludeId).Count(&cnt).Error
+	return cnt > 0, err
+}
+
+func GetUserGroupMemberIds(userGroupId uint) ([]uint32, error) {
+	userIds := make([]uint32, 0)
+	err := db.Model(&UserGroupMember{}).Where("user_group_id = ?", userGroupId).Order("user_id").Pluck("user_id", &userIds).Error
+	return userIds, err
+}
+
+func SetUserGroupMembers(userGroupId uint, userIds []uint32) error {
+	// 既存のmemberを置き換える
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_group_id = ?", userGroupId).Delete(&UserGroupMember{}).Error; err != nil {
+			return err
+		}
+		for _, userId := range userIds {
+			if err := tx.Create(&UserGroupMember{UserGroupId: userGroupId, UserId: userId}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func GetUserGroupChannelIds(userGroupId uint) ([]int, error) {
+	channelIds := make([]int, 0)
+	err := db.Model(&UserGroupChannel{}).Where("user_group_id = ?", userGroupId).Order("channel_id").Pluck("channel_id", &channelIds).Error
+	return channelIds, err
+}
+
+func SetUserGroupChannels(userGroupId uint, channelIds []int) error {
+	// 既存のchannelを置き換える
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_group_id = ?", userGroupId).Delete(&UserGroupChannel{}).Error; err != nil {
+			return err
+		}
+		for _, channelId := range channelIds {
+			if err := tx.Create(&UserGroupChannel{UserGroupId: userGroupId, ChannelId: channelId}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func DeleteUserGroupChannelsByChannelId(channelId int) error {
+	return db.Where("channel_id = ?", channelId).Delete(&UserGroupChannel{}).Error
+}
+
+func IsUserGroupMemberInWorkspace(workspaceId int, userId uint32) (bool, error) {
+	var count int64
+	groupIds := db.Model(&UserGroup{}).Select("id").Where("workspace_id = ?", workspaceId)
+	err := db.Model(&UserGroupMember{}).Where("user_id = ? AND user_group_id IN (?)", userId, groupIds).Count(&count).Error
+	return count > 0, err
+}
+
+func DeleteUserGroupMembersInWorkspace(workspaceId int, userId uint32) error {
+	// workspaceから抜けたuserをそのworkspaceのgroupから削除する
+	groupIds := db.Model(&UserGroup{}).Select("id").Where("workspace_id = ?", workspaceId)
+	return db.Where("user_id = ? AND user_group_id IN (?)", userId, groupIds).Delete(&UserGroupMember{}).Error
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+)
+
+func TestUserGroup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 作成したgroupを取得できる, 同じworkspaceで同じhandleは使えない
+	// 2 memberとchannelを設定すると置き換えられる
+	// 3 workspaceから抜けたuserと削除されたchannelはgroupから削除される
+	// 4 groupを削除するとmemberとchannelも削除される
+
+	workspaceId := int(rand.Int31())
+	handle := randomstring.HumanFriendlyEnglishString(15)
+	ug := NewUserGroup(workspaceId, handle, randomstring.EnglishFrequencyString(30), "", rand.Uint32())
+	assert.Empty(t, ug.Create().Error)
+
+	t.Run("1", func(t *testing.T) {
+		res, err := GetUserGroupById(ug.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, handle, res.Handle)
+		assert.Equal(t, workspaceId, res.WorkspaceId)
+
+		b, err := IsExistUserGroupHandle(workspaceId, handle, 0)
+		assert.Empty(t, err)
+		assert.True(t, b)
+		b, err = IsExistUserGroupHandle(workspaceId, handle, ug.ID)
+		assert.Empty(t, err)
+		assert.False(t, b)
+		assert.NotEmpty(t, NewUserGroup(workspaceId, handle, "name", "", rand.Uint32()).Create().Error)
+		assert.Empty(t, NewUserGroup(int(rand.Int31()), handle, "name", "", rand.Uint32()).Create().Error)
+
+		ugs, err := GetUserGroupsByHandles(workspaceId, []string{handle, "wrong"})
+		assert.Empty(t, err)
+		assert.Equal(t, 1, len(ugs))
+		ugs, err = GetUserGroupsByWorkspaceId(workspaceId)
+		assert.Empty(t, err)
+		assert.Equal(t, 1, len(ugs))
+	})
+
+	userIds := []uint32{1 + uint32(rand.Int31()), 1 + uint32(rand.Int31())}
+	channelIds := []int{int(rand.Int31()), int(rand.Int31())}
+
+	t.Run("2", func(t *testing.T) {
+		assert.Empty(t, SetUserGroupMembers(ug.ID, []uint32{rand.Uint32()}))
+		assert.Empty(t, SetUserGroupMembers(ug.ID, userIds))
+		res, err := GetUserGroupMemberIds(ug.ID)
+		assert.Empty(t, err)
+		assert.ElementsMatch(t, userIds, res)
+
+		assert.Empty(t, SetUserGroupChannels(ug.ID, []int{int(rand.Int31())}))
+		assert.Empty(t, SetUserGroupChannels(ug.ID, channelIds))
+		chs, err := GetUserGroupChannelIds(ug.ID)
+		assert.Empty(t, err)
+		assert.ElementsMatch(t, channelIds, chs)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.Empty(t, DeleteUserGroupMembersInWorkspace(int(rand.Int31()), userIds[0]))
+		assert.Empty(t, DeleteUserGroupMembersInWorkspace(workspaceId, userIds[0]))
+		res, err := GetUserGroupMemberIds(ug.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, userIds[1:], res)
+
+		assert.Empty(t, DeleteUserGroupChannelsByChannelId(channelIds[0]))
+		chs, err := GetUserGroupChannelIds(ug.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, channelIds[1:], chs)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Empty(t, ug.Delete())
+		_, err := GetUserGroupById(ug.ID)
+		assert.NotEmpty(t, err)
+		res, err := GetUserGroupMemberIds(ug.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, 0, len(res))
+		chs, err := GetUserGroupChannelIds(ug.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, 0, len(chs))
+	})
+}
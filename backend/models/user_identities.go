@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 外部のidentity providerのaccountとuserの紐付け
+type UserIdentity struct {
+	Provider  string    `json:"provider" gorm:"primaryKey"`
+	Subject   string    `json:"subject" gorm:"primaryKey"`
+	UserId    uint32    `json:"user_id" gorm:"not null; index"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+}
+
+func NewUserIdentity(provider, subject string, userId uint32, email string) *UserIdentity {
+	return &UserIdentity{
+		Provider: provider,
+		Subject:  subject,
+		UserId:   userId,
+		Email:    email,
+	}
+}
+
+func (ui *UserIdentity) Create() *gorm.DB {
+	return db.Create(ui)
+}
+
+func GetUserIdentity(provider, subject string) (UserIdentity, error) {
+	var ui UserIdentity
+	err := db.First(&ui, "provider = ? AND subject = ?", provider, subject).Error
+	return ui, err
+}
+
+func GetUserIdentitiesByUserId(userId uint32) ([]UserIdentity, error) {
+	var result []UserIdentity
+	err := db.Where("user_id = ?", userId).Find(&result).Error
+	return result, err
+}
+
+func DeleteUserIdentitiesByUserId(userId uint32) error {
+	return db.Where("user_id = ?", userId).Delete(&UserIdentity{}).Error
+}
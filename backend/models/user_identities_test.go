@@ -0,0 +1,51 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+)
+
+func TestUserIdentity(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 providerとsubjectからuserを特定できる
+	// 2 同じproviderとsubjectは登録できない
+	// 3 userのものをまとめて削除できる
+
+	userId := rand.Uint32()
+	subject := randomstring.EnglishFrequencyString(30)
+
+	t.Run("1", func(t *testing.T) {
+		ui := NewUserIdentity("mock", subject, userId, "test@example.com")
+		assert.Empty(t, ui.Create().Error)
+		res, err := GetUserIdentity("mock", subject)
+		assert.Empty(t, err)
+		assert.Equal(t, userId, res.UserId)
+
+		_, err = GetUserIdentity("other", subject)
+		assert.NotEmpty(t, err)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		ui := NewUserIdentity("mock", subject, rand.Uint32(), "")
+		assert.NotEmpty(t, ui.Create().Error)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		ui := NewUserIdentity("other", subject, userId, "")
+		assert.Empty(t, ui.Create().Error)
+		res, err := GetUserIdentitiesByUserId(userId)
+		assert.Empty(t, err)
+		assert.Equal(t, 2, len(res))
+
+		assert.Empty(t, DeleteUserIdentitiesByUserId(userId))
+		res, err = GetUserIdentitiesByUserId(userId)
+		assert.Empty(t, err)
+		assert.Equal(t, 0, len(res))
+	})
+}
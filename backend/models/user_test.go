@@ -1,11 +1,15 @@
 package models
 
 import (
-	"math/rand"
+	"fmt"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xyproto/randomstring"
+
+	"backend/config"
 )
 
 func CreateTest(t *testing.T) {
@@ -14,7 +18,7 @@ func CreateTest(t *testing.T) {
 	}
 	t.Run("1", func(t *testing.T) {
 		for i := 0; i < 10; i++ {
-			u := NewUser(rand.Uint32(), randomstring.EnglishFrequencyString(30), "pass")
+			u := NewUser(0, randomstring.EnglishFrequencyString(30), "pass")
 			assert.Empty(t, u.Create())
 		}
 	})
@@ -27,9 +31,8 @@ func GetUserByNameAndPasswordTest(t *testing.T) {
 	t.Run("1", func(t *testing.T) {
 		password := "pass"
 		for i := 0; i < 10; i++ {
-			id := rand.Uint32()
 			name := randomstring.EnglishFrequencyString(30)
-			u := NewUser(id, name, password)
+			u := NewUser(0, name, password)
 			assert.Empty(t, u.Create())
 			u1, err := GetUserByNameAndPassword(name, password)
 			assert.Empty(t, err)
@@ -49,3 +52,155 @@ func TestGetUsers(t *testing.T) {
 	_, err := GetUsers()
 	assert.Empty(t, err)
 }
+
+func TestCreateUserHashesPassword(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	u := NewUser(0, randomstring.EnglishFrequencyString(30), "pass")
+	assert.Empty(t, u.Create())
+	assert.NotEqual(t, "pass", u.PassWord)
+	assert.False(t, u.HasLegacyPassword())
+
+	u1, err := GetUserById(u.ID)
+	assert.Empty(t, err)
+	assert.Equal(t, u.PassWord, u1.PassWord)
+
+	_, err = GetUserByNameAndPassword(u.Name, "pass")
+	assert.Empty(t, err)
+	_, err = GetUserByNameAndPassword(u.Name, u.PassWord)
+	assert.NotEmpty(t, err)
+}
+
+func TestUpdateLegacyPassword(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	// 平文でpasswordが保存されているuserを作成
+	name := randomstring.EnglishFrequencyString(30)
+	cmd := fmt.Sprintf(`INSERT INTO %s (name, password) VALUES ($1, $2)`, config.Config.UserTableName)
+	res, err := DbConnection.Exec(cmd, name, "pass")
+	assert.Empty(t, err)
+	id, err := res.LastInsertId()
+	assert.Empty(t, err)
+
+	u, err := GetUserByNameAndPassword(name, "pass")
+	assert.Empty(t, err)
+	assert.True(t, u.HasLegacyPassword())
+
+	assert.Empty(t, u.UpdatePassword("pass"))
+	u1, err := GetUserById(uint32(id))
+	assert.Empty(t, err)
+	assert.False(t, u1.HasLegacyPassword())
+
+	_, err = GetUserByNameAndPassword(name, "pass")
+	assert.Empty(t, err)
+}
+
+func TestUniqueUsernameAndEmail(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	// 1 大文字小文字のみ異なるusernameは登録できない
+	// 2 同じemailは登録できない
+	// 3 emailが空のuserは複数登録できる
+
+	t.Run("1", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		assert.Empty(t, NewUser(0, name, "pass").Create())
+		assert.Equal(t, ErrDuplicateUserName, NewUser(0, strings.ToUpper(name), "pass").Create())
+		b, err := IsExistUserByName(strings.ToUpper(name))
+		assert.Empty(t, err)
+		assert.True(t, b)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		email := randomstring.EnglishFrequencyString(30) + "@example.com"
+		u := NewUser(0, randomstring.EnglishFrequencyString(30), "pass")
+		u.Email = email
+		assert.Empty(t, u.Create())
+		u2 := NewUser(0, randomstring.EnglishFrequencyString(30), "pass")
+		u2.Email = strings.ToUpper(email)
+		assert.Equal(t, ErrDuplicateUserEmail, u2.Create())
+
+		res, err := GetUserByEmailAndPassword(email, "pass")
+		assert.Empty(t, err)
+		assert.Equal(t, u.ID, res.ID)
+		_, err = GetUserByEmailAndPassword(email, "wrong pass")
+		assert.NotEmpty(t, err)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			assert.Empty(t, NewUser(0, randomstring.EnglishFrequencyString(30), "pass").Create())
+		}
+	})
+}
+
+func TestDeactivateAndAnonymizeUser(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	// 1 無効化と再有効化ができる
+	// 2 削除するとidで取得できず、同じusernameで登録できる
+
+	t.Run("1", func(t *testing.T) {
+		u := NewUser(0, randomstring.EnglishFrequencyString(30), "pass")
+		assert.Empty(t, u.Create())
+		assert.Empty(t, u.Deactivate())
+		res, err := GetUserById(u.ID)
+		assert.Empty(t, err)
+		assert.True(t, res.IsDeactivated())
+
+		assert.Empty(t, res.Reactivate())
+		res, err = GetUserById(u.ID)
+		assert.Empty(t, err)
+		assert.False(t, res.IsDeactivated())
+	})
+
+	t.Run("2", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		u := NewUser(0, name, "pass")
+		u.Email = name + "@example.com"
+		assert.Empty(t, u.Create())
+		assert.Empty(t, u.DeleteAccount(nil))
+		assert.Equal(t, "@deleted-user-"+strconv.FormatUint(uint64(u.ID), 10), u.Name)
+
+		_, err := GetUserById(u.ID)
+		assert.NotEmpty(t, err)
+		b, err := IsExistUserByEmail(name + "@example.com")
+		assert.Empty(t, err)
+		assert.False(t, b)
+		assert.Empty(t, NewUser(0, name, "pass").Create())
+	})
+}
+
+func TestVerifyEmail(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	// 1 確認するとemail_verified_atが保存される
+	// 2 emailがないuserは確認済みにならない
+
+	t.Run("1", func(t *testing.T) {
+		name := randomstring.EnglishFrequencyString(30)
+		u := NewUser(0, name, "pass")
+		u.Email = name + "@example.com"
+		assert.Empty(t, u.Create())
+		assert.True(t, u.HasUnverifiedEmail())
+		assert.False(t, u.IsEmailVerified())
+
+		assert.Empty(t, u.VerifyEmail())
+		res, err := GetUserById(u.ID)
+		assert.Empty(t, err)
+		assert.True(t, res.IsEmailVerified())
+		assert.False(t, res.HasUnverifiedEmail())
+	})
+
+	t.Run("2", func(t *testing.T) {
+		u := NewUser(0, randomstring.EnglishFrequencyString(30), "pass")
+		assert.Empty(t, u.Create())
+		assert.False(t, u.HasUnverifiedEmail())
+		assert.False(t, u.IsEmailVerified())
+	})
+}
@@ -2,14 +2,16 @@ package models
 
 import (
 	"fmt"
+	"time"
 
 	"backend/config"
 )
 
 type Workspace struct {
-	ID             int    `json:"id"`
-	Name           string `json:"name"`
-	PrimaryOwnerId uint32 `json:"primary_owner_id"`
+	ID             int        `json:"id"`
+	Name           string     `json:"name"`
+	PrimaryOwnerId uint32     `json:"primary_owner_id"`
+	DeletedAt      *time.Time `json:"deleted_at"`
 }
 
 func NewWorkspace(id int, name string, primaryOwnerId uint32) *Workspace {
@@ -20,44 +22,121 @@ func NewWorkspace(id int, name string, primaryOwnerId uint32) *Workspace {
 	}
 }
 
-func (w *Workspace) SetId() error {
-	cmd := fmt.Sprintf("SELECT id FROM %s", config.Config.WorkspaceTableName)
-	rows, err := DbConnection.Query(cmd)
+func (w *Workspace) Create() error {
+	// idはdatabaseが採番する
+	cmd := fmt.Sprintf("INSERT INTO %s (name, workspace_primary_owner_id) VALUES ($1, $2)", config.Config.WorkspaceTableName)
+	res, err := DbConnection.Exec(cmd, w.Name, w.PrimaryOwnerId)
 	if err != nil {
+		fmt.Println(err)
 		return err
 	}
-	defer rows.Close()
-	maxId := 0
-	for rows.Next() {
-		var id int
-		rows.Scan(&id)
-		if id > maxId {
-			maxId = id
-		}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
 	}
-	w.ID = maxId + 1
+	w.ID = int(id)
 	return nil
 }
 
-func (w *Workspace) Create() error {
-	if err := w.SetId(); err != nil {
+func GetWorkspaceById(id int) (Workspace, error) {
+	var w Workspace
+	cmd := fmt.Sprintf("SELECT id, name, workspace_primary_owner_id, deleted_at FROM %s WHERE id = $1", config.Config.WorkspaceTableName)
+	row := DbConnection.QueryRow(cmd, id)
+	err := row.Scan(&w.ID, &w.Name, &w.PrimaryOwnerId, &w.DeletedAt)
+	return w, err
+}
+
+func (w *Workspace) IsDeleted() bool {
+	return w.DeletedAt != nil
+}
+
+func (w *Workspace) SoftDelete() error {
+	// 猶予期間の間は復元できるように削除日時のみ記録する
+	now := time.Now()
+	cmd := fmt.Sprintf("UPDATE %s SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL", config.Config.WorkspaceTableName)
+	res, err := DbConnection.Exec(cmd, now, w.ID)
+	if err != nil {
 		return err
 	}
-	cmd := fmt.Sprintf("INSERT INTO %s (id, name, workspace_primary_owner_id) VALUES ($1, $2, $3)", config.Config.WorkspaceTableName)
-	_, err := DbConnection.Exec(cmd, w.ID, w.Name, w.PrimaryOwnerId)
+	n, err := res.RowsAffected()
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
-	return err
+	if n != 1 {
+		return fmt.Errorf("workspace is already deleted")
+	}
+	w.DeletedAt = &now
+	return nil
 }
 
-func GetWorkspaceById(id int) (Workspace, error) {
-	var w Workspace
-	cmd := fmt.Sprintf("SELECT id, name, workspace_primary_owner_id FROM %s WHERE id = $1", config.Config.WorkspaceTableName)
-	row := DbConnection.QueryRow(cmd, id)
-	err := row.Scan(&w.ID, &w.Name, &w.PrimaryOwnerId)
-	return w, err
+func (w *Workspace) Restore() error {
+	cmd := fmt.Sprintf("UPDATE %s SET deleted_at = NULL WHERE id = $1", config.Config.WorkspaceTableName)
+	if _, err := DbConnection.Exec(cmd, w.ID); err != nil {
+		return err
+	}
+	w.DeletedAt = nil
+	return nil
+}
+
+func GetWorkspaceIdsDeletedBefore(t time.Time) ([]int, error) {
+	ids := make([]int, 0)
+	cmd := fmt.Sprintf("SELECT id FROM %s WHERE deleted_at IS NOT NULL AND deleted_at < $1", config.Config.WorkspaceTableName)
+	rows, err := DbConnection.Query(cmd, t)
+	if err != nil {
+		return ids, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func PurgeWorkspace(workspaceId int) error {
+	// workspaceとchannel, message, DMなどworkspaceに紐づくデータをすべて削除する
+	// bot tokenは削除せずに無効にする
+	channelIds := fmt.Sprintf("SELECT id FROM %s WHERE workspace_id = $1", config.Config.ChannelsTableName)
+	dmLineIds := fmt.Sprintf("SELECT id FROM %s WHERE workspace_id = $1", gormTableName(&DMLine{}))
+	userGroupIds := fmt.Sprintf("SELECT id FROM %s WHERE workspace_id = $1", gormTableName(&UserGroup{}))
+	messageIds := fmt.Sprintf("SELECT id FROM %s WHERE channel_id IN (%s)", config.Config.MessagesTableName, channelIds)
+	dmIds := fmt.Sprintf("SELECT id FROM %s WHERE dm_line_id IN (%s)", gormTableName(&DirectMessage{}), dmLineIds)
+	cmds := []string{
+		fmt.Sprintf("DELETE FROM %s WHERE message_id IN (%s)", gormTableName(&MessageMention{}), messageIds),
+		fmt.Sprintf("DELETE FROM %s WHERE direct_message_id IN (%s)", gormTableName(&DMMention{}), dmIds),
+		fmt.Sprintf("DELETE FROM %s WHERE channel_id IN (%s)", config.Config.MessagesTableName, channelIds),
+		fmt.Sprintf("DELETE FROM %s WHERE channel_id IN (%s)", config.Config.ChannelsAndUserTableName, channelIds),
+		fmt.Sprintf("DELETE FROM %s WHERE workspace_id = $1", config.Config.ChannelsTableName),
+		fmt.Sprintf("DELETE FROM %s WHERE dm_line_id IN (%s)", gormTableName(&DirectMessage{}), dmLineIds),
+		fmt.Sprintf("DELETE FROM %s WHERE workspace_id = $1", gormTableName(&DMLine{})),
+		fmt.Sprintf("DELETE FROM %s WHERE workspace_id = $1", config.Config.WorkspaceAndUserTableName),
+		fmt.Sprintf("DELETE FROM %s WHERE workspace_id = $1", gormTableName(&WorkspaceProfile{})),
+		fmt.Sprintf("DELETE FROM %s WHERE workspace_id = $1", gormTableName(&WorkspaceSetting{})),
+		fmt.Sprintf("DELETE FROM %s WHERE workspace_id = $1", gormTableName(&WorkspaceInvite{})),
+		fmt.Sprintf("DELETE FROM %s WHERE workspace_id = $1", gormTableName(&DefaultChannel{})),
+		fmt.Sprintf("DELETE FROM %s WHERE user_group_id IN (%s)", gormTableName(&UserGroupMember{}), userGroupIds),
+		fmt.Sprintf("DELETE FROM %s WHERE user_group_id IN (%s)", gormTableName(&UserGroupChannel{}), userGroupIds),
+		fmt.Sprintf("DELETE FROM %s WHERE workspace_id = $1", gormTableName(&UserGroup{})),
+		fmt.Sprintf("DELETE FROM %s WHERE role_id IN (SELECT id FROM %s WHERE workspace_id = $1)", gormTableName(&RoleCapability{}), config.Config.RoleTableName),
+		fmt.Sprintf("DELETE FROM %s WHERE workspace_id = $1", config.Config.RoleTableName),
+		fmt.Sprintf("UPDATE %s SET revoked_at = CURRENT_TIMESTAMP WHERE workspace_id = $1 AND revoked_at IS NULL", gormTableName(&ApiToken{})),
+		fmt.Sprintf("DELETE FROM %s WHERE id = $1", config.Config.WorkspaceTableName),
+	}
+
+	tx, err := DbConnection.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, cmd := range cmds {
+		if _, err := tx.Exec(cmd, workspaceId); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 func (w *Workspace) RenameWorkspaceName() error {
@@ -67,4 +146,47 @@ func (w *Workspace) RenameWorkspaceName() error {
 	cmd := fmt.Sprintf("UPDATE %s SET name = $1 WHERE id = $2", config.Config.WorkspaceTableName)
 	_, err := DbConnection.Exec(cmd, w.Name, w.ID)
 	return err
-}
\ No newline at end of file
+}
+
+func TransferPrimaryOwnership(workspaceId int, fromUserId, toUserId uint32) error {
+	// workspaceのprimary ownerと両方のuserのroleを同時に変更する
+	// 以前のprimary ownerはownerになり, 新しいprimary ownerの有効期限はなくなる
+	tx, err := DbConnection.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cmds := []struct {
+		cmd  string
+		args []interface{}
+	}{
+		{
+			fmt.Sprintf("UPDATE %s SET workspace_primary_owner_id = $1 WHERE id = $2 AND workspace_primary_owner_id = $3", config.Config.WorkspaceTableName),
+			[]interface{}{toUserId, workspaceId, fromUserId},
+		},
+		{
+			fmt.Sprintf("UPDATE %s SET role_id = $1 WHERE workspace_id = $2 AND user_id = $3 AND role_id = $4", config.Config.WorkspaceAndUserTableName),
+			[]interface{}{RoleOwner, workspaceId, fromUserId, RolePrimaryOwner},
+		},
+		{
+			fmt.Sprintf("UPDATE %s SET role_id = $1, expires_at = NULL WHERE workspace_id = $2 AND user_id = $3 AND is_deactivated = 0", config.Config.WorkspaceAndUserTableName),
+			[]interface{}{RolePrimaryOwner, workspaceId, toUserId},
+		},
+	}
+	for _, c := range cmds {
+		res, err := tx.Exec(c.cmd, c.args...)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		// 同時に譲渡された場合などは途中までの変更を取り消す
+		if n != 1 {
+			return fmt.Errorf("failed to transfer primary ownership")
+		}
+	}
+	return tx.Commit()
+}
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"database/sql"
 	"fmt"
+	"time"
 
 	"backend/config"
 )
@@ -12,6 +14,11 @@ type WorkspaceAndUsers struct {
 	RoleId      int    `json:"role_id"`
 }
 
+func inActiveWorkspace() string {
+	// 削除されたworkspaceには誰も所属していないものとして扱う
+	return fmt.Sprintf("workspace_id NOT IN (SELECT id FROM %s WHERE deleted_at IS NOT NULL)", config.Config.WorkspaceTableName)
+}
+
 func NewWorkspaceAndUsers(workspaceId int, userId uint32, roleId int) *WorkspaceAndUsers {
 	return &WorkspaceAndUsers{
 		WorkspaceId: workspaceId,
@@ -27,7 +34,8 @@ func (wau *WorkspaceAndUsers) Create() error {
 }
 
 func GetWorkspaceAndUserByWorkspaceIdAndUserId(workspaceId int, userId uint32) (WorkspaceAndUsers, error) {
-	cmd := fmt.Sprintf("SELECT workspace_id, user_id, role_id FROM %s WHERE workspace_id = $1 AND user_id = $2", config.Config.WorkspaceAndUserTableName)
+	// workspaceで無効化されたuserは所属していないものとして扱う
+	cmd := fmt.Sprintf("SELECT workspace_id, user_id, role_id FROM %s WHERE workspace_id = $1 AND user_id = $2 AND is_deactivated = 0 AND %s", config.Config.WorkspaceAndUserTableName, inActiveWorkspace())
 	row := DbConnection.QueryRow(cmd, workspaceId, userId)
 	var wau WorkspaceAndUsers
 	err := row.Scan(&wau.WorkspaceId, &wau.UserId, &wau.RoleId)
@@ -43,8 +51,26 @@ func (wau *WorkspaceAndUsers) DeleteWorkspaceAndUser() error {
 	return err
 }
 
+func (wau *WorkspaceAndUsers) UpdateRoleId(roleId int) error {
+	// primary ownerのroleはTransferPrimaryOwnershipでのみ変更する
+	cmd := fmt.Sprintf("UPDATE %s SET role_id = $1 WHERE workspace_id = $2 AND user_id = $3 AND role_id <> $4", config.Config.WorkspaceAndUserTableName)
+	res, err := DbConnection.Exec(cmd, roleId, wau.WorkspaceId, wau.UserId, RolePrimaryOwner)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return fmt.Errorf("user not found in workspace")
+	}
+	wau.RoleId = roleId
+	return nil
+}
+
 func GetRoleIdByWorkspaceIdAndUserId(workspaceId int, userId uint32) (int, error) {
-	cmd := fmt.Sprintf("SELECT role_id FROM %s WHERE workspace_id = $1 AND user_id = $2", config.Config.WorkspaceAndUserTableName)
+	cmd := fmt.Sprintf("SELECT role_id FROM %s WHERE workspace_id = $1 AND user_id = $2 AND is_deactivated = 0 AND %s", config.Config.WorkspaceAndUserTableName, inActiveWorkspace())
 	row := DbConnection.QueryRow(cmd, workspaceId, userId)
 	var roleId int
 	err := row.Scan(&roleId)
@@ -53,7 +79,7 @@ func GetRoleIdByWorkspaceIdAndUserId(workspaceId int, userId uint32) (int, error
 
 func GetWAUsByUserId(userId uint32) ([]WorkspaceAndUsers, error) {
 	res := make([]WorkspaceAndUsers, 0)
-	cmd := fmt.Sprintf("SELECT workspace_id, user_id, role_id FROM %s WHERE user_id = $1", config.Config.WorkspaceAndUserTableName)
+	cmd := fmt.Sprintf("SELECT workspace_id, user_id, role_id FROM %s WHERE user_id = $1 AND is_deactivated = 0 AND %s", config.Config.WorkspaceAndUserTableName, inActiveWorkspace())
 	rows, err := DbConnection.Query(cmd, userId)
 	if err != nil {
 		return res, err
@@ -71,7 +97,7 @@ func GetWAUsByUserId(userId uint32) ([]WorkspaceAndUsers, error) {
 
 func GetWAUsByWorkspaceId(workspaceId int) ([]WorkspaceAndUsers, error) {
 	res := make([]WorkspaceAndUsers, 0)
-	cmd := fmt.Sprintf("SELECT workspace_id, user_id, role_id FROM %s WHERE workspace_id = $1", config.Config.WorkspaceAndUserTableName)
+	cmd := fmt.Sprintf("SELECT workspace_id, user_id, role_id FROM %s WHERE workspace_id = $1 AND is_deactivated = 0 AND %s", config.Config.WorkspaceAndUserTableName, inActiveWorkspace())
 	rows, err := DbConnection.Query(cmd, workspaceId)
 	if err != nil {
 		return res, err
@@ -86,3 +112,57 @@ func GetWAUsByWorkspaceId(workspaceId int) ([]WorkspaceAndUsers, error) {
 	}
 	return res, nil
 }
+
+func IsDeactivatedInWorkspace(workspaceId int, userId uint32) (bool, error) {
+	cmd := fmt.Sprintf("SELECT is_deactivated FROM %s WHERE workspace_id = $1 AND user_id = $2", config.Config.WorkspaceAndUserTableName)
+	row := DbConnection.QueryRow(cmd, workspaceId, userId)
+	var b bool
+	err := row.Scan(&b)
+	return b, err
+}
+
+func SetDeactivatedInWorkspace(workspaceId int, userId uint32, deactivated bool) error {
+	cmd := fmt.Sprintf("UPDATE %s SET is_deactivated = $1 WHERE workspace_id = $2 AND user_id = $3", config.Config.WorkspaceAndUserTableName)
+	_, err := DbConnection.Exec(cmd, deactivated, workspaceId, userId)
+	return err
+}
+
+func DeleteWAUsByUserId(userId uint32) error {
+	cmd := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", config.Config.WorkspaceAndUserTableName)
+	_, err := DbConnection.Exec(cmd, userId)
+	return err
+}
+
+func SetExpiresAtInWorkspace(workspaceId int, userId uint32, expiresAt *time.Time) error {
+	// nilの場合は有効期限をなくす
+	cmd := fmt.Sprintf("UPDATE %s SET expires_at = $1 WHERE workspace_id = $2 AND user_id = $3", config.Config.WorkspaceAndUserTableName)
+	var v interface{}
+	if expiresAt != nil {
+		v = expiresAt.UTC()
+	}
+	_, err := DbConnection.Exec(cmd, v, workspaceId, userId)
+	return err
+}
+
+func GetExpiresAtInWorkspace(workspaceId int, userId uint32) (*time.Time, error) {
+	cmd := fmt.Sprintf("SELECT expires_at FROM %s WHERE workspace_id = $1 AND user_id = $2", config.Config.WorkspaceAndUserTableName)
+	row := DbConnection.QueryRow(cmd, workspaceId, userId)
+	var t sql.NullTime
+	if err := row.Scan(&t); err != nil {
+		return nil, err
+	}
+	if !t.Valid {
+		return nil, nil
+	}
+	return &t.Time, nil
+}
+
+func DeactivateExpiredMembers(now time.Time) (int64, error) {
+	// 有効期限を過ぎたuserを無効化し、無効化した人数を返す
+	cmd := fmt.Sprintf("UPDATE %s SET is_deactivated = 1 WHERE is_deactivated = 0 AND expires_at IS NOT NULL AND expires_at <= $1", config.Config.WorkspaceAndUserTableName)
+	res, err := DbConnection.Exec(cmd, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
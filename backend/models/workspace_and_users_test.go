@@ -3,6 +3,7 @@ package models
 import (
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -160,3 +161,119 @@ func TestGetWAUsByWorkspaceId(t *testing.T) {
 		assert.Equal(t, 0, len(res))
 	})
 }
+
+func TestDeactivatedInWorkspace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	workspaceId := rand.Int()
+	userId := rand.Uint32()
+	assert.Empty(t, NewWorkspaceAndUsers(workspaceId, userId, 4).Create())
+
+	// 無効化されたuserは所属していないものとして扱われる
+	assert.Empty(t, SetDeactivatedInWorkspace(workspaceId, userId, true))
+	b, err := IsDeactivatedInWorkspace(workspaceId, userId)
+	assert.Empty(t, err)
+	assert.True(t, b)
+	_, err = GetWorkspaceAndUserByWorkspaceIdAndUserId(workspaceId, userId)
+	assert.NotEmpty(t, err)
+	waus, err := GetWAUsByWorkspaceId(workspaceId)
+	assert.Empty(t, err)
+	assert.Equal(t, 0, len(waus))
+
+	assert.Empty(t, SetDeactivatedInWorkspace(workspaceId, userId, false))
+	_, err = GetWorkspaceAndUserByWorkspaceIdAndUserId(workspaceId, userId)
+	assert.Empty(t, err)
+
+	assert.Empty(t, DeleteWAUsByUserId(userId))
+	_, err = IsDeactivatedInWorkspace(workspaceId, userId)
+	assert.NotEmpty(t, err)
+}
+
+func TestUpdateRoleId(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	// 1 roleを変更できる
+	// 2 primary ownerのroleは変更できない
+
+	workspaceId := rand.Int()
+
+	t.Run("1", func(t *testing.T) {
+		wau := NewWorkspaceAndUsers(workspaceId, rand.Uint32(), RoleFullMember)
+		assert.Empty(t, wau.Create())
+		assert.Empty(t, wau.UpdateRoleId(RoleAdmin))
+		roleId, err := GetRoleIdByWorkspaceIdAndUserId(workspaceId, wau.UserId)
+		assert.Empty(t, err)
+		assert.Equal(t, RoleAdmin, roleId)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		wau := NewWorkspaceAndUsers(workspaceId, rand.Uint32(), RolePrimaryOwner)
+		assert.Empty(t, wau.Create())
+		assert.NotEmpty(t, wau.UpdateRoleId(RoleOwner))
+		roleId, _ := GetRoleIdByWorkspaceIdAndUserId(workspaceId, wau.UserId)
+		assert.Equal(t, RolePrimaryOwner, roleId)
+	})
+}
+
+func TestExpiresAtInWorkspace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	// 1 有効期限を設定, 取得, 削除できる
+	// 2 有効期限を過ぎたuserのみ無効化される
+
+	workspaceId := rand.Int()
+
+	t.Run("1", func(t *testing.T) {
+		wau := NewWorkspaceAndUsers(workspaceId, rand.Uint32(), RoleMultiChannelGuest)
+		assert.Empty(t, wau.Create())
+		expiresAt, err := GetExpiresAtInWorkspace(workspaceId, wau.UserId)
+		assert.Empty(t, err)
+		assert.Nil(t, expiresAt)
+
+		want := time.Now().Add(time.Hour).Truncate(time.Second)
+		assert.Empty(t, SetExpiresAtInWorkspace(workspaceId, wau.UserId, &want))
+		expiresAt, err = GetExpiresAtInWorkspace(workspaceId, wau.UserId)
+		assert.Empty(t, err)
+		assert.True(t, want.Equal(*expiresAt))
+
+		assert.Empty(t, SetExpiresAtInWorkspace(workspaceId, wau.UserId, nil))
+		expiresAt, err = GetExpiresAtInWorkspace(workspaceId, wau.UserId)
+		assert.Empty(t, err)
+		assert.Nil(t, expiresAt)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		expired := NewWorkspaceAndUsers(workspaceId, rand.Uint32(), RoleSingleChannelGuest)
+		assert.Empty(t, expired.Create())
+		active := NewWorkspaceAndUsers(workspaceId, rand.Uint32(), RoleSingleChannelGuest)
+		assert.Empty(t, active.Create())
+		member := NewWorkspaceAndUsers(workspaceId, rand.Uint32(), RoleFullMember)
+		assert.Empty(t, member.Create())
+
+		now := time.Now()
+		past := now.Add(-time.Minute)
+		future := now.Add(time.Hour)
+		assert.Empty(t, SetExpiresAtInWorkspace(workspaceId, expired.UserId, &past))
+		assert.Empty(t, SetExpiresAtInWorkspace(workspaceId, active.UserId, &future))
+
+		n, err := DeactivateExpiredMembers(now)
+		assert.Empty(t, err)
+		assert.GreaterOrEqual(t, n, int64(1))
+
+		for _, tc := range []struct {
+			userId      uint32
+			deactivated bool
+		}{
+			{expired.UserId, true},
+			{active.UserId, false},
+			{member.UserId, false},
+		} {
+			b, err := IsDeactivatedInWorkspace(workspaceId, tc.userId)
+			assert.Empty(t, err)
+			assert.Equal(t, tc.deactivated, b)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"backend/config"
+)
+
+type WorkspaceInvite struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	WorkspaceId int        `json:"workspace_id" gorm:"not null; index"`
+	CodeHash    string     `json:"-" gorm:"not null; uniqueIndex"`
+	Email       string     `json:"email"`
+	RoleId      int        `json:"role_id" gorm:"not null"`
+	MaxUses     int        `json:"max_uses" gorm:"not null; default:0"`
+	UseCount    int        `json:"use_count" gorm:"not null; default:0"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+	CreatedBy   uint32     `json:"created_by" gorm:"not null"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"not null"`
+}
+
+func NewWorkspaceInvite(workspaceId int, codeHash string, roleId, maxUses int, expiresAt time.Time, createdBy uint32) *WorkspaceInvite {
+	// max_usesが0の場合は回数を制限しない
+	return &WorkspaceInvite{
+		WorkspaceId: workspaceId,
+		CodeHash:    codeHash,
+		RoleId:      roleId,
+		MaxUses:     maxUses,
+		ExpiresAt:   expiresAt,
+		CreatedBy:   createdBy,
+	}
+}
+
+func (wi *WorkspaceInvite) Create() *gorm.DB {
+	return db.Create(wi)
+}
+
+func GetWorkspaceInviteByCodeHash(codeHash string) (WorkspaceInvite, error) {
+	var wi WorkspaceInvite
+	err := db.First(&wi, "code_hash = ?", codeHash).Error
+	return wi, err
+}
+
+func GetWorkspaceInviteById(id uint) (WorkspaceInvite, error) {
+	var wi WorkspaceInvite
+	err := db.First(&wi, "id = ?", id).Error
+	return wi, err
+}
+
+func GetPendingWorkspaceInvitesByWorkspaceId(workspaceId int) ([]WorkspaceInvite, error) {
+	// 取り消されておらず、期限内で上限まで使われていない招待
+	var result []WorkspaceInvite
+	err := db.Where("workspace_id = ? AND revoked_at IS NULL AND expires_at > ? AND (max_uses = 0 OR use_count < max_uses)", workspaceId, time.Now()).
+		Order("created_at desc").Find(&result).Error
+	return result, err
+}
+
+func (wi *WorkspaceInvite) IsValid() bool {
+	return wi.RevokedAt == nil && time.Now().Before(wi.ExpiresAt) && (wi.MaxUses == 0 || wi.UseCount < wi.MaxUses)
+}
+
+func (wi *WorkspaceInvite) Use() (bool, error) {
+	ok, err := wi.use(db)
+	if ok {
+		wi.UseCount++
+	}
+	return ok, err
+}
+
+func (wi *WorkspaceInvite) Accept(userId uint32) (bool, error) {
+	// 招待の使用とworkspaceへの参加を同じtransactionで行う
+	// 参加できなかった場合は使用回数も元に戻る
+	ok := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		ok, err = wi.use(tx)
+		if err != nil || !ok {
+			return err
+		}
+		cmd := fmt.Sprintf("INSERT INTO %s (workspace_id, user_id, role_id) VALUES (?, ?, ?)", config.Config.WorkspaceAndUserTableName)
+		return tx.Exec(cmd, wi.WorkspaceId, userId, wi.RoleId).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		wi.UseCount++
+	}
+	return ok, nil
+}
+
+func (wi *WorkspaceInvite) use(tx *gorm.DB) (bool, error) {
+	// 同時にrequestされても上限を超えて使えないようにする
+	result := tx.Model(&WorkspaceInvite{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ? AND (max_uses = 0 OR use_count < max_uses)", wi.ID, time.Now()).
+		Update("use_count", gorm.Expr("use_count + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (wi *WorkspaceInvite) Revoke() error {
+	now := time.Now()
+	wi.RevokedAt = &now
+	return db.Model(wi).Update("revoked_at", now).Error
+}
+
+func IsRoleUsedByPendingInvite(roleId int) (bool, error) {
+	var cnt int64
+	err := db.Model(&WorkspaceInvite{}).
+		Where("role_id = ? AND revoked_at IS NULL AND expires_at > ? AND (max_uses = 0 OR use_count < max_uses)", roleId, time.Now()).
+		Count(&cnt).Error
+	return cnt > 0, err
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xyproto/randomstring"
+)
+
+func TestWorkspaceInvite(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 作成してcodeのhash値で取得できる
+	// 2 max_usesの回数まで使える
+	// 3 max_usesが0の場合は回数を制限しない
+	// 4 期限切れ, 取り消された招待は使えず一覧に含まれない
+	// 5 招待を使ってworkspaceに参加できる, 参加できなかった場合は使用回数が増えない
+
+	workspaceId := int(rand.Int31())
+	newInvite := func(maxUses int, expiresAt time.Time) *WorkspaceInvite {
+		wi := NewWorkspaceInvite(workspaceId, randomstring.EnglishFrequencyString(30), 4, maxUses, expiresAt, rand.Uint32())
+		assert.Empty(t, wi.Create().Error)
+		return wi
+	}
+
+	t.Run("1", func(t *testing.T) {
+		wi := newInvite(1, time.Now().Add(time.Hour))
+		res, err := GetWorkspaceInviteByCodeHash(wi.CodeHash)
+		assert.Empty(t, err)
+		assert.Equal(t, wi.ID, res.ID)
+		assert.True(t, res.IsValid())
+
+		res, err = GetWorkspaceInviteById(wi.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, wi.CodeHash, res.CodeHash)
+
+		_, err = GetWorkspaceInviteByCodeHash(randomstring.EnglishFrequencyString(30))
+		assert.NotEmpty(t, err)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		wi := newInvite(2, time.Now().Add(time.Hour))
+		for i := 0; i < 2; i++ {
+			b, err := wi.Use()
+			assert.Empty(t, err)
+			assert.True(t, b)
+		}
+		b, err := wi.Use()
+		assert.Empty(t, err)
+		assert.False(t, b)
+		res, _ := GetWorkspaceInviteById(wi.ID)
+		assert.Equal(t, 2, res.UseCount)
+		assert.False(t, res.IsValid())
+	})
+
+	t.Run("3", func(t *testing.T) {
+		wi := newInvite(0, time.Now().Add(time.Hour))
+		for i := 0; i < 5; i++ {
+			b, err := wi.Use()
+			assert.Empty(t, err)
+			assert.True(t, b)
+		}
+		res, _ := GetWorkspaceInviteById(wi.ID)
+		assert.True(t, res.IsValid())
+	})
+
+	t.Run("4", func(t *testing.T) {
+		pending := newInvite(0, time.Now().Add(time.Hour))
+		expired := newInvite(0, time.Now().Add(-time.Hour))
+		revoked := newInvite(0, time.Now().Add(time.Hour))
+		assert.Empty(t, revoked.Revoke())
+
+		for _, wi := range []*WorkspaceInvite{expired, revoked} {
+			b, err := wi.Use()
+			assert.Empty(t, err)
+			assert.False(t, b)
+		}
+
+		res, err := GetPendingWorkspaceInvitesByWorkspaceId(workspaceId)
+		assert.Empty(t, err)
+		ids := make([]uint, 0)
+		for _, wi := range res {
+			ids = append(ids, wi.ID)
+		}
+		assert.Contains(t, ids, pending.ID)
+		assert.NotContains(t, ids, expired.ID)
+		assert.NotContains(t, ids, revoked.ID)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		wi := newInvite(0, time.Now().Add(time.Hour))
+		userId := rand.Uint32()
+		b, err := wi.Accept(userId)
+		assert.Empty(t, err)
+		assert.True(t, b)
+		roleId, err := GetRoleIdByWorkspaceIdAndUserId(workspaceId, userId)
+		assert.Empty(t, err)
+		assert.Equal(t, wi.RoleId, roleId)
+
+		// 既に参加している場合
+		b, err = wi.Accept(userId)
+		assert.NotEmpty(t, err)
+		assert.False(t, b)
+		res, _ := GetWorkspaceInviteById(wi.ID)
+		assert.Equal(t, 1, res.UseCount)
+	})
+}
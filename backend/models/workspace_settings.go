@@ -0,0 +1,117 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 削除されたuserが送信したmessageの扱い
+const (
+	DeletedUserMessagesRetain    = "retain"
+	DeletedUserMessagesAnonymize = "anonymize"
+)
+
+// channelの作成や招待ができるuserの範囲
+const (
+	PolicyEveryone = "everyone"
+	PolicyAdmins   = "admins"
+	PolicyOwners   = "owners"
+)
+
+var Policies = []string{PolicyEveryone, PolicyAdmins, PolicyOwners}
+
+type WorkspaceSetting struct {
+	WorkspaceId                int    `json:"workspace_id" gorm:"primaryKey"`
+	DeletedUserMessagePolicy   string `json:"deleted_user_message_policy" gorm:"not null; default:retain"`
+	RequireVerifiedEmail       bool   `json:"require_verified_email" gorm:"not null; default:false"`
+	PublicChannelCreatePolicy  string `json:"public_channel_create_policy" gorm:"not null; default:everyone"`
+	PrivateChannelCreatePolicy string `json:"private_channel_create_policy" gorm:"not null; default:everyone"`
+	InvitePolicy               string `json:"invite_policy" gorm:"not null; default:admins"`
+	AllowChannelMention        bool   `json:"allow_channel_mention" gorm:"not null; default:true"`
+	// 0の場合はmessageを削除しない
+	MessageRetentionDays int `json:"message_retention_days" gorm:"not null; default:0"`
+	// 空白区切りで保存する. 空の場合はすべてのdomainのuserが参加できる
+	AllowedEmailDomains string    `json:"allowed_email_domains" gorm:"not null; default:''"`
+	Description         string    `json:"description" gorm:"not null; default:''"`
+	IconUrl             string    `json:"icon_url" gorm:"not null; default:''"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+func NewWorkspaceSetting(workspaceId int) *WorkspaceSetting {
+	return &WorkspaceSetting{
+		WorkspaceId:                workspaceId,
+		DeletedUserMessagePolicy:   DeletedUserMessagesRetain,
+		PublicChannelCreatePolicy:  PolicyEveryone,
+		PrivateChannelCreatePolicy: PolicyEveryone,
+		InvitePolicy:               PolicyAdmins,
+		AllowChannelMention:        true,
+	}
+}
+
+func (ws *WorkspaceSetting) Save() *gorm.DB {
+	// gormは作成時にfalseを初期値のtrueで置き換えるので、先に行を作成してからすべての項目を更新する
+	if res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(NewWorkspaceSetting(ws.WorkspaceId)); res.Error != nil {
+		return res
+	}
+	return db.Select("*").Updates(ws)
+}
+
+func GetWorkspaceSettingByWorkspaceId(workspaceId int) (WorkspaceSetting, error) {
+	// 設定されていないworkspaceは初期値を返す
+	var ws WorkspaceSetting
+	err := db.First(&ws, "workspace_id = ?", workspaceId).Error
+	if err == gorm.ErrRecordNotFound {
+		return *NewWorkspaceSetting(workspaceId), nil
+	}
+	return ws, err
+}
+
+func GetWorkspaceIdsByDeletedUserMessagePolicy(policy string) ([]int, error) {
+	var ids []int
+	err := db.Model(&WorkspaceSetting{}).Where("deleted_user_message_policy = ?", policy).Pluck("workspace_id", &ids).Error
+	return ids, err
+}
+
+func GetWorkspaceSettingsWithMessageRetention() ([]WorkspaceSetting, error) {
+	var result []WorkspaceSetting
+	err := db.Where("message_retention_days > 0").Find(&result).Error
+	return result, err
+}
+
+func IsRoleAllowedByPolicy(policy string, roleId int) bool {
+	switch policy {
+	case PolicyEveryone:
+		return roleId >= RolePrimaryOwner && roleId <= RoleFullMember
+	case PolicyAdmins:
+		return roleId >= RolePrimaryOwner && roleId <= RoleAdmin
+	case PolicyOwners:
+		return roleId >= RolePrimaryOwner && roleId <= RoleOwner
+	}
+	return false
+}
+
+func (ws *WorkspaceSetting) AllowedEmailDomainList() []string {
+	return strings.Fields(ws.AllowedEmailDomains)
+}
+
+func (ws *WorkspaceSetting) IsAllowedEmail(email string) bool {
+	// domainが設定されていない場合は制限しない
+	domains := ws.AllowedEmailDomainList()
+	if len(domains) == 0 {
+		return true
+	}
+	i := strings.LastIndex(email, "@")
+	if i < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[i+1:])
+	for _, d := range domains {
+		if d == domain {
+			return true
+		}
+	}
+	return false
+}
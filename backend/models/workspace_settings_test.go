@@ -0,0 +1,89 @@
+package models
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveWorkspaceSetting(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 設定されていないworkspaceは初期値になる
+	// 2 初期値と異なるfalseや0も保存できる
+
+	workspaceId := int(rand.Int31())
+
+	t.Run("1", func(t *testing.T) {
+		ws, err := GetWorkspaceSettingByWorkspaceId(workspaceId)
+		assert.Empty(t, err)
+		assert.Equal(t, *NewWorkspaceSetting(workspaceId), ws)
+		assert.True(t, ws.AllowChannelMention)
+		assert.Equal(t, PolicyAdmins, ws.InvitePolicy)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		ws := NewWorkspaceSetting(workspaceId)
+		ws.AllowChannelMention = false
+		ws.MessageRetentionDays = 30
+		ws.AllowedEmailDomains = "example.com example.org"
+		assert.Empty(t, ws.Save().Error)
+
+		res, err := GetWorkspaceSettingByWorkspaceId(workspaceId)
+		assert.Empty(t, err)
+		assert.False(t, res.AllowChannelMention)
+		assert.Equal(t, 30, res.MessageRetentionDays)
+		assert.Equal(t, []string{"example.com", "example.org"}, res.AllowedEmailDomainList())
+
+		settings, err := GetWorkspaceSettingsWithMessageRetention()
+		assert.Empty(t, err)
+		found := false
+		for _, s := range settings {
+			if s.WorkspaceId == workspaceId {
+				found = true
+			}
+		}
+		assert.True(t, found)
+
+		res.MessageRetentionDays = 0
+		assert.Empty(t, res.Save().Error)
+		res, err = GetWorkspaceSettingByWorkspaceId(workspaceId)
+		assert.Empty(t, err)
+		assert.Equal(t, 0, res.MessageRetentionDays)
+	})
+}
+
+func TestIsRoleAllowedByPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		roleId int
+		want   bool
+	}{
+		{PolicyEveryone, RoleFullMember, true},
+		{PolicyEveryone, RolePrimaryOwner, true},
+		{PolicyAdmins, RoleAdmin, true},
+		{PolicyAdmins, RoleFullMember, false},
+		{PolicyOwners, RoleOwner, true},
+		{PolicyOwners, RoleAdmin, false},
+		{"unknown", RolePrimaryOwner, false},
+		{PolicyEveryone, 0, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, IsRoleAllowedByPolicy(tt.policy, tt.roleId), tt)
+	}
+}
+
+func TestIsAllowedEmail(t *testing.T) {
+	ws := NewWorkspaceSetting(1)
+	assert.True(t, ws.IsAllowedEmail("user@example.com"))
+	assert.True(t, ws.IsAllowedEmail(""))
+
+	ws.AllowedEmailDomains = "example.com"
+	assert.True(t, ws.IsAllowedEmail("user@Example.COM"))
+	assert.False(t, ws.IsAllowedEmail("user@example.org"))
+	assert.False(t, ws.IsAllowedEmail("user@sub.example.com"))
+	assert.False(t, ws.IsAllowedEmail(""))
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xyproto/randomstring"
@@ -82,3 +83,118 @@ func TestRenameWorkspaceName(t *testing.T) {
 	assert.Equal(t, w2.PrimaryOwnerId, w.PrimaryOwnerId)
 }
 
+
+func TestTransferPrimaryOwnership(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	// 1 primary ownerとroleが変更され, 有効期限がなくなる
+	// 2 primary ownerでないuserからは譲渡できず何も変更されない
+	// 3 workspaceにいないuserには譲渡できず何も変更されない
+
+	ownerId := rand.Uint32()
+	memberId := rand.Uint32()
+	w := NewWorkspace(0, randomstring.EnglishFrequencyString(30), ownerId)
+	assert.Empty(t, w.Create())
+	assert.Empty(t, NewWorkspaceAndUsers(w.ID, ownerId, RolePrimaryOwner).Create())
+	assert.Empty(t, NewWorkspaceAndUsers(w.ID, memberId, RoleFullMember).Create())
+	expiresAt := time.Now().Add(time.Hour)
+	assert.Empty(t, SetExpiresAtInWorkspace(w.ID, memberId, &expiresAt))
+
+	t.Run("1", func(t *testing.T) {
+		assert.Empty(t, TransferPrimaryOwnership(w.ID, ownerId, memberId))
+		got, err := GetExpiresAtInWorkspace(w.ID, memberId)
+		assert.Empty(t, err)
+		assert.Nil(t, got)
+		res, err := GetWorkspaceById(w.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, memberId, res.PrimaryOwnerId)
+		roleId, _ := GetRoleIdByWorkspaceIdAndUserId(w.ID, ownerId)
+		assert.Equal(t, RoleOwner, roleId)
+		roleId, _ = GetRoleIdByWorkspaceIdAndUserId(w.ID, memberId)
+		assert.Equal(t, RolePrimaryOwner, roleId)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.NotEmpty(t, TransferPrimaryOwnership(w.ID, ownerId, ownerId))
+		res, _ := GetWorkspaceById(w.ID)
+		assert.Equal(t, memberId, res.PrimaryOwnerId)
+		roleId, _ := GetRoleIdByWorkspaceIdAndUserId(w.ID, ownerId)
+		assert.Equal(t, RoleOwner, roleId)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		assert.NotEmpty(t, TransferPrimaryOwnership(w.ID, memberId, rand.Uint32()))
+		res, _ := GetWorkspaceById(w.ID)
+		assert.Equal(t, memberId, res.PrimaryOwnerId)
+		roleId, _ := GetRoleIdByWorkspaceIdAndUserId(w.ID, memberId)
+		assert.Equal(t, RolePrimaryOwner, roleId)
+	})
+}
+
+func TestSoftDeleteAndPurgeWorkspace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	// 1 削除するとmemberが取得できなくなり、復元すると取得できる
+	// 2 完全に削除するとworkspaceに紐づくデータがすべて削除される
+
+	ownerId := rand.Uint32()
+	memberId := rand.Uint32()
+	w := NewWorkspace(0, randomstring.EnglishFrequencyString(30), ownerId)
+	assert.Empty(t, w.Create())
+	assert.Empty(t, NewWorkspaceAndUsers(w.ID, ownerId, RolePrimaryOwner).Create())
+	assert.Empty(t, NewWorkspaceAndUsers(w.ID, memberId, RoleFullMember).Create())
+	ch := NewChannel(0, "general", "", false, false, w.ID)
+	assert.Empty(t, ch.Create())
+	assert.Empty(t, NewChannelsAndUses(ch.ID, ownerId, true).Create())
+	m := NewMessage(randomstring.EnglishFrequencyString(30), ch.ID, ownerId)
+	assert.Empty(t, m.Create())
+	dl := NewDMLine(w.ID, ownerId, memberId)
+	assert.Empty(t, dl.Create().Error)
+	dm := NewDirectMessage(randomstring.EnglishFrequencyString(30), ownerId, dl.ID)
+	assert.Empty(t, dm.Create().Error)
+	ws := NewWorkspaceSetting(w.ID)
+	assert.Empty(t, ws.Save().Error)
+
+	t.Run("1", func(t *testing.T) {
+		assert.Empty(t, w.SoftDelete())
+		assert.NotEmpty(t, w.SoftDelete())
+		res, err := GetWorkspaceById(w.ID)
+		assert.Empty(t, err)
+		assert.True(t, res.IsDeleted())
+		_, err = GetWorkspaceAndUserByWorkspaceIdAndUserId(w.ID, ownerId)
+		assert.NotEmpty(t, err)
+		waus, err := GetWAUsByWorkspaceId(w.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, 0, len(waus))
+		ids, err := GetWorkspaceIdsDeletedBefore(time.Now().Add(time.Second))
+		assert.Empty(t, err)
+		assert.Contains(t, ids, w.ID)
+
+		assert.Empty(t, w.Restore())
+		_, err = GetWorkspaceAndUserByWorkspaceIdAndUserId(w.ID, ownerId)
+		assert.Empty(t, err)
+		ids, err = GetWorkspaceIdsDeletedBefore(time.Now().Add(time.Second))
+		assert.Empty(t, err)
+		assert.NotContains(t, ids, w.ID)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Empty(t, PurgeWorkspace(w.ID))
+		_, err := GetWorkspaceById(w.ID)
+		assert.NotEmpty(t, err)
+		_, err = GetChannelById(ch.ID)
+		assert.NotEmpty(t, err)
+		assert.False(t, IsExistCAUByChannelIdAndUserId(ch.ID, ownerId))
+		messages, err := GetMessagesByChannelId(ch.ID)
+		assert.Empty(t, err)
+		assert.Equal(t, 0, len(messages))
+		_, err = GetDLById(dl.ID)
+		assert.NotEmpty(t, err)
+		_, err = GetDMById(dm.ID)
+		assert.NotEmpty(t, err)
+		_, err = IsDeactivatedInWorkspace(w.ID, memberId)
+		assert.NotEmpty(t, err)
+	})
+}
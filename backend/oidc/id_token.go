@@ -0,0 +1,144 @@
+package oidc
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// id_tokenから取り出すuserの情報
+type IdTokenClaims struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+func parseRSAPublicKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
+
+func (p *Provider) fetchKeys() (map[string]interface{}, error) {
+	d, err := p.Discover()
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := p.getJSON(d.JwksUri, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		pub, err := parseRSAPublicKey(k)
+		if err != nil {
+			return nil, err
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+func (p *Provider) getKey(kid string) (interface{}, error) {
+	// 未知のkidの場合は鍵がrotateされた可能性があるので取得し直す
+	p.mu.Lock()
+	key, ok := p.keys[kid]
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	keys, err := p.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("signing key not found")
+}
+
+func hasAudience(aud interface{}, clientId string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientId
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == clientId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Provider) VerifyIdToken(rawIdToken, nonce string) (IdTokenClaims, error) {
+	t, err := jwt.Parse(rawIdToken, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		kid, _ := t.Header["kid"].(string)
+		return p.getKey(kid)
+	})
+	if err != nil {
+		return IdTokenClaims{}, err
+	}
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok || !t.Valid {
+		return IdTokenClaims{}, fmt.Errorf("invalid id_token")
+	}
+
+	// issuer, audience, nonceを確認する
+	if iss, _ := claims["iss"].(string); iss != p.Issuer {
+		return IdTokenClaims{}, fmt.Errorf("issuer mismatch in id_token")
+	}
+	if !hasAudience(claims["aud"], p.ClientId) {
+		return IdTokenClaims{}, fmt.Errorf("audience mismatch in id_token")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return IdTokenClaims{}, fmt.Errorf("exp not found in id_token")
+	}
+	if n, _ := claims["nonce"].(string); n == "" || n != nonce {
+		return IdTokenClaims{}, fmt.Errorf("nonce mismatch in id_token")
+	}
+
+	res := IdTokenClaims{}
+	res.Subject, _ = claims["sub"].(string)
+	if res.Subject == "" {
+		return IdTokenClaims{}, fmt.Errorf("sub not found in id_token")
+	}
+	res.Email, _ = claims["email"].(string)
+	res.EmailVerified, _ = claims["email_verified"].(bool)
+	res.Name, _ = claims["name"].(string)
+	res.PreferredUsername, _ = claims["preferred_username"].(string)
+	return res, nil
+}
@@ -0,0 +1,246 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"backend/config"
+	"backend/utils"
+)
+
+// 開発やtestで使うlocalのOpenID Connect provider
+// /authorizeは同意画面を出さずに次にloginするuserとしてすぐにredirectする
+type MockProvider struct {
+	Server   *httptest.Server
+	ClientId string
+	Kid      string
+
+	mu       sync.Mutex
+	key      *rsa.PrivateKey
+	nextUser MockUser
+	codes    map[string]mockAuthorization
+}
+
+type MockUser struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+type mockAuthorization struct {
+	user          MockUser
+	redirectUri   string
+	nonce         string
+	codeChallenge string
+}
+
+func NewMockProvider(clientId string) (*MockProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	m := &MockProvider{
+		ClientId: clientId,
+		Kid:      "mock-key-1",
+		key:      key,
+		codes:    make(map[string]mockAuthorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	mux.HandleFunc("/jwks", m.jwks)
+	m.Server = httptest.NewServer(mux)
+	return m, nil
+}
+
+func (m *MockProvider) Close() {
+	m.Server.Close()
+}
+
+func (m *MockProvider) Issuer() string {
+	return m.Server.URL
+}
+
+func (m *MockProvider) Config(name, redirectUrl string, workspaceId int) config.OidcProviderConfig {
+	// このproviderを使うための設定を返す
+	return config.OidcProviderConfig{
+		Name:        name,
+		Issuer:      m.Issuer(),
+		ClientId:    m.ClientId,
+		RedirectUrl: redirectUrl,
+		WorkspaceId: workspaceId,
+	}
+}
+
+func (m *MockProvider) SetNextUser(u MockUser) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextUser = u
+}
+
+func (m *MockProvider) RotateKey(kid string) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.key = key
+	m.Kid = kid
+	return nil
+}
+
+func (m *MockProvider) Authorize(authorizationUrl string) (code, state string, err error) {
+	// authorization_urlにアクセスしてredirect先のcodeとstateを返す
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authorizationUrl)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	return loc.Query().Get("code"), loc.Query().Get("state"), nil
+}
+
+func (m *MockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, Discovery{
+		Issuer:                m.Issuer(),
+		AuthorizationEndpoint: m.Issuer() + "/authorize",
+		TokenEndpoint:         m.Issuer() + "/token",
+		JwksUri:               m.Issuer() + "/jwks",
+	})
+}
+
+func (m *MockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != m.ClientId || q.Get("code_challenge_method") != "S256" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	code, err := utils.GenerateRandomString(16)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	m.mu.Lock()
+	m.codes[code] = mockAuthorization{
+		user:          m.nextUser,
+		redirectUri:   q.Get("redirect_uri"),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+	}
+	m.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *MockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	// codeは1回だけ使える
+	m.mu.Lock()
+	a, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	key, kid := m.key, m.Kid
+	m.mu.Unlock()
+
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("redirect_uri") != a.redirectUri {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	if r.PostForm.Get("client_id") != m.ClientId {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	// PKCEのcode_verifierを確認する
+	if CodeChallengeS256(r.PostForm.Get("code_verifier")) != a.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "code_verifier mismatch"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   m.Issuer(),
+		"sub":   a.user.Subject,
+		"aud":   m.ClientId,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": a.nonce,
+	}
+	if a.user.Email != "" {
+		claims["email"] = a.user.Email
+		claims["email_verified"] = a.user.EmailVerified
+	}
+	if a.user.Name != "" {
+		claims["name"] = a.user.Name
+	}
+	if a.user.PreferredUsername != "" {
+		claims["preferred_username"] = a.user.PreferredUsername
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	t.Header["kid"] = kid
+	idToken, err := t.SignedString(key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (m *MockProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	pub, kid := m.key.PublicKey, m.Kid
+	m.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []jwk{{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
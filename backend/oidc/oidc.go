@@ -0,0 +1,186 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/config"
+	"backend/utils"
+)
+
+type Provider struct {
+	Name         string
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+	WorkspaceId  int
+	Scopes       []string
+
+	httpClient *http.Client
+	mu         sync.Mutex
+	discovery  *Discovery
+	keys       map[string]interface{}
+}
+
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]*Provider)
+)
+
+func init() {
+	for _, pc := range config.Config.OidcProviders {
+		RegisterProvider(NewProvider(pc))
+	}
+}
+
+func NewProvider(pc config.OidcProviderConfig) *Provider {
+	scopes := pc.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		Name:         pc.Name,
+		Issuer:       strings.TrimSuffix(pc.Issuer, "/"),
+		ClientId:     pc.ClientId,
+		ClientSecret: pc.ClientSecret,
+		RedirectUrl:  pc.RedirectUrl,
+		WorkspaceId:  pc.WorkspaceId,
+		Scopes:       scopes,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func RegisterProvider(p *Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name] = p
+}
+
+func GetProvider(name string) (*Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+func (p *Provider) getJSON(u string, v interface{}) error {
+	resp, err := p.httpClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status from %s: %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (p *Provider) Discover() (Discovery, error) {
+	// discovery documentは一度取得したらcacheする
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return *p.discovery, nil
+	}
+	var d Discovery
+	if err := p.getJSON(p.Issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return Discovery{}, err
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != p.Issuer {
+		return Discovery{}, fmt.Errorf("issuer mismatch in discovery document")
+	}
+	p.discovery = &d
+	return d, nil
+}
+
+func GenerateCodeVerifier() (string, error) {
+	// RFC 7636: 43文字以上のランダムな文字列
+	return utils.GenerateRandomString(32)
+}
+
+func CodeChallengeS256(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *Provider) AuthCodeURL(state, nonce, codeVerifier string) (string, error) {
+	d, err := p.Discover()
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientId)
+	q.Set("redirect_uri", p.RedirectUrl)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallengeS256(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+func (p *Provider) Exchange(code, codeVerifier string) (string, error) {
+	// authorization codeをid_tokenと交換する
+	d, err := p.Discover()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectUrl)
+	form.Set("client_id", p.ClientId)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequest("POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientId), url.QueryEscape(p.ClientSecret))
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IdToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IdToken == "" {
+		return "", fmt.Errorf("id_token not found in token response")
+	}
+	return body.IdToken, nil
+}
@@ -0,0 +1,93 @@
+package oidc
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 PKCEでcodeを交換してid_tokenを検証できる
+	// 2 code_verifierが異なる場合は交換できない
+	// 3 nonceが異なるid_tokenは受け付けない
+	// 4 鍵がrotateされても新しい鍵で検証できる
+	// 5 別のclient向けのid_tokenは受け付けない
+
+	m, err := NewMockProvider("test-client")
+	assert.Empty(t, err)
+	defer m.Close()
+	p := NewProvider(m.Config("mock", "http://localhost/callback", 0))
+
+	login := func(t *testing.T, p *Provider, verifier, nonce string) (string, error) {
+		authUrl, err := p.AuthCodeURL("state-1", nonce, verifier)
+		assert.Empty(t, err)
+		u, _ := url.Parse(authUrl)
+		assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+		assert.Equal(t, CodeChallengeS256(verifier), u.Query().Get("code_challenge"))
+
+		code, state, err := m.Authorize(authUrl)
+		assert.Empty(t, err)
+		assert.Equal(t, "state-1", state)
+		return p.Exchange(code, verifier)
+	}
+
+	t.Run("1", func(t *testing.T) {
+		m.SetNextUser(MockUser{Subject: "sub-1", Email: "sub1@example.com", EmailVerified: true, PreferredUsername: "sub1"})
+		verifier, err := GenerateCodeVerifier()
+		assert.Empty(t, err)
+		assert.GreaterOrEqual(t, len(verifier), 43)
+
+		idToken, err := login(t, p, verifier, "nonce-1")
+		assert.Empty(t, err)
+		claims, err := p.VerifyIdToken(idToken, "nonce-1")
+		assert.Empty(t, err)
+		assert.Equal(t, "sub-1", claims.Subject)
+		assert.Equal(t, "sub1@example.com", claims.Email)
+		assert.True(t, claims.EmailVerified)
+		assert.Equal(t, "sub1", claims.PreferredUsername)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		verifier, _ := GenerateCodeVerifier()
+		authUrl, err := p.AuthCodeURL("state-2", "nonce-2", verifier)
+		assert.Empty(t, err)
+		code, _, err := m.Authorize(authUrl)
+		assert.Empty(t, err)
+		other, _ := GenerateCodeVerifier()
+		_, err = p.Exchange(code, other)
+		assert.NotEmpty(t, err)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		verifier, _ := GenerateCodeVerifier()
+		idToken, err := login(t, p, verifier, "nonce-3")
+		assert.Empty(t, err)
+		_, err = p.VerifyIdToken(idToken, "other-nonce")
+		assert.NotEmpty(t, err)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		assert.Empty(t, m.RotateKey("mock-key-2"))
+		verifier, _ := GenerateCodeVerifier()
+		idToken, err := login(t, p, verifier, "nonce-4")
+		assert.Empty(t, err)
+		_, err = p.VerifyIdToken(idToken, "nonce-4")
+		assert.Empty(t, err)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		pc := m.Config("mock", "http://localhost/callback", 0)
+		pc.ClientId = "other-client"
+		other := NewProvider(pc)
+		verifier, _ := GenerateCodeVerifier()
+		idToken, err := login(t, p, verifier, "nonce-5")
+		assert.Empty(t, err)
+		_, err = other.VerifyIdToken(idToken, "nonce-5")
+		assert.NotEmpty(t, err)
+	})
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/config"
+)
+
+// Window の間に Limit 回までrequestを受け付ける
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	// keyへのrequestを1回記録して受け付けられるかを返す
+	Allow(key string, rule Rule) (Result, error)
+	// 記録せずに受け付けられるかを返す
+	Peek(key string, rule Rule) (Result, error)
+	Reset(key string) error
+}
+
+// controllersから使うlimiter
+// 複数のserverで共有する場合はこのinterfaceを満たす実装に差し替える
+var DefaultLimiter Limiter
+
+func init() {
+	DefaultLimiter = NewFromConfig()
+}
+
+func NewFromConfig() Limiter {
+	switch config.Config.RateLimitDriver {
+	case "none":
+		return NewNopLimiter()
+	default:
+		return NewMemoryLimiter()
+	}
+}
+
+func ParseRule(s string) (Rule, error) {
+	// "<回数>/<期間>" の形式 (例: 30/1m)
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 {
+		return Rule{}, fmt.Errorf("invalid rate limit rule: %s", s)
+	}
+	limit, err := strconv.Atoi(parts[0])
+	if err != nil || limit <= 0 {
+		return Rule{}, fmt.Errorf("invalid rate limit rule: %s", s)
+	}
+	window, err := time.ParseDuration(parts[1])
+	if err != nil || window <= 0 {
+		return Rule{}, fmt.Errorf("invalid rate limit rule: %s", s)
+	}
+	return Rule{Limit: limit, Window: window}, nil
+}
+
+func ParseRules(s string) ([]Rule, error) {
+	// カンマ区切りで複数のruleを指定できる
+	rules := make([]Rule, 0)
+	for _, r := range strings.Split(s, ",") {
+		rule, err := ParseRule(r)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRule(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	rule, err := ParseRule("30/1m")
+	assert.Empty(t, err)
+	assert.Equal(t, Rule{Limit: 30, Window: time.Minute}, rule)
+
+	rules, err := ParseRules("5/15m, 10/1h")
+	assert.Empty(t, err)
+	assert.Equal(t, []Rule{{Limit: 5, Window: 15 * time.Minute}, {Limit: 10, Window: time.Hour}}, rules)
+
+	for _, s := range []string{"", "30", "0/1m", "a/1m", "30/x", "30/-1m"} {
+		_, err := ParseRule(s)
+		assert.NotEmpty(t, err)
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 上限まで受け付け、超えた場合は再試行までの時間を返す
+	// 2 期間が過ぎると再び受け付ける
+	// 3 Peekは記録しない
+	// 4 Resetすると再び受け付ける
+
+	t.Run("1", func(t *testing.T) {
+		ml := NewMemoryLimiter()
+		rule := Rule{Limit: 3, Window: time.Minute}
+		for i := 0; i < 3; i++ {
+			res, err := ml.Allow("key", rule)
+			assert.Empty(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 2-i, res.Remaining)
+		}
+		res, err := ml.Allow("key", rule)
+		assert.Empty(t, err)
+		assert.False(t, res.Allowed)
+		assert.True(t, res.RetryAfter > 59*time.Second && res.RetryAfter <= time.Minute)
+
+		res, err = ml.Allow("other", rule)
+		assert.Empty(t, err)
+		assert.True(t, res.Allowed)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		ml := NewMemoryLimiter()
+		rule := Rule{Limit: 1, Window: 50 * time.Millisecond}
+		res, _ := ml.Allow("key", rule)
+		assert.True(t, res.Allowed)
+		res, _ = ml.Allow("key", rule)
+		assert.False(t, res.Allowed)
+		time.Sleep(60 * time.Millisecond)
+		res, _ = ml.Allow("key", rule)
+		assert.True(t, res.Allowed)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		ml := NewMemoryLimiter()
+		rule := Rule{Limit: 1, Window: time.Minute}
+		for i := 0; i < 3; i++ {
+			res, _ := ml.Peek("key", rule)
+			assert.True(t, res.Allowed)
+		}
+		ml.Allow("key", rule)
+		res, _ := ml.Peek("key", rule)
+		assert.False(t, res.Allowed)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		ml := NewMemoryLimiter()
+		rule := Rule{Limit: 1, Window: time.Minute}
+		ml.Allow("key", rule)
+		assert.Empty(t, ml.Reset("key"))
+		res, _ := ml.Allow("key", rule)
+		assert.True(t, res.Allowed)
+	})
+}
+
+func TestLockout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 失敗が上限に達するとロックされ、失敗が増えるとロックが長くなる
+	// 2 Resetするとロックが解除される
+
+	defaultLimiter := DefaultLimiter
+	DefaultLimiter = NewMemoryLimiter()
+	defer func() { DefaultLimiter = defaultLimiter }()
+
+	l := Lockout{Name: "test", Rules: []Rule{{Limit: 2, Window: time.Minute}, {Limit: 3, Window: time.Hour}}}
+
+	t.Run("1", func(t *testing.T) {
+		retryAfter, err := l.Check("user")
+		assert.Empty(t, err)
+		assert.Equal(t, time.Duration(0), retryAfter)
+
+		assert.Empty(t, l.Fail("user"))
+		assert.Empty(t, l.Fail("user"))
+		retryAfter, _ = l.Check("user")
+		assert.True(t, retryAfter > 0 && retryAfter <= time.Minute)
+
+		assert.Empty(t, l.Fail("user"))
+		retryAfter, _ = l.Check("user")
+		assert.True(t, retryAfter > time.Minute && retryAfter <= time.Hour)
+
+		retryAfter, _ = l.Check("other")
+		assert.Equal(t, time.Duration(0), retryAfter)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		assert.Empty(t, l.Reset("user"))
+		retryAfter, _ := l.Check("user")
+		assert.Equal(t, time.Duration(0), retryAfter)
+	})
+}
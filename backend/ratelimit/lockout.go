@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+)
+
+// 失敗が続いた場合に一定時間ロックする
+// 複数のruleを指定すると失敗が増えるほどロックされる期間が長くなる
+type Lockout struct {
+	Name  string
+	Rules []Rule
+}
+
+func (l Lockout) key(i int, id string) string {
+	return fmt.Sprintf("lockout:%s:%d:%s", l.Name, i, id)
+}
+
+func (l Lockout) Check(id string) (time.Duration, error) {
+	// ロックされている場合は解除されるまでの時間を返す
+	var retryAfter time.Duration
+	for i, rule := range l.Rules {
+		res, err := DefaultLimiter.Peek(l.key(i, id), rule)
+		if err != nil {
+			return 0, err
+		}
+		if !res.Allowed && res.RetryAfter > retryAfter {
+			retryAfter = res.RetryAfter
+		}
+	}
+	return retryAfter, nil
+}
+
+func (l Lockout) Fail(id string) error {
+	for i, rule := range l.Rules {
+		if _, err := DefaultLimiter.Allow(l.key(i, id), rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l Lockout) Reset(id string) error {
+	for i := range l.Rules {
+		if err := DefaultLimiter.Reset(l.key(i, id)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// 使われなくなったkeyを削除する間隔
+const memorySweepInterval = time.Minute
+
+// requestの時刻をmemoryに保存するsliding window方式のlimiter
+// 1つのserverでのみ有効
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastSweep time.Time
+}
+
+type window struct {
+	times  []time.Time
+	length time.Duration
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{windows: make(map[string]*window), lastSweep: time.Now()}
+}
+
+func (w *window) prune(now time.Time) {
+	i := 0
+	for i < len(w.times) && !now.Before(w.times[i].Add(w.length)) {
+		i++
+	}
+	w.times = w.times[i:]
+}
+
+func (w *window) result(now time.Time, rule Rule) Result {
+	if len(w.times) < rule.Limit {
+		return Result{Allowed: true, Remaining: rule.Limit - len(w.times)}
+	}
+	// 上限を超えている場合は古い記録が期間外になるまで待つ
+	oldest := w.times[len(w.times)-rule.Limit]
+	return Result{Allowed: false, RetryAfter: oldest.Add(rule.Window).Sub(now)}
+}
+
+func (ml *MemoryLimiter) get(key string, rule Rule, now time.Time) *window {
+	if now.Sub(ml.lastSweep) > memorySweepInterval {
+		ml.sweep(now)
+	}
+	w, ok := ml.windows[key]
+	if !ok {
+		w = &window{times: make([]time.Time, 0, rule.Limit)}
+		ml.windows[key] = w
+	}
+	w.length = rule.Window
+	w.prune(now)
+	return w
+}
+
+func (ml *MemoryLimiter) sweep(now time.Time) {
+	for key, w := range ml.windows {
+		w.prune(now)
+		if len(w.times) == 0 {
+			delete(ml.windows, key)
+		}
+	}
+	ml.lastSweep = now
+}
+
+func (ml *MemoryLimiter) Allow(key string, rule Rule) (Result, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	now := time.Now()
+	w := ml.get(key, rule, now)
+	res := w.result(now, rule)
+	if res.Allowed {
+		w.times = append(w.times, now)
+		res.Remaining--
+	}
+	return res, nil
+}
+
+func (ml *MemoryLimiter) Peek(key string, rule Rule) (Result, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	now := time.Now()
+	return ml.get(key, rule, now).result(now, rule), nil
+}
+
+func (ml *MemoryLimiter) Reset(key string) error {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	delete(ml.windows, key)
+	return nil
+}
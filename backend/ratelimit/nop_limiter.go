@@ -0,0 +1,21 @@
+package ratelimit
+
+// すべてのrequestを受け付けるlimiter
+// gatewayなど別の場所で制限する場合に使う
+type NopLimiter struct{}
+
+func NewNopLimiter() *NopLimiter {
+	return &NopLimiter{}
+}
+
+func (nl *NopLimiter) Allow(key string, rule Rule) (Result, error) {
+	return Result{Allowed: true, Remaining: rule.Limit}, nil
+}
+
+func (nl *NopLimiter) Peek(key string, rule Rule) (Result, error) {
+	return Result{Allowed: true, Remaining: rule.Limit}, nil
+}
+
+func (nl *NopLimiter) Reset(key string) error {
+	return nil
+}
@@ -0,0 +1,45 @@
+package token
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"backend/utils"
+)
+
+// API tokenは "<prefix><token_id>.<random string>" の形式
+// login時のjwtTokenとはprefixで区別する
+const (
+	PersonalTokenPrefix = "pat_"
+	BotTokenPrefix      = "bot_"
+)
+
+func GenerateApiToken(prefix, tokenId string) (string, error) {
+	secret, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+	return prefix + tokenId + "." + secret, nil
+}
+
+func IsApiToken(tokenString string) bool {
+	return strings.HasPrefix(tokenString, PersonalTokenPrefix) || strings.HasPrefix(tokenString, BotTokenPrefix)
+}
+
+func HashApiToken(apiToken string) string {
+	return utils.HashToken(apiToken)
+}
+
+func GetTokenIdFromApiToken(apiToken string) (string, error) {
+	s := strings.TrimPrefix(strings.TrimPrefix(apiToken, PersonalTokenPrefix), BotTokenPrefix)
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid api token")
+	}
+	return parts[0], nil
+}
+
+func CompareApiToken(hash, apiToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashApiToken(apiToken))) == 1
+}
@@ -0,0 +1,52 @@
+package token
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// 2段階認証が有効なuserのloginでpasswordの確認後に発行する短期間のtoken
+// このtokenではAPIにアクセスできない
+
+const (
+	twoFactorPurpose           = "two_factor"
+	twoFactorChallengeLifespan = 5 * time.Minute
+)
+
+type ChallengeClaims struct {
+	UserId     uint32
+	DeviceName string
+}
+
+func GenerateTwoFactorChallengeToken(userId uint32, deviceName string) (string, error) {
+	claims := jwt.MapClaims{}
+	claims["purpose"] = twoFactorPurpose
+	claims["user_id"] = userId
+	claims["device_name"] = deviceName
+	claims["exp"] = time.Now().Add(twoFactorChallengeLifespan).Unix()
+
+	return DefaultKeyring.Sign(claims)
+}
+
+func ParseTwoFactorChallengeToken(tokenString string) (ChallengeClaims, error) {
+	jwtToken, err := DefaultKeyring.Parse(tokenString)
+	if err != nil {
+		return ChallengeClaims{}, err
+	}
+	claims, ok := jwtToken.Claims.(jwt.MapClaims)
+	if !ok || !jwtToken.Valid {
+		return ChallengeClaims{}, fmt.Errorf("error in ParseTwoFactorChallengeToken func")
+	}
+	if purpose, _ := claims["purpose"].(string); purpose != twoFactorPurpose {
+		return ChallengeClaims{}, fmt.Errorf("not a two factor challenge token")
+	}
+	uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["user_id"]), 10, 32)
+	if err != nil {
+		return ChallengeClaims{}, err
+	}
+	deviceName, _ := claims["device_name"].(string)
+	return ChallengeClaims{UserId: uint32(uid), DeviceName: deviceName}, nil
+}
@@ -0,0 +1,241 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"backend/config"
+)
+
+// secretKeyだけが設定されている場合の鍵のid
+// kidを持たない以前のtokenもこの鍵で検証する
+const defaultKeyId = "default"
+
+type Key struct {
+	Id        string
+	Method    jwt.SigningMethod
+	RetiredAt time.Time
+
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{Id: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+func NewRSAKey(id string, priv *rsa.PrivateKey) *Key {
+	return &Key{Id: id, Method: jwt.SigningMethodRS256, signKey: priv, verifyKey: &priv.PublicKey}
+}
+
+func NewEd25519Key(id string, priv ed25519.PrivateKey) *Key {
+	return &Key{Id: id, Method: SigningMethodEdDSA, signKey: priv, verifyKey: priv.Public()}
+}
+
+func (k *Key) IsAsymmetric() bool {
+	return k.Method != jwt.SigningMethodHS256
+}
+
+// 署名に使う鍵は1つで、それ以外の鍵は検証にのみ使う
+type Keyring struct {
+	mu           sync.RWMutex
+	keys         map[string]*Key
+	signingKeyId string
+	gracePeriod  time.Duration
+}
+
+var DefaultKeyring *Keyring
+
+func init() {
+	kr, err := loadKeyring()
+	if err != nil {
+		fmt.Printf("Failed to load jwt keys: %v", err)
+		os.Exit(1)
+	}
+	DefaultKeyring = kr
+}
+
+func NewKeyring(gracePeriod time.Duration) *Keyring {
+	return &Keyring{keys: make(map[string]*Key), gracePeriod: gracePeriod}
+}
+
+func (kr *Keyring) AddKey(k *Key) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	kr.keys[k.Id] = k
+	if kr.signingKeyId == "" && k.RetiredAt.IsZero() {
+		kr.signingKeyId = k.Id
+	}
+}
+
+func (kr *Keyring) SetSigningKey(id string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	k, ok := kr.keys[id]
+	if !ok {
+		return fmt.Errorf("jwt key %s not found", id)
+	}
+	if !k.RetiredAt.IsZero() {
+		return fmt.Errorf("jwt key %s is retired", id)
+	}
+	kr.signingKeyId = id
+	return nil
+}
+
+func (kr *Keyring) Rotate(k *Key) {
+	// 新しい鍵で署名し、以前の鍵は猶予期間の間だけ検証に使う
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if prev, ok := kr.keys[kr.signingKeyId]; ok {
+		prev.RetiredAt = time.Now()
+	}
+	kr.keys[k.Id] = k
+	kr.signingKeyId = k.Id
+}
+
+func (kr *Keyring) SigningKey() (*Key, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	k, ok := kr.keys[kr.signingKeyId]
+	if !ok {
+		return nil, fmt.Errorf("signing key not found")
+	}
+	return k, nil
+}
+
+func (kr *Keyring) isUsable(k *Key) bool {
+	return k.RetiredAt.IsZero() || time.Now().Before(k.RetiredAt.Add(kr.gracePeriod))
+}
+
+func (kr *Keyring) VerificationKey(id string) (*Key, error) {
+	if id == "" {
+		id = defaultKeyId
+	}
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	k, ok := kr.keys[id]
+	if !ok || !kr.isUsable(k) {
+		return nil, fmt.Errorf("unknown or expired key id")
+	}
+	return k, nil
+}
+
+func (kr *Keyring) Sign(claims jwt.MapClaims) (string, error) {
+	k, err := kr.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	t := jwt.NewWithClaims(k.Method, claims)
+	t.Header["kid"] = k.Id
+	return t.SignedString(k.signKey)
+}
+
+func (kr *Keyring) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		k, err := kr.VerificationKey(kid)
+		if err != nil {
+			return nil, err
+		}
+		// 鍵に設定されたalgorithm以外は受け付けない
+		if t.Method.Alg() != k.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return k.verifyKey, nil
+	})
+}
+
+func (kr *Keyring) PublicKeys() []*Key {
+	// JWKSで公開する非対称鍵を返す
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	res := make([]*Key, 0)
+	for _, k := range kr.keys {
+		if k.IsAsymmetric() && kr.isUsable(k) {
+			res = append(res, k)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Id < res[j].Id })
+	return res
+}
+
+func loadKeyring() (*Keyring, error) {
+	kr := NewKeyring(time.Hour * time.Duration(config.Config.KeyGracePeriodHour))
+	if config.Config.SecretKey != "" {
+		k := NewHMACKey(defaultKeyId, []byte(config.Config.SecretKey))
+		k.RetiredAt = config.Config.SecretKeyRetiredAt
+		kr.AddKey(k)
+	}
+	for _, kc := range config.Config.JwtKeys {
+		k, err := loadKey(kc)
+		if err != nil {
+			return nil, err
+		}
+		kr.AddKey(k)
+	}
+	if config.Config.SigningKeyId != "" {
+		if err := kr.SetSigningKey(config.Config.SigningKeyId); err != nil {
+			return nil, err
+		}
+	}
+	return kr, nil
+}
+
+func loadKey(kc config.JwtKeyConfig) (*Key, error) {
+	var k *Key
+	switch kc.Algorithm {
+	case "HS256":
+		if kc.Secret == "" {
+			return nil, fmt.Errorf("secret not found for jwt key %s", kc.Id)
+		}
+		k = NewHMACKey(kc.Id, []byte(kc.Secret))
+	case "RS256", "EdDSA":
+		priv, err := loadPrivateKey(kc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		switch p := priv.(type) {
+		case *rsa.PrivateKey:
+			if kc.Algorithm != "RS256" {
+				return nil, fmt.Errorf("jwt key %s is not an ed25519 key", kc.Id)
+			}
+			k = NewRSAKey(kc.Id, p)
+		case ed25519.PrivateKey:
+			if kc.Algorithm != "EdDSA" {
+				return nil, fmt.Errorf("jwt key %s is not an rsa key", kc.Id)
+			}
+			k = NewEd25519Key(kc.Id, p)
+		default:
+			return nil, fmt.Errorf("unsupported private key type for jwt key %s", kc.Id)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s for jwt key %s", kc.Algorithm, kc.Id)
+	}
+	k.RetiredAt = kc.RetiredAt
+	return k, nil
+}
+
+func loadPrivateKey(path string) (interface{}, error) {
+	// PKCS#8またはPKCS#1(RSAのみ)のPEMを読み込む
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("invalid pem file: %s", path)
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
@@ -0,0 +1,167 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+
+	"backend/config"
+)
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{"user_id": 1, "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+func TestKeyring(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 kidを持たない以前のtokenはdefaultの鍵で検証する
+	// 2 rotateした後も猶予期間の間は以前の鍵のtokenを検証できる
+	// 3 猶予期間を過ぎた鍵のtokenは検証できない
+	// 4 RS256とEdDSAで署名したtokenを検証でき、公開鍵のみJWKSに含まれる
+	// 5 鍵と異なるalgorithmや未知のkidのtokenは受け付けない
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Empty(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Empty(t, err)
+
+	t.Run("1", func(t *testing.T) {
+		kr := NewKeyring(time.Hour)
+		kr.AddKey(NewHMACKey(defaultKeyId, []byte("secret")))
+		legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString([]byte("secret"))
+		assert.Empty(t, err)
+		_, err = kr.Parse(legacy)
+		assert.Empty(t, err)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		kr := NewKeyring(time.Hour)
+		kr.AddKey(NewHMACKey("old", []byte("old-secret")))
+		oldToken, err := kr.Sign(testClaims())
+		assert.Empty(t, err)
+
+		kr.Rotate(NewHMACKey("new", []byte("new-secret")))
+		newToken, err := kr.Sign(testClaims())
+		assert.Empty(t, err)
+		k, err := kr.SigningKey()
+		assert.Empty(t, err)
+		assert.Equal(t, "new", k.Id)
+
+		_, err = kr.Parse(oldToken)
+		assert.Empty(t, err)
+		_, err = kr.Parse(newToken)
+		assert.Empty(t, err)
+		assert.NotEmpty(t, kr.SetSigningKey("old"))
+	})
+
+	t.Run("3", func(t *testing.T) {
+		kr := NewKeyring(time.Hour)
+		kr.AddKey(NewHMACKey("old", []byte("old-secret")))
+		oldToken, err := kr.Sign(testClaims())
+		assert.Empty(t, err)
+		kr.Rotate(NewHMACKey("new", []byte("new-secret")))
+
+		k, err := kr.VerificationKey("old")
+		assert.Empty(t, err)
+		k.RetiredAt = time.Now().Add(-2 * time.Hour)
+		_, err = kr.Parse(oldToken)
+		assert.NotEmpty(t, err)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		kr := NewKeyring(time.Hour)
+		kr.AddKey(NewHMACKey(defaultKeyId, []byte("secret")))
+		kr.AddKey(NewRSAKey("rsa", rsaKey))
+		kr.AddKey(NewEd25519Key("ed", edKey))
+
+		for _, id := range []string{"rsa", "ed"} {
+			assert.Empty(t, kr.SetSigningKey(id))
+			s, err := kr.Sign(testClaims())
+			assert.Empty(t, err)
+			parsed, err := kr.Parse(s)
+			assert.Empty(t, err)
+			assert.Equal(t, id, parsed.Header["kid"])
+		}
+
+		jwks := kr.JWKS()
+		assert.Equal(t, 2, len(jwks.Keys))
+		assert.Equal(t, "EdDSA", jwks.Keys[0].Alg)
+		assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+		assert.Equal(t, "RS256", jwks.Keys[1].Alg)
+		assert.Equal(t, "RSA", jwks.Keys[1].Kty)
+	})
+
+	t.Run("5", func(t *testing.T) {
+		kr := NewKeyring(time.Hour)
+		kr.AddKey(NewRSAKey("rsa", rsaKey))
+
+		// 公開鍵をHMACのsecretとして使う攻撃
+		tk := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+		tk.Header["kid"] = "rsa"
+		forged, err := tk.SignedString(x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))
+		assert.Empty(t, err)
+		_, err = kr.Parse(forged)
+		assert.NotEmpty(t, err)
+
+		tk = jwt.NewWithClaims(jwt.SigningMethodRS256, testClaims())
+		tk.Header["kid"] = "unknown"
+		unknown, err := tk.SignedString(rsaKey)
+		assert.Empty(t, err)
+		_, err = kr.Parse(unknown)
+		assert.NotEmpty(t, err)
+	})
+}
+
+func TestLoadKey(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	// 1 PKCS#1のRSA鍵を読み込める
+	// 2 PKCS#8のEd25519鍵を読み込める
+	// 3 algorithmと鍵の種類が一致しない場合はerror
+
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Empty(t, err)
+	rsaPath := filepath.Join(dir, "rsa.pem")
+	assert.Empty(t, os.WriteFile(rsaPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}), 0600))
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.Empty(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	assert.Empty(t, err)
+	edPath := filepath.Join(dir, "ed.pem")
+	assert.Empty(t, os.WriteFile(edPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	t.Run("1", func(t *testing.T) {
+		k, err := loadKey(config.JwtKeyConfig{Id: "rsa", Algorithm: "RS256", PrivateKeyFile: rsaPath})
+		assert.Empty(t, err)
+		assert.Equal(t, "RS256", k.Method.Alg())
+	})
+
+	t.Run("2", func(t *testing.T) {
+		k, err := loadKey(config.JwtKeyConfig{Id: "ed", Algorithm: "EdDSA", PrivateKeyFile: edPath})
+		assert.Empty(t, err)
+		assert.Equal(t, "EdDSA", k.Method.Alg())
+	})
+
+	t.Run("3", func(t *testing.T) {
+		_, err := loadKey(config.JwtKeyConfig{Id: "rsa", Algorithm: "EdDSA", PrivateKeyFile: rsaPath})
+		assert.NotEmpty(t, err)
+		_, err = loadKey(config.JwtKeyConfig{Id: "hs", Algorithm: "HS256"})
+		assert.NotEmpty(t, err)
+	})
+}
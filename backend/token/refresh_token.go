@@ -0,0 +1,42 @@
+package token
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/config"
+	"backend/utils"
+)
+
+// refresh tokenは "<session_id>.<random string>" の形式
+// DBにはhash値のみを保存する
+
+func GenerateRefreshToken(sessionId string) (string, error) {
+	secret, err := utils.GenerateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+	return sessionId + "." + secret, nil
+}
+
+func HashRefreshToken(refreshToken string) string {
+	return utils.HashToken(refreshToken)
+}
+
+func CompareRefreshToken(hash, refreshToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashRefreshToken(refreshToken))) == 1
+}
+
+func GetSessionIdFromRefreshToken(refreshToken string) (string, error) {
+	s := strings.Split(refreshToken, ".")
+	if len(s) != 2 || s[0] == "" || s[1] == "" {
+		return "", fmt.Errorf("invalid refresh token")
+	}
+	return s[0], nil
+}
+
+func RefreshTokenExpiresAt() time.Time {
+	return time.Now().Add(time.Hour * time.Duration(config.Config.RefreshTokenHourLifeSpan))
+}
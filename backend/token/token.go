@@ -12,43 +12,54 @@ import (
 	"backend/config"
 )
 
-func GenerateToken(userId uint32) (string, error) {
-	token_lifespan := 2
+type Claims struct {
+	UserId    uint32
+	SessionId string
+}
+
+func GenerateToken(userId uint32, sessionId string) (string, error) {
+	token_lifespan := config.Config.TokenHourLifeSpan
 	claims := jwt.MapClaims{}
 	claims["authorized"] = true
 	claims["user_id"] = userId
+	claims["session_id"] = sessionId
 	claims["exp"] = time.Now().Add(time.Hour * time.Duration(token_lifespan)).Unix()
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	return token.SignedString([]byte(config.Config.SecretKey))
+	return DefaultKeyring.Sign(claims)
 }
 
 func GetTokenFromContext(c *gin.Context) string {
-	token := c.Request.Header.Get("Authorization")
+	token := strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
 	if len(strings.Split(token, "\"")) == 3 {
 		return strings.Split(token, "\"")[1]
 	}
 	return token
 }
 
-func GetUserIdFromToken(tokenString string) (uint32, error) {
-	jwtToken, err := jwt.Parse(tokenString, func(jwtToken *jwt.Token) (interface{}, error) {
-		if _, ok := jwtToken.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("err")
-		}
-		return []byte(config.Config.SecretKey), nil
-	})
+func ParseToken(tokenString string) (Claims, error) {
+	jwtToken, err := DefaultKeyring.Parse(tokenString)
 	if err != nil {
-		return 0, err
+		return Claims{}, err
 	}
 	claims, ok := jwtToken.Claims.(jwt.MapClaims)
-	if ok && jwtToken.Valid {
-		uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["user_id"]), 10, 32)
-		if err != nil {
-			return 0, err
-		}
-		return uint32(uint(uid)), nil
+	if !ok || !jwtToken.Valid {
+		return Claims{}, fmt.Errorf("error in ParseToken func")
+	}
+	uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["user_id"]), 10, 32)
+	if err != nil {
+		return Claims{}, err
+	}
+	sessionId, ok := claims["session_id"].(string)
+	if !ok || sessionId == "" {
+		return Claims{}, fmt.Errorf("session_id not found in token")
+	}
+	return Claims{UserId: uint32(uid), SessionId: sessionId}, nil
+}
+
+func GetUserIdFromToken(tokenString string) (uint32, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return 0, err
 	}
-	return 0, fmt.Errorf("error in GetUserIdFromToken func")
+	return claims.UserId, nil
 }
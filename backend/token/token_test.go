@@ -13,7 +13,7 @@ func TestGetUserIdFromToken(t *testing.T) {
 	}
 	for i := 0; i < 1000; i++ {
 		userId := rand.Uint32()
-		jwtToken, _ := GenerateToken(userId)
+		jwtToken, _ := GenerateToken(userId, "session")
 		returnUserId, err := GetUserIdFromToken(jwtToken)
 		assert.Empty(t, err)
 		assert.Equal(t, returnUserId, userId)
@@ -25,8 +25,99 @@ func TestGenerateJWTToken(t *testing.T) {
 		t.Skip("skipping test in short mode.")
 	}
 	for i := 0; i < 1000; i++ {
-		token, err := GenerateToken(rand.Uint32())
+		token, err := GenerateToken(rand.Uint32(), "session")
 		assert.Empty(t, err)
 		assert.NotEqual(t, "", token)
 	}
 }
+
+func TestParseToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	userId := rand.Uint32()
+	jwtToken, err := GenerateToken(userId, "session")
+	assert.Empty(t, err)
+	claims, err := ParseToken(jwtToken)
+	assert.Empty(t, err)
+	assert.Equal(t, userId, claims.UserId)
+	assert.Equal(t, "session", claims.SessionId)
+
+	// session_idが含まれないtokenはerror
+	jwtToken, err = GenerateToken(userId, "")
+	assert.Empty(t, err)
+	_, err = ParseToken(jwtToken)
+	assert.NotEmpty(t, err)
+
+	_, err = ParseToken("wrong token")
+	assert.NotEmpty(t, err)
+}
+
+func TestRefreshToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	refreshToken, err := GenerateRefreshToken("session")
+	assert.Empty(t, err)
+	sessionId, err := GetSessionIdFromRefreshToken(refreshToken)
+	assert.Empty(t, err)
+	assert.Equal(t, "session", sessionId)
+
+	hash := HashRefreshToken(refreshToken)
+	assert.NotEqual(t, refreshToken, hash)
+	assert.True(t, CompareRefreshToken(hash, refreshToken))
+
+	refreshToken2, err := GenerateRefreshToken("session")
+	assert.Empty(t, err)
+	assert.NotEqual(t, refreshToken, refreshToken2)
+	assert.False(t, CompareRefreshToken(hash, refreshToken2))
+
+	_, err = GetSessionIdFromRefreshToken("wrong token")
+	assert.NotEmpty(t, err)
+}
+
+func TestTwoFactorChallengeToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	userId := rand.Uint32()
+	challenge, err := GenerateTwoFactorChallengeToken(userId, "laptop")
+	assert.Empty(t, err)
+
+	claims, err := ParseTwoFactorChallengeToken(challenge)
+	assert.Empty(t, err)
+	assert.Equal(t, userId, claims.UserId)
+	assert.Equal(t, "laptop", claims.DeviceName)
+
+	// challenge tokenはAPIのtokenとして使えない
+	_, err = ParseToken(challenge)
+	assert.NotEmpty(t, err)
+
+	// APIのtokenはchallenge tokenとして使えない
+	jwtToken, _ := GenerateToken(userId, "session")
+	_, err = ParseTwoFactorChallengeToken(jwtToken)
+	assert.NotEmpty(t, err)
+}
+
+func TestApiToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	for _, prefix := range []string{PersonalTokenPrefix, BotTokenPrefix} {
+		apiToken, err := GenerateApiToken(prefix, "tokenid")
+		assert.Empty(t, err)
+		assert.True(t, IsApiToken(apiToken))
+
+		tokenId, err := GetTokenIdFromApiToken(apiToken)
+		assert.Empty(t, err)
+		assert.Equal(t, "tokenid", tokenId)
+		assert.True(t, CompareApiToken(HashApiToken(apiToken), apiToken))
+		assert.False(t, CompareApiToken(HashApiToken(apiToken), apiToken+"x"))
+	}
+
+	// jwtTokenはAPI tokenとして扱わない
+	jwtToken, _ := GenerateToken(rand.Uint32(), "session")
+	assert.False(t, IsApiToken(jwtToken))
+	_, err := GetTokenIdFromApiToken(PersonalTokenPrefix + "wrong")
+	assert.NotEmpty(t, err)
+}
@@ -0,0 +1,38 @@
+package totp
+
+import (
+	"strings"
+
+	"backend/utils"
+)
+
+const (
+	RecoveryCodeCount = 10
+	// base32と同じ32文字を使うので偏りなく選べる
+	recoveryCodeAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+)
+
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b, err := utils.GenerateRandomBytes(10)
+		if err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		for j, c := range b {
+			if j == 5 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(recoveryCodeAlphabet[c&31])
+		}
+		codes[i] = sb.String()
+	}
+	return codes, nil
+}
+
+func HashRecoveryCode(code string) string {
+	// 入力の揺れを吸収してからhash化する
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	return utils.HashToken(normalized)
+}
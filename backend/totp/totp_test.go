@@ -0,0 +1,104 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateCode(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	// RFC 6238 Appendix B のSHA1のtest vector(下6桁)
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range vectors {
+		code, err := GenerateCode(secret, Step(time.Unix(v.unix, 0)))
+		assert.Empty(t, err)
+		assert.Equal(t, v.code, code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	secret, err := GenerateSecret()
+	assert.Empty(t, err)
+	now := time.Now()
+
+	// 1 現在のcode
+	// 2 前後1stepのずれは許容する
+	// 3 2step以上ずれたcode
+	// 4 既に使ったstepのcode
+
+	t.Run("1", func(t *testing.T) {
+		code, _ := GenerateCode(secret, Step(now))
+		step, ok := Validate(secret, code, now, 0)
+		assert.True(t, ok)
+		assert.Equal(t, Step(now), step)
+	})
+
+	t.Run("2", func(t *testing.T) {
+		code, _ := GenerateCode(secret, Step(now)-1)
+		_, ok := Validate(secret, code, now, 0)
+		assert.True(t, ok)
+		code, _ = GenerateCode(secret, Step(now)+1)
+		_, ok = Validate(secret, code, now, 0)
+		assert.True(t, ok)
+	})
+
+	t.Run("3", func(t *testing.T) {
+		code, _ := GenerateCode(secret, Step(now)-5)
+		_, ok := Validate(secret, code, now, 0)
+		assert.False(t, ok)
+		_, ok = Validate(secret, "abc", now, 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("4", func(t *testing.T) {
+		code, _ := GenerateCode(secret, Step(now))
+		_, ok := Validate(secret, code, now, Step(now))
+		assert.False(t, ok)
+	})
+}
+
+func TestProvisioningURI(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	uri := ProvisioningURI("SECRET", "alice")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/SlackCloneApp:alice?"))
+	assert.Contains(t, uri, "secret=SECRET")
+	assert.Contains(t, uri, "issuer=SlackCloneApp")
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	codes, err := GenerateRecoveryCodes()
+	assert.Empty(t, err)
+	assert.Equal(t, RecoveryCodeCount, len(codes))
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Equal(t, 11, len(code))
+		assert.False(t, seen[code])
+		seen[code] = true
+		assert.Equal(t, HashRecoveryCode(code), HashRecoveryCode(" "+strings.ToUpper(code)+" "))
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"crypto/subtle"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func IsHashedPassword(s string) bool {
+	_, err := bcrypt.Cost([]byte(s))
+	return err == nil
+}
+
+func CheckPassword(storedPassword, password string) bool {
+	// 以前のバージョンで平文のまま保存されたpasswordとも比較できるようにする
+	if !IsHashedPassword(storedPassword) {
+		return subtle.ConstantTimeCompare([]byte(storedPassword), []byte(password)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(storedPassword), []byte(password)) == nil
+}